@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonrpc2Path is the import path of the jsonrpc2 package, used by the generated code.
+const jsonrpc2Path = "simpleRpc/jsonrpc2"
+
+// method is a method of the interface, as the generated client calls it.
+type method struct {
+	Name   string
+	Ctx    string // type of the context.Context taken first, "" if none
+	Arg    string // type of the arg
+	Result string // type of the result
+}
+
+// generate the Go source of the client of the interface typeName, declared
+// in src (read from filename), calling the methods named "service.Method".
+func generate(filename string, src []byte, typeName, service string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	iface, err := findInterface(file, typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	imports := fileImports(file)
+	used := make(map[string]bool) // names of the imports used by the methods
+	expr := func(e ast.Expr) string {
+		ast.Inspect(e, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok {
+					used[x.Name] = true
+				}
+			}
+			return true
+		})
+		var buf bytes.Buffer
+		_ = format.Node(&buf, fset, e)
+		return buf.String()
+	}
+	isContext := func(e ast.Expr) bool {
+		sel, ok := e.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Context" {
+			return false
+		}
+		x, ok := sel.X.(*ast.Ident)
+		return ok && imports[x.Name] == "context"
+	}
+
+	var methods []method
+	for _, field := range iface.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) != 1 {
+			return nil, fmt.Errorf("%s: embedded interfaces are not supported", fset.Position(field.Pos()))
+		}
+		name := field.Names[0].Name
+		params, results := fieldTypes(ft.Params), fieldTypes(ft.Results)
+
+		m := method{Name: name}
+		if len(params) > 0 && isContext(params[0]) {
+			m.Ctx = expr(params[0])
+			params = params[1:]
+		}
+		if len(params) != 1 || len(results) != 2 || expr(results[1]) != "error" {
+			return nil, fmt.Errorf("%s: method %s must be like func([ctx context.Context,] arg T) (R, error)",
+				fset.Position(field.Pos()), name)
+		}
+		m.Arg, m.Result = expr(params[0]), expr(results[0])
+		methods = append(methods, m)
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("interface %s has no methods", typeName)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by naiverpc-gen -type %s", typeName)
+	if service != "" {
+		fmt.Fprintf(&buf, " -service %s", service)
+	}
+	fmt.Fprintf(&buf, "; DO NOT EDIT.\n\npackage %s\n\nimport (\n", file.Name.Name)
+	for _, spec := range usedImports(file, used) {
+		fmt.Fprintf(&buf, "\t%s\n", spec)
+	}
+	fmt.Fprintf(&buf, "\t%q\n)\n\n", jsonrpc2Path)
+
+	client := typeName + "Client"
+	fmt.Fprintf(&buf, "// %s is a %s calling the methods of a JSON-RPC server.\n", client, typeName)
+	fmt.Fprintf(&buf, "type %s struct {\n\tc jsonrpc2.Client\n}\n\n", client)
+	fmt.Fprintf(&buf, "var _ %s = (*%s)(nil)\n\n", typeName, client)
+	fmt.Fprintf(&buf, "// New%s returns a %s calling the server of c.\n", client, typeName)
+	fmt.Fprintf(&buf, "func New%s(c jsonrpc2.Client) *%s {\n\treturn &%s{c: c}\n}\n", client, client, client)
+
+	for _, m := range methods {
+		rpcName := m.Name
+		if service != "" {
+			rpcName = service + "." + m.Name
+		}
+		fmt.Fprintf(&buf, "\n// %s calls %q.\n", m.Name, rpcName)
+		if m.Ctx != "" {
+			fmt.Fprintf(&buf, "func (s *%s) %s(ctx %s, arg %s) (%s, error) {\n", client, m.Name, m.Ctx, m.Arg, m.Result)
+			fmt.Fprintf(&buf, "\tvar ret %s\n\terr := s.c.CallContext(ctx, %q, arg, &ret)\n", m.Result, rpcName)
+		} else {
+			fmt.Fprintf(&buf, "func (s *%s) %s(arg %s) (%s, error) {\n", client, m.Name, m.Arg, m.Result)
+			fmt.Fprintf(&buf, "\tvar ret %s\n\terr := s.c.Call(%q, arg, &ret)\n", m.Result, rpcName)
+		}
+		fmt.Fprintf(&buf, "\treturn ret, err\n}\n")
+	}
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("bad generated code: %w\n%s", err, buf.Bytes())
+	}
+	return out, nil
+}
+
+// findInterface finds the declaration of the interface typeName in file.
+func findInterface(file *ast.File, typeName string) (*ast.InterfaceType, error) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != typeName {
+				continue
+			}
+			iface, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an interface", typeName)
+			}
+			return iface, nil
+		}
+	}
+	return nil, errors.New("interface " + typeName + " not found")
+}
+
+// fieldTypes lists the types of fields, one per name: (a, b int) is [int, int].
+func fieldTypes(fields *ast.FieldList) []ast.Expr {
+	if fields == nil {
+		return nil
+	}
+	var types []ast.Expr
+	for _, field := range fields.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+// fileImports maps the names of the imports of file to their paths.
+func fileImports(file *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		imports[importName(spec, path)] = path
+	}
+	return imports
+}
+
+// usedImports returns the import specs of file whose names are used, sorted.
+func usedImports(file *ast.File, used map[string]bool) []string {
+	var specs []string
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		if path == jsonrpc2Path || !used[importName(spec, path)] {
+			continue
+		}
+		if spec.Name != nil {
+			specs = append(specs, spec.Name.Name+" "+spec.Path.Value)
+		} else {
+			specs = append(specs, spec.Path.Value)
+		}
+	}
+	sort.Strings(specs)
+	return specs
+}
+
+// importName is the name by which the import spec of path is referred to.
+// Without an explicit name, it's taken as the last element of the path.
+func importName(spec *ast.ImportSpec, path string) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
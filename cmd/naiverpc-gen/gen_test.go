@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_generate(t *testing.T) {
+	src := `package kv
+
+import (
+	"context"
+	"time"
+	unused "strings"
+)
+
+type Store interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	Expire(arg map[string]time.Duration) (int, error)
+}
+`
+	out, err := generate("kv.go", []byte(src), "Store", "kv")
+	if err != nil {
+		t.Fatalf("❌ generate: %v", err)
+	}
+	for _, want := range []string{
+		"// Code generated by naiverpc-gen -type Store -service kv; DO NOT EDIT.",
+		"package kv",
+		`"context"`,
+		`"time"`,
+		`"simpleRpc/jsonrpc2"`,
+		"var _ Store = (*StoreClient)(nil)",
+		"func NewStoreClient(c jsonrpc2.Client) *StoreClient {",
+		"func (s *StoreClient) Get(ctx context.Context, arg string) (*Entry, error) {",
+		`err := s.c.CallContext(ctx, "kv.Get", arg, &ret)`,
+		"func (s *StoreClient) Expire(arg map[string]time.Duration) (int, error) {",
+		`err := s.c.Call("kv.Expire", arg, &ret)`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("❌ generated code misses %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(string(out), "strings") {
+		t.Errorf("❌ generated code imports an unused package:\n%s", out)
+	}
+}
+
+func Test_generate_invalid(t *testing.T) {
+	tests := []struct {
+		name, decl string
+	}{
+		{"not found", "type Other interface{ M(int) (int, error) }"},
+		{"not an interface", "type Store struct{}"},
+		{"no error", "type Store interface{ M(int) int }"},
+		{"two args", "type Store interface{ M(a, b int) (int, error) }"},
+		{"embedded", "type Store interface{ Other }"},
+		{"empty", "type Store interface{}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := generate("kv.go", []byte("package kv\n\n"+tt.decl+"\n"), "Store", ""); err == nil {
+				t.Errorf("❌ want an error for %s", tt.decl)
+			}
+		})
+	}
+}
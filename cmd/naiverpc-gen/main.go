@@ -0,0 +1,72 @@
+// naiverpc-gen 从一个 Go 接口生成它的 JSON-RPC 客户端：
+// 接口的每个方法调用服务端的同名方法，调用方不必再手写方法名字符串与结果的反序列化。
+//
+// 接口的方法形如 (ctx 可选)：
+//
+//	Lock(ctx context.Context, req *LockRequest) (*LockResponse, error)
+//
+// 即服务端 Server.Register 所接受的函数。与 Server.RegisterService 配合：
+// 服务端 s.RegisterService("lock", mutex)，客户端由 -service lock 生成，
+// 方法名即为 "lock.Lock"。
+//
+// 通常由 go:generate 调用，写在接口所在的文件中：
+//
+//	//go:generate go run simpleRpc/cmd/naiverpc-gen -type Service -service lock
+//
+// 生成 service_client.go，其中有 ServiceClient 类型 (实现 Service 接口) 与 NewServiceClient：
+//
+//	s := lock.NewServiceClient(jsonrpc2.NewClient(t))
+//	resp, err := s.Lock(&lock.LockRequest{})
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeName = flag.String("type", "", "name of the interface to generate a client of (required)")
+	service  = flag.String("service", "", `prefix of the method names, as in "service.Method"; none by default`)
+	output   = flag.String("o", "", "output file; default <type>_client.go, lowercased, next to the source")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: %s -type Interface [flags] [file.go]\n\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "file.go defaults to $GOFILE, set by go generate.\n\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	file := os.Getenv("GOFILE")
+	if flag.NArg() == 1 {
+		file = flag.Arg(0)
+	}
+	if *typeName == "" || file == "" || flag.NArg() > 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	src, err := os.ReadFile(file)
+	must(err)
+	out, err := generate(file, src, *typeName, *service)
+	must(err)
+
+	name := *output
+	if name == "" {
+		name = filepath.Join(filepath.Dir(file), strings.ToLower(*typeName)+"_client.go")
+	}
+	must(os.WriteFile(name, out, 0o644))
+}
+
+func must(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "naiverpc-gen:", err)
+		os.Exit(1)
+	}
+}
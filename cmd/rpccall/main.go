@@ -0,0 +1,158 @@
+// rpccall 是一个命令行的 JSON-RPC 2.0 调用工具。
+//
+// 调用方法：
+//
+//	rpccall -url http://localhost:5680 add '[1, 2]'
+//	3
+//
+// 发送之前，rpccall 通过 rpc.describe 取得方法参数的 JSON Schema 并校验参数，
+// 参数有误时直接在本地报错，而不必发到服务端 (-validate=false 关闭)：
+//
+//	rpccall add '["1", 2]'
+//	invalid params: params[0]: want integer, got "1"
+//
+// 查看方法的说明 (服务端以 jsonrpc2.WithDoc、WithParamDoc 注册的文档，参数与结果的 Schema)，
+// 或列出所有方法及其摘要：
+//
+//	rpccall -describe add
+//	rpccall -list
+//	add                  Add returns the sum of a and b.
+//	divide (deprecated)  Divide divides a by b.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"simpleRpc/jsonrpc2"
+	"sort"
+	"text/tabwriter"
+)
+
+var (
+	url      = flag.String("url", "http://localhost:5680", "URL of the JSON-RPC server")
+	validate = flag.Bool("validate", true, "validate params against the schema from rpc.describe before sending")
+	describe = flag.Bool("describe", false, "describe the method instead of calling it")
+	list     = flag.Bool("list", false, "list the methods of the server")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] method [params]\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] -describe method\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] -list\n\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+// describeMethod gets the MethodDescriptor of method.
+func describeMethod(c jsonrpc2.Client, method string) (*jsonrpc2.MethodDescriptor, error) {
+	var desc jsonrpc2.MethodDescriptor
+	err := c.Call(jsonrpc2.MethodDescribe, &jsonrpc2.DescribeParams{Method: method}, &desc)
+	if err != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+// printDoc prints the documentation of the method desc, if any (see
+// jsonrpc2.WithDoc), and of its params.
+func printDoc(desc *jsonrpc2.MethodDescriptor) {
+	if desc.Description != "" {
+		fmt.Println(desc.Description)
+		fmt.Println()
+	}
+	params := desc.Params[0]
+	var fields []string
+	for name, s := range params.Schema.Properties {
+		if s.Description != "" {
+			fields = append(fields, name)
+		}
+	}
+	if params.Description == "" && len(fields) == 0 {
+		return
+	}
+	fmt.Println("Params:", params.Description)
+	sort.Strings(fields)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, name := range fields {
+		fmt.Fprintf(w, "  %s\t%s\n", name, params.Schema.Properties[name].Description)
+	}
+	must(w.Flush())
+	fmt.Println()
+}
+
+func printJSON(v any) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	must(err)
+	fmt.Println(string(b))
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	c := jsonrpc2.NewClient(jsonrpc2.NewHttpClientTransport(*url))
+
+	if *list {
+		var doc jsonrpc2.DiscoverResult
+		must(c.Call(jsonrpc2.MethodDiscover, json.RawMessage(`null`), &doc))
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, m := range doc.Methods {
+			deprecated := ""
+			if m.Deprecated {
+				deprecated = " (deprecated)"
+			}
+			fmt.Fprintf(w, "%s%s\t%s\n", m.Name, deprecated, m.Summary)
+		}
+		must(w.Flush())
+		return
+	}
+
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		usage()
+		os.Exit(2)
+	}
+	method := flag.Arg(0)
+
+	if *describe {
+		desc, err := describeMethod(c, method)
+		must(err)
+		printDoc(desc)
+		printJSON(desc)
+		return
+	}
+
+	params := json.RawMessage(`null`)
+	if flag.NArg() == 2 {
+		params = json.RawMessage(flag.Arg(1))
+		if !json.Valid(params) {
+			fail(errors.New("params should be JSON"))
+		}
+	}
+
+	if *validate {
+		desc, err := describeMethod(c, method)
+		if err != nil {
+			// e.g. a server without rpc.describe: let the server check
+			fmt.Fprintln(os.Stderr, "not validating params:", err)
+		} else if err := desc.Params[0].Schema.Validate(params); err != nil {
+			fail(fmt.Errorf("invalid params: %w", err))
+		}
+	}
+
+	var result json.RawMessage
+	must(c.Call(method, params, &result))
+	printJSON(result)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func must(err error) {
+	if err != nil {
+		fail(err)
+	}
+}
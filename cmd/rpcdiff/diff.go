@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"simpleRpc/jsonrpc2"
+)
+
+// differ sends the requests to the servers a and b, reporting how their
+// responses differ.
+type differ struct {
+	a, b    jsonrpc2.ClientTransport
+	timeout time.Duration // of each request, 0 means none
+	opts    diffOptions
+}
+
+// summary counts the requests diffed.
+type summary struct {
+	requests, differ, failed, skipped int
+}
+
+func (s summary) String() string {
+	return fmt.Sprintf("%d requests: %d differ, %d failed, %d skipped", s.requests, s.differ, s.failed, s.skipped)
+}
+
+// maxLine bounds the lines of the requests read.
+const maxLine = 16 << 20
+
+// run the requests of in, one per line, writing the differences to w.
+// It fails on lines not of requests; the requests the servers fail to
+// answer (e.g. one is down) are reported and counted as failed.
+func (d *differ) run(ctx context.Context, in io.Reader, w io.Writer) (summary, error) {
+	var s summary
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var req jsonrpc2.Request
+		if err := json.Unmarshal([]byte(text), &req); err != nil {
+			return s, fmt.Errorf("line %d: bad request: %w", line, err)
+		}
+		if req.Method == "" {
+			return s, fmt.Errorf("line %d: bad request: no method", line)
+		}
+		s.requests++
+		if req.IsNotification() {
+			s.skipped++
+			continue
+		}
+		if req.JsonRpc == "" {
+			req.JsonRpc = jsonrpc2.JsonRpc2
+		}
+
+		header := fmt.Sprintf("--- request %d: %s (id %s)", line, req.Method, req.Id)
+		respA, errA := d.send(ctx, d.a, &req)
+		respB, errB := d.send(ctx, d.b, &req)
+		if errA != nil || errB != nil {
+			s.failed++
+			fmt.Fprintln(w, header)
+			if errA != nil {
+				fmt.Fprintf(w, "  a failed: %v\n", errA)
+			}
+			if errB != nil {
+				fmt.Fprintf(w, "  b failed: %v\n", errB)
+			}
+			continue
+		}
+		if diffs := diffResponses(respA, respB, d.opts); len(diffs) > 0 {
+			s.differ++
+			fmt.Fprintln(w, header)
+			for _, diff := range diffs {
+				fmt.Fprintf(w, "  %s\n", diff)
+			}
+		}
+	}
+	return s, scanner.Err()
+}
+
+func (d *differ) send(ctx context.Context, t jsonrpc2.ClientTransport, req *jsonrpc2.Request) (*jsonrpc2.Response, error) {
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	r := *req
+	return t.SendAndReceive(ctx, &r)
+}
+
+// diffOptions tell what's compared of the responses.
+type diffOptions struct {
+	ignore       []string // paths of the results not compared, with their insides
+	errorDetails bool     // compare the messages and data of errors, not only the codes
+}
+
+// ignored tells whether path is, or is inside, one of the ignored paths.
+func (o diffOptions) ignored(path string) bool {
+	for _, p := range o.ignore {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// difference is a difference of the responses of a and b, at path.
+type difference struct {
+	path string
+	a, b string // the values, as JSON
+}
+
+func (d difference) String() string {
+	return fmt.Sprintf("%s: %s != %s", d.path, d.a, d.b)
+}
+
+// missing is the value of a difference where there is none.
+const missing = "(missing)"
+
+// diffResponses tells how a and b differ: their results as JSON values,
+// at the paths of the results ($ for the whole one), or their errors.
+func diffResponses(a, b *jsonrpc2.Response, opts diffOptions) []difference {
+	if a.Error != nil || b.Error != nil {
+		return diffErrors(a.Error, b.Error, opts)
+	}
+	va, errA := decode(a.Result)
+	vb, errB := decode(b.Result)
+	if errA != nil || errB != nil {
+		if string(a.Result) == string(b.Result) {
+			return nil
+		}
+		return []difference{{"$", string(a.Result), string(b.Result)}}
+	}
+	return diffJSON("$", va, vb, opts, nil)
+}
+
+func decode(result json.RawMessage) (any, error) {
+	if len(result) == 0 {
+		return nil, nil
+	}
+	var v any
+	err := json.Unmarshal(result, &v)
+	return v, err
+}
+
+func diffErrors(a, b *jsonrpc2.Error, opts diffOptions) []difference {
+	if a == nil || b == nil {
+		return []difference{{"error", errorText(a), errorText(b)}}
+	}
+	var diffs []difference
+	if a.Code != b.Code {
+		diffs = append(diffs, difference{"error.code", strconv.Itoa(a.Code), strconv.Itoa(b.Code)})
+	}
+	if opts.errorDetails {
+		if a.Message != b.Message {
+			diffs = append(diffs, difference{"error.message", strconv.Quote(a.Message), strconv.Quote(b.Message)})
+		}
+		da, _ := decode(a.Data)
+		db, _ := decode(b.Data)
+		diffs = diffJSON("error.data", da, db, diffOptions{}, diffs)
+	}
+	return diffs
+}
+
+// errorText is e as a line, missing if it's nil: e.g. the error of a
+// response where the other has a result.
+func errorText(e *jsonrpc2.Error) string {
+	if e == nil {
+		return missing
+	}
+	return fmt.Sprintf("%d %s", e.Code, strconv.Quote(e.Message))
+}
+
+// diffJSON appends the differences of the decoded JSON values a and b,
+// at path, to diffs: those of the members of objects and of the elements
+// of arrays, recursively, else of the values themselves.
+func diffJSON(path string, a, b any, opts diffOptions, diffs []difference) []difference {
+	if opts.ignored(path) {
+		return diffs
+	}
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			for _, k := range keys(a, b) {
+				va, okA := a[k]
+				vb, okB := b[k]
+				p := path + member(k)
+				if okA && okB {
+					diffs = diffJSON(p, va, vb, opts, diffs)
+				} else if !opts.ignored(p) {
+					diffs = append(diffs, difference{p, valueText(va, okA), valueText(vb, okB)})
+				}
+			}
+			return diffs
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			for i := 0; i < len(a) || i < len(b); i++ {
+				p := path + "[" + strconv.Itoa(i) + "]"
+				if i < len(a) && i < len(b) {
+					diffs = diffJSON(p, a[i], b[i], opts, diffs)
+				} else if !opts.ignored(p) {
+					diffs = append(diffs, difference{p, elemText(a, i), elemText(b, i)})
+				}
+			}
+			return diffs
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		diffs = append(diffs, difference{path, valueText(a, true), valueText(b, true)})
+	}
+	return diffs
+}
+
+// keys of a and b, sorted.
+func keys(a, b map[string]any) []string {
+	var ks []string
+	for k := range a {
+		ks = append(ks, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			ks = append(ks, k)
+		}
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+// member is the path of the member k of an object: .k, or ["k"] if k is
+// not an identifier.
+func member(k string) string {
+	for i, r := range k {
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return "[" + strconv.Quote(k) + "]"
+		}
+	}
+	if k == "" {
+		return `[""]`
+	}
+	return "." + k
+}
+
+func valueText(v any, ok bool) string {
+	if !ok {
+		return missing
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func elemText(a []any, i int) string {
+	if i < len(a) {
+		return valueText(a[i], true)
+	}
+	return missing
+}
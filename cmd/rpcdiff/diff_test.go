@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"simpleRpc/jsonrpc2"
+)
+
+func Test_diffJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   string
+		ignore []string
+		want   []string
+	}{
+		{"same", `{"a": 1, "b": [1, 2]}`, `{"b": [1, 2.0], "a": 1}`, nil, nil},
+		{"value", `{"sum": 3}`, `{"sum": 4}`, nil, []string{"$.sum: 3 != 4"}},
+		{"type", `{"sum": 3}`, `{"sum": "3"}`, nil, []string{`$.sum: 3 != "3"`}},
+		{"member", `{"a": 1}`, `{"b": 1}`, nil, []string{"$.a: 1 != (missing)", "$.b: (missing) != 1"}},
+		{"element", `[1, {"x": 1}]`, `[1, {"x": 2}, 3]`, nil, []string{"$[1].x: 1 != 2", "$[2]: (missing) != 3"}},
+		{"key", `{"a b": 1}`, `{"a b": 2}`, nil, []string{`$["a b"]: 1 != 2`}},
+		{"ignore", `{"time": 1, "items": [{"id": 1, "n": 1}]}`, `{"time": 2, "items": [{"id": 2, "n": 2}]}`,
+			[]string{"$.time", "$.items[0].id"}, []string{"$.items[0].n: 1 != 2"}},
+		{"ignoreInside", `{"meta": {"at": 1}}`, `{"meta": {"at": 2, "by": "b"}}`, []string{"$.meta"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &jsonrpc2.Response{Result: json.RawMessage(tt.a)}
+			b := &jsonrpc2.Response{Result: json.RawMessage(tt.b)}
+			var got []string
+			for _, d := range diffResponses(a, b, diffOptions{ignore: tt.ignore}) {
+				got = append(got, d.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("❌ diffs:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func Test_diffErrors(t *testing.T) {
+	notFound := &jsonrpc2.Response{Error: jsonrpc2.ErrMethodNotFound()}
+	result := &jsonrpc2.Response{Result: json.RawMessage(`1`)}
+	other := &jsonrpc2.Response{Error: jsonrpc2.ErrMethodNotFound().WithReason("not here")}
+	other.Error.Message = "No such method"
+
+	if diffs := diffResponses(notFound, other, diffOptions{}); len(diffs) != 0 {
+		t.Errorf("❌ diffs %v of the same codes", diffs)
+	}
+	if diffs := diffResponses(notFound, other, diffOptions{errorDetails: true}); len(diffs) != 2 {
+		t.Errorf("❌ diffs %v, want of the message and the data", diffs)
+	}
+	diffs := diffResponses(notFound, result, diffOptions{})
+	if len(diffs) != 1 || diffs[0].String() != `error: -32601 "Method not found" != (missing)` {
+		t.Errorf("❌ diffs %v, want the error against the result", diffs)
+	}
+}
+
+// serverTransport calls the server directly.
+type serverTransport struct {
+	server jsonrpc2.Server
+	err    error
+}
+
+func (t *serverTransport) SendAndReceive(ctx context.Context, req *jsonrpc2.Request) (*jsonrpc2.Response, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.server.ServeRPC(ctx, req), nil
+}
+
+func Test_differ_run(t *testing.T) {
+	v1 := jsonrpc2.NewServer()
+	v1.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil })
+	v1.MustRegister("mul", func(arg []int) (int, error) { return arg[0] * arg[1], nil })
+	v2 := jsonrpc2.NewServer()
+	v2.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil })
+	v2.MustRegister("mul", func(arg []int) (int, error) { return arg[0] + arg[1], nil }) // buggy rewrite
+
+	in := strings.NewReader(`{"jsonrpc": "2.0", "method": "add", "params": [2, 2], "id": 1}
+{"jsonrpc": "2.0", "method": "mul", "params": [2, 2], "id": 2}
+{"jsonrpc": "2.0", "method": "mul", "params": [2, 3], "id": 3}
+
+{"jsonrpc": "2.0", "method": "add", "params": [2, 3]}
+`)
+	var out strings.Builder
+	d := &differ{a: &serverTransport{server: v1}, b: &serverTransport{server: v2}}
+	s, err := d.run(context.Background(), in, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != (summary{requests: 4, differ: 1, skipped: 1}) {
+		t.Errorf("❌ summary %v", s)
+	}
+	if want := "--- request 3: mul (id 3)\n  $: 6 != 5\n"; out.String() != want {
+		t.Errorf("❌ output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	d.b = &serverTransport{err: errors.New("connection refused")}
+	out.Reset()
+	s, err = d.run(context.Background(), strings.NewReader(`{"jsonrpc": "2.0", "method": "add", "params": [1, 1], "id": 1}`), &out)
+	if err != nil || s.failed != 1 || !strings.Contains(out.String(), "b failed: connection refused") {
+		t.Errorf("❌ %v, %v:\n%s\nwant b failed", s, err, out.String())
+	}
+
+	if _, err := d.run(context.Background(), strings.NewReader(`[1, 2]`), &out); err == nil {
+		t.Error("❌ ran a line not of a request")
+	}
+}
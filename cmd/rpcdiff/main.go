@@ -0,0 +1,90 @@
+// rpcdiff 把同一批录制下来的请求分别发给两个 JSON-RPC 2.0 服务端，报告它们的响应在语义上的差异，
+// 用于比较两个版本的服务端 (A/B)，如验证重写后的实现，与影子流量 (jsonrpc2.Mirror) 互补。
+//
+// 请求从文件 (或标准输入) 读取，每行一个 JSON-RPC 请求：
+//
+//	{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}
+//	{"jsonrpc": "2.0", "method": "lock.Lock", "params": {}, "id": 2}
+//
+// 比较两个服务端：
+//
+//	rpcdiff -a http://localhost:5680 -b http://localhost:5681 requests.jsonl
+//	--- request 1: add (id 1)
+//	  $.sum: 3 != 4
+//	2 requests: 1 differ, 0 failed, 0 skipped
+//
+// 结果作为 JSON 值比较：与空白、对象键的顺序无关，逐个报告不同之处的路径。
+// 错误只比较错误码 (-error-details 也比较 message 与 data)。
+// 每次都不同的字段 (如时间戳) 可以用 -ignore 忽略：
+//
+//	rpcdiff -a ... -b ... -ignore '$.time,$.items[0].id' requests.jsonl
+//
+// 通知没有响应，不比较 (skipped)。没有差异时退出码为 0，有差异时为 1，出错时为 2，与 diff 相同。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"simpleRpc/jsonrpc2"
+)
+
+var (
+	urlA         = flag.String("a", "", "URL of the server A")
+	urlB         = flag.String("b", "", "URL of the server B")
+	ignore       = flag.String("ignore", "", "comma-separated paths of the results not compared, e.g. $.time")
+	errorDetails = flag.Bool("error-details", false, "compare the messages and data of errors too, not only their codes")
+	timeout      = flag.Duration("timeout", 10*time.Second, "timeout of each request")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: %s -a url -b url [flags] [requests.jsonl]\n\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *urlA == "" || *urlB == "" || flag.NArg() > 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	var in io.Reader = os.Stdin
+	if flag.NArg() == 1 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	d := &differ{
+		a:       jsonrpc2.NewHttpClientTransport(*urlA),
+		b:       jsonrpc2.NewHttpClientTransport(*urlB),
+		timeout: *timeout,
+		opts:    diffOptions{errorDetails: *errorDetails},
+	}
+	if *ignore != "" {
+		d.opts.ignore = strings.Split(*ignore, ",")
+	}
+	summary, err := d.run(context.Background(), in, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Println(summary)
+	switch {
+	case summary.failed > 0:
+		os.Exit(2)
+	case summary.differ > 0:
+		os.Exit(1)
+	}
+}
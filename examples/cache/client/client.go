@@ -0,0 +1,99 @@
+// 这个程序演示缓存服务的用法。
+//
+// 它把几个不同类型的值 (字符串、数字、结构体) 写入缓存，再读回到对应类型的变量中；
+// 然后设置一个带 TTL 的 key，等它过期；最后打印服务端的统计：
+//
+//	✅ greeting = "hello"
+//	✅ answer = 42
+//	✅ user = main.User{Name:"alice", Age:30}
+//	✅ session expired after 100ms
+//	stats: cache.entries = 3, cache.expired = 1, cache.get.count = 4, ..., cache.hits = 3, cache.misses = 1
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"simpleRpc/examples/cache"
+	"simpleRpc/jsonrpc2"
+	"sort"
+	"strings"
+	"time"
+)
+
+type User struct {
+	Name string
+	Age  int
+}
+
+func set(c jsonrpc2.Client, key string, value any, ttl time.Duration) {
+	raw, err := json.Marshal(value)
+	must(err)
+	must(c.Call(cache.MethodSet,
+		&cache.SetRequest{Key: key, Value: raw, TTLMs: ttl.Milliseconds()},
+		&cache.SetResponse{}))
+}
+
+// get key into value, reporting whether it's found.
+func get(c jsonrpc2.Client, key string, value any) bool {
+	var resp cache.GetResponse
+	must(c.Call(cache.MethodGet, &cache.GetRequest{Key: key}, &resp))
+	if !resp.Found {
+		return false
+	}
+	must(json.Unmarshal(resp.Value, value))
+	return true
+}
+
+// roundTrip sets key to want and gets it back into a new value of the same type.
+func roundTrip(c jsonrpc2.Client, key string, want any) {
+	set(c, key, want, 0)
+
+	got := reflect.New(reflect.TypeOf(want))
+	found := get(c, key, got.Interface())
+
+	correct := "❌"
+	if found && reflect.DeepEqual(got.Elem().Interface(), want) {
+		correct = "✅"
+	}
+	fmt.Printf("%s %s = %#v\n", correct, key, got.Elem().Interface())
+}
+
+func main() {
+	c := jsonrpc2.NewClient(
+		jsonrpc2.NewHttpClientTransport("http://localhost" + cache.ServerAddr))
+
+	roundTrip(c, "greeting", "hello")
+	roundTrip(c, "answer", 42)
+	roundTrip(c, "user", User{Name: "alice", Age: 30})
+
+	ttl := 100 * time.Millisecond
+	set(c, "session", "s3cr3t", ttl)
+	time.Sleep(2 * ttl)
+	var session string
+	correct := "✅"
+	if get(c, "session", &session) {
+		correct = "❌"
+	}
+	fmt.Printf("%s session expired after %v\n", correct, ttl)
+
+	var stats cache.StatsResponse
+	must(c.Call(cache.MethodStats, &cache.StatsRequest{}, &stats))
+
+	var names []string
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "%s = %d, ", name, stats[name])
+	}
+	fmt.Printf("stats: %s", strings.TrimSuffix(sb.String(), ", "))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,169 @@
+// 这个程序实现了一个内存 LRU 缓存服务 CacheServer。
+//
+// 该服务提供三个远程过程：Get、Set 和 Stats。
+// Set 可以为 key 设置任意 JSON 值，并可指定 TTL；缓存满 (-capacity) 时淘汰最久未使用的 key。
+//
+// 缓存自己的指标 (命中、未命中、淘汰、过期、条目数、Get 耗时) 通过 Server 的 metrics 钩子
+// (jsonrpc2.Server.WithMetrics) 记录，与服务端自身的指标汇总在一起，
+// 由 Stats 远程过程返回，同时也可以通过 HTTP 查看：
+//
+//	curl http://localhost:5685/debug/jsonrpc2
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"simpleRpc/examples/cache"
+	"simpleRpc/jsonrpc2"
+	"sync"
+	"time"
+)
+
+var capacity = flag.Int("capacity", 1024, "max number of entries")
+
+// LRU is a least-recently-used cache of values V by keys K, with TTL.
+type LRU[K comparable, V any] struct {
+	capacity int
+	metrics  jsonrpc2.Metrics
+	clock    jsonrpc2.Clock // tells when entries expire
+
+	mu      sync.Mutex
+	ll      *list.List // of *entry[K, V], the most recently used at front
+	entries map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key      K
+	value    V
+	expireAt time.Time // zero means never
+}
+
+func NewLRU[K comparable, V any](capacity int, metrics jsonrpc2.Metrics) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		metrics:  metrics,
+		clock:    jsonrpc2.SystemClock,
+		ll:       list.New(),
+		entries:  make(map[K]*list.Element),
+	}
+}
+
+// WithClock sets the Clock telling when entries expire, e.g. a
+// jsonrpc2.FakeClock in tests, and returns c for chaining.
+func (c *LRU[K, V]) WithClock(clock jsonrpc2.Clock) *LRU[K, V] {
+	c.clock = clock
+	return c
+}
+
+func (c *LRU[K, V]) Get(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.metrics.Add("cache.misses", 1)
+		return value, false
+	}
+	e := el.Value.(*entry[K, V])
+	if !e.expireAt.IsZero() && c.clock.Now().After(e.expireAt) {
+		c.remove(el)
+		c.metrics.Add("cache.expired", 1)
+		c.metrics.Add("cache.misses", 1)
+		return value, false
+	}
+
+	c.ll.MoveToFront(el)
+	c.metrics.Add("cache.hits", 1)
+	return e.value, true
+}
+
+func (c *LRU[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = c.clock.Now().Add(ttl)
+	}
+
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expireAt = value, expireAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value, expireAt: expireAt})
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+		c.metrics.Add("cache.evictions", 1)
+	}
+	c.metrics.Set("cache.entries", int64(c.ll.Len()))
+}
+
+// remove el. c.mu must be held.
+func (c *LRU[K, V]) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.entries, el.Value.(*entry[K, V]).key)
+	c.metrics.Set("cache.entries", int64(c.ll.Len()))
+}
+
+type CacheServer struct {
+	lru    *LRU[string, json.RawMessage]
+	server jsonrpc2.Server // for Stats
+}
+
+func (s *CacheServer) Get(req *cache.GetRequest) (*cache.GetResponse, error) {
+	start := time.Now()
+	defer func() { s.lru.metrics.Observe("cache.get", time.Since(start)) }()
+
+	value, ok := s.lru.Get(req.Key)
+	return &cache.GetResponse{Found: ok, Value: value}, nil
+}
+
+func (s *CacheServer) Set(req *cache.SetRequest) (*cache.SetResponse, error) {
+	if !json.Valid(req.Value) {
+		return nil, errors.New("value should be a JSON value")
+	}
+	s.lru.Set(req.Key, req.Value, time.Duration(req.TTLMs)*time.Millisecond)
+	return &cache.SetResponse{}, nil
+}
+
+func (s *CacheServer) Stats(req *cache.StatsRequest) (cache.StatsResponse, error) {
+	return s.server.Stats(), nil
+}
+
+func main() {
+	flag.Parse()
+
+	metrics := jsonrpc2.NewMemoryMetrics()
+	s := jsonrpc2.NewServer().WithMetrics(metrics)
+
+	c := &CacheServer{
+		lru:    NewLRU[string, json.RawMessage](*capacity, metrics),
+		server: s,
+	}
+
+	must(s.RegisterAll(map[string]any{
+		cache.MethodGet:   c.Get,
+		cache.MethodSet:   c.Set,
+		cache.MethodStats: c.Stats,
+	}))
+
+	st := jsonrpc2.NewHttpServerTransport(cache.ServerAddr)
+	st.Use(s)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", st)
+	mux.Handle(cache.DebugPath, jsonrpc2.DebugHandler(s))
+	must(http.ListenAndServe(cache.ServerAddr, mux))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,39 @@
+package cache
+
+import "encoding/json"
+
+const ServerAddr = ":5685"
+
+// DebugPath serves the stats of the server over HTTP, see jsonrpc2.DebugHandler.
+const DebugPath = "/debug/jsonrpc2"
+
+// Values are any JSON values: they're kept as they are, and come back as they were set.
+
+const MethodGet = "get"
+
+type GetRequest struct {
+	Key string `json:"key"`
+}
+
+type GetResponse struct {
+	Found bool            `json:"found"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+const MethodSet = "set"
+
+type SetRequest struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+	TTLMs int64           `json:"ttl_ms,omitempty"` // 0 means never expire
+}
+
+type SetResponse struct{}
+
+const MethodStats = "stats"
+
+type StatsRequest struct{}
+
+// StatsResponse is the jsonrpc2.Server.Stats of the server,
+// including the "cache.*" metrics of the cache, e.g. "cache.hits".
+type StatsResponse map[string]int64
@@ -0,0 +1,90 @@
+// 这个程序启动 N 个并发协程，从计数器服务分配 id。
+//
+// 每个协程用一个新的 token 调用 Next，然后模拟 "回复丢失后重试"，
+// 用同一个 token 再调用一次 Next：两次应当得到同一个值。
+//
+// 如果一切正确，那么 N 个协程分配到的 id 互不相同，且是连续的。例如 N = 1000 时：
+//
+//	✅ allocated 1000 ids: [1, 1000], 1000 unique
+//
+// 重启服务端再运行一次，id 从 1001 开始继续分配。
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"simpleRpc/examples/counter"
+	"simpleRpc/jsonrpc2"
+	"sync"
+)
+
+// N is the number of concurrent goroutines.
+var N = flag.Int("n", 1000, "number of goroutines")
+var name = flag.String("name", "orders", "name of the counter")
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func allocate(c jsonrpc2.Client) int64 {
+	req := &counter.NextRequest{Name: *name, Token: newToken()}
+
+	var first, retried counter.NextResponse
+	must(c.Call(counter.MethodNext, req, &first))
+	// pretend the reply was lost, and retry
+	must(c.Call(counter.MethodNext, req, &retried))
+
+	if first.Value != retried.Value {
+		panic(fmt.Sprintf("❌ retry got %d, want %d", retried.Value, first.Value))
+	}
+	return first.Value
+}
+
+func main() {
+	flag.Parse()
+
+	c := jsonrpc2.NewClient(
+		jsonrpc2.NewHttpClientTransport("http://localhost" + counter.ServerAddr))
+
+	var mu sync.Mutex
+	ids := make(map[int64]bool)
+	lo, hi := int64(-1), int64(-1)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < *N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := allocate(c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			ids[id] = true
+			if lo < 0 || id < lo {
+				lo = id
+			}
+			if id > hi {
+				hi = id
+			}
+		}()
+	}
+	wg.Wait()
+
+	correct := "❌"
+	if len(ids) == *N && hi-lo+1 == int64(*N) {
+		correct = "✅"
+	}
+	fmt.Printf("%s allocated %d ids: [%d, %d], %d unique", correct, *N, lo, hi, len(ids))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,179 @@
+// 这个程序实现了一个分布式计数器 (序列号生成器) 服务 CounterServer。
+//
+// 该服务提供一个远程过程：Next，为名为 name 的计数器分配下一个值。
+// 每个计数器从 1 开始，单调递增，且不会重复分配。
+//
+// 持久化：每次分配在回复之前都通过 Persister 钩子落盘 (默认是 -log 指定的追加日志文件)，
+// 重启后重放日志恢复所有计数器，所以计数器的值不会回退。
+//
+// 恰好一次 (exactly-once)：每次分配由客户端给出的 token 标识。
+// 请求超时或连接中断后，客户端用同一个 token 重试，得到的是原来分配的值，而不是一个新值。
+// 所以 id 既不会丢失 (at-least-once，靠客户端重试)，也不会被重复分配 (at-most-once，靠 token)。
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"simpleRpc/examples/counter"
+	"simpleRpc/jsonrpc2"
+	"sync"
+)
+
+var logFile = flag.String("log", "counter.log", "file to persist the counters")
+
+// Allocation is a value allocated for a token.
+type Allocation struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Value int64  `json:"value"`
+}
+
+// Persister is the persistence hook of CounterServer.
+type Persister interface {
+	// Load all the allocations persisted, in order.
+	Load() ([]Allocation, error)
+	// Save an allocation durably before it's replied.
+	Save(a Allocation) error
+}
+
+type CounterServer struct {
+	persister Persister
+
+	mu       sync.Mutex
+	counters map[string]int64
+	tokens   map[string]int64 // name + "\x00" + token -> value
+}
+
+// NewCounterServer restores the counters from p.
+func NewCounterServer(p Persister) (*CounterServer, error) {
+	s := &CounterServer{
+		persister: p,
+		counters:  make(map[string]int64),
+		tokens:    make(map[string]int64),
+	}
+
+	allocations, err := p.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range allocations {
+		s.apply(a)
+	}
+	return s, nil
+}
+
+func (s *CounterServer) Next(req *counter.NextRequest) (*counter.NextResponse, error) {
+	if req.Name == "" || req.Token == "" {
+		return nil, errors.New("name and token are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a retry: same value
+	if v, ok := s.tokens[tokenKey(req.Name, req.Token)]; ok {
+		return &counter.NextResponse{Value: v}, nil
+	}
+
+	a := Allocation{Name: req.Name, Token: req.Token, Value: s.counters[req.Name] + 1}
+	if err := s.persister.Save(a); err != nil {
+		return nil, err // not allocated: nothing changes in memory
+	}
+	s.apply(a)
+
+	return &counter.NextResponse{Value: a.Value}, nil
+}
+
+// apply a (persisted) allocation in memory. s.mu must be held, or s not shared yet.
+func (s *CounterServer) apply(a Allocation) {
+	if a.Value > s.counters[a.Name] {
+		s.counters[a.Name] = a.Value
+	}
+	s.tokens[tokenKey(a.Name, a.Token)] = a.Value
+}
+
+func tokenKey(name, token string) string {
+	return name + "\x00" + token
+}
+
+// FilePersister persists the allocations in an append-only file,
+// one JSON object per line, fsync-ed on every Save.
+type FilePersister struct {
+	f *os.File
+}
+
+func OpenFilePersister(path string) (*FilePersister, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePersister{f: f}, nil
+}
+
+func (p *FilePersister) Load() ([]Allocation, error) {
+	if _, err := p.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var allocations []Allocation
+	var good int64 // offset of the end of the last good record
+	r := bufio.NewReader(p.f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		var a Allocation
+		if err == io.EOF || json.Unmarshal(line, &a) != nil {
+			// the end, or a torn write at the tail, from a crash during
+			// Save: it was never replied, so it's safe to drop.
+			break
+		}
+		allocations = append(allocations, a)
+		good += int64(len(line))
+	}
+
+	// cut the torn write off, not to glue the next record onto it
+	if err := p.f.Truncate(good); err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+func (p *FilePersister) Save(a Allocation) error {
+	line, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	if _, err := p.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return p.f.Sync()
+}
+
+func main() {
+	flag.Parse()
+
+	p, err := OpenFilePersister(*logFile)
+	must(err)
+
+	c, err := NewCounterServer(p)
+	must(err)
+
+	s := jsonrpc2.NewServer()
+
+	must(s.Register(counter.MethodNext, c.Next))
+
+	st := jsonrpc2.NewHttpServerTransport(counter.ServerAddr)
+	must(st.Serve(s))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
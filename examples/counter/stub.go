@@ -0,0 +1,16 @@
+package counter
+
+const ServerAddr = ":5682"
+
+const MethodNext = "next"
+
+type NextRequest struct {
+	Name string `json:"name"` // the counter
+	// Token identifies the allocation: retrying a call with the same
+	// token gets the same value instead of allocating another one.
+	Token string `json:"token"`
+}
+
+type NextResponse struct {
+	Value int64 `json:"value"`
+}
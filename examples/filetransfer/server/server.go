@@ -0,0 +1,148 @@
+// 这个程序实现了一个分块文件传输服务 FileServer。
+//
+// 该服务提供三个远程过程：Open、ReadChunk 和 Close。
+// 客户端先 Open 一个文件，得到句柄、文件大小和整个文件的 sha256；
+// 然后以任意 offset 逐块 ReadChunk，每块附带自己的 sha256 用于校验；
+// 最后 Close 句柄。因为 ReadChunk 可以从任意 offset 开始，中断的传输可以续传。
+//
+// 文件只能从 -root 目录 (默认为当前目录) 中读取。
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"simpleRpc/examples/filetransfer"
+	"simpleRpc/jsonrpc2"
+	"sync"
+)
+
+var root = flag.String("root", ".", "directory to serve files from")
+
+type FileServer struct {
+	root string
+
+	mu         sync.Mutex
+	files      map[int64]*os.File
+	nextHandle int64
+}
+
+func NewFileServer(root string) *FileServer {
+	return &FileServer{
+		root:  root,
+		files: make(map[int64]*os.File),
+	}
+}
+
+func (s *FileServer) Open(req *filetransfer.OpenRequest) (*filetransfer.OpenResponse, error) {
+	// Clean against "/" so that the name can't climb out of the root
+	path := filepath.Join(s.root, filepath.Clean("/"+req.Name))
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, errors.New("not a regular file: " + req.Name)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.nextHandle++
+	handle := s.nextHandle
+	s.files[handle] = f
+	s.mu.Unlock()
+
+	return &filetransfer.OpenResponse{
+		Handle: handle,
+		Size:   info.Size(),
+		Sha256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+func (s *FileServer) ReadChunk(req *filetransfer.ReadChunkRequest) (*filetransfer.ReadChunkResponse, error) {
+	f, err := s.file(req.Handle)
+	if err != nil {
+		return nil, err
+	}
+
+	length := req.Length
+	if length <= 0 {
+		length = filetransfer.ChunkSize
+	}
+	if length > filetransfer.MaxChunkSize {
+		length = filetransfer.MaxChunkSize
+	}
+
+	// ReadAt is safe for concurrent use: chunks can be fetched in parallel
+	data := make([]byte, length)
+	n, err := f.ReadAt(data, req.Offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data = data[:n]
+
+	sum := sha256.Sum256(data)
+	return &filetransfer.ReadChunkResponse{
+		Data:   data,
+		Sha256: hex.EncodeToString(sum[:]),
+		EOF:    err == io.EOF,
+	}, nil
+}
+
+func (s *FileServer) Close(req *filetransfer.CloseRequest) (*filetransfer.CloseResponse, error) {
+	s.mu.Lock()
+	f, ok := s.files[req.Handle]
+	delete(s.files, req.Handle)
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, errors.New("bad handle")
+	}
+	return &filetransfer.CloseResponse{}, f.Close()
+}
+
+func (s *FileServer) file(handle int64) (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[handle]
+	if !ok {
+		return nil, errors.New("bad handle")
+	}
+	return f, nil
+}
+
+func main() {
+	flag.Parse()
+
+	fs := NewFileServer(*root)
+
+	s := jsonrpc2.NewServer()
+
+	must(s.RegisterAll(map[string]any{
+		filetransfer.MethodOpen:      fs.Open,
+		filetransfer.MethodReadChunk: fs.ReadChunk,
+		filetransfer.MethodClose:     fs.Close,
+	}))
+
+	st := jsonrpc2.NewHttpServerTransport(filetransfer.ServerAddr)
+	must(st.Serve(s))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,44 @@
+package filetransfer
+
+const ServerAddr = ":5681"
+
+// ChunkSize is the default length of a chunk, and MaxChunkSize the limit.
+// A chunk of []byte goes over JSON as base64, about 4/3 of its size.
+const (
+	ChunkSize    = 256 << 10
+	MaxChunkSize = 4 << 20
+)
+
+const MethodOpen = "open"
+
+type OpenRequest struct {
+	Name string `json:"name"`
+}
+
+type OpenResponse struct {
+	Handle int64  `json:"handle"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"` // checksum of the whole file, hex
+}
+
+const MethodReadChunk = "readChunk"
+
+type ReadChunkRequest struct {
+	Handle int64 `json:"handle"`
+	Offset int64 `json:"offset"`
+	Length int   `json:"length"`
+}
+
+type ReadChunkResponse struct {
+	Data   []byte `json:"data"`   // base64 in JSON
+	Sha256 string `json:"sha256"` // checksum of Data, hex
+	EOF    bool   `json:"eof"`
+}
+
+const MethodClose = "close"
+
+type CloseRequest struct {
+	Handle int64 `json:"handle"`
+}
+
+type CloseResponse struct{}
@@ -0,0 +1,89 @@
+// 这个程序比较通过不同传输层调用限流服务的延迟。
+//
+// 对每种传输层，用 -c 个并发协程共调用 -n 次 Allow，统计每次调用的延迟：
+//
+//	http: 10000 calls, 2113 allowed, p50 = 412µs, p99 = 1.9ms
+//	tcp:  10000 calls, 2098 allowed, p50 = 98µs, p99 = 420µs
+//	unix: 10000 calls, 2101 allowed, p50 = 61µs, p99 = 301µs
+//
+// (数字因机器而异。) TCP 和 Unix 传输复用一条长连接，并发的调用在这条连接上流水线化，
+// 省去了 HTTP 每次调用的请求头与连接管理开销。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"simpleRpc/examples/ratelimit"
+	"simpleRpc/jsonrpc2"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	N = flag.Int("n", 10000, "number of calls per transport")
+	C = flag.Int("c", 8, "number of concurrent goroutines")
+)
+
+func bench(c jsonrpc2.Client) (latencies []time.Duration, allowed int) {
+	var mu sync.Mutex
+	calls := make(chan int)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < *C; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range calls {
+				req := &ratelimit.AllowRequest{Key: fmt.Sprintf("user-%d", i%10), N: 1}
+				var resp ratelimit.AllowResponse
+
+				start := time.Now()
+				must(c.Call(ratelimit.MethodAllow, req, &resp))
+				d := time.Since(start)
+
+				mu.Lock()
+				latencies = append(latencies, d)
+				if resp.Allowed {
+					allowed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := 0; i < *N; i++ {
+		calls <- i
+	}
+	close(calls)
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies, allowed
+}
+
+func main() {
+	flag.Parse()
+
+	transports := []struct {
+		name      string
+		transport jsonrpc2.ClientTransport
+	}{
+		{"http", jsonrpc2.NewHttpClientTransport("http://localhost" + ratelimit.HttpAddr)},
+		{"tcp", jsonrpc2.NewTcpClientTransport("localhost" + ratelimit.TcpAddr)},
+		{"unix", jsonrpc2.NewUnixClientTransport(ratelimit.UnixSocket)},
+	}
+
+	for _, t := range transports {
+		latencies, allowed := bench(jsonrpc2.NewClient(t.transport))
+		fmt.Printf("%-5s %d calls, %d allowed, p50 = %v, p99 = %v\n",
+			t.name+":", len(latencies), allowed,
+			latencies[len(latencies)/2], latencies[len(latencies)*99/100])
+	}
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
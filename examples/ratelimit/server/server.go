@@ -0,0 +1,102 @@
+// 这个程序实现了一个限流服务 RateLimitServer。
+//
+// 该服务提供一个远程过程：Allow，对 key 做令牌桶 (token bucket) 检查：
+// 每个 key 一个桶，容量为 -burst，每秒补充 -rate 个令牌。Allow(key, n) 从 key 的桶中取 n 个令牌，
+// 取得到就允许 (allowed)，取不到就拒绝，并告知多久之后可以再试。
+//
+// 同一个服务同时通过 HTTP、TCP 和 Unix socket 三种传输层提供，
+// 以便客户端比较它们的延迟：限流这样的控制面调用，每个业务请求都要先调一次，
+// 延迟直接叠加在业务请求上，所以长连接的 TCP / Unix 传输比每次调用都走 HTTP 合适得多。
+package main
+
+import (
+	"flag"
+	"math"
+	"os"
+	"simpleRpc/examples/ratelimit"
+	"simpleRpc/jsonrpc2"
+	"sync"
+	"time"
+)
+
+var (
+	rate  = flag.Float64("rate", 100, "tokens added to a bucket per second")
+	burst = flag.Float64("burst", 100, "capacity of a bucket")
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type RateLimitServer struct {
+	rate, burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewRateLimitServer(rate, burst float64) *RateLimitServer {
+	return &RateLimitServer{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (s *RateLimitServer) Allow(req *ratelimit.AllowRequest) (*ratelimit.AllowResponse, error) {
+	n := float64(req.N)
+	if n <= 0 {
+		n = 1
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[req.Key]
+	if !ok {
+		b = &bucket{tokens: s.burst, last: now}
+		s.buckets[req.Key] = b
+	}
+
+	// refill
+	b.tokens = math.Min(s.burst, b.tokens+now.Sub(b.last).Seconds()*s.rate)
+	b.last = now
+
+	if b.tokens >= n {
+		b.tokens -= n
+		return &ratelimit.AllowResponse{Allowed: true, Remaining: b.tokens}, nil
+	}
+
+	resp := &ratelimit.AllowResponse{Allowed: false, Remaining: b.tokens}
+	if n <= s.burst { // else never
+		wait := (n - b.tokens) / s.rate
+		resp.RetryAfterMs = int64(math.Ceil(wait * 1000))
+	}
+	return resp, nil
+}
+
+func main() {
+	flag.Parse()
+
+	rl := NewRateLimitServer(*rate, *burst)
+
+	s := jsonrpc2.NewServer()
+
+	must(s.Register(ratelimit.MethodAllow, rl.Allow))
+
+	_ = os.Remove(ratelimit.UnixSocket) // left by the last run
+
+	errs := make(chan error)
+	go func() { errs <- jsonrpc2.NewHttpServerTransport(ratelimit.HttpAddr).Serve(s) }()
+	go func() { errs <- jsonrpc2.NewTcpServerTransport(ratelimit.TcpAddr).Serve(s) }()
+	go func() { errs <- jsonrpc2.NewUnixServerTransport(ratelimit.UnixSocket).Serve(s) }()
+	must(<-errs)
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
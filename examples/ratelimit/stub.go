@@ -0,0 +1,22 @@
+package ratelimit
+
+// The server serves the same service over all the three transports.
+const (
+	HttpAddr   = ":5683"
+	TcpAddr    = ":5684"
+	UnixSocket = "/tmp/ratelimit.sock"
+)
+
+const MethodAllow = "allow"
+
+type AllowRequest struct {
+	Key string `json:"key"` // e.g. a user, an IP, an API token
+	N   int    `json:"n"`   // number of tokens to take, 1 if <= 0
+}
+
+type AllowResponse struct {
+	Allowed   bool    `json:"allowed"`
+	Remaining float64 `json:"remaining"` // tokens left in the bucket
+	// RetryAfterMs tells when the N tokens will be available, if not allowed.
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
@@ -0,0 +1,67 @@
+// 这个程序估计本机时钟与时间服务的偏差，并比较对冲 (hedging) 前后 Ping 的尾延迟。
+//
+// 偏差估计：调用 -samples 次 Now，每次以 -timeout 为超时，取往返时间最短的一次，
+// 按 NTP 的方式计算偏差 (见 timeservice.Measure)。本机与服务端是同一台机器时，偏差应接近 0：
+//
+//	offset = 12µs ± 310µs (best rtt = 620µs)
+//
+// 对冲：Ping -n 次，先不对冲，再在 -hedge 之后发出第二个请求，比较 p50 / p99：
+//
+//	plain:  p50 = 2.7ms, p99 = 50.4ms
+//	hedged: p50 = 2.8ms, p99 = 9.6ms (hedged 11% of the calls)
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"simpleRpc/examples/timeservice"
+	"simpleRpc/jsonrpc2"
+	"sort"
+	"time"
+)
+
+var (
+	samples = flag.Int("samples", 8, "number of Now samples")
+	timeout = flag.Duration("timeout", 100*time.Millisecond, "timeout of a Now sample")
+	N       = flag.Int("n", 200, "number of pings")
+	hedge   = flag.Duration("hedge", 6*time.Millisecond, "delay before hedging a ping")
+)
+
+// pings returns the sorted RTTs of N pings, and how many were hedged.
+func pings(c jsonrpc2.Client, hedge time.Duration) (rtts []time.Duration, hedgedCount int) {
+	for i := 0; i < *N; i++ {
+		rtt, hedged, err := timeservice.HedgedPing(context.Background(), c, int64(i), hedge)
+		must(err)
+		rtts = append(rtts, rtt)
+		if hedged {
+			hedgedCount++
+		}
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	return rtts, hedgedCount
+}
+
+func main() {
+	flag.Parse()
+
+	c := jsonrpc2.NewClient(
+		jsonrpc2.NewHttpClientTransport("http://localhost" + timeservice.ServerAddr))
+
+	best, err := timeservice.EstimateOffset(context.Background(), c, *samples, *timeout)
+	must(err)
+	fmt.Printf("offset = %v ± %v (best rtt = %v)\n", best.Offset, best.RTT/2, best.RTT)
+
+	plain, _ := pings(c, time.Hour) // never hedged
+	fmt.Printf("plain:  p50 = %v, p99 = %v\n", plain[len(plain)/2], plain[len(plain)*99/100])
+
+	hedged, count := pings(c, *hedge)
+	fmt.Printf("hedged: p50 = %v, p99 = %v (hedged %d%% of the calls)\n",
+		hedged[len(hedged)/2], hedged[len(hedged)*99/100], count*100 / *N)
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
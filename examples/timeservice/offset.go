@@ -0,0 +1,110 @@
+package timeservice
+
+import (
+	"context"
+	"errors"
+	"simpleRpc/jsonrpc2"
+	"time"
+)
+
+// Sample is one Now call: the clock of the server, seen from the client.
+type Sample struct {
+	Offset time.Duration // server clock - client clock
+	RTT    time.Duration // round-trip time of the call
+}
+
+// Measure calls Now once and estimates the offset, NTP-style, assuming the
+// server read its clock halfway through the round trip:
+//
+//	offset = server - (sent + rtt/2)
+//
+// The error of the estimate is at most rtt/2.
+func Measure(ctx context.Context, c jsonrpc2.Client) (Sample, error) {
+	var resp NowResponse
+	sent := time.Now()
+	if err := c.CallContext(ctx, MethodNow, &NowRequest{}, &resp); err != nil {
+		return Sample{}, err
+	}
+	rtt := time.Since(sent)
+
+	server := time.Unix(0, resp.UnixNano)
+	return Sample{
+		Offset: server.Sub(sent.Add(rtt / 2)),
+		RTT:    rtt,
+	}, nil
+}
+
+// EstimateOffset takes n samples, each bounded by timeout, and returns the
+// one of the smallest RTT: the most accurate. Timed out samples are skipped.
+func EstimateOffset(ctx context.Context, c jsonrpc2.Client, n int, timeout time.Duration) (Sample, error) {
+	var best Sample
+	var err error
+	found := false
+
+	for i := 0; i < n; i++ {
+		sctx, cancel := context.WithTimeout(ctx, timeout)
+		var s Sample
+		s, err = Measure(sctx, c)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return Sample{}, ctx.Err()
+			}
+			continue
+		}
+		if !found || s.RTT < best.RTT {
+			best, found = s, true
+		}
+	}
+
+	if !found {
+		if err == nil {
+			err = errors.New("no samples")
+		}
+		return Sample{}, err
+	}
+	return best, nil
+}
+
+// HedgedPing pings the server, and pings again if the first doesn't come
+// back within hedge, taking whichever answers first: the tail latency is
+// cut at the cost of some extra calls. The loser is cancelled.
+func HedgedPing(ctx context.Context, c jsonrpc2.Client, seq int64, hedge time.Duration) (rtt time.Duration, hedged bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // cancel the loser
+
+	type result struct {
+		err error
+	}
+	results := make(chan result, 2)
+	ping := func() {
+		var resp PingResponse
+		err := c.CallContext(ctx, MethodPing, &PingRequest{Seq: seq}, &resp)
+		if err == nil && resp.Seq != seq {
+			err = errors.New("ping: seq mismatch")
+		}
+		results <- result{err}
+	}
+
+	start := time.Now()
+	go ping()
+
+	timer := time.NewTimer(hedge)
+	defer timer.Stop()
+
+	pending := 1
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil || pending == 0 {
+				return time.Since(start), hedged, r.err
+			}
+		case <-timer.C:
+			hedged = true
+			pending++
+			go ping()
+		}
+	}
+}
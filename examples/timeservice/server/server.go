@@ -0,0 +1,66 @@
+// 这个程序实现了一个简易的时间服务 (ntp-ish) TimeServer。
+//
+// 该服务提供两个远程过程：Now 返回服务端的时钟，Ping 原样返回请求中的序号。
+//
+// 为了演示客户端的对冲 (hedging) 与超时，服务端可以用 -jitter 模拟不稳定的网络：
+// 每个调用随机延迟 [0, jitter) 的时间，并且有 -slow 的概率延迟 10 倍 jitter，制造长尾。
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"simpleRpc/examples/timeservice"
+	"simpleRpc/jsonrpc2"
+	"time"
+)
+
+var (
+	jitter = flag.Duration("jitter", 5*time.Millisecond, "max random delay of a call")
+	slow   = flag.Float64("slow", 0.05, "probability of a call being 10x jitter slow")
+)
+
+type TimeServer struct{}
+
+// delay simulates the network.
+func delay() {
+	if *jitter <= 0 {
+		return
+	}
+	d := time.Duration(rand.Int63n(int64(*jitter)))
+	if rand.Float64() < *slow {
+		d = 10 * *jitter
+	}
+	time.Sleep(d)
+}
+
+func (s *TimeServer) Now(req *timeservice.NowRequest) (*timeservice.NowResponse, error) {
+	delay()
+	return &timeservice.NowResponse{UnixNano: time.Now().UnixNano()}, nil
+}
+
+func (s *TimeServer) Ping(req *timeservice.PingRequest) (*timeservice.PingResponse, error) {
+	delay()
+	return &timeservice.PingResponse{Seq: req.Seq}, nil
+}
+
+func main() {
+	flag.Parse()
+
+	ts := &TimeServer{}
+
+	s := jsonrpc2.NewServer()
+
+	must(s.RegisterAll(map[string]any{
+		timeservice.MethodNow:  ts.Now,
+		timeservice.MethodPing: ts.Ping,
+	}))
+
+	st := jsonrpc2.NewHttpServerTransport(timeservice.ServerAddr)
+	must(st.Serve(s))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,21 @@
+package timeservice
+
+const ServerAddr = ":5686"
+
+const MethodNow = "now"
+
+type NowRequest struct{}
+
+type NowResponse struct {
+	UnixNano int64 `json:"unix_nano"` // the clock of the server
+}
+
+const MethodPing = "ping"
+
+type PingRequest struct {
+	Seq int64 `json:"seq"`
+}
+
+type PingResponse struct {
+	Seq int64 `json:"seq"` // echoed
+}
@@ -0,0 +1,87 @@
+package jsonrpc2
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// 这个文件保护 StreamServerTransport 的 accept 循环：连接数上限、accept 速率限制，
+// 以及在 fd 耗尽等临时错误下退避重试而不是退出。
+
+// maxAcceptBackoff caps the backoff between retries of failed accepts.
+const maxAcceptBackoff = time.Second
+
+// acceptor accepts the connections of a listener within the caps of a
+// StreamServerTransport: MaxConnections and AcceptRate.
+type acceptor struct {
+	l     net.Listener
+	slots chan struct{} // one per open connection, nil: no cap
+	every time.Duration // min interval between accepts, 0: no limit
+	last  time.Time     // of the last accept
+
+	logger Logger
+}
+
+func (t *StreamServerTransport) newAcceptor(l net.Listener, logger Logger) *acceptor {
+	a := &acceptor{l: l, logger: logger}
+	if t.MaxConnections > 0 {
+		a.slots = make(chan struct{}, t.MaxConnections)
+	}
+	if t.AcceptRate > 0 {
+		a.every = time.Second / time.Duration(t.AcceptRate)
+	}
+	return a
+}
+
+// accept waits for a free slot and the rate limit, then accepts the next
+// connection. Temporary failures, e.g. running out of file descriptors,
+// are retried with a backoff. release must be called once conn is closed.
+func (a *acceptor) accept() (conn net.Conn, release func(), err error) {
+	if a.slots != nil {
+		a.slots <- struct{}{}
+	}
+	release = func() {
+		if a.slots != nil {
+			<-a.slots
+		}
+	}
+
+	if a.every > 0 {
+		if wait := a.every - time.Since(a.last); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+
+	var backoff time.Duration
+	for {
+		conn, err = a.l.Accept()
+		if err == nil {
+			a.last = time.Now()
+			return conn, release, nil
+		}
+		if !temporaryAcceptError(err) {
+			release()
+			return nil, nil, err
+		}
+
+		if backoff == 0 {
+			backoff = 5 * time.Millisecond
+		} else if backoff *= 2; backoff > maxAcceptBackoff {
+			backoff = maxAcceptBackoff
+		}
+		a.logger.Log(LevelWarn, "failed to accept", Field{"error", err}, Field{"retry_in", backoff})
+		time.Sleep(backoff)
+	}
+}
+
+// temporaryAcceptError reports whether an Accept may succeed if retried.
+func temporaryAcceptError(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.ENOBUFS)
+}
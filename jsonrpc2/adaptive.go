@@ -0,0 +1,112 @@
+package jsonrpc2
+
+// 这个文件实现自适应的并发上限 (WithAdaptiveConcurrency)：按方法执行的耗时以 AIMD
+// 调整 WithMaxConcurrency 的上限——耗时接近空闲时的最低值则每轮加一，明显变慢则按比例
+// 回退，以便在过载时保护下游的资源 (如锁服务的 channel)，而无需手工调优。
+
+import "time"
+
+// AdaptiveConcurrency configures WithAdaptiveConcurrency. The zero value of
+// each field means its default.
+type AdaptiveConcurrency struct {
+	Min     int // the lowest limit, 1 by default
+	Max     int // the highest limit, 1000 by default
+	Initial int // the limit to start with, 10 (within Min and Max) by default
+
+	// Tolerance is how many times slower than the best latency seen the
+	// calls may get before the limit backs off, 2 by default.
+	Tolerance float64
+
+	// Backoff is the factor the limit backs off by, 0.9 by default.
+	Backoff float64
+}
+
+// withDefaults returns a with the defaults of the fields not set.
+func (a AdaptiveConcurrency) withDefaults() AdaptiveConcurrency {
+	if a.Min <= 0 {
+		a.Min = 1
+	}
+	if a.Max <= 0 {
+		a.Max = 1000
+	}
+	if a.Max < a.Min {
+		a.Max = a.Min
+	}
+	if a.Initial <= 0 {
+		a.Initial = 10
+	}
+	if a.Initial < a.Min {
+		a.Initial = a.Min
+	}
+	if a.Initial > a.Max {
+		a.Initial = a.Max
+	}
+	if a.Tolerance <= 1 {
+		a.Tolerance = 2
+	}
+	if a.Backoff <= 0 || a.Backoff >= 1 {
+		a.Backoff = 0.9
+	}
+	return a
+}
+
+// aimd adjusts the limit of a limiter by the latencies of the calls:
+// additive increase while they're about as fast as the best seen,
+// multiplicative decrease once they're slower than tolerated.
+type aimd struct {
+	AdaptiveConcurrency
+
+	best time.Duration // the lowest latency, drifting up to the latest ones
+
+	// of the round, since the limit last changed
+	calls int
+	total time.Duration // the latencies of the calls summed
+	full  bool          // the limit was reached
+}
+
+func newAIMD(a AdaptiveConcurrency) *aimd {
+	return &aimd{AdaptiveConcurrency: a.withDefaults()}
+}
+
+// adapt the limit to the latency of a call done, with inflight calls
+// (that one included), returning the new limit.
+//
+// The limit changes once a round, i.e. as many calls as the limit, not
+// to back off over and over for the same overload: it backs off if the
+// calls of the round were too slow on average, else grows by 1 if they
+// reached the limit.
+func (a *aimd) adapt(limit, inflight int, latency time.Duration) int {
+	if a.best == 0 || latency < a.best {
+		a.best = latency
+	} else {
+		// forget a best too old, e.g. of a cache since emptied
+		a.best += (latency - a.best) / 256
+	}
+	a.calls++
+	a.total += latency
+	a.full = a.full || inflight >= limit
+	if a.calls < limit {
+		return limit
+	}
+
+	switch {
+	case float64(a.total/time.Duration(a.calls)) > float64(a.best)*a.Tolerance:
+		limit = int(float64(limit) * a.Backoff)
+		if limit < a.Min {
+			limit = a.Min
+		}
+	case a.full && limit < a.Max:
+		limit++
+	}
+	a.calls, a.total, a.full = 0, 0, false
+	return limit
+}
+
+// WithAdaptiveConcurrency 原址设置自适应的并发上限，并返回 Server 以供链式
+func (s *server) WithAdaptiveConcurrency(a AdaptiveConcurrency) Server {
+	l := newLimiter(0, s.maxQueue)
+	l.adaptive = newAIMD(a)
+	l.limit = l.adaptive.Initial
+	s.limiter = l
+	return s
+}
@@ -0,0 +1,112 @@
+package jsonrpc2
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_aimd(t *testing.T) {
+	a := newAIMD(AdaptiveConcurrency{Min: 2, Max: 5, Initial: 4})
+
+	// round runs as many calls as the limit, returning the limit after
+	round := func(limit, inflight int, latency time.Duration) int {
+		for i := 0; i < limit; i++ {
+			limit = a.adapt(limit, inflight, latency)
+		}
+		return limit
+	}
+	steps := []struct {
+		name     string
+		inflight int
+		latency  time.Duration
+		want     int
+	}{
+		{"full", 4, time.Millisecond, 5},
+		{"max", 5, time.Millisecond, 5},
+		{"notFull", 1, time.Millisecond, 5},
+		{"tolerated", 5, 2 * time.Millisecond, 5},
+		{"slow", 5, 10 * time.Millisecond, 4},
+		{"slower", 4, 20 * time.Millisecond, 3},
+		{"min", 3, 50 * time.Millisecond, 2},
+		{"stillSlow", 2, 50 * time.Millisecond, 2},
+	}
+	limit := 4
+	for _, step := range steps {
+		if limit = round(limit, step.inflight, step.latency); limit != step.want {
+			t.Fatalf("❌ %s: limit %d, want %d", step.name, limit, step.want)
+		}
+	}
+}
+
+func Test_limiter_adaptive(t *testing.T) {
+	l := newLimiter(2, -1)
+	l.adaptive = newAIMD(AdaptiveConcurrency{Backoff: 0.5})
+	m := NewMemoryMetrics()
+
+	// queue a caller, acquiring once the returned channel is closed
+	queue := func() <-chan struct{} {
+		t.Helper()
+		acquired := make(chan struct{})
+		go func() {
+			if _, ok := l.acquire(context.Background(), SystemClock, m); ok {
+				close(acquired)
+			}
+		}()
+		for deadline := time.Now().Add(time.Second); l.stats()["queue.depth"] != 1; {
+			if time.Now().After(deadline) {
+				t.Fatal("❌ not queued")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return acquired
+	}
+	wait := func(acquired <-chan struct{}) {
+		t.Helper()
+		select {
+		case <-acquired:
+		case <-time.After(time.Second):
+			t.Fatal("❌ not acquired")
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := l.acquire(context.Background(), SystemClock, m); !ok {
+			t.Fatal("❌ not acquired")
+		}
+	}
+	waiter := queue()
+
+	l.release(m, time.Millisecond) // handed over
+	wait(waiter)
+	l.release(m, 10*time.Millisecond) // too slow: backs off to 1
+	if got := l.stats(); got["concurrency.limit"] != 1 || got["concurrency.inflight"] != 1 {
+		t.Fatalf("❌ backed off: %v", got)
+	}
+
+	waiter = queue()               // not acquiring beyond the limit lowered
+	l.release(m, time.Millisecond) // fast and full: grows to 2, for the waiter
+	wait(waiter)
+	if got := l.stats(); got["concurrency.limit"] != 2 || got["concurrency.inflight"] != 1 {
+		t.Errorf("❌ grown: %v", got)
+	}
+	if m.Snapshot()["concurrency.limit"] != 2 {
+		t.Errorf("❌ limit not reported: %v", m.Snapshot())
+	}
+}
+
+func Test_server_WithAdaptiveConcurrency(t *testing.T) {
+	s := NewServer().WithAdaptiveConcurrency(AdaptiveConcurrency{Initial: 3}).WithMaxQueue(0)
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	if got := s.Stats()["concurrency.limit"]; got != 3 {
+		t.Errorf("❌ limit %d, want 3", got)
+	}
+	if resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "echo", Params: []byte(`1`), Id: Int64ID(1)}); resp.Error != nil {
+		t.Errorf("❌ %v", resp.Error)
+	}
+
+	s.WithMaxConcurrency(7)
+	if got := s.Stats()["concurrency.limit"]; got != 7 {
+		t.Errorf("❌ limit %d after WithMaxConcurrency, want 7", got)
+	}
+}
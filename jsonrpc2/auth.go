@@ -0,0 +1,87 @@
+package jsonrpc2
+
+// 这个文件实现流式连接 (TCP、Unix socket、WebSocket) 的认证握手：
+// 服务端设置了 Authenticate 时，连接上的第一条消息必须是 rpc.auth 调用，
+// 认证通过后得到的身份 (identity) 附加到这条连接后续所有请求的 context 上；
+// 认证失败或第一条消息不是 rpc.auth，则回复错误并关闭连接；
+// 没在 HandshakeTimeout 内发来 rpc.auth 的连接同样被关闭。
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// MethodAuth is the call authenticating a stream connection, to be its first
+// message if the server transport has an Authenticator (see
+// StreamServerTransport.Authenticate). The params are for the Authenticator,
+// e.g. a token; the result is true.
+//
+// Clients send it by themselves on every connection, see
+// StreamClientTransport.AuthParams.
+const MethodAuth = "rpc.auth"
+
+// Authenticator checks the params of the MethodAuth call of a connection,
+// returning the identity of the caller, e.g. a user name, or an error to
+// refuse the connection: an *Error is answered as it is, other errors with
+// ErrUnauthorized. The ctx carries the TransportInfo of the connection.
+type Authenticator func(ctx context.Context, params json.RawMessage) (identity any, err error)
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the identity of the caller.
+// Transports authenticating connections attach it to their requests.
+func WithIdentity(ctx context.Context, identity any) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity attached by WithIdentity,
+// e.g. by the Authenticator of the connection of the request.
+func IdentityFromContext(ctx context.Context) (any, bool) {
+	identity := ctx.Value(identityKey{})
+	return identity, identity != nil
+}
+
+// DefaultHandshakeTimeout is the default StreamServerTransport.HandshakeTimeout.
+const DefaultHandshakeTimeout = 10 * time.Second
+
+var errNotAuthenticated = errors.New("the connection must be authenticated first, by " + MethodAuth)
+
+// authenticate the connection by its first message, body, answering it
+// (by write) with the result of auth. The returned ctx carries the identity;
+// it's false if the connection is refused and must be closed.
+func authenticate(ctx context.Context, auth Authenticator, body []byte, write func([]byte) error, logger Logger) (context.Context, bool) {
+	var req Request
+	var rpcErr *Error
+	var identity any
+	switch err := unmarshalRequest(bytes.NewReader(body), &req); {
+	case isBatch(body) || err != nil || req.Method != MethodAuth || req.Id == nil:
+		rpcErr = ErrUnauthorized().WithReason(errNotAuthenticated.Error())
+	default:
+		identity, err = auth(ctx, req.Params)
+		if err != nil {
+			if !errors.As(err, &rpcErr) {
+				rpcErr = ErrUnauthorized().WithReason(err.Error())
+			}
+		}
+	}
+
+	resp := &Response{JsonRpc: JsonRpc2, Id: req.Id, Result: json.RawMessage(`true`)}
+	if rpcErr != nil {
+		resp = errorResponse(req.Id, rpcErr)
+	}
+	out, err := json.Marshal(resp)
+	if err == nil {
+		err = write(out)
+	}
+	if err != nil {
+		logger.Log(LevelWarn, "failed to write response", Field{"error", err})
+		return ctx, false
+	}
+	if rpcErr != nil {
+		return ctx, false
+	}
+	return WithIdentity(ctx, identity), true
+}
@@ -0,0 +1,146 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_StreamServerTransport_Authenticate(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("whoami", func(ctx context.Context, arg int) (string, error) {
+		identity, _ := IdentityFromContext(ctx)
+		name, _ := identity.(string)
+		return name, nil
+	})
+	auth := func(ctx context.Context, params json.RawMessage) (any, error) {
+		var token string
+		if err := json.Unmarshal(params, &token); err != nil {
+			return nil, err
+		}
+		switch token {
+		case "alice-token":
+			return "alice", nil
+		case "banned":
+			return nil, NewError(4003, "Banned")
+		}
+		return nil, errors.New("bad token")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp", Authenticate: auth}).ServeListener(l, s)
+
+	wst := NewWebSocketServerTransport("")
+	wst.Authenticate = auth
+	wst.Use(s)
+	ts := httptest.NewServer(wst)
+	defer ts.Close()
+
+	dialers := map[string]func(params any) *StreamClientTransport{
+		"tcp": func(params any) *StreamClientTransport {
+			ct := NewTcpClientTransport(l.Addr().String())
+			ct.AuthParams = params
+			return ct
+		},
+		"websocket": func(params any) *StreamClientTransport {
+			ct := NewWebSocketClientTransport("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+			ct.AuthParams = params
+			return ct
+		},
+	}
+	tests := []struct {
+		name     string
+		params   any
+		want     string
+		wantCode int // 0: no error
+	}{
+		{"authenticated", "alice-token", "alice", 0},
+		{"badToken", "nope", "", ErrUnauthorized().Code},
+		{"ownError", "banned", "", 4003},
+		{"noAuth", nil, "", ErrUnauthorized().Code},
+	}
+	for transport, dial := range dialers {
+		for _, tt := range tests {
+			t.Run(transport+"/"+tt.name, func(t *testing.T) {
+				ct := dial(tt.params)
+				defer ct.Close()
+				c := NewClient(ct)
+
+				// twice: the connection is kept, or refused again
+				for i := 0; i < 2; i++ {
+					var got string
+					err := c.Call("whoami", 1, &got)
+					var rpcErr *Error
+					code := 0
+					if errors.As(err, &rpcErr) {
+						code = rpcErr.Code
+					}
+					if got != tt.want || code != tt.wantCode || (err != nil) != (tt.wantCode != 0) {
+						t.Fatalf("❌ whoami = %q, %v; want %q with code %d", got, err, tt.want, tt.wantCode)
+					}
+				}
+				t.Logf("✅ %s", tt.name)
+			})
+		}
+	}
+}
+
+func Test_StreamServerTransport_HandshakeTimeout(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	auth := func(ctx context.Context, params json.RawMessage) (any, error) { return "anyone", nil }
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp", Authenticate: auth, HandshakeTimeout: 50 * time.Millisecond}).ServeListener(l, s)
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+
+	// silent: closed once the handshake times out
+	silent, r := dial()
+	defer silent.Close()
+	if _, err := readFrame(r, 0); err != io.EOF {
+		t.Errorf("❌ silent connection: %v, want closed (EOF)", err)
+	}
+
+	// authenticated: kept past the handshake timeout
+	conn, r := dial()
+	defer conn.Close()
+	for i, call := range []string{
+		`{"jsonrpc": "2.0", "method": "rpc.auth", "params": "token", "id": 1}`,
+		`{"jsonrpc": "2.0", "method": "echo", "params": 42, "id": 2}`,
+	} {
+		if i > 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err := writeFrame(conn, []byte(call)); err != nil {
+			t.Fatal(err)
+		}
+		body, err := readFrame(r, 0)
+		if err != nil || strings.Contains(string(body), `"error"`) {
+			t.Fatalf("❌ %s: %s, %v", call, body, err)
+		}
+	}
+	t.Logf("✅ the authenticated connection is kept")
+}
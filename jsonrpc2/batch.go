@@ -0,0 +1,119 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// BatchOrder tells how the responses of a batch are ordered.
+type BatchOrder int
+
+const (
+	// BatchOrderRequest responds in the same order as the requests in the batch.
+	// This is the default.
+	BatchOrderRequest BatchOrder = iota
+	// BatchOrderCompletion responds in the order the entries finish.
+	// The caller correlates responses to requests by id, as the spec allows.
+	// Slow entries don't hold back fast ones when the batch runs in parallel.
+	BatchOrderCompletion
+)
+
+// isBatch reports whether data looks like a batch: a JSON array.
+func isBatch(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '['
+}
+
+// unmarshalBatch splits a batch into its entries, without parsing the entries.
+func unmarshalBatch(data []byte) ([]json.RawMessage, error) {
+	var batch []json.RawMessage
+	err := json.Unmarshal(data, &batch)
+	return batch, err
+}
+
+// serveBatchEntry parses, validates and serves one entry of a batch.
+// It returns nil for a notification.
+func (s *server) serveBatchEntry(ctx context.Context, raw json.RawMessage) *Response {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, ErrInvalidRequest().WithReason(err.Error()))
+	}
+	if err := validateRequest(s, &req, raw); err != nil {
+		return errorResponse(req.Id, ErrInvalidRequest().WithReason(err.Error()))
+	}
+	return s.ServeRPC(ctx, &req)
+}
+
+func (s *server) ServeBatch(ctx context.Context, batch []json.RawMessage) []*Response {
+	if len(batch) == 0 {
+		return []*Response{errorResponse(nil, ErrInvalidRequest().WithReason("empty batch"))}
+	}
+
+	// the entries share one response: no streaming
+	ctx = withResultSink(ctx, nil)
+	ctx = withPartialSink(ctx, nil)
+
+	workers := s.batchParallelism
+	if workers > len(batch) {
+		workers = len(batch)
+	}
+
+	responses := make([]*Response, 0, len(batch))
+
+	if workers <= 1 { // sequential
+		for _, raw := range batch {
+			if resp := s.serveBatchEntry(ctx, raw); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		return responses
+	}
+
+	type result struct {
+		index int
+		resp  *Response
+	}
+	jobs := make(chan int)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		s.pool.goTask(func() {
+			defer wg.Done()
+			for index := range jobs {
+				results <- result{index, s.serveBatchEntry(ctx, batch[index])}
+			}
+		})
+	}
+	go func() {
+		for i := range batch {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	switch s.batchOrder {
+	case BatchOrderCompletion:
+		for r := range results {
+			if r.resp != nil {
+				responses = append(responses, r.resp)
+			}
+		}
+	default:
+		ordered := make([]*Response, len(batch))
+		for r := range results {
+			ordered[r.index] = r.resp
+		}
+		for _, resp := range ordered {
+			if resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+	}
+	return responses
+}
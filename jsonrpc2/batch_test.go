@@ -0,0 +1,165 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newBatchTestServer(t *testing.T) Server {
+	s := NewServer()
+	// sleep sleeps for arg milliseconds and returns arg.
+	err := s.Register("sleep", func(arg int) (int, error) {
+		time.Sleep(time.Duration(arg) * time.Millisecond)
+		return arg, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func batchIds(responses []*Response) []int64 {
+	var ids []int64
+	for _, r := range responses {
+		if r.Id == nil {
+			ids = append(ids, -1)
+			continue
+		}
+		n, _ := r.Id.Int64()
+		ids = append(ids, n)
+	}
+	return ids
+}
+
+func Test_server_ServeBatch(t *testing.T) {
+	batch := []json.RawMessage{
+		[]byte(`{"jsonrpc": "2.0", "method": "sleep", "params": 60, "id": 1}`),
+		[]byte(`{"jsonrpc": "2.0", "method": "sleep", "params": 1, "id": 2}`),
+		[]byte(`1`),
+		[]byte(`{"jsonrpc": "2.0", "method": "nope", "params": 1, "id": 4}`),
+	}
+
+	tests := []struct {
+		name        string
+		parallelism int
+		order       BatchOrder
+		want        []int64 // ids, -1 for null
+	}{
+		{"sequential", 0, BatchOrderRequest, []int64{1, 2, -1, 4}},
+		{"parallelRequestOrder", 4, BatchOrderRequest, []int64{1, 2, -1, 4}},
+		{"parallelCompletionOrder", 4, BatchOrderCompletion, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newBatchTestServer(t).WithBatchParallelism(tt.parallelism).WithBatchOrder(tt.order)
+
+			start := time.Now()
+			responses := s.ServeBatch(context.Background(), batch)
+			elapsed := time.Since(start)
+
+			if len(responses) != len(batch) {
+				t.Fatalf("got %d responses, want %d", len(responses), len(batch))
+			}
+
+			got := batchIds(responses)
+			if tt.want != nil {
+				for i := range tt.want {
+					if got[i] != tt.want[i] {
+						t.Fatalf("ids = %v, want %v", got, tt.want)
+					}
+				}
+			} else if got[len(got)-1] != 1 { // the slow one finishes last
+				t.Errorf("ids = %v, want id 1 at last", got)
+			}
+
+			for _, r := range responses {
+				if r.Id == nil && (r.Error == nil || r.Error.Code != ErrInvalidRequest().Code) {
+					t.Errorf("bad entry should be Invalid Request, got %#v", r.Error)
+				}
+				if r.Id != nil && *r.Id == *Int64ID(4) && (r.Error == nil || r.Error.Code != ErrMethodNotFound().Code) {
+					t.Errorf("unknown method should be Method not found, got %#v", r.Error)
+				}
+			}
+			t.Logf("✅ ids = %v, elapsed = %v", got, elapsed)
+		})
+	}
+
+	t.Run("notifications", func(t *testing.T) {
+		batch := []json.RawMessage{
+			[]byte(`{"jsonrpc": "2.0", "method": "sleep", "params": 1}`),
+			[]byte(`{"jsonrpc": "2.0", "method": "sleep", "params": 2, "id": 2}`),
+			[]byte(`{"jsonrpc": "2.0", "method": "nope", "params": 3}`),
+		}
+		for _, parallelism := range []int{0, 2} {
+			s := newBatchTestServer(t).WithBatchParallelism(parallelism)
+			got := batchIds(s.ServeBatch(context.Background(), batch))
+			if len(got) != 1 || got[0] != 2 {
+				t.Errorf("❌ parallelism %d: ids = %v, want [2]", parallelism, got)
+			}
+			if responses := s.ServeBatch(context.Background(), batch[:1]); len(responses) != 0 {
+				t.Errorf("❌ parallelism %d: %d responses to notifications only", parallelism, len(responses))
+			}
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		responses := newBatchTestServer(t).ServeBatch(context.Background(), nil)
+		if len(responses) != 1 || responses[0].Error == nil || responses[0].Error.Code != ErrInvalidRequest().Code {
+			t.Errorf("empty batch should be a single Invalid Request, got %v", responses)
+		}
+	})
+}
+
+func Test_HttpServerTransport_batch(t *testing.T) {
+	st := NewHttpServerTransport("")
+	st.Use(newBatchTestServer(t).WithBatchParallelism(2))
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	doPost := func(body string) string {
+		resp, err := http.Post(ts.URL, "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bytes.TrimSpace(b))
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"batch",
+			`[{"jsonrpc": "2.0", "method": "sleep", "params": 2, "id": 1}, {"jsonrpc": "2.0", "method": "sleep", "params": 1, "id": 2}]`,
+			`[{"jsonrpc":"2.0","result":2,"id":1},{"jsonrpc":"2.0","result":1,"id":2}]`},
+		{"invalidBatch",
+			`[1]`,
+			`[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":{"reason":"json: cannot unmarshal number into Go value of type jsonrpc2.Request"}},"id":null}]`},
+		{"emptyBatch",
+			`[]`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":{"reason":"empty batch"}},"id":null}`},
+		{"badJson",
+			`[{"jsonrpc": "2.0", "method"`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error","data":{"reason":"unexpected end of JSON input"}},"id":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doPost(tt.body); got != tt.want {
+				t.Errorf("❌\ngot  = %s\nwant = %s\n", got, tt.want)
+			} else {
+				t.Logf("✅ got  = %s\n", got)
+			}
+		})
+	}
+}
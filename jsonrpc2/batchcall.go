@@ -0,0 +1,213 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// BatchCall is a call of Client.CallBatch.
+type BatchCall struct {
+	Method string
+	Arg    any
+	Ret    any // where to parse the result into, may be nil
+}
+
+// BatchResult is the outcome of a BatchCall: Error is nil if the call
+// succeeded, and its result is parsed into the Ret of the call.
+type BatchResult struct {
+	Error error
+}
+
+// BatchCallError is the error of a failed call of a batch, in a BatchError.
+type BatchCallError struct {
+	Index  int // of the call in the batch
+	Method string
+	Err    error
+}
+
+func (e *BatchCallError) Error() string {
+	return fmt.Sprintf("call %d (%s): %v", e.Index, e.Method, e.Err)
+}
+
+func (e *BatchCallError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError aggregates the errors of the failed calls of a batch,
+// see JoinBatchErrors. errors.Is and errors.As look into all of them.
+type BatchError struct {
+	Calls  int               // in the batch
+	Failed []*BatchCallError // in the order of the calls
+}
+
+// JoinBatchErrors returns a *BatchError of the failed calls of a batch,
+// given the calls and their results by Client.CallBatch, or nil if none failed:
+//
+//	results, err := c.CallBatch(calls)
+//	if err == nil {
+//		err = jsonrpc2.JoinBatchErrors(calls, results)
+//	}
+func JoinBatchErrors(calls []BatchCall, results []BatchResult) error {
+	e := &BatchError{Calls: len(calls)}
+	for i, r := range results {
+		if r.Error == nil {
+			continue
+		}
+		var method string
+		if i < len(calls) {
+			method = calls[i].Method
+		}
+		e.Failed = append(e.Failed, &BatchCallError{Index: i, Method: method, Err: r.Error})
+	}
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *BatchError) Error() string {
+	s := fmt.Sprintf("%d of %d calls of the batch failed: %v", len(e.Failed), e.Calls, e.Failed[0])
+	if len(e.Failed) > 1 {
+		s += fmt.Sprintf(" (and %d more)", len(e.Failed)-1)
+	}
+	return s
+}
+
+// Unwrap returns the *BatchCallError of each failed call.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f
+	}
+	return errs
+}
+
+// Err returns the error of the call of index i, nil if it succeeded.
+func (e *BatchError) Err(i int) error {
+	for _, f := range e.Failed {
+		if f.Index == i {
+			return f.Err
+		}
+	}
+	return nil
+}
+
+// BatchClientTransport is a ClientTransport able to send a batch of requests
+// at once. Client.CallBatch sends the requests one by one (simultaneously)
+// over transports not implementing it.
+type BatchClientTransport interface {
+	ClientTransport
+
+	// SendAndReceiveBatch sends the requests as a batch and returns the
+	// responses, in any order: they are matched to the requests by id.
+	SendAndReceiveBatch(ctx context.Context, reqs []*Request) ([]*Response, error)
+}
+
+// errNoResponse is the error of a call the server didn't respond to in a batch.
+var errNoResponse = errors.New("no response for the call in the batch")
+
+func (c *client) CallBatch(calls []BatchCall) ([]BatchResult, error) {
+	return c.CallBatchContext(context.Background(), calls)
+}
+
+func (c *client) CallBatchContext(ctx context.Context, calls []BatchCall) ([]BatchResult, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	results := make([]BatchResult, len(calls))
+
+	// build the requests, calls failing locally (e.g. invalid args) aren't sent
+	meta := outgoingMeta(ctx)
+	reqs := make([]*Request, 0, len(calls))
+	index := make(map[ID]int, len(calls)) // request id -> call index
+	for i, call := range calls {
+		req, err := c.newRequest(call.Method, call.Arg)
+		if err != nil {
+			results[i].Error = err
+			continue
+		}
+		req.Meta = meta
+		reqs = append(reqs, req)
+		index[*req.Id] = i
+	}
+	if len(reqs) == 0 {
+		return results, nil
+	}
+
+	responses, sendErrs, err := c.sendBatch(ctx, reqs)
+	if err != nil {
+		if ctx.Err() != nil {
+			for _, req := range reqs {
+				go c.cancelRemote(*req.Id)
+			}
+		}
+		return nil, err
+	}
+
+	answered := make(map[int]bool, len(reqs))
+	for _, resp := range responses {
+		if resp == nil || resp.Id == nil {
+			continue
+		}
+		i, ok := index[*resp.Id]
+		if !ok || answered[i] {
+			continue
+		}
+		answered[i] = true
+		results[i].Error = c.handleResponse(resp, calls[i].Ret)
+	}
+	for _, req := range reqs {
+		i := index[*req.Id]
+		switch {
+		case answered[i]:
+		case sendErrs[*req.Id] != nil:
+			results[i].Error = sendErrs[*req.Id]
+		default:
+			results[i].Error = fmt.Errorf("%w: %s (id %s)", errNoResponse, req.Method, req.Id)
+		}
+	}
+	return results, nil
+}
+
+// sendBatch sends reqs as a batch if the transport can, else one by one
+// simultaneously. Then the requests failing to be sent are left without a
+// response, their errors in sendErrs by id, unless all of them fail: the
+// batch fails as a whole.
+func (c *client) sendBatch(ctx context.Context, reqs []*Request) (responses []*Response, sendErrs map[ID]error, err error) {
+	if bt, ok := c.transport.(BatchClientTransport); ok {
+		retryable := true
+		for _, req := range reqs {
+			retryable = retryable && c.retry.retries(req.Method)
+		}
+		err = c.withRetries(ctx, retryable, func() error {
+			responses, err = bt.SendAndReceiveBatch(ctx, reqs)
+			return err
+		})
+		return responses, nil, err
+	}
+
+	responses = make([]*Response, len(reqs))
+	errs := make([]error, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req *Request) {
+			defer wg.Done()
+			responses[i], errs[i] = c.sendAndReceive(ctx, req)
+		}(i, req)
+	}
+	wg.Wait()
+
+	sendErrs = make(map[ID]error)
+	for i, err := range errs {
+		if err != nil {
+			sendErrs[*reqs[i].Id] = err
+		}
+	}
+	if len(sendErrs) == len(reqs) {
+		return nil, nil, errs[0]
+	}
+	return responses, sendErrs, nil
+}
@@ -0,0 +1,135 @@
+package jsonrpc2
+
+// 这个文件让 HttpServerTransport 可以直接被浏览器中的网页调用 (见 AllowOrigins 与 JSONP)，
+// 便于用静态网页调用演示服务。
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// jsonpCallback is what a JSONP callback name may look like: a JavaScript
+// identifier path, e.g. "cb" or "app.handlers.cb". Nothing else gets into the script.
+var jsonpCallback = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// serveBrowser handles the browser specific parts of r: CORS headers,
+// preflight requests and JSONP. It reports whether r is served already.
+//
+// A JSONP request is a GET like
+//
+//	/rpc?method=add&params=[1,2]&id=1&callback=cb
+//
+// answered with the response wrapped in a call to callback:
+//
+//	cb({"jsonrpc":"2.0","result":3,"id":1});
+func (t *HttpServerTransport) serveBrowser(w http.ResponseWriter, r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" && t.allowOrigin(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		if len(t.AllowOrigins) == 0 {
+			http.Error(w, "cross-origin requests not allowed", http.StatusMethodNotAllowed)
+			return true
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Max-Age", "86400")
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	case http.MethodGet:
+		if t.JSONP && r.URL.Query().Has("callback") {
+			t.serveJSONP(w, r)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *HttpServerTransport) allowOrigin(origin string) bool {
+	for _, allowed := range t.AllowOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// serveJSONP serves a JSONP GET request.
+func (t *HttpServerTransport) serveJSONP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	callback := q.Get("callback")
+	if !jsonpCallback.MatchString(callback) {
+		http.Error(w, "bad callback", http.StatusBadRequest)
+		return
+	}
+
+	jw := &jsonpWriter{ResponseWriter: w, callback: callback}
+	defer jw.close()
+
+	req := map[string]any{
+		"jsonrpc": JsonRpc2,
+		"method":  q.Get("method"),
+	}
+	if params := q.Get("params"); params != "" {
+		if !json.Valid([]byte(params)) {
+			_ = writeJsonResponse(jw, errorResponse(nil, ErrParseError().WithReason("params should be JSON")))
+			return
+		}
+		req["params"] = json.RawMessage(params)
+	}
+	if id := q.Get("id"); id != "" {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			http.Error(w, "bad id", http.StatusBadRequest)
+			return
+		}
+		req["id"] = n
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	t.serveBody(jw, r, body)
+}
+
+// jsonpWriter wraps what's written in a call to callback.
+type jsonpWriter struct {
+	http.ResponseWriter
+	callback string
+	started  bool
+}
+
+func (w *jsonpWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, _ = w.ResponseWriter.Write([]byte("/**/" + w.callback + "("))
+}
+
+func (w *jsonpWriter) Write(p []byte) (int, error) {
+	w.start()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *jsonpWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *jsonpWriter) close() {
+	if w.started {
+		_, _ = w.ResponseWriter.Write([]byte(");"))
+	}
+}
@@ -0,0 +1,84 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func Test_HttpServerTransport_browser(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil })
+
+	st := NewHttpServerTransport("")
+	st.AllowOrigins = []string{"https://demo.example.com"}
+	st.JSONP = true
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	do := func(method, target, origin, contentType, body string) (*http.Response, string) {
+		req, err := http.NewRequest(method, ts.URL+target, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, string(bytes.TrimSpace(b))
+	}
+
+	jsonp := func(q url.Values) string {
+		return "/?" + q.Encode()
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		origin     string
+		body       string
+		wantStatus int
+		wantAllow  string
+		wantBody   string
+	}{
+		{"simpleRequest", "POST", "/", "https://demo.example.com",
+			`{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}`,
+			200, "https://demo.example.com", `{"jsonrpc":"2.0","result":3,"id":1}`},
+		{"otherOrigin", "POST", "/", "https://evil.example.com",
+			`{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}`,
+			200, "", `{"jsonrpc":"2.0","result":3,"id":1}`},
+		{"preflight", "OPTIONS", "/", "https://demo.example.com", ``,
+			204, "https://demo.example.com", ``},
+		{"jsonp", "GET", jsonp(url.Values{"method": {"add"}, "params": {"[1,2]"}, "id": {"1"}, "callback": {"app.cb"}}), "", ``,
+			200, "", `/**/app.cb({"jsonrpc":"2.0","result":3,"id":1}` + "\n);"},
+		{"jsonpBadParams", "GET", jsonp(url.Values{"method": {"add"}, "params": {"[1,"}, "id": {"1"}, "callback": {"cb"}}), "", ``,
+			200, "", `/**/cb({"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error","data":{"reason":"params should be JSON"}},"id":null}` + "\n);"},
+		{"jsonpBadCallback", "GET", jsonp(url.Values{"method": {"add"}, "params": {"[1,2]"}, "id": {"1"}, "callback": {"alert(1)//"}}), "", ``,
+			400, "", `bad callback`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := do(tt.method, tt.target, tt.origin, "text/plain", tt.body)
+			allow := resp.Header.Get("Access-Control-Allow-Origin")
+			if resp.StatusCode != tt.wantStatus || allow != tt.wantAllow || body != tt.wantBody {
+				t.Errorf("❌ got %d, allow=%q, %s\nwant %d, allow=%q, %s",
+					resp.StatusCode, allow, body, tt.wantStatus, tt.wantAllow, tt.wantBody)
+			} else {
+				t.Logf("✅ %d, allow=%q, %s", resp.StatusCode, allow, body)
+			}
+		})
+	}
+}
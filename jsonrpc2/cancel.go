@@ -0,0 +1,111 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// MethodCancel is the built-in method cancelling an in-flight request,
+// sent by Client.CallContext when its ctx is done before the response arrives.
+//
+// The params is CancelParams, the result tells whether the request was found
+// (it may have finished already). The context of the cancelled request is
+// cancelled: methods taking a context.Context can stop their work, and
+// requests still waiting for WithMaxConcurrency are dropped.
+const MethodCancel = "rpc.cancel"
+
+// CancelParams is the params of MethodCancel.
+type CancelParams struct {
+	Id ID `json:"id"` // id of the request to cancel
+}
+
+// inflight tracks the in-flight requests to cancel them by id.
+//
+// Ids are only unique per client, so requests are keyed by their sender
+// as well (see senderOf): a client can only cancel its own requests.
+type inflight struct {
+	mu      sync.Mutex
+	cancels map[inflightKey]*inflightEntry
+}
+
+type inflightKey struct {
+	sender string
+	id     ID
+}
+
+type inflightEntry struct {
+	cancel context.CancelFunc
+}
+
+type senderKey struct{}
+
+// senderOf tells who sent req, served in ctx: the client session of
+// Request.Client, else the connection of a transport keeping one for all
+// the requests of a client, else the remote host, if known.
+func senderOf(ctx context.Context, req *Request) string {
+	if req.Client != "" {
+		return "client:" + req.Client
+	}
+	info, ok := TransportInfoFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	// the requests of a client over HTTP may come by several connections
+	if info.ConnID != 0 && info.Kind != "http" {
+		return "conn:" + strconv.FormatUint(info.ConnID, 10)
+	}
+	if info.RemoteAddr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(info.RemoteAddr.String())
+	if err != nil {
+		return "host:" + info.RemoteAddr.String()
+	}
+	return "host:" + host
+}
+
+// track makes ctx of req cancellable by its id until done is called. The
+// ctx returned tells the sender of req, for cancelRequest.
+func (f *inflight) track(ctx context.Context, req *Request) (_ context.Context, done func()) {
+	sender := senderOf(ctx, req)
+	ctx = context.WithValue(ctx, senderKey{}, sender)
+	ctx, cancel := context.WithCancel(ctx)
+	key := inflightKey{sender, *req.Id}
+	entry := &inflightEntry{cancel}
+
+	f.mu.Lock()
+	if f.cancels == nil {
+		f.cancels = make(map[inflightKey]*inflightEntry)
+	}
+	f.cancels[key] = entry
+	f.mu.Unlock()
+
+	return ctx, func() {
+		f.mu.Lock()
+		if f.cancels[key] == entry { // not another request of the same key
+			delete(f.cancels, key)
+		}
+		f.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancel the request id from sender. Returns false if there is no such request.
+func (f *inflight) cancel(sender string, id ID) bool {
+	f.mu.Lock()
+	entry, ok := f.cancels[inflightKey{sender, id}]
+	f.mu.Unlock()
+
+	if ok {
+		entry.cancel()
+	}
+	return ok
+}
+
+// cancelRequest is the MethodCancel method.
+func (s *server) cancelRequest(ctx context.Context, params *CancelParams) (bool, error) {
+	sender, _ := ctx.Value(senderKey{}).(string)
+	return s.inflight.cancel(sender, params.Id), nil
+}
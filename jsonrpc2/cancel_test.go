@@ -0,0 +1,282 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_server_cancel(t *testing.T) {
+	s := NewServer()
+	started := make(chan struct{})
+	err := s.Register("wait", func(ctx context.Context, arg int) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := func(remote string) context.Context {
+		return WithTransportInfo(context.Background(), &TransportInfo{Kind: "test", RemoteAddr: addr{"tcp", remote}})
+	}
+
+	done := make(chan *Response)
+	go func() {
+		done <- s.ServeRPC(from("10.0.0.1:1234"), &Request{JsonRpc: JsonRpc2, Method: "wait", Params: []byte(`1`), Id: Int64ID(1)})
+	}()
+	<-started
+
+	cancel := func(remote string, id int64) *Response {
+		return s.ServeRPC(from(remote), &Request{JsonRpc: JsonRpc2, Method: MethodCancel,
+			Params: []byte(fmt.Sprintf(`{"id":%d}`, id)), Id: Int64ID(100)})
+	}
+
+	// other hosts can't cancel it
+	if resp := cancel("10.0.0.2:1234", 1); string(resp.Result) != "false" {
+		t.Errorf("cancel from other host = %s, want false", resp.Result)
+	}
+	// unknown id
+	if resp := cancel("10.0.0.1:5678", 2); string(resp.Result) != "false" {
+		t.Errorf("cancel of unknown id = %s, want false", resp.Result)
+	}
+	// the same host, maybe another connection
+	if resp := cancel("10.0.0.1:5678", 1); string(resp.Result) != "true" {
+		t.Errorf("cancel = %s, want true", resp.Result)
+	}
+
+	select {
+	case resp := <-done:
+		if resp.Error == nil || resp.Error.Code != ErrRequestCancelled().Code {
+			t.Errorf("want ErrRequestCancelled, got %#v", resp.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the request was not cancelled")
+	}
+}
+
+func Test_server_cancel_senders(t *testing.T) {
+	s := NewServer()
+	started := make(chan struct{}, 1)
+	s.MustRegister("wait", func(ctx context.Context, arg int) (int, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	// the requests of two clients behind the same host, and of two
+	// connections, all with the id 1
+	host := &TransportInfo{Kind: "http", RemoteAddr: addr{"tcp", "10.0.0.1:1234"}, ConnID: 1}
+	conn := func(id uint64) *TransportInfo {
+		return &TransportInfo{Kind: "tcp", RemoteAddr: addr{"tcp", "10.0.0.2:1234"}, ConnID: id}
+	}
+	senders := []struct {
+		name   string
+		info   *TransportInfo
+		client string
+	}{
+		{"clientA", host, "a"},
+		{"clientB", host, "b"},
+		{"conn1", conn(1), ""},
+		{"conn2", conn(2), ""},
+	}
+	done := make([]chan *Response, len(senders))
+	for i, sender := range senders {
+		done[i] = make(chan *Response, 1)
+		ctx := WithTransportInfo(context.Background(), sender.info)
+		req := &Request{JsonRpc: JsonRpc2, Method: "wait", Params: []byte(`1`), Id: Int64ID(1), Client: sender.client}
+		go func(i int) { done[i] <- s.ServeRPC(ctx, req) }(i)
+		<-started
+	}
+
+	// each sender cancels its own request only
+	for i, sender := range senders {
+		ctx := WithTransportInfo(context.Background(), sender.info)
+		resp := s.ServeRPC(ctx, &Request{JsonRpc: JsonRpc2, Method: MethodCancel, Params: []byte(`{"id":1}`), Id: Int64ID(2), Client: sender.client})
+		if string(resp.Result) != "true" {
+			t.Errorf("❌ %s: cancel = %s, want true", sender.name, resp.Result)
+		}
+		select {
+		case <-done[i]:
+		case <-time.After(time.Second):
+			t.Fatalf("❌ %s: not cancelled", sender.name)
+		}
+		for j := i + 1; j < len(done); j++ {
+			select {
+			case <-done[j]:
+				t.Fatalf("❌ %s cancelled by %s", senders[j].name, sender.name)
+			default:
+			}
+		}
+	}
+}
+
+func Test_inflight_done(t *testing.T) {
+	var f inflight
+	req := &Request{JsonRpc: JsonRpc2, Method: "wait", Id: Int64ID(1), Client: "a"}
+
+	// a request done does not forget another one of the same key
+	_, done1 := f.track(context.Background(), req)
+	ctx2, done2 := f.track(context.Background(), req)
+	defer done2()
+	done1()
+	if !f.cancel("client:a", *req.Id) || ctx2.Err() == nil {
+		t.Error("❌ the second request is not cancellable once the first is done")
+	}
+}
+
+func Test_client_CallContext_cancel(t *testing.T) {
+	s := NewServer()
+	events := s.Events()
+
+	cancelled := make(chan struct{})
+	err := s.Register("wait", func(ctx context.Context, arg int) (int, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return 0, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	cli := NewClient(NewHttpClientTransport(ts.URL))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = cli.CallContext(ctx, "wait", 1, new(int))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CallContext() error = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CallContext() returned after %v", elapsed)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("server-side work was not cancelled")
+	}
+
+	// the client asks the server to cancel, too
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Kind == EventRequestStarted && e.Method == MethodCancel {
+				return
+			}
+		case <-timeout:
+			t.Fatal("no rpc.cancel received")
+		}
+	}
+}
+
+func Test_client_WithTimeout(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("sleep", func(ctx context.Context, ms int) (int, error) {
+		select {
+		case <-time.After(time.Duration(ms) * time.Millisecond):
+			return ms, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	})
+
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ctx     time.Duration // deadline of the ctx, 0: none
+		sleep   int
+		wantErr error
+	}{
+		{"noTimeout", 0, 0, 80, nil},
+		{"timeout", 30 * time.Millisecond, 0, 1000, context.DeadlineExceeded},
+		{"ctxDeadlineFirst", 30 * time.Millisecond, 500 * time.Millisecond, 80, nil},
+	}
+	c := NewClient(NewHttpClientTransport(ts.URL)) // one client: unique ids
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.WithTimeout(tt.timeout)
+
+			ctx := context.Background()
+			if tt.ctx > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctx)
+				defer cancel()
+			}
+
+			var ret int
+			err := c.CallContext(ctx, "sleep", tt.sleep, &ret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("❌ err = %v, want %v", err, tt.wantErr)
+			}
+			t.Logf("✅ ret = %d, err = %v", ret, err)
+		})
+	}
+}
+
+// Test_HttpServerTransport_requestContext checks that the context of the
+// HTTP request reaches methods taking a context.Context: it's cancelled
+// once the client is gone, even if it sends no rpc.cancel.
+func Test_HttpServerTransport_requestContext(t *testing.T) {
+	type waitArg struct{ N int }
+	type waitRet struct{ N int }
+
+	s := NewServer()
+	got := make(chan error, 1)
+	s.MustRegister("wait", func(ctx context.Context, arg *waitArg) (*waitRet, error) {
+		if info, ok := TransportInfoFromContext(ctx); !ok || info.Kind != "http" {
+			got <- fmt.Errorf("no http TransportInfo in ctx: %v", info)
+			return nil, nil
+		}
+		<-ctx.Done()
+		got <- ctx.Err()
+		return &waitRet{N: arg.N}, nil
+	})
+
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	body := `{"jsonrpc":"2.0","method":"wait","params":{"N":1},"id":1}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatal("❌ the request should time out")
+	}
+
+	select {
+	case err := <-got:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("❌ method ctx: %v, want context.Canceled", err)
+		}
+		t.Logf("✅ method ctx: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("❌ the method ctx was not cancelled")
+	}
+}
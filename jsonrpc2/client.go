@@ -1,9 +1,15 @@
 package jsonrpc2
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"sync/atomic"
+	"time"
 )
 
 // TODO: client RPC 业务逻辑 和 传输层、编码层 分离
@@ -11,53 +17,445 @@ import (
 type Client interface {
 	// Call a remote method with arg and return the result in ret.
 	Call(method string, arg any, ret any) error
+
+	// CallContext is Call with a ctx to set a deadline or cancel the call.
+	//
+	// If ctx is done before the response arrives, the call returns at once
+	// and the server is asked to stop working on it as well, by a MethodCancel
+	// request (ignored by servers that don't support it).
+	CallContext(ctx context.Context, method string, arg any, ret any) error
+
+	// CallStream calls a streaming method (see StreamFunc) with arg,
+	// calling each with the values it sends, in order, as they come over
+	// transports implementing StreamingClientTransport, else all at once
+	// when the call is done. If each fails, the call is given up (and the
+	// server asked to cancel it), returning the error of each.
+	//
+	// The calls are not retried, for the values are delivered already.
+	CallStream(ctx context.Context, method string, arg any, each func(value json.RawMessage) error) error
+
+	// Subscribe subscribes to the topic of the server (see Server.Topic),
+	// calling handler with its messages, in order, until unsubscribe is
+	// called. Subscriptions are renewed on the connections redialed; the
+	// messages published meanwhile are lost.
+	//
+	// The transport must implement EventClientTransport, e.g. a
+	// StreamClientTransport, whose handlers must return quickly.
+	Subscribe(topic string, handler func(message json.RawMessage)) (unsubscribe func() error, err error)
+
+	// WithTimeout sets the default timeout of the calls (and batches and
+	// notifications) whose ctx has no deadline, including those of Call.
+	// A deadline of the ctx, shorter or longer, takes precedence.
+	// d <= 0 (the default) means no timeout.
+	WithTimeout(d time.Duration) Client
+
+	// WithRetry makes calls (and batches) of the methods failing with a
+	// transport error, e.g. a connection reset, be retried, up to
+	// maxAttempts attempts in all, waiting backoff before the first retry,
+	// doubled for every next one. Errors of the server (*Error) and of ctx
+	// are not retried, nor are notifications.
+	//
+	// A request may have reached the server before the error, so only the
+	// calls of the methods named are retried, as names or patterns (see
+	// Server.Register): the idempotent ones, or "*" for all of them if the
+	// server is WithAtMostOnce, which deduplicates the retries by their
+	// ids (they are resent with the same id). A batch is retried only if
+	// all its methods are. No methods, or maxAttempts <= 1 (the default),
+	// means no retries.
+	WithRetry(maxAttempts int, backoff time.Duration, methods ...string) Client
+
+	// WithLogger sets the Logger of the client: every call (method, id,
+	// duration and error) at LevelDebug, and the retries at LevelWarn.
+	// It logs the failures of the transport as well, unless the transport
+	// has a Logger of its own (e.g. StreamClientTransport.Logger).
+	// nil (the default) means a StdLogger.
+	WithLogger(l Logger) Client
+
+	// WithSchemas makes the client validate the args of calls against the
+	// params schemas of the methods in doc (see DiscoverSchemas and
+	// LoadSchemas) before sending: invalid args fail at once with an
+	// ErrInvalidParams, detailed like the server would, without a network
+	// round trip. Methods not in doc are sent as they are.
+	// nil (the default) turns validation off.
+	WithSchemas(doc *DiscoverResult) Client
+
+	// OnErrorCode makes calls failing with an *Error of code return what
+	// translate makes of it instead, e.g. an application sentinel error:
+	//
+	//	c.OnErrorCode(-32601, func(e *jsonrpc2.Error) error { return ErrNotSupported })
+	//
+	// translate may wrap e to keep its details (fmt.Errorf("...: %w", e)).
+	// If it returns nil, the *Error is returned as it is.
+	// A later translator of the same code replaces the earlier one.
+	OnErrorCode(code int, translate func(*Error) error) Client
+
+	// CallBatch makes all the calls in a single batch request, i.e. one
+	// round trip over transports supporting it (see BatchClientTransport),
+	// and returns their results in the same order as the calls.
+	//
+	// The error is for the batch as a whole (e.g. the server unreachable);
+	// each call fails or succeeds on its own in its BatchResult.
+	CallBatch(calls []BatchCall) ([]BatchResult, error)
+
+	// CallBatchContext is CallBatch with a ctx to set a deadline or cancel the batch.
+	CallBatchContext(ctx context.Context, calls []BatchCall) ([]BatchResult, error)
+
+	// Notify a remote method with arg: send a request without id, to which
+	// the server responds nothing. The method is executed with no way to
+	// know its result, or even whether it exists.
+	//
+	// The transport must implement NotifyClientTransport.
+	Notify(method string, arg any) error
+
+	// NotifyContext is Notify with a ctx to set a deadline or cancel the sending.
+	NotifyContext(ctx context.Context, method string, arg any) error
+
+	// Close closes the transport, if it's an io.Closer, releasing its
+	// connections, e.g. the idle ones of an HttpClientTransport.
+	Close() error
 }
 
+// NotifyClientTransport is a ClientTransport able to send notifications,
+// which are responded nothing.
+type NotifyClientTransport interface {
+	ClientTransport
+
+	// Notify sends the notification req, without waiting for a response.
+	Notify(ctx context.Context, req *Request) error
+}
+
+// ErrNotifyUnsupported is returned by Client.Notify over transports not
+// implementing NotifyClientTransport.
+var ErrNotifyUnsupported = errors.New("jsonrpc2: the transport can't send notifications")
+
 type client struct {
 	transport ClientTransport
+	identity  string // the Client of the requests, see newClientIdentity
 	nextId    atomic.Int64
+	schemas   map[string]*Schema // params schemas by method, nil: no validation
+
+	translators map[int]func(*Error) error // by error code, see OnErrorCode
+	timeout     time.Duration              // default of the calls, 0: none
+	retry       retryPolicy
+	logger      Logger // nil: defaultLogger
+}
+
+// retryPolicy is how calls failing with transport errors are retried.
+// The zero retryPolicy makes no retries.
+type retryPolicy struct {
+	attempts int           // in all, the first one included
+	backoff  time.Duration // before the first retry, doubled for every next one
+	names    map[string]bool
+	patterns []*methodPattern
+}
+
+func newRetryPolicy(attempts int, backoff time.Duration, methods []string) retryPolicy {
+	p := retryPolicy{attempts: attempts, backoff: backoff, names: make(map[string]bool)}
+	for _, method := range methods {
+		if isPattern(method) {
+			if pattern, err := compilePattern(method); err == nil {
+				p.patterns = append(p.patterns, pattern)
+				continue
+			}
+		}
+		p.names[method] = true
+	}
+	return p
+}
+
+// retries tells whether the calls of the method are retried.
+func (p *retryPolicy) retries(method string) bool {
+	if p.names[method] {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if _, ok := pattern.match(method); ok {
+			return true
+		}
+	}
+	return false
 }
 
 func NewClient(transport ClientTransport) Client {
 	return &client{
 		transport: transport,
+		identity:  newClientIdentity(),
+	}
+}
+
+// newClientIdentity makes the identity of a client session: a random
+// (version 4) UUID, so that the ids of clients don't collide on servers
+// WithAtMostOnce. It's empty in the unlikely case the system has no
+// randomness to offer, leaving the requests unscoped.
+func newClientIdentity() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return ""
+	}
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// WithSchemas 原址设置用于校验参数的 schema，并返回 Client 以供链式
+func (c *client) WithSchemas(doc *DiscoverResult) Client {
+	if doc == nil {
+		c.schemas = nil
+		return c
+	}
+	c.schemas = make(map[string]*Schema, len(doc.Methods))
+	for _, m := range doc.Methods {
+		if len(m.Params) > 0 && m.Params[0].Schema != nil {
+			c.schemas[m.Name] = m.Params[0].Schema
+		}
 	}
+	return c
+}
+
+// WithRetry 原址设置传输错误的重试策略，并返回 Client 以供链式
+func (c *client) WithRetry(maxAttempts int, backoff time.Duration, methods ...string) Client {
+	c.retry = newRetryPolicy(maxAttempts, backoff, methods)
+	return c
+}
+
+// WithLogger 原址设置 Logger，并返回 Client 以供链式
+func (c *client) WithLogger(l Logger) Client {
+	c.logger = l
+	if t, ok := c.transport.(interface{ useLogger(Logger) }); ok && l != nil {
+		t.useLogger(l)
+	}
+	return c
+}
+
+// WithTimeout 原址设置调用的默认超时，并返回 Client 以供链式
+func (c *client) WithTimeout(d time.Duration) Client {
+	c.timeout = d
+	return c
+}
+
+// withTimeout bounds ctx by the default timeout, unless it has a deadline.
+func (c *client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// OnErrorCode 原址设置错误码的翻译函数，并返回 Client 以供链式
+func (c *client) OnErrorCode(code int, translate func(*Error) error) Client {
+	if c.translators == nil {
+		c.translators = make(map[int]func(*Error) error)
+	}
+	c.translators[code] = translate
+	return c
+}
+
+// translate the *Error e by the translator of its code, if any.
+func (c *client) translate(e *Error) error {
+	if f, ok := c.translators[e.Code]; ok && f != nil {
+		if err := f(e); err != nil {
+			return err
+		}
+	}
+	return e
 }
 
 func (c *client) Call(method string, arg any, ret any) error {
-	// arg -> json
-	if arg == nil {
-		return errors.New("arg is nil")
+	return c.CallContext(context.Background(), method, arg, ret)
+}
+
+func (c *client) CallContext(ctx context.Context, method string, arg any, ret any) (err error) {
+	req, err := c.newRequest(method, arg)
+	if err != nil {
+		return err
 	}
+	start := time.Now()
+	defer func() {
+		loggerOr(c.logger).Log(LevelDebug, "call", Field{"method", method}, Field{"id", formatId(req.Id)},
+			Field{"duration", time.Since(start)}, Field{"error", err})
+	}()
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req.Meta = outgoingMeta(ctx)
 
-	argJson, err := json.Marshal(arg)
+	// remote procedure call
+	rpcResp, err := c.sendAndReceive(ctx, req)
 	if err != nil {
+		if ctx.Err() != nil {
+			go c.cancelRemote(*req.Id)
+		}
 		return err
 	}
 
-	// build request
+	fillCallInfo(ctx, rpcResp)
+	return c.handleResponse(rpcResp, ret)
+}
+
+func (c *client) CallStream(ctx context.Context, method string, arg any, each func(value json.RawMessage) error) (err error) {
+	req, err := c.newRequest(method, arg)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	defer func() {
+		loggerOr(c.logger).Log(LevelDebug, "call", Field{"method", method}, Field{"id", formatId(req.Id)},
+			Field{"duration", time.Since(start)}, Field{"error", err})
+	}()
 
-	id := c.nextId.Add(1)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req.Meta = outgoingMeta(ctx)
 
-	req := Request{
-		JsonRpc: JsonRpc2,
-		Method:  method,
-		Params:  argJson,
-		Id:      &id,
+	var eachErr error
+	eachValue := func(value json.RawMessage) error {
+		eachErr = each(value)
+		return eachErr
 	}
-	if err := req.validate(); err != nil {
+
+	var rpcResp *Response
+	if st, ok := c.transport.(StreamingClientTransport); ok {
+		rpcResp, err = st.SendAndStream(ctx, req, eachValue)
+	} else {
+		rpcResp, err = c.transport.SendAndReceive(ctx, req)
+	}
+	if err != nil {
+		if ctx.Err() != nil || eachErr != nil {
+			go c.cancelRemote(*req.Id)
+		}
 		return err
 	}
 
-	// remote procedure call
-	rpcResp, err := c.transport.SendAndReceive(&req)
+	fillCallInfo(ctx, rpcResp)
+	if rpcResp.Error != nil {
+		return c.translate(rpcResp.Error)
+	}
+	if len(rpcResp.Result) == 0 || string(rpcResp.Result) == "null" {
+		return nil // streamed already
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(rpcResp.Result, &values); err != nil {
+		return fmt.Errorf("streaming result should be an array: %w", err)
+	}
+	for _, value := range values {
+		if err := each(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendAndReceive req by the transport, retrying transport errors as the
+// retry policy says. Retries resend req as it is, with the same id, so that
+// a server deduplicating requests (see Server.WithAtMostOnce) executes it
+// at most once even if an attempt reached it.
+func (c *client) sendAndReceive(ctx context.Context, req *Request) (resp *Response, err error) {
+	err = c.withRetries(ctx, c.retry.retries(req.Method), func() error {
+		resp, err = c.transport.SendAndReceive(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// withRetries calls send until it succeeds, fails with an *Error or ctx is
+// done, at most c.retry.attempts times, backing off between the attempts.
+// It calls send once only, if not retryable.
+func (c *client) withRetries(ctx context.Context, retryable bool, send func() error) error {
+	backoff := c.retry.backoff
+	for attempt := 1; ; attempt++ {
+		err := send()
+		var rpcErr *Error
+		if err == nil || !retryable || attempt >= c.retry.attempts || ctx.Err() != nil || errors.As(err, &rpcErr) {
+			return err
+		}
+
+		loggerOr(c.logger).Log(LevelWarn, "retrying", Field{"attempt", attempt + 1}, Field{"backoff", backoff}, Field{"error", err})
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *client) Close() error {
+	if closer, ok := c.transport.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *client) Notify(method string, arg any) error {
+	return c.NotifyContext(context.Background(), method, arg)
+}
+
+func (c *client) NotifyContext(ctx context.Context, method string, arg any) error {
+	nt, ok := c.transport.(NotifyClientTransport)
+	if !ok {
+		return ErrNotifyUnsupported
+	}
+
+	req, err := c.newRequest(method, arg)
 	if err != nil {
 		return err
 	}
+	req.Id = nil
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req.Meta = outgoingMeta(ctx)
+
+	return nt.Notify(ctx, req)
+}
+
+// newRequest builds the request calling method with arg, validating arg
+// against the schema of method, if any (see WithSchemas).
+func (c *client) newRequest(method string, arg any) (*Request, error) {
+	// arg -> json
+	if arg == nil {
+		return nil, errors.New("arg is nil")
+	}
 
+	params, err := c.marshalValue(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema, ok := c.schemas[method]; ok {
+		argJson := params
+		if _, ok := c.transport.(valueCodec); ok {
+			if argJson, err = json.Marshal(arg); err != nil {
+				return nil, err
+			}
+		}
+		if err := schema.Validate(argJson); err != nil {
+			return nil, c.translate(ErrInvalidParams().WithReason(err.Error()))
+		}
+	}
+
+	// build request
+
+	req := &Request{
+		JsonRpc: JsonRpc2,
+		Method:  method,
+		Params:  params,
+		Id:      Int64ID(c.nextId.Add(1)),
+		Client:  c.identity,
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// handleResponse returns the error of rpcResp, or parses its result into ret.
+func (c *client) handleResponse(rpcResp *Response, ret any) error {
 	// case 0: rpc error
 	if rpcResp.Error != nil {
-		return rpcResp.Error
+		return c.translate(rpcResp.Error)
 	}
 
 	// case 1: rpc success
@@ -70,9 +468,66 @@ func (c *client) Call(method string, arg any, ret any) error {
 		return errors.New("result should not be nil")
 	}
 
+	if vc, ok := c.transport.(valueCodec); ok {
+		return vc.unmarshalValue(rpcResp.Result, ret)
+	}
 	if err := rpcResp.unmarshalResult(ret); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// marshalValue encodes v (an arg) for the transport:
+// by JSON, unless the transport is a valueCodec.
+func (c *client) marshalValue(v any) ([]byte, error) {
+	if vc, ok := c.transport.(valueCodec); ok {
+		return vc.marshalValue(v)
+	}
+	return json.Marshal(v)
+}
+
+// cancelTimeout bounds how long the client tries to deliver a MethodCancel.
+const cancelTimeout = 5 * time.Second
+
+// cancelRemote asks the server to cancel the in-flight request id.
+// It's best-effort: errors (including servers not knowing MethodCancel) are ignored.
+func (c *client) cancelRemote(id ID) {
+	params, _ := c.marshalValue(CancelParams{Id: id})
+	cancelId := Int64ID(c.nextId.Add(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+
+	_, _ = c.transport.SendAndReceive(ctx, &Request{
+		JsonRpc: JsonRpc2,
+		Method:  MethodCancel,
+		Params:  params,
+		Id:      cancelId,
+		Client:  c.identity,
+	})
+}
+
+// DiscoverSchemas gets the OpenRPC document of the server of c by MethodDiscover,
+// e.g. for Client.WithSchemas.
+func DiscoverSchemas(ctx context.Context, c Client) (*DiscoverResult, error) {
+	var doc DiscoverResult
+	if err := c.CallContext(ctx, MethodDiscover, json.RawMessage(`null`), &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// LoadSchemas reads an OpenRPC document (like the result of MethodDiscover)
+// from a local file, e.g. for Client.WithSchemas.
+func LoadSchemas(path string) (*DiscoverResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc DiscoverResult
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("bad OpenRPC document %s: %w", path, err)
+	}
+	return &doc, nil
+}
@@ -1,9 +1,21 @@
 package jsonrpc2
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_client_Call(t *testing.T) {
@@ -97,3 +109,404 @@ func Test_client_Call(t *testing.T) {
 	}
 	close(chDoneTest)
 }
+
+// serverTransport serves requests with a Server in process, counting them.
+type serverTransport struct {
+	server Server
+	sent   atomic.Int64 // by the batches sent in parallel, too
+}
+
+func (t *serverTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
+	t.sent.Add(1)
+	return t.server.ServeRPC(ctx, req), nil
+}
+
+func Test_client_WithSchemas(t *testing.T) {
+	type Point struct{ X, Y int }
+
+	s := NewServer()
+	s.MustRegister("norm1", func(p Point) (int, error) { return p.X + p.Y, nil })
+	transport := &serverTransport{server: s}
+	c := NewClient(transport)
+
+	doc, err := DiscoverSchemas(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// round trip through a local file
+	path := filepath.Join(t.TempDir(), "openrpc.json")
+	data, _ := json.Marshal(doc)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err = LoadSchemas(path); err != nil {
+		t.Fatal(err)
+	}
+	c.WithSchemas(doc)
+
+	tests := []struct {
+		name     string
+		method   string
+		arg      any
+		wantSent bool
+		wantErr  bool
+	}{
+		{"valid", "norm1", Point{1, 2}, true, false},
+		{"invalid", "norm1", map[string]any{"X": "1"}, false, true},
+		{"unknown method", "nope", 1, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport.sent.Store(0)
+			var ret int
+			err := c.Call(tt.method, tt.arg, &ret)
+			if (err != nil) != tt.wantErr || (transport.sent.Load() > 0) != tt.wantSent {
+				t.Errorf("❌ err = %v, sent = %d; want error: %v, sent: %v", err, transport.sent.Load(), tt.wantErr, tt.wantSent)
+			} else {
+				t.Logf("✅ err = %v, sent = %d", err, transport.sent.Load())
+			}
+		})
+	}
+
+	var rpcErr *Error
+	err = c.Call("norm1", map[string]any{"X": "1"}, nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != ErrInvalidParams().Code {
+		t.Errorf("❌ invalid args: err = %v, want ErrInvalidParams", err)
+	}
+}
+
+func Test_client_OnErrorCode(t *testing.T) {
+	errNotSupported := errors.New("not supported")
+
+	s := NewServer()
+	s.MustRegister("fail", func(arg int) (int, error) { return 0, errors.New("boom") })
+	c := NewClient(&serverTransport{server: s}).
+		OnErrorCode(ErrMethodNotFound().Code, func(e *Error) error { return errNotSupported }).
+		OnErrorCode(-1, func(e *Error) error { return fmt.Errorf("failed: %w", e) }).
+		OnErrorCode(ErrInvalidParams().Code, func(e *Error) error { return nil })
+
+	tests := []struct {
+		name     string
+		method   string
+		arg      any
+		wantErr  error
+		wantCode int // of the *Error in the chain, 0 for none
+	}{
+		{"sentinel", "nope", 1, errNotSupported, 0},
+		{"wrapped", "fail", 1, nil, -1},
+		{"nil translation keeps the error", "fail", "x", nil, ErrInvalidParams().Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.Call(tt.method, tt.arg, nil)
+			var rpcErr *Error
+			code := 0
+			if errors.As(err, &rpcErr) {
+				code = rpcErr.Code
+			}
+			if (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) || code != tt.wantCode {
+				t.Errorf("❌ err = %v, want %v with code %d", err, tt.wantErr, tt.wantCode)
+			} else {
+				t.Logf("✅ err = %v", err)
+			}
+		})
+	}
+}
+
+// shortageError is an ErrorCoder with data.
+type shortageError struct{ item string }
+
+func (e *shortageError) Error() string  { return "short of " + e.item }
+func (e *shortageError) ErrorCode() int { return 1004 }
+func (e *shortageError) ErrorData() any { return map[string]string{"item": e.item} }
+
+// closedError is an ErrorCoder without data.
+type closedError struct{}
+
+func (closedError) Error() string  { return "closed" }
+func (closedError) ErrorCode() int { return 1005 }
+
+func Test_client_NewError(t *testing.T) {
+	errOutOfStock := NewError(1001, "Out of stock")
+
+	s := NewServer()
+	s.MustRegister("buy", func(arg string) (int, error) {
+		return 0, fmt.Errorf("buy %s: %w", arg, errOutOfStock)
+	})
+	s.MustRegister("sell", Typed(func(arg int) (int, error) {
+		return 0, NewError(1002, "Bad price").WithData(map[string]int{"min": 10})
+	}))
+	s.MustRegister("lend", func(arg int) (int, error) {
+		return 0, NewError(1003, "Not lent").WithReason("closed")
+	})
+	s.MustRegister("order", func(arg string) (int, error) {
+		return 0, fmt.Errorf("order: %w", &shortageError{arg})
+	})
+	s.MustRegister("open", func(arg int) (int, error) {
+		return 0, closedError{}
+	})
+	c := NewClient(&serverTransport{server: s})
+
+	tests := []struct {
+		method string
+		arg    any
+		want   *Error
+	}{
+		{"buy", "apple", &Error{Code: 1001, Message: "Out of stock"}},
+		{"sell", 1, &Error{Code: 1002, Message: "Bad price", Data: json.RawMessage(`{"min":10}`)}},
+		{"lend", 1, &Error{Code: 1003, Message: "Not lent", Data: json.RawMessage(`{"reason":"closed"}`)}},
+		{"order", "pear", &Error{Code: 1004, Message: "order: short of pear", Data: json.RawMessage(`{"item":"pear"}`)}},
+		{"open", 1, &Error{Code: 1005, Message: "closed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			err := c.Call(tt.method, tt.arg, nil)
+			var rpcErr *Error
+			if !errors.As(err, &rpcErr) || !reflect.DeepEqual(rpcErr, tt.want) {
+				t.Errorf("❌ err = %v, want %v", err, tt.want)
+			} else {
+				t.Logf("✅ err = %v", err)
+			}
+		})
+	}
+
+	if errOutOfStock.Data != nil {
+		t.Errorf("❌ the shared error was modified: %v", errOutOfStock)
+	}
+	if e := NewError(1, "x").WithData(func() {}); e.Code != 1 {
+		t.Errorf("❌ WithData of a func = %v", e)
+	} else if reason, ok := e.Reason(); !ok || reason == "" {
+		t.Errorf("❌ WithData of a func: no reason in %s", e.Data)
+	}
+}
+
+func Test_client_CallBatch(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil })
+	s.MustRegister("fail", func(arg int) (int, error) { return 0, errors.New("boom") })
+
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	transports := map[string]ClientTransport{
+		"http (one round trip)":   NewHttpClientTransport(ts.URL),
+		"in process (one by one)": &serverTransport{server: s},
+	}
+	for name, transport := range transports {
+		t.Run(name, func(t *testing.T) {
+			var sum1, sum2 int
+			calls := []BatchCall{
+				{Method: "add", Arg: []int{1, 2}, Ret: &sum1},
+				{Method: "fail", Arg: 1},
+				{Method: "add", Arg: []int{3, 4}, Ret: &sum2},
+				{Method: "nope", Arg: 1},
+				{Method: "add", Arg: nil}, // fails locally
+			}
+			results, err := NewClient(transport).CallBatch(calls)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantErrs := []string{"", "jsonrpc2 error -1: boom", "", "jsonrpc2 error -32601: Method not found", "arg is nil"}
+			for i, r := range results {
+				got := ""
+				if r.Error != nil {
+					got = r.Error.Error()
+				}
+				if got != wantErrs[i] {
+					t.Errorf("❌ results[%d].Error = %q, want %q", i, got, wantErrs[i])
+				}
+			}
+
+			err = JoinBatchErrors(calls, results)
+			var batchErr *BatchError
+			var rpcErr *Error
+			switch {
+			case !errors.As(err, &batchErr) || len(batchErr.Failed) != 3:
+				t.Errorf("❌ JoinBatchErrors = %v, want 3 failed calls", err)
+			case batchErr.Err(0) != nil || batchErr.Err(1) != results[1].Error || batchErr.Failed[2].Method != "add":
+				t.Errorf("❌ failed calls = %v", batchErr.Failed)
+			case !errors.As(err, &rpcErr) || rpcErr.Code != -1:
+				t.Errorf("❌ errors.As(%v) = %v, want the *Error of the first failed call", err, rpcErr)
+			}
+			if err := JoinBatchErrors(calls[:1], results[:1]); err != nil {
+				t.Errorf("❌ JoinBatchErrors of no failed call = %v", err)
+			}
+
+			if sum1 != 3 || sum2 != 7 {
+				t.Errorf("❌ sums = %d, %d; want 3, 7", sum1, sum2)
+			} else {
+				t.Logf("✅ sums = %d, %d; results = %+v", sum1, sum2, results)
+			}
+		})
+	}
+}
+
+func Test_client_Notify(t *testing.T) {
+	notified := make(chan int, 1)
+	s := NewServer()
+	s.MustRegister("record", func(arg int) (int, error) { notified <- arg; return arg, nil })
+
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+
+	tcp := NewTcpClientTransport(l.Addr().String())
+	defer tcp.Close()
+
+	transports := map[string]ClientTransport{
+		"http": NewHttpClientTransport(ts.URL),
+		"tcp":  tcp,
+	}
+	for name, transport := range transports {
+		t.Run(name, func(t *testing.T) {
+			c := NewClient(transport)
+			if err := c.Notify("record", 42); err != nil {
+				t.Fatalf("❌ Notify: %v", err)
+			}
+			select {
+			case got := <-notified:
+				if got != 42 {
+					t.Fatalf("❌ notified %d, want 42", got)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("❌ the method was not executed")
+			}
+
+			// not even errors are responded
+			if err := c.Notify("nope", 1); err != nil {
+				t.Fatalf("❌ Notify of an unknown method: %v", err)
+			}
+
+			// the connection keeps serving calls
+			var ret int
+			if err := c.Call("record", 7, &ret); err != nil || ret != 7 {
+				t.Fatalf("❌ Call after Notify = %d, %v", ret, err)
+			}
+			<-notified
+			t.Logf("✅ notified")
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		err := NewClient(&serverTransport{server: s}).Notify("record", 1)
+		if !errors.Is(err, ErrNotifyUnsupported) {
+			t.Fatalf("❌ err = %v, want ErrNotifyUnsupported", err)
+		}
+	})
+}
+
+// Test_server_nullId checks that a request of the id null is no
+// notification: it's answered, with the id null.
+func Test_server_nullId(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	const body = `{"jsonrpc":"2.0","method":"echo","params":1,"id":null}`
+	const want = `{"jsonrpc":"2.0","result":1,"id":null}`
+
+	t.Run("http", func(t *testing.T) {
+		st := NewHttpServerTransport("")
+		st.Use(s)
+		w := httptest.NewRecorder()
+		st.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if got := strings.TrimSpace(w.Body.String()); w.Code != http.StatusOK || got != want {
+			t.Errorf("❌ %d %s, want %s", w.Code, got, want)
+		}
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if err := writeFrame(conn, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readFrame(bufio.NewReader(conn), 0)
+		if err != nil || string(got) != want {
+			t.Errorf("❌ %s, %v; want %s", got, err, want)
+		}
+	})
+}
+
+// lostResponseTransport serves with server, but loses the responses to the
+// first lost requests, as if the connection broke after sending them.
+type lostResponseTransport struct {
+	server Server
+	lost   int
+	sent   int
+}
+
+func (t *lostResponseTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
+	t.sent++
+	resp := t.server.ServeRPC(ctx, req)
+	if t.sent <= t.lost {
+		return nil, errors.New("connection reset")
+	}
+	return resp, nil
+}
+
+func Test_client_WithRetry(t *testing.T) {
+	executed := 0
+	s := NewServer().WithAtMostOnce()
+	s.MustRegister("incr", func(n int) (int, error) { executed++; return n + 1, nil })
+
+	// the request reached the server: the retry is suppressed, not executed again
+	transport := &lostResponseTransport{server: s, lost: 1}
+	c := NewClient(transport).WithRetry(3, time.Millisecond, "*")
+	err := c.Call("incr", 1, nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != ErrAtMostOnce().Code {
+		t.Errorf("❌ retried call: err = %v, want ErrAtMostOnce", err)
+	}
+	if executed != 1 || transport.sent != 2 {
+		t.Errorf("❌ executed %d times in %d attempts, want once in 2", executed, transport.sent)
+	}
+
+	// replaying results, the retry gets the result of the first attempt
+	s = NewServer().WithAtMostOnce(ReplayResults())
+	s.MustRegister("incr", func(n int) (int, error) { executed++; return n + 1, nil })
+	executed = 0
+	var got int
+	if err := NewClient(&lostResponseTransport{server: s, lost: 1}).WithRetry(3, time.Millisecond, "incr").Call("incr", 1, &got); err != nil || got != 2 || executed != 1 {
+		t.Errorf("❌ retried call replaying results = %d, %v, executed %d times; want 2 once", got, err, executed)
+	}
+
+	// batches are retried too, one request at a time here
+	s = NewServer().WithAtMostOnce() // a new client reuses the ids
+	s.MustRegister("incr", func(n int) (int, error) { return n + 1, nil })
+	failing := &failingTransport{server: s, fails: 1}
+	results, err := NewClient(failing).WithRetry(2, time.Millisecond, "incr").CallBatch([]BatchCall{{Method: "incr", Arg: 41, Ret: &got}})
+	if err != nil || results[0].Error != nil || got != 42 {
+		t.Errorf("❌ retried batch = %v, %v, %d; want 42", results, err, got)
+	}
+
+	// the methods not opted in are not retried: they may not be idempotent
+	for _, methods := range [][]string{nil, {"get", "kv.*"}} {
+		failing = &failingTransport{server: s, fails: 1}
+		if err := NewClient(failing).WithRetry(3, time.Millisecond, methods...).Call("incr", 1, nil); err == nil || failing.sent != 1 {
+			t.Errorf("❌ retrying %v: incr = %v in %d attempts, want the error at once", methods, err, failing.sent)
+		}
+	}
+
+}
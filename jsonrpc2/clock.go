@@ -0,0 +1,204 @@
+package jsonrpc2
+
+// 这个文件抽象出时钟 (Clock) 与抖动 (Jitter)，
+// 让与时间有关的功能 (TTL、重试退避、证书检查、耗时统计……) 可以注入 FakeClock，
+// 测试无需真的等待，且结果确定。
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time, and makes timers.
+// SystemClock is the real one; FakeClock is for tests.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a time.Timer made by a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// SystemClock is the Clock of the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+// clockOrSystem returns c, or SystemClock if c is nil.
+func clockOrSystem(c Clock) Clock {
+	if c == nil {
+		return SystemClock
+	}
+	return c
+}
+
+// clockOf returns the Clock of s, see Server.WithClock.
+func clockOf(s Server) Clock {
+	switch srv := s.(type) {
+	case *server:
+		return srv.clock
+	case *ProxyServer:
+		return clockOf(srv.Server)
+	default:
+		return SystemClock
+	}
+}
+
+// withTimeout is context.WithTimeout timed by c: the context is done with
+// context.DeadlineExceeded once a timer of c fires. Its Deadline is that
+// of ctx, if c is not SystemClock.
+func withTimeout(ctx context.Context, c Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := c.(systemClock); ok {
+		return context.WithTimeout(ctx, d)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	tc := &timeoutCtx{Context: ctx}
+	timer := c.NewTimer(d)
+	go func() {
+		select {
+		case <-timer.C():
+			tc.timedOut.Store(true)
+			cancel()
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}()
+	return tc, cancel
+}
+
+// timeoutCtx is a context made by withTimeout.
+type timeoutCtx struct {
+	context.Context
+	timedOut atomic.Bool
+}
+
+func (c *timeoutCtx) Err() error {
+	if err := c.Context.Err(); err == nil || !c.timedOut.Load() {
+		return err
+	}
+	return context.DeadlineExceeded
+}
+
+// since is time.Since by c.
+func since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// FakeClock is a Clock for tests: its time only moves by Advance,
+// firing the timers due at once.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a FakeClock starting at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the time forward by d, firing the timers due, in order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+	for len(c.timers) > 0 && !c.timers[0].at.After(c.now) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		t.ch <- t.at
+	}
+}
+
+// Timers returns how many timers are waiting, e.g. for tests to wait until
+// the code under test has set its timer before calling Advance.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	ch    chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, waiting := range t.clock.timers {
+		if waiting == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Jitter randomizes a wait d, e.g. a retry backoff, so that many clients
+// failing together don't all retry together.
+// A nil Jitter leaves waits as they are, which keeps tests deterministic.
+type Jitter func(d time.Duration) time.Duration
+
+// RandomJitter makes a Jitter spreading waits uniformly over
+// [d*(1-fraction), d*(1+fraction)]. fraction is clamped into [0, 1].
+func RandomJitter(fraction float64) Jitter {
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return func(d time.Duration) time.Duration {
+		return time.Duration(float64(d) * (1 + fraction*(2*rand.Float64()-1)))
+	}
+}
+
+// apply j to d, if j is not nil.
+func (j Jitter) apply(d time.Duration) time.Duration {
+	if j == nil {
+		return d
+	}
+	return j(d)
+}
@@ -0,0 +1,74 @@
+package jsonrpc2
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_FakeClock(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+
+	t1 := c.NewTimer(2 * time.Second)
+	t2 := c.NewTimer(time.Second)
+	t3 := c.NewTimer(3 * time.Second)
+	if !t3.Stop() {
+		t.Error("❌ Stop() of a waiting timer should be true")
+	}
+
+	fired := func(tm Timer) (time.Time, bool) {
+		select {
+		case at := <-tm.C():
+			return at, true
+		default:
+			return time.Time{}, false
+		}
+	}
+
+	c.Advance(time.Second)
+	if at, ok := fired(t2); !ok || !at.Equal(start.Add(time.Second)) {
+		t.Errorf("❌ t2 fired: %v at %v, want at +1s", ok, at)
+	}
+	if _, ok := fired(t1); ok {
+		t.Error("❌ t1 fired early")
+	}
+
+	c.Advance(5 * time.Second)
+	if at, ok := fired(t1); !ok || !at.Equal(start.Add(2*time.Second)) {
+		t.Errorf("❌ t1 fired: %v at %v, want at +2s", ok, at)
+	}
+	if _, ok := fired(t3); ok {
+		t.Error("❌ stopped t3 fired")
+	}
+	if got := c.Now(); !got.Equal(start.Add(6 * time.Second)) {
+		t.Errorf("❌ Now() = %v, want +6s", got)
+	}
+	if c.Timers() != 0 {
+		t.Errorf("❌ Timers() = %d, want 0", c.Timers())
+	}
+}
+
+func Test_RandomJitter(t *testing.T) {
+	tests := []struct {
+		fraction float64
+		min, max time.Duration
+	}{
+		{0, time.Second, time.Second},
+		{0.5, 500 * time.Millisecond, 1500 * time.Millisecond},
+		{2, 0, 2 * time.Second}, // clamped to 1
+	}
+	for _, tt := range tests {
+		j := RandomJitter(tt.fraction)
+		for i := 0; i < 100; i++ {
+			if d := j(time.Second); d < tt.min || d > tt.max {
+				t.Fatalf("❌ RandomJitter(%v)(1s) = %v, want in [%v, %v]", tt.fraction, d, tt.min, tt.max)
+			}
+		}
+		t.Logf("✅ RandomJitter(%v) in [%v, %v]", tt.fraction, tt.min, tt.max)
+	}
+
+	var none Jitter
+	if d := none.apply(time.Second); d != time.Second {
+		t.Errorf("❌ nil Jitter changed 1s into %v", d)
+	}
+}
@@ -6,17 +6,69 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"time"
 )
 
 // JsonRpc2 is the version of JSON-RPC 2.0.
 const JsonRpc2 = "2.0"
 
 // Request object for JSON-RPC 2.0
+//
+// A Request without Id is a notification: the server executes it, but
+// responds nothing, not even an error.
 type Request struct {
 	JsonRpc string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params"` // delay parsing until we know the inType
-	Id      *int64          `json:"id"`
+	Id      *ID             `json:"id,omitempty"`
+
+	// Client is the identity of the client session sending the request,
+	// an extension member of the request object: servers WithAtMostOnce
+	// dedupe the ids of each client apart. Clients made by NewClient set
+	// it to a random UUID. Empty means unknown: the ids of all such
+	// requests are deduped together.
+	Client string `json:"client,omitempty"`
+
+	// Meta is the metadata of the request, see WithRequestMeta. It's an
+	// extension member of the request object.
+	Meta map[string]string `json:"meta,omitempty"`
+
+	nullId bool // the id is null, not missing: it's no notification
+}
+
+// IsNotification reports whether r is a notification: a request without
+// id. A request of the id null is none, it's answered with the id null.
+func (r Request) IsNotification() bool {
+	return r.Id == nil && !r.nullId
+}
+
+// UnmarshalJSON tells the id null from the id missing, see IsNotification.
+func (r *Request) UnmarshalJSON(data []byte) error {
+	// the fields of Request without this method, not to recurse, named
+	// alike for the errors
+	type plain Request
+	type Request plain
+	if err := json.Unmarshal(data, (*Request)(r)); err != nil {
+		return err
+	}
+
+	r.nullId = false
+	if r.Id == nil {
+		var msg struct {
+			Id json.RawMessage `json:"id"`
+		}
+		_ = json.Unmarshal(data, &msg) // it's an object, see above
+		r.nullId = msg.Id != nil
+	}
+	return nil
+}
+
+// formatId formats an id for logs: as in JSON, or null.
+func formatId(id *ID) string {
+	if id == nil {
+		return "null"
+	}
+	return id.String()
 }
 
 // unmarshalRequest data into a Request object req.
@@ -37,16 +89,26 @@ func (r Request) unmarshalParam(inType reflect.Type) (reflect.Value, error) {
 	badValue := reflect.Zero(inType)
 	dst := reflect.New(inType)
 
-	if r.Params == nil {
+	params := paramsOrNull(r.Params, inType)
+	if params == nil {
 		return badValue, errors.New("params should not be nil")
 	}
 
-	if err := json.Unmarshal(r.Params, dst.Interface()); err != nil {
+	if err := json.Unmarshal(params, dst.Interface()); err != nil {
 		return badValue, err
 	}
 	return dst.Elem(), nil
 }
 
+// paramsOrNull is params, or null if they are omitted (as the spec allows)
+// to a parameter taking null: a pointer or a json.RawMessage.
+func paramsOrNull(params json.RawMessage, inType reflect.Type) json.RawMessage {
+	if params == nil && (inType.Kind() == reflect.Pointer || inType == rawMessageType) {
+		return json.RawMessage("null")
+	}
+	return params
+}
+
 func (r Request) validate() error {
 	if r.JsonRpc != JsonRpc2 {
 		return errors.New("invalid jsonrpc version")
@@ -54,12 +116,20 @@ func (r Request) validate() error {
 	if r.Method == "" {
 		return errors.New("method should not be empty")
 	}
-	if r.Id == nil {
-		return errors.New("id should not be nil")
+	if s, ok := r.Meta[MetaTimeout]; ok {
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("bad meta %s: %w", MetaTimeout, err)
+		}
 	}
 	return nil
 }
 
+// timeout is the MetaTimeout of r, if any.
+func (r Request) timeout() (time.Duration, bool) {
+	d, err := time.ParseDuration(r.Meta[MetaTimeout])
+	return d, err == nil
+}
+
 // marshal r into w.
 func (r Request) marshal(w io.Writer) error {
 	return json.NewEncoder(w).Encode(r)
@@ -75,27 +145,89 @@ type Response struct {
 	JsonRpc string          `json:"jsonrpc"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *Error          `json:"error,omitempty"`
-	Id      *int64          `json:"id"` // int or null
+	Id      *ID             `json:"id"` // number, string or null
+
+	// Meta is the metadata of the response, see SetResponseMeta. It's an
+	// extension member of the response object, except over HTTP, where
+	// single responses carry it in headers instead (see MetaHeaderPrefix).
+	Meta map[string]string `json:"meta,omitempty"`
+
+	// warnings for the caller, sent out of band by the transports that
+	// can, e.g. as Warning headers over HTTP.
+	warnings []string
+
+	// pretty asks the transports to indent the response, see Server.WithPretty.
+	pretty bool
+}
+
+// ResultMarshaler is implemented by results controlling their own wire
+// format as the Result of a Response, which may differ from their
+// MarshalJSON used elsewhere (e.g. in logs or storage).
+//
+// The returned JSON text is sent as it is, except for insignificant
+// whitespace, which the transports may normalize.
+type ResultMarshaler interface {
+	MarshalResult() ([]byte, error)
 }
 
-// marshalResult fills the Result field with the given value.
-func (r *Response) marshalResult(result any) error {
+// marshalResult fills the Result field with the given value, marshaled by
+// marshal if not nil (see MarshalResultWith), else by its MarshalResult if
+// it's a ResultMarshaler, else by json.Marshal.
+func (r *Response) marshalResult(result any, marshal func(any) ([]byte, error)) error {
 	if result == nil {
 		return nil
 	}
 
-	b, err := json.Marshal(result)
+	if marshal == nil {
+		marshal = json.Marshal
+		if m, ok := result.(ResultMarshaler); ok {
+			marshal = func(any) ([]byte, error) { return m.MarshalResult() }
+		}
+	}
+
+	b, err := marshal(result)
 	if err != nil {
 		return err
 	}
+	if !json.Valid(b) {
+		return errors.New("invalid JSON result")
+	}
 	r.Result = b
 	return nil
 }
 
+// resultMarshalError tells that the result of a method can't be marshaled,
+// e.g. it holds a channel or a func, or its MarshalResult fails.
+type resultMarshalError struct {
+	method string
+	typ    string // of the result
+	err    error
+}
+
+func newResultMarshalError(method string, result any, err error) *resultMarshalError {
+	return &resultMarshalError{method: method, typ: fmt.Sprintf("%T", result), err: err}
+}
+
+func (e *resultMarshalError) Error() string {
+	return fmt.Sprintf("result of %s (%s) can't be marshaled: %v", e.method, e.typ, e.err)
+}
+
+func (e *resultMarshalError) Unwrap() error {
+	return e.err
+}
+
+// rpcError is the ErrInternalError answering the call, telling the method
+// and the type of the result in its Data, for the caller to report.
+func (e *resultMarshalError) rpcError() *Error {
+	return ErrInternalError().WithReason(e.err.Error()).
+		withDataField("method", e.method).
+		withDataField("type", e.typ)
+}
+
 // marshal marshals the response into a byte slice.
 // This should be called after the Result or Error field is filled.
 func (r *Response) marshal(w io.Writer) error {
-	return json.NewEncoder(w).Encode(r)
+	return encodeJSON(w, r, r.pretty)
 }
 
 // validate checks if the response is valid: either Result or Error is filled.
@@ -144,14 +276,62 @@ func (e *Error) Error() string {
 	return s
 }
 
-// withReason writes a detailed reason for the error in the Data field.
+// Reason returns the detailed reason in the Data field, written by the
+// server with the error, e.g. "params should be JSON".
+// It's false if Data is not an object with a string reason in it.
+func (e *Error) Reason() (string, bool) {
+	var data struct {
+		Reason *string `json:"reason"`
+	}
+	if err := json.Unmarshal(e.Data, &data); err != nil || data.Reason == nil {
+		return "", false
+	}
+	return *data.Reason, true
+}
+
+// NewError makes an Error of code, for methods to return their own errors:
+//
+//	return nil, jsonrpc2.NewError(1001, "Out of stock").WithReason("no apples left")
+//	return nil, jsonrpc2.NewError(1001, "Out of stock").WithData(&Shortage{Item: "apple"})
+//
+// The error is answered as is (even wrapped, see errors.As), instead of
+// the code -1 of other errors, but those implementing ErrorCoder.
+// Codes from -32768 to -32000 are reserved by JSON-RPC, see the pre-defined errors.
+func NewError(code int, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// WithReason writes a detailed reason for the error in the Data field.
 // The modifying is done in-place. Returning the error object itself is for chaining.
-func (e *Error) withReason(reason string) *Error {
+func (e *Error) WithReason(reason string) *Error {
 	data, _ := json.Marshal(map[string]string{"reason": reason})
 	e.Data = data
 	return e
 }
 
+// WithData writes data, marshaled to JSON, in the Data field, replacing any
+// reason. If data can't be marshaled, the reason why is written instead.
+// The modifying is done in-place, like WithReason.
+func (e *Error) WithData(data any) *Error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return e.WithReason(fmt.Sprintf("data can't be marshaled: %v", err))
+	}
+	e.Data = raw
+	return e
+}
+
+// withRetryAfter writes the reason and a hint of when to retry (in milliseconds)
+// in the Data field. The modifying is done in-place, like WithReason.
+func (e *Error) withRetryAfter(reason string, after time.Duration) *Error {
+	data, _ := json.Marshal(map[string]any{
+		"reason":         reason,
+		"retry_after_ms": after.Milliseconds(),
+	})
+	e.Data = data
+	return e
+}
+
 // pre-defined errors
 var (
 	ErrParseError     = func() *Error { return &Error{Code: -32700, Message: "Parse error"} }      // Invalid JSON was received by the server. An error occurred on the server while parsing the JSON text.
@@ -160,12 +340,57 @@ var (
 	ErrInvalidParams  = func() *Error { return &Error{Code: -32602, Message: "Invalid params"} }   // Invalid function parameter(s).
 	ErrInternalError  = func() *Error { return &Error{Code: -32603, Message: "Internal error"} }   // Internal JSON-RPC error.
 	ErrServerError    = func() *Error { return &Error{Code: -32000, Message: "Server error"} }     // -32000 to -32099: Reserved for implementation-defined server-errors.
+	ErrServerBusy     = func() *Error { return &Error{Code: -32001, Message: "Server busy"} }      // The request was shed by the concurrency limit. Data carries a retry_after_ms hint.
+	ErrRequestTimeout = func() *Error { return &Error{Code: -32002, Message: "Request timeout"} }  // The request was not done within the timeout of the server.
+	ErrUnauthorized   = func() *Error { return &Error{Code: -32003, Message: "Unauthorized"} }     // The connection was not authenticated, see MethodAuth.
+	ErrQuotaExceeded  = func() *Error { return &Error{Code: -32004, Message: "Quota exceeded"} }   // The caller used up its quota of the method, see Quotas. Data carries the QuotaStatus and a retry_after_ms hint.
+	ErrNotReady       = func() *Error { return &Error{Code: -32005, Message: "Server not ready"} } // The server is starting, see Server.WithReadinessGate. Data carries a retry_after_ms hint.
+
+	ErrRequestCancelled = func() *Error { return &Error{Code: -32800, Message: "Request cancelled"} } // The request was cancelled, e.g. by rpc.cancel. Same code as LSP.
 
 	ErrAtMostOnce = func() *Error { return &Error{Code: -2022, Message: "duplicated request: violate at-most-once"} }
 )
 
+// ErrorCoder is implemented by the errors of methods knowing their codes,
+// e.g. those of a domain package not to depend on jsonrpc2:
+//
+//	type OutOfStockError struct{ Item string }
+//
+//	func (e *OutOfStockError) Error() string  { return "out of stock: " + e.Item }
+//	func (e *OutOfStockError) ErrorCode() int { return 1001 }
+//
+// A method failing with an ErrorCoder (or an error wrapping one) is
+// answered with an Error of its code and the message of the error, and
+// the Data of ErrorData if it has that method as well:
+//
+//	func (e *OutOfStockError) ErrorData() any { return map[string]string{"item": e.Item} }
+type ErrorCoder interface {
+	error
+	ErrorCode() int
+}
+
+// methodError is the Error answering a call whose method failed with err:
+// a copy of err if it's an *Error (e.g. made by NewError), of the code of
+// err if it's an ErrorCoder, else an Error of code -1 with the message of err.
+func methodError(err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		e := *rpcErr // methods may return a shared *Error, which is modified in-place later
+		return &e
+	}
+	var coder ErrorCoder
+	if errors.As(err, &coder) {
+		e := &Error{Code: coder.ErrorCode(), Message: err.Error()}
+		if d, ok := coder.(interface{ ErrorData() any }); ok {
+			e.WithData(d.ErrorData())
+		}
+		return e
+	}
+	return &Error{Code: -1, Message: err.Error()}
+}
+
 // errorResponse helps to create a response for an error.
-func errorResponse(id *int64, err *Error) *Response {
+func errorResponse(id *ID, err *Error) *Response {
 	return &Response{
 		JsonRpc: JsonRpc2,
 		Id:      id,
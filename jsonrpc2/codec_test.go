@@ -38,3 +38,28 @@ func TestRequest_unmarshalParam(t *testing.T) {
 		})
 	}
 }
+
+func TestError_Reason(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *Error
+		want   string
+		wantOk bool
+	}{
+		{"WithReason", ErrInvalidParams().WithReason("bad"), "bad", true},
+		{"withRetryAfter", ErrServerBusy().withRetryAfter("busy", 0), "busy", true},
+		{"emptyReason", ErrInvalidParams().WithReason(""), "", true},
+		{"noData", ErrInternalError(), "", false},
+		{"noReason", &Error{Data: json.RawMessage(`{"deprecated":"x"}`)}, "", false},
+		{"notObject", &Error{Data: json.RawMessage(`"oops"`)}, "", false},
+		{"notString", &Error{Data: json.RawMessage(`{"reason":42}`)}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.err.Reason()
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Reason() = (%q, %v), want (%q, %v)", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 这个文件实现宽松的参数解码 (见 Server.WithParamCoercion)：
+// 为了与把一切都转成字符串的客户端 (PHP、shell 脚本等) 互通，
+// 把 "123" 转为数字，把 1/0、"true"/"false" 转为布尔值。
+
+type paramCoercionKey struct{}
+
+// withParamCoercion returns a copy of ctx in which params are decoded leniently.
+func withParamCoercion(ctx context.Context) context.Context {
+	return context.WithValue(ctx, paramCoercionKey{}, true)
+}
+
+func paramCoercionFromContext(ctx context.Context) bool {
+	on, _ := ctx.Value(paramCoercionKey{}).(bool)
+	return on
+}
+
+// decodeParams decodes params into dst (a pointer). If that fails and
+// coercion is on in ctx, params are coerced to the type of dst and decoded
+// again. The error is the one of the strict decoding.
+// Params of gob requests are decoded by gob, strictly.
+func decodeParams(ctx context.Context, params json.RawMessage, dst any) error {
+	if gobFromContext(ctx) {
+		return gobUnmarshal(params, dst)
+	}
+
+	err := json.Unmarshal(params, dst)
+	if err == nil || !paramCoercionFromContext(ctx) {
+		return err
+	}
+
+	v := reflect.ValueOf(dst).Elem()
+	coerced, cerr := coerceJSON(params, v.Type())
+	if cerr != nil {
+		return err
+	}
+	v.Set(reflect.Zero(v.Type())) // drop what the failed decoding filled
+	if json.Unmarshal(coerced, dst) != nil {
+		return err
+	}
+	return nil
+}
+
+// unmarshalParamContext is unmarshalParam, decoding leniently if coercion is on in ctx,
+// or by gob for gob requests.
+func (r Request) unmarshalParamContext(ctx context.Context, inType reflect.Type) (reflect.Value, error) {
+	if gobFromContext(ctx) && r.Params != nil {
+		dst := reflect.New(inType)
+		if err := gobUnmarshal(r.Params, dst.Interface()); err != nil {
+			return reflect.Zero(inType), err
+		}
+		return dst.Elem(), nil
+	}
+
+	param, err := r.unmarshalParam(inType)
+	if err == nil || !paramCoercionFromContext(ctx) {
+		return param, err
+	}
+
+	coerced, cerr := coerceJSON(r.Params, inType)
+	if cerr != nil {
+		return param, err
+	}
+	r.Params = coerced
+	if p, cerr := r.unmarshalParam(inType); cerr == nil {
+		return p, nil
+	}
+	return param, err
+}
+
+// coerceJSON rewrites the JSON data to fit values of type t, where it can:
+// numeric strings into numbers, and 1/0, "1"/"0", "true"/"false" into booleans.
+func coerceJSON(data []byte, t reflect.Type) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(coerceValue(v, t))
+}
+
+func coerceValue(v any, t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == rawMessageType || t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return v // decodes itself
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if s, ok := v.(string); ok {
+			s = strings.TrimSpace(s)
+			if _, err := strconv.ParseFloat(s, 64); err == nil && json.Valid([]byte(s)) {
+				return json.Number(s)
+			}
+		}
+	case reflect.Bool:
+		switch v {
+		case json.Number("1"), "1", "true":
+			return true
+		case json.Number("0"), "0", "false":
+			return false
+		}
+	case reflect.Slice, reflect.Array:
+		if items, ok := v.([]any); ok {
+			for i := range items {
+				items[i] = coerceValue(items[i], t.Elem())
+			}
+		}
+	case reflect.Map:
+		if obj, ok := v.(map[string]any); ok {
+			for k := range obj {
+				obj[k] = coerceValue(obj[k], t.Elem())
+			}
+		}
+	case reflect.Struct:
+		if obj, ok := v.(map[string]any); ok {
+			fields := jsonFields(t)
+			for k := range obj {
+				if ft, ok := lookupField(fields, k); ok {
+					obj[k] = coerceValue(obj[k], ft)
+				}
+			}
+		}
+	}
+	return v
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// jsonFields maps the JSON names of the fields of struct type t to their
+// types, like encoding/json (roughly: names from json tags, embedded
+// structs flattened).
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for n, t := range jsonFields(ft) {
+				if _, ok := fields[n]; !ok {
+					fields[n] = t
+				}
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// lookupField finds the field for the object key, matching names
+// case-insensitively as encoding/json does.
+func lookupField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if t, ok := fields[key]; ok {
+		return t, true
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,96 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_coerceJSON(t *testing.T) {
+	type Inner struct {
+		N uint8
+	}
+	type Arg struct {
+		Count  int     `json:"count"`
+		Rate   float64 `json:"rate"`
+		On     bool    `json:"on"`
+		Name   string  `json:"name"`
+		Ids    []int   `json:"ids"`
+		Inner          // embedded: flattened
+		Nested *Inner  `json:"nested"`
+		Raw    json.RawMessage
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"numbers", `{"count":"123","rate":" 1.5 "}`, `{"count":123,"rate":1.5}`},
+		{"bools", `{"on":1}`, `{"on":true}`},
+		{"bool strings", `{"on":"false"}`, `{"on":false}`},
+		{"strings untouched", `{"name":"123"}`, `{"name":"123"}`},
+		{"not a number", `{"count":"abc"}`, `{"count":"abc"}`},
+		{"slice", `{"ids":["1",2,"3"]}`, `{"ids":[1,2,3]}`},
+		{"embedded", `{"N":"7"}`, `{"N":7}`},
+		{"nested, case-insensitive", `{"Nested":{"n":"8"}}`, `{"Nested":{"n":8}}`},
+		{"raw untouched", `{"Raw":"1"}`, `{"Raw":"1"}`},
+		{"unknown field", `{"x":"1"}`, `{"x":"1"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := coerceJSON([]byte(tt.data), reflect.TypeOf(Arg{}))
+			if err != nil || string(got) != tt.want {
+				t.Errorf("❌ coerceJSON(%s) = %s, %v, want %s", tt.data, got, err, tt.want)
+			} else {
+				t.Logf("✅ %s -> %s", tt.data, got)
+			}
+		})
+	}
+}
+
+func Test_WithParamCoercion(t *testing.T) {
+	type Arg struct {
+		A, B int
+		Neg  bool
+	}
+	add := func(arg *Arg) (int, error) {
+		if arg.Neg {
+			return -(arg.A + arg.B), nil
+		}
+		return arg.A + arg.B, nil
+	}
+
+	tests := []struct {
+		name   string
+		coerce bool
+		method string
+		params string
+		want   string // result, or error code
+	}{
+		{"off", false, "add", `{"A":"1","B":"2"}`, `-32602`},
+		{"on", true, "add", `{"A":"1","B":"2","Neg":"1"}`, `-3`},
+		{"on, typed", true, "typed", `{"A":"1","B":2}`, `3`},
+		{"on, still invalid", true, "add", `{"A":"x"}`, `-32602`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer().WithParamCoercion(tt.coerce)
+			s.MustRegister("add", add)
+			s.MustRegister("typed", Typed(func(arg Arg) (int, error) { return add(&arg) }))
+
+			resp := s.ServeRPC(context.Background(), &Request{
+				JsonRpc: JsonRpc2, Method: tt.method, Params: json.RawMessage(tt.params), Id: Int64ID(1)})
+			got, _ := json.Marshal(resp.Result)
+			if resp.Error != nil {
+				got, _ = json.Marshal(resp.Error.Code)
+			}
+			if string(got) != tt.want {
+				t.Errorf("❌ got %s\nwant %s", got, tt.want)
+			} else {
+				t.Logf("✅ %s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package jsonrpc2
+
+// 这个文件实现流式连接 (TCP、Unix socket、WebSocket) 的压缩协商：
+// 客户端设置了 Compression 时，连接上的第一条消息是 rpc.compress 调用，按偏好顺序列出它支持的算法，
+// 服务端从中选出第一个自己也允许的算法作为结果 (都不允许则为 "none")。
+// 此后双方的每条消息都各自独立地压缩 (WebSocket 上以二进制消息发送)。
+//
+// 内置的算法只有 "deflate" (compress/flate)：snappy、zstd 等需要第三方库，
+// 可以由使用者通过 RegisterCompression 注册，两端注册了同名的算法即可协商使用。
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// MethodCompress is the call negotiating the compression of a stream
+// connection, sent first by clients offering compression (see
+// StreamClientTransport.Compression), even before MethodAuth. The params are
+// CompressParams, the result is CompressResult.
+const MethodCompress = "rpc.compress"
+
+// CompressionNone is the algorithm of connections not compressed.
+const CompressionNone = "none"
+
+// CompressParams are the params of MethodCompress.
+type CompressParams struct {
+	Algorithms []string `json:"algorithms"` // supported by the client, the preferred first
+}
+
+// CompressResult is the result of MethodCompress.
+type CompressResult struct {
+	Algorithm string `json:"algorithm"` // picked by the server, CompressionNone if none
+}
+
+// Compression is a compression algorithm of the messages of stream
+// connections, see RegisterCompression.
+type Compression struct {
+	Name string
+
+	// Compress a message.
+	Compress func(p []byte) ([]byte, error)
+
+	// Decompress a message, failing if it would be bigger than limit bytes.
+	Decompress func(p []byte, limit int64) ([]byte, error)
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = map[string]*Compression{
+		"deflate": {Name: "deflate", Compress: deflate, Decompress: inflate},
+	}
+)
+
+// RegisterCompression makes the algorithm c available to the stream
+// transports of both ends, by c.Name, e.g. snappy or zstd by a package of
+// them. A later registration of a name replaces the earlier one.
+func RegisterCompression(c Compression) {
+	if c.Name == "" || c.Name == CompressionNone || c.Compress == nil || c.Decompress == nil {
+		panic(fmt.Sprintf("jsonrpc2: bad compression %q", c.Name))
+	}
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	compressions[c.Name] = &c
+}
+
+// compressionOf name, nil if it's not registered.
+func compressionOf(name string) *Compression {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	return compressions[name]
+}
+
+func deflate(p []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(p); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func inflate(p []byte, limit int64) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(p))
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, errTooBigDecompressed
+	}
+	return out, nil
+}
+
+var errTooBigDecompressed = errors.New("decompressed message too big")
+
+// pickCompression picks the first of offered in allowed, nil if none is.
+func pickCompression(offered, allowed []string) *Compression {
+	for _, name := range offered {
+		for _, a := range allowed {
+			if a == name {
+				if c := compressionOf(name); c != nil {
+					return c
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// compressionStats counts the bytes of the messages of the connections
+// compressed, to tell the ratio achieved.
+type compressionStats struct {
+	raw        atomic.Int64 // of the messages
+	compressed atomic.Int64 // of the messages on the wire
+}
+
+func (s *compressionStats) record(raw, compressed int) {
+	s.raw.Add(int64(raw))
+	s.compressed.Add(int64(compressed))
+}
+
+// snapshot reports the counters into stats as "compression.bytes_raw",
+// "compression.bytes_compressed" and "compression.ratio_pct", the
+// percentage of the raw bytes put on the wire.
+func (s *compressionStats) snapshot(stats map[string]int64) map[string]int64 {
+	raw, compressed := s.raw.Load(), s.compressed.Load()
+	stats["compression.bytes_raw"] = raw
+	stats["compression.bytes_compressed"] = compressed
+	stats["compression.ratio_pct"] = 0
+	if raw > 0 {
+		stats["compression.ratio_pct"] = compressed * 100 / raw
+	}
+	return stats
+}
+
+// compressedIO wraps the read and write of messages of a connection to
+// (de)compress them by c.
+type compressedIO struct {
+	c     *Compression
+	stats *compressionStats
+	limit int64 // of the messages decompressed, against compression bombs; 0: DefaultMaxFrameSize
+}
+
+func (z compressedIO) read(read func() ([]byte, error)) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		p, err := read()
+		if err != nil {
+			return nil, err
+		}
+		limit := z.limit
+		if limit <= 0 {
+			limit = DefaultMaxFrameSize
+		}
+		body, err := z.c.Decompress(p, limit)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s message: %w", z.c.Name, err)
+		}
+		z.stats.record(len(body), len(p))
+		return body, nil
+	}
+}
+
+func (z compressedIO) write(write func([]byte) error) func([]byte) error {
+	return func(body []byte) error {
+		p, err := z.c.Compress(body)
+		if err != nil {
+			return err
+		}
+		z.stats.record(len(body), len(p))
+		return write(p)
+	}
+}
+
+// compressedConn is a messageConn compressing the messages of conn.
+type compressedConn struct {
+	messageConn
+	read  func() ([]byte, error)
+	write func([]byte) error
+}
+
+func newCompressedConn(conn messageConn, c *Compression, stats *compressionStats, limit int64) *compressedConn {
+	z := compressedIO{c: c, stats: stats, limit: limit}
+	write := conn.writeMessage
+	if bw, ok := conn.(binaryMessageWriter); ok {
+		write = bw.writeBinaryMessage
+	}
+	return &compressedConn{
+		messageConn: conn,
+		read:        z.read(conn.readMessage),
+		write:       z.write(write),
+	}
+}
+
+func (c *compressedConn) readMessage() ([]byte, error) { return c.read() }
+
+func (c *compressedConn) writeMessage(body []byte) error { return c.write(body) }
+
+// binaryMessageWriter is a messageConn telling binary messages from text
+// ones, e.g. a wsConn: compressed messages are binary.
+type binaryMessageWriter interface {
+	writeBinaryMessage(body []byte) error
+}
+
+// compressId is the id of the MethodCompress call of a connection,
+// before the MethodAuth one (authId).
+const compressId = -1
+
+// negotiateCompression offers the algorithms to the server by a
+// MethodCompress call on conn, before anything else is read from it,
+// returning conn compressing by the algorithm picked, if any.
+// Servers not knowing MethodCompress answer an error: conn is not compressed.
+func negotiateCompression(ctx context.Context, conn messageConn, algorithms []string, stats *compressionStats, limit int64) (messageConn, error) {
+	params, err := json.Marshal(CompressParams{Algorithms: algorithms})
+	if err != nil {
+		return nil, err
+	}
+	reqJson, err := Request{JsonRpc: JsonRpc2, Method: MethodCompress, Params: params, Id: Int64ID(compressId)}.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.writeMessage(reqJson); err != nil {
+		return nil, err
+	}
+
+	type read struct {
+		body []byte
+		err  error
+	}
+	done := make(chan read, 1)
+	go func() {
+		body, err := conn.readMessage()
+		done <- read{body, err}
+	}()
+	var r read
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		_ = conn.Close()
+		return nil, ctx.Err()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var resp Response
+	if err := unmarshalResponse(bytes.NewReader(r.body), &resp); err != nil {
+		return nil, fmt.Errorf("bad response to %s: %w", MethodCompress, err)
+	}
+	if resp.Error != nil {
+		return conn, nil
+	}
+	var result CompressResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("bad result of %s: %w", MethodCompress, err)
+	}
+	if result.Algorithm == CompressionNone {
+		return conn, nil
+	}
+	c := compressionOf(result.Algorithm)
+	if c == nil || !contains(algorithms, result.Algorithm) {
+		return nil, fmt.Errorf("%s: server picked %q, not offered", MethodCompress, result.Algorithm)
+	}
+	return newCompressedConn(conn, c, stats, limit), nil
+}
+
+// isCompressRequest tells whether body is a MethodCompress call.
+func isCompressRequest(body []byte) (*Request, bool) {
+	if isBatch(body) {
+		return nil, false
+	}
+	var req Request
+	if err := unmarshalRequest(bytes.NewReader(body), &req); err != nil || req.Method != MethodCompress || req.Id == nil {
+		return nil, false
+	}
+	return &req, true
+}
+
+// answerCompress answers the MethodCompress call req by write, picking the
+// first algorithm offered in allowed. It returns the compression picked,
+// nil for CompressionNone.
+func answerCompress(req *Request, allowed []string, write func([]byte) error) (*Compression, error) {
+	var params CompressParams
+	var c *Compression
+	resp := &Response{JsonRpc: JsonRpc2, Id: req.Id}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		resp.Error = ErrInvalidParams().WithReason(err.Error())
+	} else {
+		result := CompressResult{Algorithm: CompressionNone}
+		if c = pickCompression(params.Algorithms, allowed); c != nil {
+			result.Algorithm = c.Name
+		}
+		resp.Result, _ = json.Marshal(result)
+	}
+
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return c, write(out)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
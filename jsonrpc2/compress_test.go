@@ -0,0 +1,121 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_StreamTransport_Compression(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+	s.MustRegister("whoami", func(ctx context.Context, arg int) (string, error) {
+		identity, _ := IdentityFromContext(ctx)
+		return identity.(string), nil
+	})
+	auth := func(ctx context.Context, params json.RawMessage) (any, error) { return "alice", nil }
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	st := &StreamServerTransport{Network: "tcp", Compression: []string{"deflate"}, Authenticate: auth}
+	go st.ServeListener(l, s)
+
+	wst := NewWebSocketServerTransport("")
+	wst.Compression = []string{"deflate"}
+	wst.Authenticate = auth
+	wst.Use(s)
+	ts := httptest.NewServer(wst)
+	defer ts.Close()
+
+	// a plain server, not allowing compression
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(plain, s)
+
+	tests := []struct {
+		name       string
+		transport  *StreamClientTransport
+		server     interface{ Stats() map[string]int64 }
+		compressed bool
+	}{
+		{"tcp", NewTcpClientTransport(l.Addr().String()), st, true},
+		{"websocket", NewWebSocketClientTransport("ws"+strings.TrimPrefix(ts.URL, "http"), nil), wst, true},
+		{"not allowed", NewTcpClientTransport(plain.Addr().String()), nil, false},
+	}
+	long := strings.Repeat("compress me ", 1000)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.transport.Compression = []string{"zstd", "deflate"} // zstd is not registered
+			if tt.server != nil {
+				tt.transport.AuthParams = "token"
+			}
+			defer tt.transport.Close()
+			c := NewClient(tt.transport)
+
+			var got string
+			if err := c.Call("echo", long, &got); err != nil || got != long {
+				t.Fatalf("❌ echo = %d bytes, %v; want %d bytes", len(got), err, len(long))
+			}
+			if tt.server != nil {
+				if err := c.Call("whoami", 1, &got); err != nil || got != "alice" {
+					t.Errorf("❌ whoami after compression = %q, %v; want alice", got, err)
+				}
+			}
+
+			stats := tt.transport.Stats()
+			if !tt.compressed {
+				if stats["compression.bytes_compressed"] != 0 {
+					t.Errorf("❌ compressed %d bytes, want none", stats["compression.bytes_raw"])
+				}
+				return
+			}
+			if ratio := stats["compression.ratio_pct"]; ratio <= 0 || ratio >= 10 {
+				t.Errorf("❌ client compression ratio = %d%%, want a few %%", ratio)
+			}
+			if ratio := tt.server.Stats()["compression.ratio_pct"]; ratio <= 0 || ratio >= 10 {
+				t.Errorf("❌ server compression ratio = %d%%, want a few %%", ratio)
+			}
+		})
+	}
+}
+
+func Test_pickCompression(t *testing.T) {
+	RegisterCompression(Compression{
+		Name:       "identity",
+		Compress:   func(p []byte) ([]byte, error) { return p, nil },
+		Decompress: func(p []byte, limit int64) ([]byte, error) { return p, nil },
+	})
+
+	tests := []struct {
+		offered, allowed []string
+		want             string // "" for none
+	}{
+		{[]string{"identity", "deflate"}, []string{"deflate", "identity"}, "identity"}, // by the client's preference
+		{[]string{"zstd", "deflate"}, []string{"zstd", "deflate"}, "deflate"},          // zstd not registered
+		{[]string{"deflate"}, nil, ""},
+		{nil, []string{"deflate"}, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if c := pickCompression(tt.offered, tt.allowed); c != nil {
+			got = c.Name
+		}
+		if got != tt.want {
+			t.Errorf("❌ pickCompression(%v, %v) = %q, want %q", tt.offered, tt.allowed, got, tt.want)
+		}
+	}
+
+	bomb, _ := deflate(make([]byte, 1<<20))
+	if _, err := inflate(bomb, 1<<10); err != errTooBigDecompressed {
+		t.Errorf("❌ inflate over the limit: err = %v", err)
+	}
+}
@@ -0,0 +1,398 @@
+// Package config builds a jsonrpc2 Server and its transports from a JSON
+// config file, instead of wiring them by hand in every main():
+//
+//	{
+//	  "server": {"at_most_once": true, "dedupe_ttl": "5m", "max_concurrency": 64, "logging": {"sample_every": 100, "errors": true}},
+//	  "transports": [
+//	    {"kind": "http", "addr": ":5680", "write_timeout": "10s"},
+//	    {"kind": "tcp", "addr": ":5679", "request_timeout": "5s",
+//	     "tls": {"cert_file": "server.crt", "key_file": "server.key"}}
+//	  ]
+//	}
+//
+// e.g.
+//
+//	cfg, err := config.Load("server.json")
+//	s := cfg.NewServer()
+//	s.MustRegister("add", add)
+//	err = cfg.Serve(s)
+//
+// Only JSON is read: YAML would take a dependency the module doesn't have.
+// Durations are strings of time.ParseDuration, e.g. "1.5s".
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"simpleRpc/jsonrpc2"
+)
+
+// Config is the config file.
+type Config struct {
+	Server     Server      `json:"server"`
+	Transports []Transport `json:"transports"`
+
+	// ShutdownTimeout bounds how long ServeContext waits for the requests
+	// in flight once its ctx is done. 0 means DefaultShutdownTimeout.
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+}
+
+// DefaultShutdownTimeout is the ShutdownTimeout of a Config not setting it.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Server configures the Server, see its With* options.
+type Server struct {
+	AtMostOnce           bool     `json:"at_most_once"`
+	ReplayResults        bool     `json:"replay_results"`     // of at_most_once, see jsonrpc2.ReplayResults
+	DedupeTTL            Duration `json:"dedupe_ttl"`         // of at_most_once, see jsonrpc2.WithTTL
+	DedupeMaxEntries     int      `json:"dedupe_max_entries"` // of at_most_once, see jsonrpc2.WithMaxEntries
+	MaxConcurrency       int      `json:"max_concurrency"`
+	TenantMaxConcurrency int      `json:"tenant_max_concurrency"`
+	MaxQueue             *int     `json:"max_queue"` // nil means unbounded
+	BatchParallelism     int      `json:"batch_parallelism"`
+	ParamCoercion        bool     `json:"param_coercion"`
+	Pretty               bool     `json:"pretty"`
+	ReadinessGate        bool     `json:"readiness_gate"`
+	StrictSpec           bool     `json:"strict_spec"`
+	PhaseTimings         bool     `json:"phase_timings"`
+	WorkerPool           int      `json:"worker_pool"`
+
+	// AdaptiveConcurrency, if set, replaces MaxConcurrency.
+	AdaptiveConcurrency *AdaptiveConcurrency `json:"adaptive_concurrency"`
+
+	Logging Logging `json:"logging"`
+}
+
+// AdaptiveConcurrency is the jsonrpc2.AdaptiveConcurrency of the Server,
+// 0 for the defaults.
+type AdaptiveConcurrency struct {
+	Min       int     `json:"min"`
+	Max       int     `json:"max"`
+	Initial   int     `json:"initial"`
+	Tolerance float64 `json:"tolerance"`
+	Backoff   float64 `json:"backoff"`
+}
+
+// Logging configures the logs of the Server.
+type Logging struct {
+	// Verbose logs every request and response, whatever SampleEvery.
+	Verbose bool `json:"verbose"`
+
+	// SampleEvery and Errors are the jsonrpc2.LogSampling of the server.
+	SampleEvery int  `json:"sample_every"`
+	Errors      bool `json:"errors"`
+}
+
+// Transport configures a server transport. Kind is one of "http", "tcp",
+// "unix" and "websocket"; the other fields are those of the transports
+// of that kind, and are rejected for the others.
+type Transport struct {
+	Kind string `json:"kind"`
+	Addr string `json:"addr"` // the ListenAddr, a path for "unix"
+	TLS  *TLS   `json:"tls"`  // nil means no TLS
+
+	WriteTimeout       Duration `json:"write_timeout"`
+	ReusePort          bool     `json:"reuse_port"`           // http, tcp, websocket
+	RequestTimeout     Duration `json:"request_timeout"`      // tcp, unix, websocket
+	MaxConnConcurrency int      `json:"max_conn_concurrency"` // tcp, unix, websocket
+	MaxConnQueue       int      `json:"max_conn_queue"`       // tcp, unix, websocket
+	MaxConnections     int      `json:"max_connections"`      // tcp, unix
+	Compression        []string `json:"compression"`          // tcp, unix, websocket
+	MaxFrameSize       int64    `json:"max_frame_size"`       // tcp, unix, websocket
+	AllowOrigins       []string `json:"allow_origins"`        // http
+	TenantHeader       string   `json:"tenant_header"`        // http
+	AllowGob           bool     `json:"allow_gob"`            // http
+}
+
+// TLS configures a transport to serve over TLS the certificate of the
+// files, reloaded when they change (see jsonrpc2.CertReloader).
+type TLS struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+// Duration is a time.Duration written as a string, e.g. "500ms".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration should be a string like \"1.5s\": %s", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads the config file at path, see Parse.
+// It must be JSON, whatever its extension: YAML is not supported.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse the config data. Unknown fields are errors, to catch typos,
+// and so are transports of unknown kinds or with fields of other kinds.
+func Parse(data []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Transports) == 0 {
+		return nil, errors.New("no transports")
+	}
+	if sc := cfg.Server; !sc.AtMostOnce && (sc.ReplayResults || sc.DedupeTTL != 0 || sc.DedupeMaxEntries != 0) {
+		return nil, errors.New("server: replay_results, dedupe_ttl and dedupe_max_entries need at_most_once")
+	}
+	for i, t := range cfg.Transports {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("transports[%d]: %w", i, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// validate tells whether t sets only the fields of its kind.
+func (t *Transport) validate() error {
+	streamOnly := t.RequestTimeout != 0 || t.MaxConnConcurrency != 0 || t.MaxConnQueue != 0 || len(t.Compression) > 0 || t.MaxFrameSize != 0
+	httpOnly := len(t.AllowOrigins) > 0 || t.TenantHeader != "" || t.AllowGob
+
+	var misplaced string
+	switch t.Kind {
+	case "http":
+		if streamOnly || t.MaxConnections != 0 {
+			misplaced = "request_timeout, max_conn_concurrency, max_conn_queue, compression, max_frame_size and max_connections"
+		}
+	case "tcp", "unix":
+		if httpOnly {
+			misplaced = "allow_origins, tenant_header and allow_gob"
+		}
+	case "websocket":
+		if httpOnly || t.MaxConnections != 0 {
+			misplaced = "allow_origins, tenant_header, allow_gob and max_connections"
+		}
+	default:
+		return fmt.Errorf("unknown kind %q, want http, tcp, unix or websocket", t.Kind)
+	}
+	if misplaced != "" {
+		return fmt.Errorf("%s transports don't take %s", t.Kind, misplaced)
+	}
+	if t.ReusePort && t.Kind == "unix" {
+		return errors.New("unix transports don't take reuse_port")
+	}
+	if t.TLS != nil && (t.TLS.CertFile == "" || t.TLS.KeyFile == "") {
+		return errors.New("tls needs cert_file and key_file")
+	}
+	return nil
+}
+
+// sampling is the jsonrpc2.LogSampling of l.
+func (l Logging) sampling() jsonrpc2.LogSampling {
+	s := jsonrpc2.LogSampling{Every: l.SampleEvery, Errors: l.Errors}
+	if l.Verbose {
+		s.Every = 1
+	}
+	return s
+}
+
+// NewServer makes a Server configured by c.Server, to register the methods on.
+func (c *Config) NewServer() jsonrpc2.Server {
+	sc := c.Server
+	s := jsonrpc2.NewServer().
+		WithMaxConcurrency(sc.MaxConcurrency).
+		WithTenantMaxConcurrency(sc.TenantMaxConcurrency).
+		WithBatchParallelism(sc.BatchParallelism).
+		WithParamCoercion(sc.ParamCoercion).
+		WithPretty(sc.Pretty).
+		WithPhaseTimings(sc.PhaseTimings).
+		WithWorkerPool(sc.WorkerPool).
+		WithLogSampling(sc.Logging.sampling())
+	if ac := sc.AdaptiveConcurrency; ac != nil {
+		s.WithAdaptiveConcurrency(jsonrpc2.AdaptiveConcurrency(*ac))
+	}
+	if sc.MaxQueue != nil {
+		s.WithMaxQueue(*sc.MaxQueue)
+	}
+	if sc.AtMostOnce {
+		opts := []jsonrpc2.AtMostOnceOption{
+			jsonrpc2.WithTTL(time.Duration(sc.DedupeTTL)),
+			jsonrpc2.WithMaxEntries(sc.DedupeMaxEntries),
+		}
+		if sc.ReplayResults {
+			opts = append(opts, jsonrpc2.ReplayResults())
+		}
+		s.WithAtMostOnce(opts...)
+	}
+	if sc.ReadinessGate {
+		s.WithReadinessGate()
+	}
+	if sc.StrictSpec {
+		s.WithStrictSpec()
+	}
+	return s
+}
+
+// NewTransports makes the server transports of c.Transports, in order.
+func (c *Config) NewTransports() ([]jsonrpc2.ServerTransport, error) {
+	transports := make([]jsonrpc2.ServerTransport, 0, len(c.Transports))
+	for i, t := range c.Transports {
+		st, err := t.newTransport()
+		if err != nil {
+			return nil, fmt.Errorf("transports[%d]: %w", i, err)
+		}
+		transports = append(transports, st)
+	}
+	return transports, nil
+}
+
+// newTransport makes the server transport configured by t.
+func (t *Transport) newTransport() (jsonrpc2.ServerTransport, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	var reloader *jsonrpc2.CertReloader
+	if t.TLS != nil {
+		var err error
+		if reloader, err = jsonrpc2.NewCertReloader(t.TLS.CertFile, t.TLS.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	switch t.Kind {
+	case "http":
+		st := jsonrpc2.NewHttpServerTransport(t.Addr)
+		st.WriteTimeout = time.Duration(t.WriteTimeout)
+		st.AllowOrigins = t.AllowOrigins
+		st.TenantHeader = t.TenantHeader
+		st.AllowGob = t.AllowGob
+		st.ReusePort = t.ReusePort
+		if reloader != nil {
+			st.TLSConfig = reloader.TLSConfig()
+		}
+		return st, nil
+	case "websocket":
+		st := jsonrpc2.NewWebSocketServerTransport(t.Addr)
+		st.WriteTimeout = time.Duration(t.WriteTimeout)
+		st.RequestTimeout = time.Duration(t.RequestTimeout)
+		st.MaxConnConcurrency = t.MaxConnConcurrency
+		st.MaxConnQueue = t.MaxConnQueue
+		st.Compression = t.Compression
+		st.MaxMessageSize = t.MaxFrameSize
+		st.ReusePort = t.ReusePort
+		if reloader != nil {
+			st.TLSConfig = reloader.TLSConfig()
+		}
+		return st, nil
+	default: // tcp, unix
+		st := &jsonrpc2.StreamServerTransport{
+			Network:            t.Kind,
+			ListenAddr:         t.Addr,
+			WriteTimeout:       time.Duration(t.WriteTimeout),
+			RequestTimeout:     time.Duration(t.RequestTimeout),
+			MaxConnConcurrency: t.MaxConnConcurrency,
+			MaxConnQueue:       t.MaxConnQueue,
+			MaxConnections:     t.MaxConnections,
+			Compression:        t.Compression,
+			MaxFrameSize:       t.MaxFrameSize,
+			ReusePort:          t.ReusePort,
+		}
+		if reloader != nil {
+			st.TLSConfig = reloader.TLSConfig()
+		}
+		return st, nil
+	}
+}
+
+// Serve s on all the transports of c, until one of them fails: the others
+// are shut down as by ServeContext, and its error is returned.
+func (c *Config) Serve(s jsonrpc2.Server) error {
+	return c.ServeContext(context.Background(), s)
+}
+
+// shutdowner is a server transport able to shut down gracefully,
+// e.g. jsonrpc2.HttpServerTransport.
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// ServeContext is Serve until ctx is done, then shuts the transports down
+// gracefully: they stop taking requests, and the requests in flight are
+// answered, waiting up to ShutdownTimeout. It returns nil once they are
+// shut down, or the first error of a transport failing to.
+// If a transport fails to serve before, the others are shut down all the
+// same, and its error is returned.
+//
+// WebSocket transports can't be shut down: they are left serving.
+func (c *Config) ServeContext(ctx context.Context, s jsonrpc2.Server) error {
+	transports, err := c.NewTransports()
+	if err != nil {
+		return err
+	}
+	errs := make(chan error, len(transports))
+	for _, st := range transports {
+		st := st
+		go func() { errs <- st.Serve(s) }()
+	}
+
+	var serveErr error
+	select {
+	case serveErr = <-errs: // not to leave the others serving
+	case <-ctx.Done():
+	}
+	if err := c.shutdown(transports); serveErr == nil {
+		return err
+	}
+	return serveErr
+}
+
+// shutdown the transports gracefully, waiting up to ShutdownTimeout.
+// It returns the first error of a transport failing to.
+func (c *Config) shutdown(transports []jsonrpc2.ServerTransport) error {
+	timeout := time.Duration(c.ShutdownTimeout)
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	shutdownErrs := make(chan error, len(transports))
+	for _, st := range transports {
+		st := st
+		go func() {
+			if sd, ok := st.(shutdowner); ok {
+				shutdownErrs <- sd.Shutdown(shutdownCtx)
+			} else {
+				shutdownErrs <- nil
+			}
+		}()
+	}
+	var firstErr error
+	for range transports {
+		if err := <-shutdownErrs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
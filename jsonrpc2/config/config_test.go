@@ -0,0 +1,169 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"simpleRpc/jsonrpc2"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`{
+		"server": {"at_most_once": true, "max_queue": 0, "readiness_gate": true},
+		"transports": [
+			{"kind": "http", "addr": ":5680", "write_timeout": "10s", "allow_gob": true},
+			{"kind": "tcp", "addr": ":5679", "request_timeout": "1.5s", "max_connections": 100},
+			{"kind": "unix", "addr": "/tmp/rpc.sock"},
+			{"kind": "websocket", "addr": ":5681", "max_conn_concurrency": 4}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("❌ Parse: %v", err)
+	}
+
+	transports, err := cfg.NewTransports()
+	if err != nil {
+		t.Fatalf("❌ NewTransports: %v", err)
+	}
+	if len(transports) != 4 {
+		t.Fatalf("❌ got %d transports, want 4", len(transports))
+	}
+	if h, ok := transports[0].(*jsonrpc2.HttpServerTransport); !ok || h.ListenAddr != ":5680" || h.WriteTimeout != 10*time.Second || !h.AllowGob {
+		t.Errorf("❌ bad http transport: %#v", transports[0])
+	}
+	if s, ok := transports[1].(*jsonrpc2.StreamServerTransport); !ok || s.Network != "tcp" || s.RequestTimeout != 1500*time.Millisecond || s.MaxConnections != 100 {
+		t.Errorf("❌ bad tcp transport: %#v", transports[1])
+	}
+	if s, ok := transports[2].(*jsonrpc2.StreamServerTransport); !ok || s.Network != "unix" || s.ListenAddr != "/tmp/rpc.sock" {
+		t.Errorf("❌ bad unix transport: %#v", transports[2])
+	}
+	if w, ok := transports[3].(*jsonrpc2.WebSocketServerTransport); !ok || w.MaxConnConcurrency != 4 {
+		t.Errorf("❌ bad websocket transport: %#v", transports[3])
+	}
+
+	s := cfg.NewServer()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+	id := int64(1)
+	req := &jsonrpc2.Request{JsonRpc: jsonrpc2.JsonRpc2, Method: "echo", Params: json.RawMessage(`"x"`), Id: jsonrpc2.Int64ID(id)}
+	if resp := s.ServeRPC(context.Background(), req); resp.Error == nil || resp.Error.Code != jsonrpc2.ErrNotReady().Code {
+		t.Errorf("❌ want the readiness gate on, got %+v", resp)
+	}
+	s.SetReady()
+	if resp := s.ServeRPC(context.Background(), req); resp.Error != nil {
+		t.Errorf("❌ echo: %v", resp.Error)
+	}
+	if resp := s.ServeRPC(context.Background(), req); resp.Error == nil || resp.Error.Code != jsonrpc2.ErrAtMostOnce().Code {
+		t.Errorf("❌ want at-most-once on, got %+v", resp)
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	tests := []struct {
+		name, config string
+	}{
+		{"not json", `transports: []`},
+		{"unknown field", `{"transports": [{"kind": "http", "addr": ":1", "write_timout": "1s"}]}`},
+		{"no transports", `{"server": {"pretty": true}}`},
+		{"unknown kind", `{"transports": [{"kind": "grpc", "addr": ":1"}]}`},
+		{"bad duration", `{"transports": [{"kind": "tcp", "addr": ":1", "request_timeout": 5}]}`},
+		{"field of another kind", `{"transports": [{"kind": "tcp", "addr": ":1", "allow_origins": ["*"]}]}`},
+		{"reuse port of unix", `{"transports": [{"kind": "unix", "addr": "/tmp/rpc.sock", "reuse_port": true}]}`},
+		{"frame size of http", `{"transports": [{"kind": "http", "addr": ":1", "max_frame_size": 1024}]}`},
+		{"half tls", `{"transports": [{"kind": "http", "addr": ":1", "tls": {"cert_file": "a.crt"}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.config)); err == nil {
+				t.Errorf("❌ want an error for %s", tt.config)
+			}
+		})
+	}
+}
+
+func TestConfig_ServeContext(t *testing.T) {
+	// a free port, to serve on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cfg, err := Parse([]byte(`{"transports": [{"kind": "tcp", "addr": "` + addr + `"}], "shutdown_timeout": "1s"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := cfg.NewServer()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- cfg.ServeContext(ctx, s) }()
+
+	c := jsonrpc2.NewClient(jsonrpc2.NewTcpClientTransport(addr))
+	defer c.Close()
+	var got string
+	for i := 0; i < 50; i++ { // while the server starts
+		if err = c.Call("echo", "hi", &got); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || got != "hi" {
+		t.Fatalf("❌ echo = %q, %v", got, err)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("❌ ServeContext = %v, want nil once shut down", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("❌ ServeContext didn't return once ctx was done")
+	}
+}
+
+func TestConfig_ServeContext_failing(t *testing.T) {
+	// a port taken, to fail serving on, and a free one
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cfg, err := Parse([]byte(`{"transports": [{"kind": "tcp", "addr": "` + addr + `"}, {"kind": "tcp", "addr": "` + busy.Addr().String() + `"}], "shutdown_timeout": "1s"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := cfg.NewServer()
+
+	served := make(chan error, 1)
+	go func() { served <- cfg.ServeContext(context.Background(), s) }()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Fatal("❌ ServeContext = nil, want the error of the port taken")
+		}
+		t.Logf("✅ ServeContext = %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("❌ ServeContext didn't return once a transport failed")
+	}
+
+	for i := 0; i < 20; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			t.Fatal("❌ the other transport is left serving")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
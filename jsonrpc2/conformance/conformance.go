@@ -0,0 +1,200 @@
+// Package conformance checks jsonrpc2 transports against the examples of the
+// JSON-RPC 2.0 specification (https://www.jsonrpc.org/specification#examples).
+//
+// The examples are kept as raw JSON fixtures. Run sends each fixture request
+// through a Target, i.e. a client end of any ServerTransport serving NewServer,
+// and compares the raw reply to the expected response.
+//
+// e.g.
+//
+//	st := jsonrpc2.NewHttpServerTransport("")
+//	st.Use(conformance.NewServer())
+//	ts := httptest.NewServer(st)
+//	conformance.Run(t, conformance.HttpTarget(ts.URL))
+package conformance
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"simpleRpc/jsonrpc2"
+)
+
+//go:embed fixtures.json
+var fixturesJSON []byte
+
+// Feature is an optional part of the spec that a fixture relies on.
+type Feature string
+
+const (
+	FeatureNotifications Feature = "notifications" // requests without id, answered with nothing
+	FeatureStringIds     Feature = "string-ids"    // "id": "1"
+)
+
+// Fixture is a raw request and the raw response expected for it.
+type Fixture struct {
+	Name     string    `json:"name"`
+	Request  string    `json:"request"`
+	Response string    `json:"response"` // empty if nothing should be responded
+	Requires []Feature `json:"requires,omitempty"`
+}
+
+// Fixtures returns the canonical fixtures.
+func Fixtures() []Fixture {
+	var fixtures []Fixture
+	if err := json.Unmarshal(fixturesJSON, &fixtures); err != nil {
+		panic("conformance: bad fixtures.json: " + err.Error())
+	}
+	return fixtures
+}
+
+// Target is the client end of a served transport: it sends a raw request
+// and returns the raw response. An empty response means nothing was responded.
+type Target interface {
+	Send(request []byte) (response []byte, err error)
+}
+
+// Run checks every fixture against target, each as a subtest.
+// Fixtures requiring an unsupported Feature are skipped.
+func Run(t *testing.T, target Target, unsupported ...Feature) {
+	for _, f := range Fixtures() {
+		f := f
+		t.Run(f.Name, func(t *testing.T) {
+			for _, u := range unsupported {
+				for _, r := range f.Requires {
+					if u == r {
+						t.Skipf("feature %q unsupported", r)
+					}
+				}
+			}
+
+			got, err := target.Send([]byte(f.Request))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Compare(got, []byte(f.Response)); err != nil {
+				t.Errorf("❌ %v\nrequest = %s\ngot     = %s\nwant    = %s", err, f.Request, got, f.Response)
+			}
+		})
+	}
+}
+
+// Compare checks whether the got response is equivalent to the want response.
+//
+// Both are parsed so formatting doesn't matter. The "data" of errors is
+// ignored, since it's up to the implementation, and responses of a batch may
+// come in any order, as the spec allows.
+func Compare(got, want []byte) error {
+	if len(want) == 0 {
+		if len(trimSpace(got)) != 0 {
+			return errors.New("no response expected")
+		}
+		return nil
+	}
+
+	g, err := normalize(got)
+	if err != nil {
+		return errors.New("bad response: " + err.Error())
+	}
+	w, err := normalize(want)
+	if err != nil {
+		return errors.New("bad fixture: " + err.Error())
+	}
+	if !reflect.DeepEqual(g, w) {
+		return errors.New("response mismatch")
+	}
+	return nil
+}
+
+// normalize parses a response (or a batch of responses) into plain values,
+// dropping error data and sorting batches.
+func normalize(data []byte) (any, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	dropErrorData := func(v any) {
+		if obj, ok := v.(map[string]any); ok {
+			if e, ok := obj["error"].(map[string]any); ok {
+				delete(e, "data")
+			}
+		}
+	}
+
+	batch, ok := v.([]any)
+	if !ok {
+		dropErrorData(v)
+		return v, nil
+	}
+
+	keys := make([]string, len(batch))
+	for i, r := range batch {
+		dropErrorData(r)
+		b, _ := json.Marshal(r) // map keys are sorted by json.Marshal
+		keys[i] = string(b)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func trimSpace(b []byte) []byte {
+	for len(b) > 0 && (b[0] == ' ' || b[0] == '\n' || b[0] == '\r' || b[0] == '\t') {
+		b = b[1:]
+	}
+	return b
+}
+
+// NewServer creates a Server with the methods used in the spec examples:
+// subtract, sum, update, notify_hello, notify_sum and get_data.
+func NewServer() jsonrpc2.Server {
+	s := jsonrpc2.NewServer()
+	must(s.Register("subtract", func(p subtractParams) (int, error) {
+		return p.Minuend - p.Subtrahend, nil
+	}))
+	must(s.Register("sum", func(p []int) (int, error) {
+		sum := 0
+		for _, n := range p {
+			sum += n
+		}
+		return sum, nil
+	}))
+	must(s.Register("update", func(p []int) (*struct{}, error) { return nil, nil }))
+	must(s.Register("notify_hello", func(p []int) (*struct{}, error) { return nil, nil }))
+	must(s.Register("notify_sum", func(p []int) (*struct{}, error) { return nil, nil }))
+	must(s.Register("get_data", func(p json.RawMessage) ([]any, error) {
+		return []any{"hello", 5}, nil
+	}))
+	return s
+}
+
+// subtractParams accepts both positional [minuend, subtrahend]
+// and named {"minuend": ..., "subtrahend": ...} params.
+type subtractParams struct {
+	Minuend    int `json:"minuend"`
+	Subtrahend int `json:"subtrahend"`
+}
+
+func (p *subtractParams) UnmarshalJSON(data []byte) error {
+	var positional []int
+	if err := json.Unmarshal(data, &positional); err == nil {
+		if len(positional) != 2 {
+			return errors.New("subtract expects 2 params")
+		}
+		p.Minuend, p.Subtrahend = positional[0], positional[1]
+		return nil
+	}
+
+	type named subtractParams // no UnmarshalJSON method
+	return json.Unmarshal(data, (*named)(p))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
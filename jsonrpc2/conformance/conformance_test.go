@@ -0,0 +1,42 @@
+package conformance
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"simpleRpc/jsonrpc2"
+)
+
+func TestHttpServerTransport(t *testing.T) {
+	st := jsonrpc2.NewHttpServerTransport("")
+	st.Use(NewServer())
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	Run(t, HttpTarget(ts.URL))
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name    string
+		got     string
+		want    string
+		wantErr bool
+	}{
+		{"same", `{"jsonrpc":"2.0","result":19,"id":1}`, `{"jsonrpc": "2.0", "result": 19, "id": 1}`, false},
+		{"errorData", `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error","data":"x"},"id":null}`,
+			`{"jsonrpc": "2.0", "error": {"code": -32700, "message": "Parse error"}, "id": null}`, false},
+		{"batchOrder", `[{"result":1,"id":1},{"result":2,"id":2}]`, `[{"result":2,"id":2},{"result":1,"id":1}]`, false},
+		{"different", `{"jsonrpc":"2.0","result":18,"id":1}`, `{"jsonrpc":"2.0","result":19,"id":1}`, true},
+		{"unexpected", `{"jsonrpc":"2.0","result":18,"id":1}`, ``, true},
+		{"nothing", ``, ``, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Compare([]byte(tt.got), []byte(tt.want))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Compare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
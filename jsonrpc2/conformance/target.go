@@ -0,0 +1,19 @@
+package conformance
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// HttpTarget is a Target that POSTs requests to the URL.
+type HttpTarget string
+
+func (u HttpTarget) Send(request []byte) ([]byte, error) {
+	resp, err := http.Post(string(u), "application/json", bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,22 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DebugHandler serves Server.Stats as JSON, for humans and scrapers.
+//
+// e.g.
+//
+//	http.Handle("/debug/jsonrpc2", jsonrpc2.DebugHandler(s))
+func DebugHandler(s Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(s.Stats()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package jsonrpc2
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AtMostOnceOption configures the at-most-once dedupe, see Server.WithAtMostOnce.
+type AtMostOnceOption func(*atMostOnceConfig)
+
+// atMostOnceConfig is what the AtMostOnceOptions tell.
+type atMostOnceConfig struct {
+	replay     bool          // see ReplayResults
+	ttl        time.Duration // see WithTTL
+	maxEntries int           // see WithMaxEntries
+}
+
+// WithTTL makes the dedupe forget the ids ttl after they were first seen:
+// a request repeating an id older than that is executed again. Set it
+// well above how long clients keep retrying. ttl <= 0 (the default) keeps
+// the ids forever.
+func WithTTL(ttl time.Duration) AtMostOnceOption {
+	return func(c *atMostOnceConfig) {
+		c.ttl = ttl
+	}
+}
+
+// WithMaxEntries bounds how many ids the dedupe remembers: beyond n, the
+// oldest ones are forgotten, counted as "dedupe.evictions" in Metrics and
+// Stats. n <= 0 (the default) means no bound.
+func WithMaxEntries(n int) AtMostOnceOption {
+	return func(c *atMostOnceConfig) {
+		c.maxEntries = n
+	}
+}
+
+// ReplayResults makes duplicated requests be answered the response of the
+// original one, instead of ErrAtMostOnce: a client retrying a call whose
+// response was lost gets its result, while the method is still executed
+// only once. A duplicate of a request in flight waits for its response.
+// The responses of the streaming methods (StreamFunc, ResultWriter) are
+// not kept, they may be streamed: their duplicates get ErrAtMostOnce.
+//
+// The responses are kept for as long as their ids are, see WithTTL and
+// WithMaxEntries.
+func ReplayResults() AtMostOnceOption {
+	return func(c *atMostOnceConfig) {
+		c.replay = true
+	}
+}
+
+// clientDedupeKey scopes the at-most-once dedupe key of an id to the client
+// session sending it, see Request.Client. Like tenantDedupeKey, the length
+// prefix keeps it unambiguous whatever the client contains.
+func clientDedupeKey(client, key string) string {
+	return "c" + strconv.Itoa(len(client)) + ":" + client + "/" + key
+}
+
+// dedupeEntry is the value of a request in the dedupe store.
+type dedupeEntry struct {
+	// replaying results only:
+	done     chan struct{} // closed once resp is set, or the entry is forgotten
+	resp     *Response     // of the original request, nil if forgotten
+	streamed bool          // the original request was served, its response not kept
+}
+
+// replay the response of e to the duplicate request of id.
+func (e *dedupeEntry) replay(id *ID) *Response {
+	resp := *e.resp
+	resp.Id = id
+	return &resp
+}
+
+// dedupeRequest looks req up by key in the dedupe store, storing it if it's
+// new. For a duplicate, it returns the response to answer: ErrAtMostOnce, or
+// the response of the original request (waited for) with ReplayResults.
+// Else it returns the entry of req. With ReplayResults, the caller must set
+// its response (or forget it) and then close done.
+func (s *server) dedupeRequest(ctx context.Context, key string, req *Request, metrics Metrics, info *TransportInfo) (*dedupeEntry, *Response) {
+	entry := &dedupeEntry{}
+	if s.atMostOnceConfig.replay {
+		entry.done = make(chan struct{})
+	}
+	for first := true; ; first = false {
+		original, dup := s.atMostOnce.loadOrStore(key, entry, s.clock.Now())
+		if first || !dup { // a new entry counts, even after a forgotten original
+			s.dedupe.record(dup, metrics, s.metrics)
+		}
+		if !dup {
+			return entry, nil
+		}
+		if first {
+			s.events.emit(Event{Kind: EventDedupeHit, Method: req.Method, Id: req.Id, Transport: info})
+		}
+		if !s.atMostOnceConfig.replay {
+			return nil, errorResponse(req.Id, ErrAtMostOnce())
+		}
+
+		select {
+		case <-original.done:
+		case <-ctx.Done():
+			return nil, errorResponse(req.Id, ErrRequestCancelled().WithReason(ctx.Err().Error()))
+		}
+		if original.streamed {
+			return nil, errorResponse(req.Id, ErrAtMostOnce())
+		}
+		if original.resp != nil {
+			return nil, original.replay(req.Id)
+		}
+		// the original was forgotten without running: try again to be it
+	}
+}
+
+// dedupeStore is the dedupe store of the at-most-once server: the entries
+// of the requests by key, the oldest first, expired after ttl and evicted
+// beyond maxEntries.
+type dedupeStore struct {
+	ttl        time.Duration // 0: none
+	maxEntries int           // 0: no bound
+	onEvict    func()        // called for every entry expired or evicted, under mu
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *dedupeItem, in order
+	order   *list.List               // the oldest first
+}
+
+// dedupeItem is an entry in the order of a dedupeStore.
+type dedupeItem struct {
+	key   string
+	entry *dedupeEntry
+	added time.Time
+}
+
+func newDedupeStore(c atMostOnceConfig, onEvict func()) *dedupeStore {
+	return &dedupeStore{
+		ttl:        c.ttl,
+		maxEntries: c.maxEntries,
+		onEvict:    onEvict,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// loadOrStore returns the entry of key, if any, else stores entry for it at now.
+func (d *dedupeStore) loadOrStore(key string, entry *dedupeEntry, now time.Time) (actual *dedupeEntry, loaded bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expire(now)
+	if e, ok := d.entries[key]; ok {
+		return e.Value.(*dedupeItem).entry, true
+	}
+	d.entries[key] = d.order.PushBack(&dedupeItem{key: key, entry: entry, added: now})
+	for d.maxEntries > 0 && d.order.Len() > d.maxEntries {
+		d.remove(d.order.Front())
+		d.onEvict()
+	}
+	return entry, false
+}
+
+// delete the entry of key, if it's still entry, telling whether it was.
+func (d *dedupeStore) delete(key string, entry *dedupeEntry) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.entries[key]
+	if !ok || e.Value.(*dedupeItem).entry != entry {
+		return false
+	}
+	d.remove(e)
+	return true
+}
+
+// expire the entries older than ttl at now. d.mu must be held.
+func (d *dedupeStore) expire(now time.Time) {
+	if d.ttl <= 0 {
+		return
+	}
+	for e := d.order.Front(); e != nil && now.Sub(e.Value.(*dedupeItem).added) >= d.ttl; e = d.order.Front() {
+		d.remove(e)
+		d.onEvict()
+	}
+}
+
+// remove e. d.mu must be held.
+func (d *dedupeStore) remove(e *list.Element) {
+	delete(d.entries, e.Value.(*dedupeItem).key)
+	d.order.Remove(e)
+}
+
+// dedupeStats counts the lookups of the at-most-once dedupe store.
+type dedupeStats struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	entries   atomic.Int64
+	evictions atomic.Int64
+}
+
+// record a lookup into the counters and the metrics m (maybe labeled by
+// tenant), and the size of the store into the gauge of server.
+func (d *dedupeStats) record(hit bool, m, server Metrics) {
+	if hit {
+		d.hits.Add(1)
+		m.Add("dedupe.hits", 1)
+		return
+	}
+	d.misses.Add(1)
+	m.Add("dedupe.misses", 1)
+	server.Set("dedupe.entries", d.entries.Add(1))
+}
+
+// forget an entry removed from the store.
+func (d *dedupeStats) forget(server Metrics) {
+	server.Set("dedupe.entries", d.entries.Add(-1))
+}
+
+// evict an entry expired or evicted from the store.
+func (d *dedupeStats) evict(server Metrics) {
+	d.evictions.Add(1)
+	server.Add("dedupe.evictions", 1)
+	d.forget(server)
+}
+
+func (d *dedupeStats) reset() {
+	d.hits.Store(0)
+	d.misses.Store(0)
+	d.entries.Store(0)
+	d.evictions.Store(0)
+}
+
+// stats reports the counters, and the percentage of lookups that were hits.
+func (d *dedupeStats) stats() map[string]int64 {
+	hits, misses := d.hits.Load(), d.misses.Load()
+	stats := map[string]int64{
+		"dedupe.hits":         hits,
+		"dedupe.misses":       misses,
+		"dedupe.entries":      d.entries.Load(),
+		"dedupe.evictions":    d.evictions.Load(),
+		"dedupe.hit_rate_pct": 0,
+	}
+	if total := hits + misses; total > 0 {
+		stats["dedupe.hit_rate_pct"] = hits * 100 / total
+	}
+	return stats
+}
@@ -0,0 +1,123 @@
+package jsonrpc2
+
+// 这个文件实现服务描述 (ServiceDesc)：服务名与各方法的名字只在描述中写一次，
+// 服务端 RegisterDesc 与客户端 NewDescClient 都由它得到方法名，两端的方法名字符串不会再各写各的而不一致。
+//
+// 方法由方法表达式 (method expression，如 Service.Lock) 描述，
+// 服务端据此把实现绑定为 Func (无需反射)，客户端据此以相同的参数、结果类型调用。
+
+import (
+	"context"
+	"fmt"
+)
+
+// ServiceDesc describes a service of the interface API once, for both its
+// server (RegisterDesc) and its clients (NewDescClient):
+//
+//	var (
+//		lockMethod   = &jsonrpc2.MethodDesc[Service, *LockRequest, *LockResponse]{Name: "Lock", Func: Service.Lock}
+//		unlockMethod = &jsonrpc2.MethodDesc[Service, *UnlockRequest, *UnlockResponse]{Name: "Unlock", Func: Service.Unlock}
+//	)
+//
+//	var ServiceDesc = &jsonrpc2.ServiceDesc[Service]{
+//		Name:      "lock",
+//		Methods:   []jsonrpc2.Method[Service]{lockMethod, unlockMethod},
+//		NewClient: func(c *jsonrpc2.DescClient[Service]) Service { return descClient{c} },
+//	}
+//
+// where descClient implements Service by the MethodDesc:
+//
+//	func (s descClient) Lock(ctx context.Context, req *LockRequest) (*LockResponse, error) {
+//		return lockMethod.Call(ctx, s.c, req)
+//	}
+type ServiceDesc[API any] struct {
+	Name    string        // of the service: the methods are called Name.Method
+	Methods []Method[API] // the MethodDesc of the methods
+
+	// NewClient makes the API calling the methods on the server of c,
+	// by the Call of their MethodDesc. See NewDescClient.
+	NewClient func(c *DescClient[API]) API
+}
+
+// Method is a method of the API of a ServiceDesc: a *MethodDesc.
+type Method[API any] interface {
+	// MethodName is the name of the method, without the service name.
+	MethodName() string
+
+	// bind the method to impl, making the function to register.
+	bind(impl API) any
+
+	// options to register the method with.
+	options() []MethodOption
+}
+
+// MethodDesc describes a method of API taking T and returning R.
+type MethodDesc[API, T, R any] struct {
+	Name string // without the service name
+
+	// Func is the method expression of the method, e.g. Service.Lock.
+	Func func(impl API, ctx context.Context, arg T) (R, error)
+
+	// Options to register the method with, e.g. Unlimited.
+	Options []MethodOption
+}
+
+func (m *MethodDesc[API, T, R]) MethodName() string {
+	return m.Name
+}
+
+func (m *MethodDesc[API, T, R]) options() []MethodOption {
+	return m.Options
+}
+
+func (m *MethodDesc[API, T, R]) bind(impl API) any {
+	return Func[T, R](func(ctx context.Context, arg T) (R, error) {
+		return m.Func(impl, ctx, arg)
+	})
+}
+
+// Call the method on the server of c.
+func (m *MethodDesc[API, T, R]) Call(ctx context.Context, c *DescClient[API], arg T) (R, error) {
+	var ret R
+	err := c.c.CallContext(ctx, c.desc.Name+"."+m.Name, arg, &ret)
+	return ret, err
+}
+
+// DescClient calls the methods of a ServiceDesc, see MethodDesc.Call.
+type DescClient[API any] struct {
+	c    Client
+	desc *ServiceDesc[API]
+}
+
+// NewDescClient makes the API of desc calling the server of c, by desc.NewClient.
+// It panics if desc has no NewClient.
+func NewDescClient[API any](c Client, desc *ServiceDesc[API]) API {
+	if desc.NewClient == nil {
+		panic(fmt.Sprintf("jsonrpc2: service %s has no NewClient", desc.Name))
+	}
+	return desc.NewClient(&DescClient[API]{c: c, desc: desc})
+}
+
+// RegisterDesc registers the methods of desc on s, served by impl, as
+// desc.Name.Method, with the Options of their MethodDesc: all of them, or
+// none if any fails.
+func RegisterDesc[API any](s Server, desc *ServiceDesc[API], impl API) error {
+	if len(desc.Methods) == 0 {
+		return fmt.Errorf("register service %s: no methods", desc.Name)
+	}
+	methods := make(map[string]any, len(desc.Methods))
+	for _, m := range desc.Methods {
+		if m == nil || m.MethodName() == "" {
+			return fmt.Errorf("register service %s: method without a name", desc.Name)
+		}
+		name := desc.Name + "." + m.MethodName()
+		if _, dup := methods[name]; dup {
+			return fmt.Errorf("register service %s: multiple methods %s", desc.Name, name)
+		}
+		methods[name] = m.bind(impl)
+		if opts := m.options(); len(opts) > 0 {
+			methods[name] = methodWithOptions{f: methods[name], opts: opts}
+		}
+	}
+	return s.RegisterAll(methods)
+}
@@ -0,0 +1,62 @@
+package jsonrpc2
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type descCounter interface {
+	Add(ctx context.Context, n int) (int, error)
+}
+
+type descCounterImpl struct{ total int }
+
+func (c *descCounterImpl) Add(ctx context.Context, n int) (int, error) {
+	c.total += n
+	return c.total, nil
+}
+
+var descAdd = &MethodDesc[descCounter, int, int]{Name: "Add", Func: descCounter.Add}
+
+type descCounterClient struct{ c *DescClient[descCounter] }
+
+func (c descCounterClient) Add(ctx context.Context, n int) (int, error) {
+	return descAdd.Call(ctx, c.c, n)
+}
+
+var descCounterDesc = &ServiceDesc[descCounter]{
+	Name:      "counter",
+	Methods:   []Method[descCounter]{descAdd},
+	NewClient: func(c *DescClient[descCounter]) descCounter { return descCounterClient{c} },
+}
+
+func TestServiceDesc(t *testing.T) {
+	s := NewServer()
+	if err := RegisterDesc[descCounter](s, descCounterDesc, &descCounterImpl{}); err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := s.(*server).methods["counter.Add"]; !ok {
+		t.Fatal("❌ counter.Add not registered")
+	} else if _, typed := h.(*typedHandler[int, int]); !typed {
+		t.Errorf("❌ counter.Add dispatched by %T, want a typedHandler", h)
+	}
+
+	counter := NewDescClient(NewClient(&serverTransport{server: s}), descCounterDesc)
+	for i, want := range []int{2, 5} {
+		got, err := counter.Add(context.Background(), i+2)
+		if err != nil || got != want {
+			t.Errorf("❌ Add(%d) = %d, %v; want %d", i+2, got, err, want)
+		}
+	}
+
+	// registering again fails as a whole
+	if err := RegisterDesc[descCounter](s, descCounterDesc, &descCounterImpl{}); err == nil {
+		t.Error("❌ registering counter twice: want error")
+	}
+
+	dup := &ServiceDesc[descCounter]{Name: "dup", Methods: []Method[descCounter]{descAdd, descAdd}}
+	if err := RegisterDesc[descCounter](s, dup, &descCounterImpl{}); err == nil || !strings.Contains(err.Error(), "multiple") {
+		t.Errorf("❌ registering a method twice: err = %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package jsonrpc2
+
+// 这个文件实现 rpc. 开头的保留方法 (JSON-RPC 2.0 规范保留了这个前缀，用于系统扩展)。
+//
+// 用户不能注册 rpc. 开头的方法 (除非 WithReservedNames 放开)，
+// 这些名字被路由到 Server 自带的系统方法：
+//   - rpc.discover: 列出所有方法及其参数与结果的 JSON Schema (OpenRPC 格式)；
+//   - rpc.describe: 单个方法的参数与结果的 JSON Schema，比 rpc.discover 轻量；
+//   - rpc.health: 健康检查；
+//   - rpc.cancel: 取消在途请求，见 cancel.go。
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ReservedPrefix starts the names of methods reserved by the spec for
+// system extensions. Only the built-in methods of a Server may use it.
+const ReservedPrefix = "rpc."
+
+// isReserved tells whether name is in the reserved namespace.
+func isReserved(name string) bool {
+	return strings.HasPrefix(name, ReservedPrefix)
+}
+
+const (
+	// MethodDiscover is the built-in method describing all the methods of
+	// a Server. It takes no params, and returns a DiscoverResult.
+	MethodDiscover = "rpc.discover"
+
+	// MethodDescribe is the built-in method describing a single method,
+	// lighter than MethodDiscover. The params is DescribeParams (or just
+	// the name of the method), the result a MethodDescriptor.
+	MethodDescribe = "rpc.describe"
+
+	// MethodHealth is the built-in health check. It takes no params,
+	// and returns a HealthResult.
+	MethodHealth = "rpc.health"
+)
+
+// OpenRPCVersion is the version of the OpenRPC specification DiscoverResult follows.
+const OpenRPCVersion = "1.2.6"
+
+// DiscoverResult is the result of MethodDiscover: an OpenRPC document.
+type DiscoverResult struct {
+	OpenRPC string             `json:"openrpc"`
+	Info    DiscoverInfo       `json:"info"`
+	Methods []MethodDescriptor `json:"methods"`
+}
+
+type DiscoverInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// MethodDescriptor describes a method.
+//
+// The params of a method is a single value (not a list of arguments),
+// so Params always has one ContentDescriptor, named "params". Those of
+// the functions of positional parameters (see Register) are an array,
+// whose Schema has the PrefixItems of the parameters.
+type MethodDescriptor struct {
+	Name        string              `json:"name"`
+	Summary     string              `json:"summary,omitempty"`     // see WithDoc
+	Description string              `json:"description,omitempty"` // see WithDoc
+	Params      []ContentDescriptor `json:"params"`
+	Result      *ContentDescriptor  `json:"result,omitempty"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+}
+
+// ContentDescriptor describes the params or the result of a method.
+type ContentDescriptor struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"` // see WithParamDoc
+	Schema      *Schema `json:"schema"`
+}
+
+// DescribeParams is the params of MethodDescribe.
+type DescribeParams struct {
+	Method string `json:"method"`
+}
+
+// HealthResult is the result of MethodHealth.
+type HealthResult struct {
+	Status string `json:"status"` // "ok", or "starting" before Server.SetReady
+}
+
+// signer is implemented by the handlers knowing the types of their params
+// and result. nil types mean unknown.
+type signer interface {
+	signature() (params, result reflect.Type)
+}
+
+func (m *method) signature() (params, result reflect.Type) {
+	return m.inType, m.outType
+}
+
+func (h *typedHandler[T, R]) signature() (params, result reflect.Type) {
+	return reflect.TypeOf((*T)(nil)).Elem(), reflect.TypeOf((*R)(nil)).Elem()
+}
+
+func (m *streamMethod) signature() (params, result reflect.Type) {
+	return m.inType, nil
+}
+
+// paramsSchema returns the Schema of the params of h, whose type is params
+// by its signature.
+func paramsSchema(h handler, params reflect.Type) *Schema {
+	m, ok := h.(*method)
+	if !ok || m.inTypes == nil {
+		return schemaOf(params)
+	}
+	s := &Schema{Type: "array", PrefixItems: make([]*Schema, len(m.inTypes))}
+	for i, t := range m.inTypes {
+		s.PrefixItems[i] = schemaOf(t)
+	}
+	return s
+}
+
+// describe the method name served by h, configured as info (may be nil).
+func describe(name string, h handler, info *methodInfo) MethodDescriptor {
+	var params, result reflect.Type
+	if s, ok := h.(signer); ok {
+		params, result = s.signature()
+	}
+	d := MethodDescriptor{
+		Name:   name,
+		Params: []ContentDescriptor{{Name: "params", Schema: paramsSchema(h, params)}},
+		Result: &ContentDescriptor{Name: "result", Schema: schemaOf(result)},
+	}
+	if info == nil {
+		return d
+	}
+	d.Deprecated = info.deprecated
+	d.Summary, _, _ = strings.Cut(info.doc, "\n")
+	d.Description = info.doc
+	for field, doc := range info.paramDocs {
+		if field == "" {
+			d.Params[0].Description = doc
+		} else if p := d.Params[0].Schema.Properties[field]; p != nil {
+			p.Description = doc
+		}
+	}
+	return d
+}
+
+// checkParamDocs checks that the params of h have the fields documented
+// by info, if their type is known.
+func checkParamDocs(h handler, info *methodInfo) error {
+	s, ok := h.(signer)
+	if !ok {
+		return nil
+	}
+	params, _ := s.signature()
+	schema := paramsSchema(h, params)
+	for field := range info.paramDocs {
+		if field != "" && schema.Type != "" && schema.Properties[field] == nil {
+			return fmt.Errorf("no param %q to document", field)
+		}
+	}
+	return nil
+}
+
+// discover is the MethodDiscover method.
+func (s *server) discover(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+	s.mu.RLock()
+	filter := s.methodFilter
+	methods := make([]MethodDescriptor, 0, len(s.methods))
+	for name, h := range s.methods {
+		if filter != nil && !filter(ctx, name) {
+			continue
+		}
+		methods = append(methods, describe(name, h, s.infos[name]))
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+
+	return json.Marshal(DiscoverResult{
+		OpenRPC: OpenRPCVersion,
+		Info:    DiscoverInfo{Title: "jsonrpc2", Version: "0.0.0"},
+		Methods: methods,
+	})
+}
+
+// describeMethod is the MethodDescribe method.
+func (s *server) describeMethod(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+	var p DescribeParams
+	if err := json.Unmarshal(params, &p.Method); err != nil {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("params should be {\"method\": name}: %w", err)
+		}
+	}
+
+	s.mu.RLock()
+	h, ok := s.methods[p.Method]
+	info := s.infos[p.Method]
+	s.mu.RUnlock()
+
+	if !ok || !s.visible(ctx, p.Method) {
+		return nil, fmt.Errorf("method not found: %q", p.Method)
+	}
+	return json.Marshal(describe(p.Method, h, info))
+}
+
+// health is the MethodHealth method.
+func health(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+	return json.Marshal(HealthResult{Status: "ok"})
+}
+
+// health is the MethodHealth method of s, telling whether it's ready.
+func (s *server) health(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+	if s.notReady.Load() {
+		return json.Marshal(HealthResult{Status: "starting"})
+	}
+	return health(ctx, params)
+}
@@ -0,0 +1,138 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_server_reservedNames(t *testing.T) {
+	s := NewServer()
+
+	if err := s.Register("rpc.mine", func(arg int) (int, error) { return arg, nil }); err == nil {
+		t.Error("❌ registering rpc.mine should fail")
+	}
+	if err := s.RegisterAll(map[string]any{
+		"add":        func(arg []int) (int, error) { return arg[0] + arg[1], nil },
+		"rpc.health": health,
+	}); err == nil {
+		t.Error("❌ RegisterAll with rpc.health should fail")
+	}
+	if err := s.Register("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil }); err != nil {
+		t.Errorf("❌ the failed RegisterAll should register nothing: %v", err)
+	}
+
+	// override for tests
+	s.WithReservedNames(true)
+	if err := s.Register(MethodHealth, func(arg any) (*HealthResult, error) {
+		return &HealthResult{Status: "stubbed"}, nil
+	}); err != nil {
+		t.Fatalf("❌ overriding rpc.health: %v", err)
+	}
+	if err := s.Register(MethodHealth, health); err == nil {
+		t.Error("❌ registering rpc.health twice should fail")
+	}
+
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: MethodHealth, Params: []byte(`{}`), Id: Int64ID(1)})
+	if string(resp.Result) != `{"status":"stubbed"}` {
+		t.Errorf("❌ rpc.health = %s, %v", resp.Result, resp.Error)
+	}
+}
+
+func Test_server_discover(t *testing.T) {
+	type Point struct {
+		X, Y   int
+		Label  string    `json:"label,omitempty"`
+		At     time.Time `json:"at"`
+		hidden int
+	}
+	type Node struct {
+		Value int    `json:"value"`
+		Next  *Node  `json:"next"`
+		Tags  []byte `json:"tags"`
+		Meta  map[string]float64
+	}
+
+	s := NewServer()
+	s.MustRegister("move", func(p *Point) (*Point, error) { return p, nil })
+	s.MustRegister("typed", Typed(func(n *Node) (bool, error) { return true, nil }))
+
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: MethodDiscover, Id: Int64ID(1)})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	var doc DiscoverResult
+	if err := json.Unmarshal(resp.Result, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	methods := map[string]MethodDescriptor{}
+	var names []string
+	for _, m := range doc.Methods {
+		methods[m.Name] = m
+		names = append(names, m.Name)
+	}
+	if want := []string{"move", MethodCancel, MethodDescribe, MethodDiscover, MethodHealth, MethodSubscribe, MethodUnsubscribe, "typed"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("❌ methods = %v, want %v", names, want)
+	}
+
+	pointSchema := &Schema{Type: "object", Properties: map[string]*Schema{
+		"X":     {Type: "integer"},
+		"Y":     {Type: "integer"},
+		"label": {Type: "string"},
+		"at":    {Type: "string", Format: "date-time"},
+	}}
+	if got := methods["move"].Params[0].Schema; !reflect.DeepEqual(got, pointSchema) {
+		t.Errorf("❌ move params = %s", mustJSON(got))
+	}
+	if got := methods["move"].Result.Schema; !reflect.DeepEqual(got, pointSchema) {
+		t.Errorf("❌ move result = %s", mustJSON(got))
+	}
+
+	nodeSchema := &Schema{Type: "object", Properties: map[string]*Schema{
+		"value": {Type: "integer"},
+		"next":  {Type: "object"}, // recursive
+		"tags":  {Type: "string", Format: "byte"},
+		"Meta":  {Type: "object", AdditionalProperties: &Schema{Type: "number"}},
+	}}
+	if got := methods["typed"].Params[0].Schema; !reflect.DeepEqual(got, nodeSchema) {
+		t.Errorf("❌ typed params = %s", mustJSON(got))
+	}
+	if got := methods["typed"].Result.Schema; !reflect.DeepEqual(got, &Schema{Type: "boolean"}) {
+		t.Errorf("❌ typed result = %s", mustJSON(got))
+	}
+	t.Logf("✅ %s", resp.Result)
+}
+
+func mustJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func Test_server_describe(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil })
+
+	tests := []struct {
+		name    string
+		params  string
+		want    string
+		wantErr bool
+	}{
+		{"object", `{"method": "add"}`, `{"name":"add","params":[{"name":"params","schema":{"type":"array","items":{"type":"integer"}}}],"result":{"name":"result","schema":{"type":"integer"}}}`, false},
+		{"name", `"add"`, `{"name":"add","params":[{"name":"params","schema":{"type":"array","items":{"type":"integer"}}}],"result":{"name":"result","schema":{"type":"integer"}}}`, false},
+		{"unknown", `{"method": "nope"}`, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: MethodDescribe, Params: []byte(tt.params), Id: Int64ID(1)})
+			if (resp.Error != nil) != tt.wantErr || string(resp.Result) != tt.want {
+				t.Errorf("❌ got %s, %v\nwant %s", resp.Result, resp.Error, tt.want)
+			} else {
+				t.Logf("✅ got %s, %v", resp.Result, resp.Error)
+			}
+		})
+	}
+}
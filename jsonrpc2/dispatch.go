@@ -0,0 +1,201 @@
+package jsonrpc2
+
+// 这个文件实现方法调度 (dispatch) 的抽象。
+//
+// 注册的函数有两种调度方式:
+//   - method: 基于 reflect，任意 func(arg T) (R, error) 都能注册，每次调用都要 reflect.Call;
+//   - typedHandler / rawHandler: 基于泛型 / 闭包，参数和返回值类型在编译期已知，调用时无需反射;
+//   - streamMethod: 结果写入 ResultWriter 的流式方法，见 resultwriter.go;
+//   - streamingHandler: 逐个 Send 结果值的 StreamFunc，见 sender.go。
+//
+// Register 会自动为已知的形状 (Typed / TypedContext / Streaming 包装的函数、RawFunc) 选择后者。
+// 二者的比较见 dispatch_test.go 中的 benchmark。
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// handler serves the requests of a registered method.
+type handler interface {
+	// serve req, returning the response and the error (if any) behind an
+	// error response, e.g. a *panicError.
+	serve(ctx context.Context, req *Request) (*Response, error)
+}
+
+// streamer is implemented by the handlers writing their results as they
+// go (StreamFunc, ResultWriter), see serveWithTimeout.
+type streamer interface {
+	streams()
+}
+
+// newHandler picks the dispatch of f: reflection-free for the shapes known
+// at compile time, reflect-based (method) for anything else.
+func newHandler(f any) (handler, error) {
+	switch f := f.(type) {
+	case typedFunc:
+		return f.handler(), nil
+	case RawFunc:
+		return rawHandler(f), nil
+	case func(ctx context.Context, params json.RawMessage) (json.RawMessage, error):
+		return rawHandler(f), nil
+	}
+	if m, ok := newStreamMethod(f); ok {
+		return m, nil
+	}
+	return newMethod(f)
+}
+
+// typedFunc is implemented by the values of Func.
+type typedFunc interface {
+	handler() handler
+}
+
+// Func is a method function whose param and result types are known at
+// compile time. Registering a Func skips reflection on every call.
+//
+// Use Typed or TypedContext to make one with type inference:
+//
+//	s.Register("add", jsonrpc2.Typed(add)) // add: func(*AddArg) (*AddRet, error)
+type Func[T, R any] func(ctx context.Context, arg T) (R, error)
+
+// Typed wraps f as a Func.
+func Typed[T, R any](f func(arg T) (R, error)) Func[T, R] {
+	return func(ctx context.Context, arg T) (R, error) {
+		return f(arg)
+	}
+}
+
+// TypedContext wraps f (taking a context.Context) as a Func.
+func TypedContext[T, R any](f func(ctx context.Context, arg T) (R, error)) Func[T, R] {
+	return f
+}
+
+func (f Func[T, R]) handler() handler {
+	return &typedHandler[T, R]{f: f}
+}
+
+// typedHandler is the reflection-free handler of a Func.
+// It behaves the same as method.serve.
+type typedHandler[T, R any] struct {
+	f Func[T, R]
+}
+
+func (h *typedHandler[T, R]) serve(ctx context.Context, req *Request) (res *Response, err error) {
+	if req == nil {
+		return errorResponse(nil, ErrInvalidRequest().WithReason("nil request")), errors.New("nil request")
+	}
+
+	res = &Response{
+		JsonRpc: JsonRpc2,
+		Id:      req.Id,
+	}
+
+	params := req.Params
+	if params == nil { // reflecting on T only then
+		params = paramsOrNull(nil, reflect.TypeOf((*T)(nil)).Elem())
+	}
+	if params == nil {
+		err = errors.New("params should not be nil")
+		res.Error = ErrInvalidParams().WithReason(err.Error())
+		return
+	}
+	timer := phaseTimerFromContext(ctx)
+	timer.start()
+	var arg T
+	err = decodeParams(ctx, params, &arg)
+	if err == nil {
+		err = validateArg(&arg)
+	}
+	timer.done(phaseDecode)
+	if err != nil {
+		res.Error = ErrInvalidParams().WithReason(err.Error())
+		return
+	}
+
+	ret, err := h.call(ctx, arg)
+	timer.done(phaseCall)
+	if err != nil {
+		res.Error = methodError(err)
+		return
+	}
+
+	err = res.marshalResultContext(ctx, ret)
+	timer.done(phaseEncode)
+	if err != nil {
+		me := newResultMarshalError(req.Method, ret, err)
+		res.Result = nil
+		res.Error = me.rpcError()
+		return res, me
+	}
+
+	return res, nil
+}
+
+// call f, recovering panics like method.call.
+func (h *typedHandler[T, R]) call(ctx context.Context, arg T) (ret any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r}
+		}
+	}()
+
+	r, err := h.f(ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RawFunc is a method function working on raw JSON: it gets the params
+// as they are and returns the result as it is, without any (un)marshaling
+// by the server. The result must be valid JSON.
+type RawFunc func(ctx context.Context, params json.RawMessage) (json.RawMessage, error)
+
+// rawHandler is the handler of a RawFunc.
+type rawHandler RawFunc
+
+func (h rawHandler) serve(ctx context.Context, req *Request) (res *Response, err error) {
+	if req == nil {
+		return errorResponse(nil, ErrInvalidRequest().WithReason("nil request")), errors.New("nil request")
+	}
+
+	res = &Response{
+		JsonRpc: JsonRpc2,
+		Id:      req.Id,
+	}
+
+	if gobFromContext(ctx) {
+		res.Error = ErrInvalidRequest().WithReason(errGobUnsupported.Error())
+		return res, errGobUnsupported
+	}
+
+	timer := phaseTimerFromContext(ctx)
+	timer.start()
+	ret, err := h.call(ctx, req.Params)
+	timer.done(phaseCall)
+	if err != nil {
+		res.Error = methodError(err)
+		return
+	}
+	if !json.Valid(ret) {
+		me := newResultMarshalError(req.Method, ret, errors.New("invalid JSON result"))
+		res.Error = me.rpcError()
+		return res, me
+	}
+
+	res.Result = ret
+	return res, nil
+}
+
+// call h, recovering panics like method.call.
+func (h rawHandler) call(ctx context.Context, params json.RawMessage) (ret json.RawMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r}
+		}
+	}()
+	return h(ctx, params)
+}
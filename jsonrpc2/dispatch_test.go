@@ -0,0 +1,125 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type dispatchArg struct{ A, B int }
+type dispatchRet struct{ C int }
+
+func dispatchAdd(arg *dispatchArg) (*dispatchRet, error) {
+	return &dispatchRet{C: arg.A + arg.B}, nil
+}
+
+func dispatchAddRaw(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+	var arg dispatchArg
+	if err := json.Unmarshal(params, &arg); err != nil {
+		return nil, err
+	}
+	return json.Marshal(dispatchRet{C: arg.A + arg.B})
+}
+
+func Test_newHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		f    any
+		want reflect.Type
+	}{
+		{"reflect", dispatchAdd, reflect.TypeOf(&method{})},
+		{"typed", Typed(dispatchAdd), reflect.TypeOf(&typedHandler[*dispatchArg, *dispatchRet]{})},
+		{"typedContext", TypedContext(func(ctx context.Context, arg int) (int, error) { return arg, nil }),
+			reflect.TypeOf(&typedHandler[int, int]{})},
+		{"raw", dispatchAddRaw, reflect.TypeOf(rawHandler(nil))},
+		{"rawFunc", RawFunc(dispatchAddRaw), reflect.TypeOf(rawHandler(nil))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := newHandler(tt.f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.TypeOf(h); got != tt.want {
+				t.Errorf("newHandler() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The dispatches must behave the same.
+func Test_dispatch_equivalence(t *testing.T) {
+	handlers := map[string]any{
+		"reflect": dispatchAdd,
+		"typed":   Typed(dispatchAdd),
+		"raw":     dispatchAddRaw,
+	}
+
+	requests := []*Request{
+		{Id: Int64ID(1), Params: []byte(`{"A":1,"B":2}`)},
+		{Id: Int64ID(2), Params: []byte(`{"A":"x"}`)},
+	}
+
+	for _, req := range requests {
+		var want []byte
+		for name, f := range handlers {
+			h, err := newHandler(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, _ := h.serve(context.Background(), req)
+			got, _ := json.Marshal(res)
+			if name == "raw" && res.Error != nil {
+				continue // raw functions report their own errors
+			}
+			if want == nil {
+				want = got
+			} else if string(got) != string(want) {
+				t.Errorf("%s: got %s, want %s", name, got, want)
+			}
+		}
+	}
+}
+
+func Test_typedHandler_panic(t *testing.T) {
+	h, _ := newHandler(Typed(func(arg int) (int, error) { panic("boom") }))
+	res, err := h.serve(context.Background(), &Request{Params: []byte(`1`)})
+	var pe *panicError
+	if !errors.As(err, &pe) || res.Error == nil {
+		t.Errorf("want recovered panic, got res=%v err=%v", res, err)
+	}
+}
+
+func BenchmarkDispatch(b *testing.B) {
+	handlers := []struct {
+		name string
+		f    any
+	}{
+		{"reflect", dispatchAdd},
+		{"typed", Typed(dispatchAdd)},
+		{"raw", dispatchAddRaw},
+	}
+
+	id := int64(1)
+	req := &Request{JsonRpc: JsonRpc2, Method: "add", Params: []byte(`{"A":1,"B":2}`), Id: Int64ID(id)}
+
+	for _, h := range handlers {
+		b.Run(h.name, func(b *testing.B) {
+			s := NewServer().WithMetrics(nil)
+			if err := s.Register("add", h.f); err != nil {
+				b.Fatal(err)
+			}
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if resp := s.ServeRPC(ctx, req); resp.Error != nil {
+					b.Fatal(resp.Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package jsonrpc2
+
+// 这个文件实现从环境变量构造客户端 (NewClientFromEnv)，
+// 容器中部署时，不改代码就能调整服务地址、超时、重试与 TLS。
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix prefixes the names of the environment variables read by
+// NewClientFromEnv.
+const EnvPrefix = "JSONRPC2_"
+
+// defaultRetryBackoff is the backoff before the first retry, if
+// JSONRPC2_RETRY_BACKOFF is not set.
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// NewClientFromEnv makes a Client configured by environment variables, so
+// that deployments (e.g. containers) can tune it without code changes:
+//
+//   - JSONRPC2_ENDPOINT (required): the server, whose scheme picks the
+//     transport: http://host:port/path or https://..., tcp://host:port,
+//     unix:///path/to/socket, ws://host:port/path or wss://...
+//   - JSONRPC2_TIMEOUT: the default timeout of the calls (see
+//     Client.WithTimeout), as for time.ParseDuration, e.g. "5s".
+//   - JSONRPC2_RETRIES: how many times a call failing with a transport
+//     error (not an *Error of the server) is retried, 0 by default.
+//     It requires JSONRPC2_RETRY_METHODS.
+//   - JSONRPC2_RETRY_METHODS: the methods retried, comma separated names
+//     or patterns (see Client.WithRetry), e.g. "*" for all of them if the
+//     server is WithAtMostOnce: retries resend the request with the same
+//     id, to be deduplicated.
+//   - JSONRPC2_RETRY_BACKOFF: the wait before the first retry, doubled for
+//     every next one, 100ms by default.
+//   - JSONRPC2_TLS_CA_FILE: PEM certificates of the CAs to trust, instead
+//     of the system ones.
+//   - JSONRPC2_TLS_CERT_FILE and JSONRPC2_TLS_KEY_FILE: the client
+//     certificate, for servers requiring one (mutual TLS).
+//   - JSONRPC2_TLS_SERVER_NAME: the name to verify the certificate of the
+//     server against, instead of the host of the endpoint.
+//   - JSONRPC2_TLS_INSECURE_SKIP_VERIFY: "true" not to verify the server
+//     at all, for tests only.
+//
+// Setting any JSONRPC2_TLS_* makes tcp:// connections TLS ones.
+func NewClientFromEnv() (Client, error) {
+	endpoint := os.Getenv(EnvPrefix + "ENDPOINT")
+	if endpoint == "" {
+		return nil, errors.New(EnvPrefix + "ENDPOINT is not set")
+	}
+	tlsConfig, err := tlsConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	transport, err := transportOf(endpoint, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%sENDPOINT: %w", EnvPrefix, err)
+	}
+
+	c := &client{transport: transport, identity: newClientIdentity()}
+	if c.timeout, err = durationEnv("TIMEOUT", 0); err != nil {
+		return nil, err
+	}
+	backoff, err := durationEnv("RETRY_BACKOFF", defaultRetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	retries := 0
+	if s := os.Getenv(EnvPrefix + "RETRIES"); s != "" {
+		if retries, err = strconv.Atoi(s); err != nil || retries < 0 {
+			return nil, fmt.Errorf("%sRETRIES: want a number >= 0, got %q", EnvPrefix, s)
+		}
+	}
+	var methods []string
+	if s := os.Getenv(EnvPrefix + "RETRY_METHODS"); s != "" {
+		methods = strings.Split(s, ",")
+		for i := range methods {
+			methods[i] = strings.TrimSpace(methods[i])
+		}
+	}
+	if retries > 0 && len(methods) == 0 {
+		return nil, fmt.Errorf("%sRETRIES: retrying no methods without %sRETRY_METHODS", EnvPrefix, EnvPrefix)
+	}
+	c.retry = newRetryPolicy(retries+1, backoff, methods)
+	return c, nil
+}
+
+// transportOf makes the ClientTransport of endpoint, see NewClientFromEnv.
+func transportOf(endpoint string, tlsConfig *tls.Config) (ClientTransport, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HttpClientTransport{Addr: endpoint, TLSConfig: tlsConfig}, nil
+	case "tcp":
+		t := NewTcpClientTransport(u.Host)
+		if tlsConfig != nil {
+			t.Dialer = &tls.Dialer{Config: tlsConfig}
+		}
+		return t, nil
+	case "unix":
+		return NewUnixClientTransport(u.Path), nil
+	case "ws", "wss":
+		return NewWebSocketClientTransport(endpoint, &WebSocketDialer{TLSConfig: tlsConfig}), nil
+	default:
+		return nil, fmt.Errorf("unknown scheme %q, want http(s), tcp, unix or ws(s)", u.Scheme)
+	}
+}
+
+// tlsConfigFromEnv makes the tls.Config of the JSONRPC2_TLS_* variables,
+// nil if none is set.
+func tlsConfigFromEnv() (*tls.Config, error) {
+	caFile := os.Getenv(EnvPrefix + "TLS_CA_FILE")
+	certFile := os.Getenv(EnvPrefix + "TLS_CERT_FILE")
+	keyFile := os.Getenv(EnvPrefix + "TLS_KEY_FILE")
+	serverName := os.Getenv(EnvPrefix + "TLS_SERVER_NAME")
+	insecure := os.Getenv(EnvPrefix + "TLS_INSECURE_SKIP_VERIFY")
+	if caFile == "" && certFile == "" && keyFile == "" && serverName == "" && insecure == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{ServerName: serverName}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%sTLS_CA_FILE: no certificates in %s", EnvPrefix, caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%sTLS_CERT_FILE and %sTLS_KEY_FILE: %w", EnvPrefix, EnvPrefix, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if insecure != "" {
+		skip, err := strconv.ParseBool(insecure)
+		if err != nil {
+			return nil, fmt.Errorf("%sTLS_INSECURE_SKIP_VERIFY: %w", EnvPrefix, err)
+		}
+		config.InsecureSkipVerify = skip
+	}
+	return config, nil
+}
+
+// durationEnv reads the duration of the variable EnvPrefix+name, def if it's not set.
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	s := os.Getenv(EnvPrefix + name)
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s%s: %w", EnvPrefix, name, err)
+	}
+	return d, nil
+}
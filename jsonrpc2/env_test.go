@@ -0,0 +1,151 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewClientFromEnv(t *testing.T) {
+	t.Setenv(EnvPrefix+"ENDPOINT", "tcp://localhost:5679")
+	t.Setenv(EnvPrefix+"TIMEOUT", "2s")
+	t.Setenv(EnvPrefix+"RETRIES", "3")
+	t.Setenv(EnvPrefix+"RETRY_METHODS", "get, kv.*")
+
+	c, err := NewClientFromEnv()
+	if err != nil {
+		t.Fatalf("❌ NewClientFromEnv: %v", err)
+	}
+	cc := c.(*client)
+	if st, ok := cc.transport.(*StreamClientTransport); !ok || st.Network != "tcp" || st.Addr != "localhost:5679" {
+		t.Errorf("❌ bad transport %#v", cc.transport)
+	}
+	if cc.timeout != 2*time.Second {
+		t.Errorf("❌ timeout = %v, want 2s", cc.timeout)
+	}
+	if cc.retry.attempts != 4 || cc.retry.backoff != defaultRetryBackoff {
+		t.Errorf("❌ retry = %+v, want 4 attempts", cc.retry)
+	}
+	for method, want := range map[string]bool{"get": true, "kv.put": true, "put": false} {
+		if got := cc.retry.retries(method); got != want {
+			t.Errorf("❌ retries %s = %v, want %v", method, got, want)
+		}
+	}
+}
+
+func TestNewClientFromEnv_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"no endpoint", nil},
+		{"unknown scheme", map[string]string{"ENDPOINT": "grpc://localhost:1"}},
+		{"bad timeout", map[string]string{"ENDPOINT": "http://localhost:1", "TIMEOUT": "5"}},
+		{"bad retries", map[string]string{"ENDPOINT": "http://localhost:1", "RETRIES": "-1"}},
+		{"retries of no methods", map[string]string{"ENDPOINT": "http://localhost:1", "RETRIES": "2"}},
+		{"missing CA file", map[string]string{"ENDPOINT": "https://localhost:1", "TLS_CA_FILE": "/nonexistent"}},
+		{"cert without key", map[string]string{"ENDPOINT": "https://localhost:1", "TLS_CERT_FILE": "/nonexistent"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvPrefix+"ENDPOINT", "")
+			for k, v := range tt.env {
+				t.Setenv(EnvPrefix+k, v)
+			}
+			if _, err := NewClientFromEnv(); err == nil {
+				t.Errorf("❌ want an error for %v", tt.env)
+			}
+		})
+	}
+}
+
+func TestNewClientFromEnv_tls(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewTLSServer(st)
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(EnvPrefix+"ENDPOINT", ts.URL)
+	c, err := NewClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if err := c.Call("echo", "hi", &got); err == nil {
+		t.Errorf("❌ want the certificate of the server untrusted without the CA")
+	}
+
+	t.Setenv(EnvPrefix+"TLS_CA_FILE", caFile)
+	if c, err = NewClientFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Call("echo", "hi", &got); err != nil || got != "hi" {
+		t.Errorf("❌ echo over TLS = %q, %v", got, err)
+	}
+}
+
+// failingTransport fails the first fails requests, then serves with server.
+type failingTransport struct {
+	server Server
+	fails  int
+	sent   int
+}
+
+func (t *failingTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
+	t.sent++
+	if t.sent <= t.fails {
+		return nil, errors.New("connection reset")
+	}
+	return t.server.ServeRPC(ctx, req), nil
+}
+
+func Test_client_retry(t *testing.T) {
+	s := NewServer().WithAtMostOnce()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+
+	tests := []struct {
+		name     string
+		fails    int
+		attempts int
+		wantErr  bool
+		wantSent int
+	}{
+		{"no retries", 1, 0, true, 1},
+		{"retried", 2, 3, false, 3},
+		{"out of retries", 3, 3, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &failingTransport{server: s, fails: tt.fails}
+			c := &client{transport: transport, retry: newRetryPolicy(tt.attempts, time.Millisecond, []string{"*"})}
+			var got string
+			err := c.Call("echo", "hi", &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("❌ err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if transport.sent != tt.wantSent {
+				t.Errorf("❌ sent %d times, want %d", transport.sent, tt.wantSent)
+			}
+		})
+	}
+
+	// errors of the server are not retried
+	transport := &failingTransport{server: s}
+	c := &client{transport: transport, retry: newRetryPolicy(3, time.Millisecond, []string{"*"})}
+	if err := c.Call("nonexistent", "hi", nil); err == nil || transport.sent != 1 {
+		t.Errorf("❌ got %v after %d attempts, want Method not found at once", err, transport.sent)
+	}
+}
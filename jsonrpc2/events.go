@@ -0,0 +1,90 @@
+package jsonrpc2
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventKind tells what happened in an Event.
+type EventKind int
+
+const (
+	EventMethodRegistered EventKind = iota + 1 // a method was registered
+	EventRequestStarted                        // ServeRPC got a request
+	EventRequestFinished                       // ServeRPC made a response
+	EventPanicRecovered                        // a method panicked, and the panic was recovered
+	EventDedupeHit                             // at-most-once rejected a duplicated request
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventMethodRegistered:
+		return "MethodRegistered"
+	case EventRequestStarted:
+		return "RequestStarted"
+	case EventRequestFinished:
+		return "RequestFinished"
+	case EventPanicRecovered:
+		return "PanicRecovered"
+	case EventDedupeHit:
+		return "DedupeHit"
+	}
+	return "Unknown"
+}
+
+// Event is something that happened in a Server, see Server.Events.
+type Event struct {
+	Kind   EventKind
+	Time   time.Time
+	Method string
+	Id     *ID // nil for EventMethodRegistered
+
+	// Transport that the request came from, if the transport told.
+	// nil for EventMethodRegistered.
+	Transport *TransportInfo
+
+	Duration time.Duration // EventRequestFinished: time spent in ServeRPC
+	Error    *Error        // EventRequestFinished: the error responded, if any
+	Panic    any           // EventPanicRecovered: the recovered value
+}
+
+// eventsBuffer is the capacity of the channel returned by Server.Events.
+const eventsBuffer = 256
+
+// eventStream delivers Events to the channel returned by Server.Events.
+// Nothing is delivered (nor allocated) until someone asks for the channel.
+type eventStream struct {
+	ch      atomic.Pointer[chan Event]
+	dropped func() // called when an event is dropped
+	clock   Clock  // times the events, nil means SystemClock
+}
+
+// channel returns the events channel, creating it on the first call.
+func (es *eventStream) channel() <-chan Event {
+	if ch := es.ch.Load(); ch != nil {
+		return *ch
+	}
+	ch := make(chan Event, eventsBuffer)
+	if es.ch.CompareAndSwap(nil, &ch) {
+		return ch
+	}
+	return *es.ch.Load()
+}
+
+// emit delivers e without blocking: if the channel is full, e is dropped.
+func (es *eventStream) emit(e Event) {
+	ch := es.ch.Load()
+	if ch == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = clockOrSystem(es.clock).Now()
+	}
+	select {
+	case *ch <- e:
+	default:
+		if es.dropped != nil {
+			es.dropped()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_server_Events(t *testing.T) {
+	s := NewServer().WithAtMostOnce()
+	events := s.Events()
+
+	if err := s.Register("add", func(arg *struct{ A, B int }) (*struct{ C int }, error) {
+		return &struct{ C int }{C: arg.A + arg.B}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("panic", func(arg int) (int, error) {
+		panic(errors.New("boom"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "add", Params: []byte(`{"A":1,"B":2}`), Id: Int64ID(1)})
+	s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "add", Params: []byte(`{"A":1,"B":2}`), Id: Int64ID(1)})
+	s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "panic", Params: []byte(`1`), Id: Int64ID(2)})
+
+	want := []struct {
+		kind   EventKind
+		method string
+	}{
+		{EventMethodRegistered, "add"},
+		{EventMethodRegistered, "panic"},
+		{EventRequestStarted, "add"},
+		{EventRequestFinished, "add"},
+		{EventRequestStarted, "add"},
+		{EventDedupeHit, "add"},
+		{EventRequestFinished, "add"},
+		{EventRequestStarted, "panic"},
+		{EventPanicRecovered, "panic"},
+		{EventRequestFinished, "panic"},
+	}
+	for i, w := range want {
+		select {
+		case e := <-events:
+			if e.Kind != w.kind || e.Method != w.method {
+				t.Errorf("event %d = %v(%s), want %v(%s)", i, e.Kind, e.Method, w.kind, w.method)
+			}
+			if e.Time.IsZero() {
+				t.Errorf("event %d has no time", i)
+			}
+			if e.Kind == EventRequestFinished && e.Method == "panic" && e.Error == nil {
+				t.Errorf("event %d should carry the error response", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for event %d (%v)", i, w.kind)
+		}
+	}
+}
+
+func Test_server_EventsDropped(t *testing.T) {
+	s := NewServer()
+	_ = s.Events() // subscribe, but never read
+
+	for i := 0; i < eventsBuffer+10; i++ {
+		_ = s.Register(fmt.Sprintf("m%d", i), func(arg int) (int, error) { return arg, nil })
+	}
+
+	if got := s.Stats()["events.dropped"]; got == 0 {
+		t.Errorf("events.dropped = %d, want > 0", got)
+	}
+}
@@ -0,0 +1,26 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// FallbackFunc serves the calls of the methods not registered, given
+// their names, e.g. a dynamic dispatcher, a proxy or a mock. The result
+// must be valid JSON. Returning ErrMethodNotFound() tells that the method
+// doesn't exist after all.
+type FallbackFunc func(method string, params json.RawMessage) (json.RawMessage, error)
+
+// fallbackHandler is the handler of the call of method by the fallback f.
+func fallbackHandler(f FallbackFunc, method string) handler {
+	return rawHandler(func(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+		return f(method, params)
+	})
+}
+
+func (s *server) RegisterFallback(f FallbackFunc) Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = f
+	return s
+}
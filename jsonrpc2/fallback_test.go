@@ -0,0 +1,57 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func Test_server_RegisterFallback(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil })
+	var called []string
+	s.RegisterFallback(func(method string, params json.RawMessage) (json.RawMessage, error) {
+		called = append(called, method)
+		switch method {
+		case "fail":
+			return nil, errors.New("mock failure")
+		case "missing":
+			return nil, ErrMethodNotFound()
+		}
+		return json.RawMessage(`{"method":"` + method + `","params":` + string(params) + `}`), nil
+	})
+	c := NewClient(&serverTransport{server: s})
+
+	var sum int
+	if err := c.Call("add", []int{1, 2}, &sum); err != nil || sum != 3 {
+		t.Errorf("❌ add = %d, %v; want the registered method", sum, err)
+	}
+	var got struct {
+		Method string
+		Params []int
+	}
+	if err := c.Call("users.get", []int{7}, &got); err != nil || got.Method != "users.get" || len(got.Params) != 1 {
+		t.Errorf("❌ users.get = %+v, %v; want by the fallback", got, err)
+	}
+
+	var rpcErr *Error
+	if err := c.Call("fail", 1, nil); !errors.As(err, &rpcErr) || rpcErr.Message != "mock failure" {
+		t.Errorf("❌ fail: %v, want the error of the fallback", err)
+	}
+	if err := c.Call("missing", 1, nil); !errors.As(err, &rpcErr) || rpcErr.Code != ErrMethodNotFound().Code {
+		t.Errorf("❌ missing: %v, want ErrMethodNotFound", err)
+	}
+	if err := c.Call("rpc.nothing", 1, nil); !errors.As(err, &rpcErr) || rpcErr.Code != ErrMethodNotFound().Code {
+		t.Errorf("❌ rpc.nothing: %v, want ErrMethodNotFound, reserved", err)
+	}
+	if len(called) != 3 {
+		t.Errorf("❌ fallback called for %v, want users.get, fail and missing", called)
+	}
+
+	s.RegisterFallback(nil)
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "users.get", Params: json.RawMessage(`1`), Id: Int64ID(0)})
+	if resp.Error == nil || resp.Error.Code != ErrMethodNotFound().Code {
+		t.Errorf("❌ without the fallback: %+v, want ErrMethodNotFound", resp)
+	}
+}
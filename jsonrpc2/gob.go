@@ -0,0 +1,274 @@
+package jsonrpc2
+
+// 这个文件实现 gob 编码的 HTTP 调用，用于两端都是 Go 的部署：
+// 请求与响应、以及其中的参数与结果都用 encoding/gob 编码，不经过 JSON。
+// 字段按名字精确匹配 (没有 JSON 那样大小写不敏感的意外)，大的结构体也编码得更快。
+//
+// 请求与响应的外层结构与 JSON-RPC 相同 (method、params、id / result、error、id)，
+// 服务端的方法照常注册，只是参数与结果换成 gob 编解码。
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// GobContentType is the Content-Type of gob encoded requests and responses,
+// see HttpServerTransport.AllowGob and GobHttpClientTransport.
+const GobContentType = "application/x-gob"
+
+// gobRequest is a Request on the wire, gob encoded.
+// Params is the gob encoding of the arg.
+type gobRequest struct {
+	Method string
+	Params []byte
+	Id     *ID
+	Client string
+	Meta   map[string]string
+}
+
+// gobResponse is a Response on the wire, gob encoded.
+// Result is the gob encoding of the result.
+type gobResponse struct {
+	Result []byte
+	Error  *Error
+	Id     *ID
+	Meta   map[string]string
+}
+
+// gobMarshal encodes v alone, type information included.
+func gobMarshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gobUnmarshal decodes data encoded by gobMarshal into v, a pointer.
+func gobUnmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type gobKey struct{}
+
+// withGob returns a copy of ctx in which params and results are gob encoded.
+func withGob(ctx context.Context) context.Context {
+	return context.WithValue(ctx, gobKey{}, true)
+}
+
+func gobFromContext(ctx context.Context) bool {
+	on, _ := ctx.Value(gobKey{}).(bool)
+	return on
+}
+
+// errGobUnsupported fails gob calls to methods working on JSON by themselves:
+// RawFunc and ResultWriter ones.
+var errGobUnsupported = errors.New("the method takes JSON, not gob")
+
+// marshalResultContext is marshalResult by the marshal function in ctx,
+// if any (see withResultMarshal), or by gob for gob requests.
+func (r *Response) marshalResultContext(ctx context.Context, result any) error {
+	if !gobFromContext(ctx) {
+		return r.marshalResult(result, resultMarshalFromContext(ctx))
+	}
+	if result == nil {
+		return nil
+	}
+	b, err := gobMarshal(result)
+	if err != nil {
+		return err
+	}
+	r.Result = b
+	return nil
+}
+
+// isGob tells whether r carries a gob encoded request.
+func isGob(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == GobContentType
+}
+
+// serveGob serves the gob encoded request of r, see AllowGob.
+func (t *HttpServerTransport) serveGob(w http.ResponseWriter, r *http.Request) {
+	var gr gobRequest
+	if err := gob.NewDecoder(r.Body).Decode(&gr); err != nil {
+		writeGobResponse(loggerOf(t.server), w, errorResponse(nil, ErrParseError().WithReason(err.Error())))
+		return
+	}
+
+	req := &Request{JsonRpc: JsonRpc2, Method: gr.Method, Params: gr.Params, Id: gr.Id, Client: gr.Client, Meta: gr.Meta}
+	if err := req.validate(); err != nil {
+		writeGobResponse(loggerOf(t.server), w, errorResponse(req.Id, ErrInvalidRequest().WithReason(err.Error())))
+		return
+	}
+
+	resp := t.server.ServeRPC(withGob(t.context(r)), req)
+
+	// a notification is answered with nothing
+	if resp == nil && req.IsNotification() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeGobResponse(loggerOf(t.server), w, resp)
+}
+
+// writeGobResponse responds with the gob encoded response, logging failures by logger.
+func writeGobResponse(logger Logger, w http.ResponseWriter, response *Response) {
+	err := errors.New("nil response")
+	if response != nil {
+		err = response.validate()
+	}
+	if err != nil {
+		logger.Log(LevelWarn, "failed to write response", Field{"error", err})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", GobContentType)
+	for _, warning := range response.warnings {
+		w.Header().Add("Warning", "299 - "+strconv.Quote(warning))
+	}
+	err = gob.NewEncoder(w).Encode(gobResponse{
+		Result: response.Result,
+		Error:  response.Error,
+		Id:     response.Id,
+		Meta:   response.Meta,
+	})
+	if err != nil {
+		logger.Log(LevelWarn, "failed to write response", Field{"error", err})
+	}
+}
+
+// valueCodec is implemented by the client transports encoding the args
+// and results of calls themselves, instead of by JSON, e.g. GobHttpClientTransport.
+type valueCodec interface {
+	marshalValue(v any) ([]byte, error)
+	unmarshalValue(data []byte, v any) error
+}
+
+// GobHttpClientTransport calls a HttpServerTransport allowing gob (see
+// AllowGob) with gob encoded requests and responses, for Go clients of Go
+// servers: the args and results of the calls are encoded by encoding/gob
+// instead of JSON.
+//
+// The methods called must be registered from Go functions: RawFunc and
+// ResultWriter methods work on JSON, and fail gob calls with ErrInvalidRequest.
+// Batches are sent as separate requests.
+type GobHttpClientTransport struct {
+	Addr string
+
+	// TLSConfig and HTTPClient work as for HttpClientTransport: by
+	// default, the requests are posted by a client of the transport's own,
+	// keeping up to DefaultMaxIdleConnsPerHost idle connections.
+	TLSConfig  *tls.Config
+	HTTPClient *http.Client
+
+	own ownHTTPClient
+}
+
+func NewGobHttpClientTransport(addr string) *GobHttpClientTransport {
+	return &GobHttpClientTransport{Addr: addr}
+}
+
+// Close closes the idle connections of the transport.
+// The transport may still be used, opening new connections.
+func (t *GobHttpClientTransport) Close() error {
+	t.httpClient().CloseIdleConnections()
+	return nil
+}
+
+func (t *GobHttpClientTransport) httpClient() *http.Client {
+	return t.own.get(t.HTTPClient, t.TLSConfig)
+}
+
+func (t *GobHttpClientTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, errors.New("no response")
+	}
+	return resp, nil
+}
+
+// Notify sends the notification req. The server responds nothing but
+// for a request it can't take, e.g. invalid, whose error is returned.
+func (t *GobHttpClientTransport) Notify(ctx context.Context, req *Request) error {
+	resp, err := t.post(ctx, req)
+	if err != nil || resp == nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
+
+func (t *GobHttpClientTransport) marshalValue(v any) ([]byte, error) {
+	return gobMarshal(v)
+}
+
+func (t *GobHttpClientTransport) unmarshalValue(data []byte, v any) error {
+	return gobUnmarshal(data, v)
+}
+
+// post the gob encoded req to the server, and returns its response,
+// nil if there is none (a notification).
+//
+// A server not allowing gob answers with a JSON error, which is returned as well.
+func (t *GobHttpClientTransport) post(ctx context.Context, req *Request) (*Response, error) {
+	var body bytes.Buffer
+	err := gob.NewEncoder(&body).Encode(gobRequest{Method: req.Method, Params: req.Params, Id: req.Id, Client: req.Client, Meta: req.Meta})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Addr, &body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", GobContentType)
+	httpReq.Header.Set("Accept-Encoding", "gzip")
+
+	httpResp, err := t.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := readResponseBody(httpResp)
+	if err != nil {
+		return nil, err
+	}
+	if len(respBody) == 0 {
+		return nil, nil
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(httpResp.Header.Get("Content-Type")); mediaType != GobContentType {
+		var rpcResp Response
+		if json.Unmarshal(respBody, &rpcResp) == nil && rpcResp.Error != nil {
+			return nil, rpcResp.Error
+		}
+		return nil, fmt.Errorf("jsonrpc2: not a gob response: %s %q", httpResp.Status, respBody)
+	}
+
+	var gr gobResponse
+	if err := gob.NewDecoder(bytes.NewReader(respBody)).Decode(&gr); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: %v", ErrTruncatedResponse, err)
+		}
+		return nil, err
+	}
+	return &Response{JsonRpc: JsonRpc2, Result: gr.Result, Error: gr.Error, Id: gr.Id, Meta: gr.Meta}, nil
+}
@@ -0,0 +1,119 @@
+package jsonrpc2
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type gobShape struct {
+	ID   int // JSON would take "id" as well: gob matches names exactly
+	Tags map[string][]byte
+	Next *gobShape
+}
+
+func newGobTestServer(t *testing.T, allow bool) *httptest.Server {
+	s := NewServer()
+	s.MustRegister("echo", func(arg gobShape) (gobShape, error) { return arg, nil })
+	s.MustRegister("typed", Typed(func(arg int) (string, error) { return "ok", nil }))
+	s.MustRegister("fail", func(arg int) (int, error) { return 0, NewError(1001, "nope").WithReason("bad") })
+	s.MustRegister("raw", RawFunc(func(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+		return params, nil
+	}))
+
+	st := NewHttpServerTransport("")
+	st.AllowGob = allow
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func Test_GobHttpClientTransport(t *testing.T) {
+	ts := newGobTestServer(t, true)
+	c := NewClient(NewGobHttpClientTransport(ts.URL))
+
+	arg := gobShape{ID: 1, Tags: map[string][]byte{"a": {0, 1}}, Next: &gobShape{ID: 2}}
+	var ret gobShape
+	if err := c.Call("echo", arg, &ret); err != nil || !reflect.DeepEqual(ret, arg) {
+		t.Fatalf("❌ echo = %+v, %v", ret, err)
+	}
+
+	var s string
+	if err := c.Call("typed", 1, &s); err != nil || s != "ok" {
+		t.Fatalf("❌ typed = %q, %v", s, err)
+	}
+
+	tests := []struct {
+		method string
+		arg    any
+		code   int
+	}{
+		{"fail", 1, 1001},
+		{"raw", 1, ErrInvalidRequest().Code},
+		{"nope", 1, ErrMethodNotFound().Code},
+		{"typed", "x", ErrInvalidParams().Code}, // a string is not an int
+	}
+	for _, tt := range tests {
+		err := c.Call(tt.method, tt.arg, nil)
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != tt.code {
+			t.Errorf("❌ %s: err = %v, want code %d", tt.method, err, tt.code)
+		}
+	}
+
+	if err := c.Notify("typed", 1); err != nil {
+		t.Errorf("❌ Notify: %v", err)
+	}
+
+	// JSON clients are served as usual
+	var n string
+	if err := NewClient(NewHttpClientTransport(ts.URL)).Call("typed", 1, &n); err != nil || n != "ok" {
+		t.Errorf("❌ JSON call = %q, %v", n, err)
+	}
+	t.Logf("✅ calls over gob")
+}
+
+func Test_GobHttpClientTransport_TLSConfig(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg gobShape) (gobShape, error) { return arg, nil })
+	st := NewHttpServerTransport("")
+	st.AllowGob = true
+	st.Use(s)
+	ts := httptest.NewTLSServer(st)
+	defer ts.Close()
+
+	ct := NewGobHttpClientTransport(ts.URL)
+	var ret gobShape
+	if err := NewClient(ct).Call("echo", gobShape{ID: 1}, &ret); err == nil {
+		t.Errorf("❌ want the certificate of the server untrusted without its TLSConfig")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	ct = NewGobHttpClientTransport(ts.URL)
+	ct.TLSConfig = &tls.Config{RootCAs: pool}
+	c := NewClient(ct)
+	defer c.Close()
+	if err := c.Call("echo", gobShape{ID: 1}, &ret); err != nil || ret.ID != 1 {
+		t.Fatalf("❌ echo over TLS = %+v, %v", ret, err)
+	}
+	t.Logf("✅ gob over TLS by the TLSConfig")
+}
+
+func Test_GobHttpClientTransport_notAllowed(t *testing.T) {
+	ts := newGobTestServer(t, false)
+	c := NewClient(NewGobHttpClientTransport(ts.URL))
+
+	err := c.Call("typed", 1, nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != ErrParseError().Code {
+		t.Fatalf("❌ err = %v, want a parse error", err)
+	}
+	t.Logf("✅ err = %v", err)
+}
@@ -0,0 +1,140 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// groupWriterBufferSize is the size of the buffer where responses are coalesced.
+const groupWriterBufferSize = 64 << 10
+
+// groupWriter writes frames with group commit: frames written while others
+// are waiting to be written are coalesced in a buffer, and flushed into the
+// underlying writer in one write once nobody is waiting, or after interval
+// (if > 0), whichever is later. The buffer is flushed early when it's full.
+//
+// Under many concurrent small responses this saves a write syscall per
+// response; a lone response is flushed at once (interval = 0) or at most
+// interval later.
+//
+// If maxPending > 0, a writer finding more than maxPending bytes of frames
+// already waiting to get out (buffered, or waiting for mu)
+// fails with errSlowClient instead of piling up more.
+type groupWriter struct {
+	waiting    atomic.Int32 // writers waiting for mu
+	pending    atomic.Int64 // bytes of frames given but not written out yet
+	interval   time.Duration
+	maxPending int64
+
+	mu    sync.Mutex
+	w     *bufio.Writer
+	timer *time.Timer // the delayed flush scheduled, nil if none
+	err   error       // the first write error, sticky
+}
+
+// errSlowClient tells that the peer doesn't read its responses fast enough.
+var errSlowClient = errors.New("client too slow reading responses")
+
+func newGroupWriter(w io.Writer, interval time.Duration) *groupWriter {
+	g := &groupWriter{interval: interval}
+	g.w = bufio.NewWriterSize(&writtenOut{w, g}, groupWriterBufferSize)
+	return g
+}
+
+// writtenOut writes into w the bytes flushed by g, counting them out of
+// its pending bytes: by a flush, or as the buffer fills up.
+type writtenOut struct {
+	w io.Writer
+	g *groupWriter
+}
+
+func (w *writtenOut) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.g.pending.Add(-int64(n))
+	return n, err
+}
+
+// frameSize is the size of the frame of body, see writeFrame.
+func frameSize(body []byte) int64 {
+	var digits [20]byte
+	return int64(len("Content-Length: \r\n\r\n") + len(strconv.AppendInt(digits[:0], int64(len(body)), 10)) + len(body))
+}
+
+// writeFrame writes body as a frame, see writeFrame.
+func (g *groupWriter) writeFrame(body []byte) error {
+	n := frameSize(body)
+	if p := g.pending.Add(n); g.maxPending > 0 && p > g.maxPending {
+		g.pending.Add(-n)
+		return errSlowClient
+	}
+
+	g.waiting.Add(1)
+	g.mu.Lock()
+	g.waiting.Add(-1)
+	defer g.mu.Unlock()
+
+	if g.err != nil {
+		g.pending.Add(-n)
+		return g.err
+	}
+	if g.err = writeFrame(g.w, body); g.err != nil {
+		return g.err
+	}
+
+	if g.waiting.Load() > 0 {
+		return nil // the last one in the group flushes
+	}
+	if g.interval <= 0 {
+		return g.flushLocked()
+	}
+	if g.timer == nil {
+		g.timer = time.AfterFunc(g.interval, func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			g.timer = nil
+			_ = g.flushLocked()
+		})
+	}
+	return nil
+}
+
+// flush what's buffered now.
+func (g *groupWriter) flush() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	return g.flushLocked()
+}
+
+// flushLocked flushes the buffer. g.mu must be held.
+func (g *groupWriter) flushLocked() error {
+	if g.err != nil {
+		return g.err
+	}
+	g.err = g.w.Flush()
+	return g.err
+}
+
+// deadlineWriter sets a write deadline of timeout on conn before every write.
+type deadlineWriter struct {
+	conn    io.Writer
+	timeout time.Duration
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	if d, ok := w.conn.(interface{ SetWriteDeadline(time.Time) error }); ok && w.timeout > 0 {
+		if err := d.SetWriteDeadline(time.Now().Add(w.timeout)); err != nil {
+			return 0, err
+		}
+	}
+	return w.conn.Write(p)
+}
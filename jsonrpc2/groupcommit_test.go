@@ -0,0 +1,48 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func Test_groupWriter_frameSize(t *testing.T) {
+	for _, body := range []string{"", "x", `{"jsonrpc":"2.0","result":1,"id":1}`} {
+		var buf bytes.Buffer
+		_ = writeFrame(&buf, []byte(body))
+		if got := frameSize([]byte(body)); got != int64(buf.Len()) {
+			t.Errorf("❌ frameSize(%q) = %d, want %d", body, got, buf.Len())
+		}
+	}
+}
+
+// Test_groupWriter_burst writes bursts of frames concurrently, coalesced
+// and written out as the buffer fills up: none of them is pending long.
+func Test_groupWriter_burst(t *testing.T) {
+	var out bytes.Buffer
+	g := newGroupWriter(&out, 0)
+	g.maxPending = 2 * groupWriterBufferSize
+
+	frame := bytes.Repeat([]byte("x"), 1<<10)
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if err := g.writeFrame(frame); err != nil {
+					t.Errorf("❌ %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := g.pending.Load(); got != 0 {
+		t.Errorf("❌ %d bytes pending once all written", got)
+	}
+	if want := 64 * 200 * frameSize(frame); int64(out.Len()) != want {
+		t.Errorf("❌ %d bytes written, want %d", out.Len(), want)
+	}
+}
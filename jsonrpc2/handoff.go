@@ -0,0 +1,236 @@
+package jsonrpc2
+
+// 这个文件实现监听套接字的零停机交接，升级服务时不拒绝客户端的连接。两种方式：
+//
+//   - SO_REUSEPORT (各 ServerTransport 的 ReusePort)：新旧进程同时监听同一个 TCP 地址，
+//     由内核把新连接分给它们，新进程就绪后旧进程再优雅关闭；
+//   - 传递 fd (Handoff)：旧进程把监听套接字传给它启动的新进程 (按 systemd 的 LISTEN_FDS 约定)，
+//     新进程的传输层监听同一地址时直接接手，交接期间到达的连接在内核的 backlog 中等待。Unix socket 也适用。
+//
+// 同样的约定下，传输层也能直接接手 systemd socket activation 传入的监听套接字。
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The environment variables passing listeners to a process, as systemd
+// does for socket activation: LISTEN_FDS of them, from the fd 3 on, for
+// the process LISTEN_PID (if set).
+const (
+	envListenFds   = "LISTEN_FDS"
+	envListenPid   = "LISTEN_PID"
+	listenFdsStart = 3
+)
+
+// errReusePortUnsupported fails listening with ReusePort where the system
+// has no SO_REUSEPORT.
+var errReusePortUnsupported = errors.New("jsonrpc2: SO_REUSEPORT is not supported on this system")
+
+// listen on addr of network for a server transport: the listener of the
+// same address inherited from the parent process (see Handoff) is taken
+// over, if any, else it listens anew, with SO_REUSEPORT if reusePort
+// (TCP only). The listener is handed off by NewHandoff until it's closed.
+func listen(network, addr string, reusePort bool) (net.Listener, error) {
+	l := takeInherited(network, addr)
+	if l == nil {
+		var lc net.ListenConfig
+		if reusePort && strings.HasPrefix(network, "tcp") {
+			lc.Control = setReusePort
+		}
+		var err error
+		if l, err = lc.Listen(context.Background(), network, addr); err != nil {
+			return nil, err
+		}
+	}
+	return handoffListeners.track(l), nil
+}
+
+// inherited holds the listeners passed to this process, not taken over yet.
+var inherited struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners []net.Listener
+}
+
+// takeInherited takes the inherited listener of addr, nil if there is none.
+func takeInherited(network, addr string) net.Listener {
+	inherited.once.Do(func() { inherit(inheritedFiles()) })
+
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	for i, l := range inherited.listeners {
+		if sameAddr(l.Addr(), network, addr) {
+			inherited.listeners = append(inherited.listeners[:i], inherited.listeners[i+1:]...)
+			return l
+		}
+	}
+	return nil
+}
+
+// inheritedFiles are the files of the fds passed by LISTEN_FDS. The
+// variables are unset, not to be passed on to the children of the process.
+func inheritedFiles() []*os.File {
+	n, err := strconv.Atoi(os.Getenv(envListenFds))
+	pid := os.Getenv(envListenPid)
+	if err != nil || n <= 0 || (pid != "" && pid != strconv.Itoa(os.Getpid())) {
+		return nil
+	}
+	_ = os.Unsetenv(envListenFds)
+	_ = os.Unsetenv(envListenPid)
+
+	files := make([]*os.File, n)
+	for i := range files {
+		fd := listenFdsStart + i
+		files[i] = os.NewFile(uintptr(fd), "listener "+strconv.Itoa(fd))
+	}
+	return files
+}
+
+// inherit the listeners of files, closing them. Those not of listeners are skipped.
+func inherit(files []*os.File) {
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	for _, f := range files {
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			defaultLogger.Log(LevelWarn, "skipped an inherited file", Field{"file", f.Name()}, Field{"error", err})
+			continue
+		}
+		inherited.listeners = append(inherited.listeners, l)
+	}
+}
+
+// sameAddr tells whether a listens on addr of network, e.g. [::]:5680 on ":5680".
+func sameAddr(a net.Addr, network, addr string) bool {
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		want, err := net.ResolveTCPAddr(network, addr)
+		if err != nil || want.Port == 0 || want.Port != a.Port {
+			return false
+		}
+		if want.IP == nil {
+			return a.IP.IsUnspecified()
+		}
+		return want.IP.Equal(a.IP)
+	case *net.UnixAddr:
+		return strings.HasPrefix(network, "unix") && a.Name == addr
+	default:
+		return false
+	}
+}
+
+// handoffListeners are the listeners opened by listen, not closed yet.
+var handoffListeners listenerSet
+
+type listenerSet struct {
+	mu sync.Mutex
+	m  map[*handoffListener]struct{}
+}
+
+// track l until it's closed.
+func (s *listenerSet) track(l net.Listener) net.Listener {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[*handoffListener]struct{})
+	}
+	hl := &handoffListener{Listener: l, set: s}
+	s.m[hl] = struct{}{}
+	return hl
+}
+
+// handoffListener is a listener tracked by a listenerSet.
+type handoffListener struct {
+	net.Listener
+	set *listenerSet
+}
+
+func (l *handoffListener) Close() error {
+	l.set.mu.Lock()
+	delete(l.set.m, l)
+	l.set.mu.Unlock()
+	return l.Listener.Close()
+}
+
+// Handoff holds the listeners of the server transports of this process,
+// for a successor process to take them over, e.g. to upgrade a server
+// without refusing any connection:
+//
+//	h, err := jsonrpc2.NewHandoff()
+//	// ... shut the transports down (e.g. config.Config.ServeContext), then:
+//	err = h.Start(exec.Command(newBinary, args...))
+//
+// The transports of the successor listening on the same addresses (by
+// Serve) take the listeners over instead of listening anew. Meanwhile
+// the listeners stay open in h, even once the transports are shut down:
+// the connections arriving wait in their backlog, not refused.
+type Handoff struct {
+	files []*os.File
+}
+
+// NewHandoff duplicates the listeners of the server transports serving
+// in this process (those listening by Serve, not by ServeListener).
+// Unix sockets are no longer removed when the transports close them.
+func NewHandoff() (*Handoff, error) {
+	handoffListeners.mu.Lock()
+	defer handoffListeners.mu.Unlock()
+
+	h := &Handoff{}
+	for l := range handoffListeners.m {
+		fl, ok := l.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			h.Close()
+			return nil, fmt.Errorf("jsonrpc2: can't hand off the listener of %v", l.Addr())
+		}
+		f, err := fl.File()
+		if err != nil {
+			h.Close()
+			return nil, err
+		}
+		if ul, ok := l.Listener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false) // the successor listens on it
+		}
+		h.files = append(h.files, f)
+	}
+	return h, nil
+}
+
+// Start cmd, the successor, passing it the listeners of h, by the fds
+// from 3 on (before cmd.ExtraFiles) and LISTEN_FDS added to cmd.Env.
+// h is closed then: its listeners are the successor's.
+func (h *Handoff) Start(cmd *exec.Cmd) error {
+	defer h.Close()
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, envListenFds+"=") && !strings.HasPrefix(kv, envListenPid+"=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, envListenFds+"="+strconv.Itoa(len(h.files)))
+	cmd.ExtraFiles = append(append([]*os.File{}, h.files...), cmd.ExtraFiles...)
+	return cmd.Start()
+}
+
+// Close the listeners of h, if it's not Start'ed: the connections
+// waiting in their backlog are refused.
+func (h *Handoff) Close() error {
+	for _, f := range h.files {
+		f.Close()
+	}
+	h.files = nil
+	return nil
+}
@@ -0,0 +1,125 @@
+package jsonrpc2
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func Test_listen_ReusePort(t *testing.T) {
+	l1, err := listen("tcp", "127.0.0.1:0", true)
+	if errors.Is(err, errReusePortUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l1.Close()
+	addr := l1.Addr().String()
+
+	if l, err := listen("tcp", addr, false); err == nil {
+		l.Close()
+		t.Errorf("❌ listened on %s taken, without ReusePort", addr)
+	}
+	l2, err := listen("tcp", addr, true)
+	if err != nil {
+		t.Fatalf("❌ listen on %s with ReusePort: %v", addr, err)
+	}
+	l2.Close()
+}
+
+func Test_Handoff(t *testing.T) {
+	takeInherited("tcp", "127.0.0.1:0") // inherit from the env first
+
+	tests := []struct {
+		network, addr string
+	}{
+		{"tcp", "127.0.0.1:0"},
+		{"unix", filepath.Join(t.TempDir(), "rpc.sock")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			l, err := listen(tt.network, tt.addr, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := l.Addr().String()
+
+			h, err := NewHandoff()
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.Close() // the old transport shut down
+
+			// connections keep being accepted by the kernel meanwhile
+			conn, err := net.Dial(tt.network, addr)
+			if err != nil {
+				t.Fatalf("❌ dial during the handoff: %v", err)
+			}
+			defer conn.Close()
+
+			// the successor takes the listener over
+			inherit(h.files)
+			h.files = nil
+			taken, err := listen(tt.network, addr, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer taken.Close()
+			discardInherited() // those of the other tests serving
+			handoffListeners.mu.Lock()
+			_, tracked := handoffListeners.m[taken.(*handoffListener)]
+			handoffListeners.mu.Unlock()
+			if !tracked {
+				t.Error("❌ the listener taken over is not to hand off in turn")
+			}
+
+			accepted, err := taken.Accept()
+			if err != nil {
+				t.Fatalf("❌ accept the connection waiting: %v", err)
+			}
+			defer accepted.Close()
+			if _, err := conn.Write([]byte("hi")); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, 2)
+			if _, err := accepted.Read(buf); err != nil || string(buf) != "hi" {
+				t.Errorf("❌ read %q, %v; want hi", buf, err)
+			}
+		})
+	}
+}
+
+// discardInherited closes the inherited listeners not taken over.
+func discardInherited() {
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	for _, l := range inherited.listeners {
+		l.Close()
+	}
+	inherited.listeners = nil
+}
+
+func Test_sameAddr(t *testing.T) {
+	tcp := func(s string) net.Addr { a, _ := net.ResolveTCPAddr("tcp", s); return a }
+	tests := []struct {
+		a             net.Addr
+		network, addr string
+		want          bool
+	}{
+		{tcp("[::]:5680"), "tcp", ":5680", true},
+		{tcp("0.0.0.0:5680"), "tcp", ":5680", true},
+		{tcp("127.0.0.1:5680"), "tcp", "127.0.0.1:5680", true},
+		{tcp("127.0.0.1:5680"), "tcp", ":5680", false},
+		{tcp("[::]:5680"), "tcp", ":5681", false},
+		{tcp("[::]:5680"), "tcp", ":0", false},
+		{&net.UnixAddr{Name: "/tmp/rpc.sock", Net: "unix"}, "unix", "/tmp/rpc.sock", true},
+		{&net.UnixAddr{Name: "/tmp/rpc.sock", Net: "unix"}, "tcp", "/tmp/rpc.sock", false},
+	}
+	for _, tt := range tests {
+		if got := sameAddr(tt.a, tt.network, tt.addr); got != tt.want {
+			t.Errorf("❌ sameAddr(%v, %s, %s) = %v, want %v", tt.a, tt.network, tt.addr, got, tt.want)
+		}
+	}
+}
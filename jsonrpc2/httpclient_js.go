@@ -0,0 +1,24 @@
+//go:build js && wasm
+
+package jsonrpc2
+
+// 这个文件让客户端在浏览器中 (GOOS=js GOARCH=wasm) 经由 fetch API 调用：net/http 只有在
+// http.Transport 没有自定义拨号时才用 fetch 发送请求。压缩由浏览器协商并解压，TLS 与连接复用
+// 也归浏览器管。浏览器里没有套接字，只有 HttpClientTransport 可用。
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// browserFetch tells whether the HTTP requests are sent by the fetch API
+// of a browser: it negotiates the compression (Accept-Encoding is
+// forbidden to set) and decompresses the responses by itself.
+const browserFetch = true
+
+// newHTTPTransport makes the http.RoundTripper of the own http.Client of
+// an HttpClientTransport: one without dialers, to be sent by fetch.
+// The TLS is the browser's, tlsConfig is ignored.
+func newHTTPTransport(*tls.Config) http.RoundTripper {
+	return &http.Transport{}
+}
@@ -0,0 +1,21 @@
+//go:build !(js && wasm)
+
+package jsonrpc2
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// browserFetch tells whether the HTTP requests are sent by the fetch API
+// of a browser, see httpclient_js.go.
+const browserFetch = false
+
+// newHTTPTransport makes the http.RoundTripper of the own http.Client of
+// an HttpClientTransport.
+func newHTTPTransport(tlsConfig *tls.Config) http.RoundTripper {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	tr.TLSClientConfig = tlsConfig
+	return tr
+}
@@ -0,0 +1,83 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ID is the id of a request, and of its response: a number or a string,
+// as the spec allows. Numbers are integers, those with fractional parts
+// are rejected. The Client sends numbers, the Server echoes whatever it
+// is given.
+//
+// IDs are comparable: two are equal if they're of the same type and
+// value, so 1 and "1" are different ids.
+type ID struct {
+	num   int64
+	str   string
+	isStr bool
+}
+
+// Int64ID returns the number id n.
+func Int64ID(n int64) *ID {
+	return &ID{num: n}
+}
+
+// StringID returns the string id s.
+func StringID(s string) *ID {
+	return &ID{str: s, isStr: true}
+}
+
+// Int64 returns the number of id, or ok=false if it's a string.
+func (id ID) Int64() (n int64, ok bool) {
+	return id.num, !id.isStr
+}
+
+// IsString tells whether id is a string.
+func (id ID) IsString() bool {
+	return id.isStr
+}
+
+// String formats id as in JSON, e.g. 42 or "42".
+func (id ID) String() string {
+	if id.isStr {
+		return strconv.Quote(id.str)
+	}
+	return strconv.FormatInt(id.num, 10)
+}
+
+func (id ID) MarshalJSON() ([]byte, error) {
+	if id.isStr {
+		return json.Marshal(id.str)
+	}
+	return strconv.AppendInt(nil, id.num, 10), nil
+}
+
+func (id *ID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*id = ID{str: s, isStr: true}
+		return nil
+	}
+	n, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("jsonrpc2: id %s is neither an integer nor a string", data)
+	}
+	*id = ID{num: n}
+	return nil
+}
+
+// GobEncode encodes id for the gob transports, as in JSON.
+func (id ID) GobEncode() ([]byte, error) {
+	return id.MarshalJSON()
+}
+
+func (id *ID) GobDecode(data []byte) error {
+	return id.UnmarshalJSON(data)
+}
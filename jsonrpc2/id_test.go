@@ -0,0 +1,91 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+func TestID_JSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want *ID
+	}{
+		{`1`, Int64ID(1)},
+		{`-42`, Int64ID(-42)},
+		{`"1"`, StringID("1")},
+		{`"a\"b"`, StringID(`a"b`)},
+		{`""`, StringID("")},
+		{`null`, nil},
+	}
+	for _, tt := range tests {
+		var req Request
+		if err := json.Unmarshal([]byte(`{"jsonrpc":"2.0","method":"m","id":`+tt.json+`}`), &req); err != nil {
+			t.Errorf("❌ %s: %v", tt.json, err)
+			continue
+		}
+		if (req.Id == nil) != (tt.want == nil) || req.Id != nil && *req.Id != *tt.want {
+			t.Errorf("❌ %s = %v, want %v", tt.json, req.Id, tt.want)
+			continue
+		}
+		if req.Id == nil {
+			continue
+		}
+		if out, err := json.Marshal(req.Id); err != nil || string(out) != tt.json {
+			t.Errorf("❌ %s marshaled as %s, %v", tt.json, out, err)
+		}
+	}
+
+	if *Int64ID(1) == *StringID("1") {
+		t.Error("❌ 1 == \"1\"")
+	}
+	for _, bad := range []string{`1.5`, `1e3`, `true`, `{}`, `[1]`} {
+		var id ID
+		if err := json.Unmarshal([]byte(bad), &id); err == nil {
+			t.Errorf("❌ unmarshaled the id %s as %v", bad, id)
+		}
+	}
+}
+
+func TestID_gob(t *testing.T) {
+	for _, id := range []*ID{Int64ID(7), StringID("seven")} {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(gobRequest{Method: "m", Id: id}); err != nil {
+			t.Fatal(err)
+		}
+		var got gobRequest
+		if err := gob.NewDecoder(&buf).Decode(&got); err != nil || got.Id == nil || *got.Id != *id {
+			t.Errorf("❌ %v decoded as %v, %v", id, got.Id, err)
+		}
+	}
+}
+
+func Test_server_stringIds(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "echo", Params: []byte(`"x"`), Id: StringID("abc")})
+	if resp.Error != nil || resp.Id == nil || *resp.Id != *StringID("abc") {
+		t.Errorf("❌ response %#v, want the string id echoed", resp)
+	}
+
+	// over a connection, where the client matches the responses by id
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+	ct := NewTcpClientTransport(l.Addr().String())
+	defer ct.Close()
+
+	for _, id := range []*ID{StringID("1"), Int64ID(1)} {
+		resp, err := ct.SendAndReceive(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "echo", Params: []byte(`"y"`), Id: id})
+		if err != nil || resp.Id == nil || *resp.Id != *id || string(resp.Result) != `"y"` {
+			t.Errorf("❌ %v: %#v, %v", id, resp, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package jsonrpc2
+
+import "strconv"
+
+// IDKeyer maps request ids to the keys of the at-most-once dedupe store.
+//
+// Keys must be canonical: the same id always maps to the same key, and
+// different ids (including ids of different JSON types, like 1 and "1")
+// to different keys, so every store backend sees the same keys for the
+// same requests.
+type IDKeyer interface {
+	// Key returns the key of id, or ok=false if requests with this id
+	// can't be deduplicated (e.g. a null id).
+	Key(id *ID) (key string, ok bool)
+}
+
+// DefaultIDKeyer encodes numeric ids as "n:" followed by their decimal form,
+// and string ids as "s:" followed by the string.
+var DefaultIDKeyer IDKeyer = defaultIDKeyer{}
+
+type defaultIDKeyer struct{}
+
+func (defaultIDKeyer) Key(id *ID) (string, bool) {
+	if id == nil {
+		return "", false
+	}
+	if id.IsString() {
+		return "s:" + id.str, true
+	}
+	return "n:" + strconv.FormatInt(id.num, 10), true
+}
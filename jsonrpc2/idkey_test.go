@@ -0,0 +1,60 @@
+package jsonrpc2
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDefaultIDKeyer(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      *ID
+		wantKey string
+		wantOk  bool
+	}{
+		{"nil", nil, "", false},
+		{"zero", Int64ID(0), "n:0", true},
+		{"positive", Int64ID(42), "n:42", true},
+		{"negative", Int64ID(-42), "n:-42", true},
+		{"string", StringID("42"), "s:42", true},
+		{"empty string", StringID(""), "s:", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := DefaultIDKeyer.Key(tt.id)
+			if key != tt.wantKey || ok != tt.wantOk {
+				t.Errorf("Key() = (%q, %v), want (%q, %v)", key, ok, tt.wantKey, tt.wantOk)
+			}
+		})
+	}
+}
+
+// modKeyer keys ids by their remainder of 10, so 1 and 11 are duplicates.
+type modKeyer struct{}
+
+func (modKeyer) Key(id *ID) (string, bool) {
+	if id == nil {
+		return "", false
+	}
+	n, ok := id.Int64()
+	if !ok {
+		return "", false
+	}
+	return string(rune('0' + n%10)), true
+}
+
+func Test_server_WithIDKeyer(t *testing.T) {
+	s := NewServer().WithAtMostOnce().WithIDKeyer(modKeyer{})
+	if err := s.Register("echo", func(arg int) (int, error) { return arg, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	first := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "echo", Params: []byte(`1`), Id: Int64ID(1)})
+	if first.Error != nil {
+		t.Fatalf("first call error: %v", first.Error)
+	}
+	dup := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "echo", Params: []byte(`1`), Id: Int64ID(11)})
+	if dup.Error == nil || dup.Error.Code != ErrAtMostOnce().Code {
+		t.Errorf("want ErrAtMostOnce for the same key, got %#v", dup.Error)
+	}
+}
@@ -0,0 +1,116 @@
+package jsonrpc2
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPFilter lets server transports turn away clients by their IP address,
+// before reading anything from them: a simple network-level protection of
+// internal services.
+//
+// A client is denied if its address is in any Deny network; otherwise it's
+// allowed if Allow is empty or its address is in any Allow network.
+// Clients without an IP address (e.g. over a Unix socket) are not filtered.
+type IPFilter struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// NewIPFilter parses the CIDR lists allow and deny, e.g. "10.0.0.0/8".
+// A plain address, like "127.0.0.1" or "::1", is a network of that single address.
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	f := &IPFilter{}
+	var err error
+	if f.Allow, err = parseCIDRs(allow); err != nil {
+		return nil, err
+	}
+	if f.Deny, err = parseCIDRs(deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("bad IP address %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("bad CIDR %q: %w", cidr, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Allowed reports whether the client at ip may be served.
+// A nil IPFilter allows everyone.
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	if f == nil {
+		return true
+	}
+	for _, n := range f.Deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, n := range f.Allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedAddr is Allowed for the address of a client, e.g. net.Conn.RemoteAddr.
+func (f *IPFilter) allowedAddr(addr net.Addr) bool {
+	if f == nil || addr == nil {
+		return true
+	}
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return f.Allowed(a.IP)
+	case *net.UDPAddr:
+		return f.Allowed(a.IP)
+	case *net.IPAddr:
+		return f.Allowed(a.IP)
+	}
+	return f.allowedHostPort(addr.String())
+}
+
+// allowedHostPort is Allowed for an address like http.Request.RemoteAddr.
+// Addresses that are not IPs are not filtered.
+func (f *IPFilter) allowedHostPort(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return true
+	}
+	return f.Allowed(ip)
+}
+
+// allowedRequest is Allowed for the peer of r, by its RemoteAddr.
+// Headers like X-Forwarded-For are not trusted.
+func (f *IPFilter) allowedRequest(r *http.Request) bool {
+	return f == nil || f.allowedHostPort(r.RemoteAddr)
+}
@@ -0,0 +1,118 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_IPFilter_Allowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		ip    string
+		want  bool
+	}{
+		{"noLists", nil, nil, "203.0.113.7", true},
+		{"allowed", []string{"10.0.0.0/8"}, nil, "10.1.2.3", true},
+		{"notAllowed", []string{"10.0.0.0/8"}, nil, "192.168.1.1", false},
+		{"denied", nil, []string{"192.168.0.0/16"}, "192.168.1.1", false},
+		{"denyWins", []string{"10.0.0.0/8"}, []string{"10.0.0.13"}, "10.0.0.13", false},
+		{"singleAddress", []string{"127.0.0.1"}, nil, "127.0.0.1", true},
+		{"ipv6", []string{"fd00::/8"}, nil, "fd12::1", true},
+		{"ipv6NotAllowed", []string{"fd00::/8"}, nil, "2001:db8::1", false},
+		{"ipv4MappedIpv6", []string{"10.0.0.0/8"}, nil, "::ffff:10.0.0.1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewIPFilter(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Allowed(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("❌ Allowed(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("badCIDR", func(t *testing.T) {
+		if _, err := NewIPFilter([]string{"10.0.0.0/33"}, nil); err == nil {
+			t.Error("❌ want an error")
+		}
+		if _, err := NewIPFilter(nil, []string{"not an ip"}); err == nil {
+			t.Error("❌ want an error")
+		}
+	})
+}
+
+func Test_HttpServerTransport_IPFilter(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	st.IPFilter, _ = NewIPFilter([]string{"10.0.0.0/8"}, nil)
+
+	tests := []struct {
+		remoteAddr string
+		wantStatus int
+	}{
+		{"10.0.0.1:1234", http.StatusOK},
+		{"192.168.1.1:1234", http.StatusForbidden},
+		{"[fd00::1]:1234", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/",
+				bytes.NewBufferString(`{"jsonrpc":"2.0","method":"echo","params":1,"id":1}`))
+			r.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+			st.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("❌ status = %d, want %d", w.Code, tt.wantStatus)
+			} else {
+				t.Logf("✅ status = %d", w.Code)
+			}
+		})
+	}
+}
+
+func Test_StreamServerTransport_IPFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		deny    []string
+		wantErr bool
+	}{
+		{"allowed", []string{"10.0.0.0/8"}, false},
+		{"denied", []string{"127.0.0.0/8"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			st := &StreamServerTransport{Network: "tcp"}
+			st.IPFilter, _ = NewIPFilter(nil, tt.deny)
+			go st.ServeListener(l, newStreamTestEchoServer(t))
+
+			ct := NewTcpClientTransport(l.Addr().String())
+			defer ct.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var ret int
+			err = NewClient(ct).CallContext(ctx, "echo", 1, &ret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("❌ err = %v, wantErr %v", err, tt.wantErr)
+			}
+			t.Logf("✅ ret = %d, err = %v", ret, err)
+		})
+	}
+}
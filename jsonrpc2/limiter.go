@@ -0,0 +1,147 @@
+package jsonrpc2
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter bounds how many method calls execute simultaneously.
+//
+// Callers beyond the limit wait in a FIFO queue for a free slot.
+// If the queue is full as well, the caller is shed.
+type limiter struct {
+	mu       sync.Mutex
+	limit    int // max concurrently executing calls
+	maxQueue int // max waiting calls, < 0 means unbounded
+	inflight int
+	queue    []chan struct{}
+
+	// avgHold is a moving average of how long a slot is held,
+	// used to hint shed callers when to come back.
+	avgHold time.Duration
+
+	adaptive *aimd // adjusting limit, nil: it's fixed
+}
+
+func newLimiter(limit, maxQueue int) *limiter {
+	return &limiter{limit: limit, maxQueue: maxQueue}
+}
+
+// acquire takes a slot, waiting in the queue if necessary.
+// It returns how long the caller waited by c, and ok=false if the caller
+// was shed or ctx was done while waiting.
+// A successful acquire must be paired with a release.
+func (l *limiter) acquire(ctx context.Context, c Clock, m Metrics) (waited time.Duration, ok bool) {
+	l.mu.Lock()
+	if l.inflight < l.limit {
+		l.inflight++
+		m.Set("concurrency.inflight", int64(l.inflight))
+		l.mu.Unlock()
+		return 0, true
+	}
+	if l.maxQueue >= 0 && len(l.queue) >= l.maxQueue {
+		l.mu.Unlock()
+		return 0, false
+	}
+	ch := make(chan struct{})
+	l.queue = append(l.queue, ch)
+	m.Set("queue.depth", int64(len(l.queue)))
+	l.mu.Unlock()
+
+	start := c.Now()
+	select {
+	case <-ch: // the slot is handed over by release
+		return since(c, start), true
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	for i, waiting := range l.queue {
+		if waiting == ch {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			m.Set("queue.depth", int64(len(l.queue)))
+			l.mu.Unlock()
+			return since(c, start), false
+		}
+	}
+	l.mu.Unlock()
+
+	// too late: the slot was handed over meanwhile, pass it on
+	<-ch
+	l.mu.Lock()
+	l.pass(m)
+	l.mu.Unlock()
+	return since(c, start), false
+}
+
+// release gives back a slot held for the duration held.
+// The slot goes to the first waiter in the queue, if any.
+func (l *limiter) release(m Metrics, held time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// EWMA with alpha = 1/8
+	l.avgHold += (held - l.avgHold) / 8
+
+	if l.adaptive != nil {
+		if limit := l.adaptive.adapt(l.limit, l.inflight, held); limit != l.limit {
+			l.limit = limit
+			m.Set("concurrency.limit", int64(limit))
+			l.fill(m)
+		}
+	}
+
+	l.pass(m)
+}
+
+// pass the slot of the caller on to the first waiter, or free it,
+// if the limit was lowered meanwhile. l.mu must be held.
+func (l *limiter) pass(m Metrics) {
+	if len(l.queue) > 0 && l.inflight <= l.limit {
+		next := l.queue[0]
+		l.queue = l.queue[1:]
+		m.Set("queue.depth", int64(len(l.queue)))
+		close(next)
+		return
+	}
+	l.inflight--
+	m.Set("concurrency.inflight", int64(l.inflight))
+}
+
+// fill the slots of a limit raised with the waiters. l.mu must be held.
+func (l *limiter) fill(m Metrics) {
+	for len(l.queue) > 0 && l.inflight < l.limit {
+		next := l.queue[0]
+		l.queue = l.queue[1:]
+		l.inflight++
+		close(next)
+	}
+	m.Set("queue.depth", int64(len(l.queue)))
+	m.Set("concurrency.inflight", int64(l.inflight))
+}
+
+// retryAfter estimates when a shed caller may find a free slot:
+// the time to drain the current queue, plus one more slot.
+func (l *limiter) retryAfter() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d := l.avgHold * time.Duration(len(l.queue)+1) / time.Duration(l.limit)
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+	return d
+}
+
+// stats reports the current state and thresholds of the limiter.
+func (l *limiter) stats() map[string]int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return map[string]int64{
+		"concurrency.limit":    int64(l.limit),
+		"concurrency.inflight": int64(l.inflight),
+		"queue.limit":          int64(l.maxQueue),
+		"queue.depth":          int64(len(l.queue)),
+	}
+}
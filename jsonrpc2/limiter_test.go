@@ -0,0 +1,149 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_limiter(t *testing.T) {
+	l := newLimiter(1, 1)
+	m := NewMemoryMetrics()
+	clock := NewFakeClock(time.Unix(0, 0))
+
+	if _, ok := l.acquire(context.Background(), clock, m); !ok {
+		t.Fatal("first acquire should succeed")
+	}
+
+	// the 2nd caller waits in the queue
+	acquired := make(chan time.Duration)
+	go func() {
+		waited, ok := l.acquire(context.Background(), clock, m)
+		if !ok {
+			t.Error("queued acquire should succeed")
+		}
+		acquired <- waited
+	}()
+
+	for l.stats()["queue.depth"] != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the 3rd caller is shed: queue is full
+	if _, ok := l.acquire(context.Background(), clock, m); ok {
+		t.Fatal("acquire should be shed when the queue is full")
+	}
+
+	clock.Advance(3 * time.Second)
+	l.release(m, 10*time.Millisecond)
+	if waited := <-acquired; waited != 3*time.Second {
+		t.Errorf("waited = %v, want 3s by the clock", waited)
+	}
+	if got := l.stats(); got["queue.depth"] != 0 || got["concurrency.inflight"] != 1 {
+		t.Errorf("stats after handover = %v", got)
+	}
+
+	if d := l.retryAfter(); d < time.Millisecond {
+		t.Errorf("retryAfter() = %v, want >= 1ms", d)
+	}
+
+	l.release(m, 10*time.Millisecond)
+	if got := l.stats()["concurrency.inflight"]; got != 0 {
+		t.Errorf("inflight = %d, want 0", got)
+	}
+}
+
+func Test_server_WithMaxConcurrency(t *testing.T) {
+	s := NewServer().WithMaxConcurrency(1).WithMaxQueue(0)
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	err := s.Register("block", func(arg int) (int, error) {
+		close(started)
+		<-block
+		return arg, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "block", Params: []byte(`1`), Id: Int64ID(1)})
+		if resp.Error != nil {
+			t.Errorf("first call error: %v", resp.Error)
+		}
+	}()
+	<-started
+
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "block", Params: []byte(`2`), Id: Int64ID(2)})
+	if resp.Error == nil || resp.Error.Code != ErrServerBusy().Code {
+		t.Fatalf("want ErrServerBusy, got %#v", resp.Error)
+	}
+	var data struct {
+		Reason       string `json:"reason"`
+		RetryAfterMs *int64 `json:"retry_after_ms"`
+	}
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil || data.RetryAfterMs == nil {
+		t.Errorf("want retry_after_ms in error data, got %s (%v)", resp.Error.Data, err)
+	}
+
+	close(block)
+	wg.Wait()
+
+	stats := s.Stats()
+	if stats["requests.shed"] != 1 {
+		t.Errorf("requests.shed = %d, want 1", stats["requests.shed"])
+	}
+	if stats["concurrency.limit"] != 1 || stats["queue.limit"] != 0 {
+		t.Errorf("thresholds not exposed: %v", stats)
+	}
+
+	// debug endpoint
+	rec := httptest.NewRecorder()
+	DebugHandler(s).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/jsonrpc2", nil))
+	var got map[string]int64
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["requests.shed"] != 1 || got["concurrency.limit"] != 1 {
+		t.Errorf("DebugHandler = %s", rec.Body.String())
+	}
+}
+
+func Test_server_Unlimited(t *testing.T) {
+	s := NewServer().WithMaxConcurrency(1).WithMaxQueue(0).WithTenantMaxConcurrency(1)
+
+	permits := make(chan struct{}, 1)
+	permits <- struct{}{} // held
+	started := make(chan struct{})
+	s.MustRegister("lock", func(ctx context.Context, arg int) (int, error) {
+		close(started)
+		permits <- struct{}{}
+		return arg, nil
+	})
+	s.MustRegister("unlock", func(arg int) (int, error) {
+		<-permits
+		return arg, nil
+	}, Unlimited())
+
+	ctx := WithTenant(context.Background(), "acme")
+	locked := make(chan *Response)
+	go func() {
+		locked <- s.ServeRPC(ctx, &Request{JsonRpc: JsonRpc2, Method: "lock", Params: []byte(`1`), Id: Int64ID(1)})
+	}()
+	<-started // taking the only slot, waiting for unlock
+
+	resp := s.ServeRPC(ctx, &Request{JsonRpc: JsonRpc2, Method: "unlock", Params: []byte(`2`), Id: Int64ID(2)})
+	if resp.Error != nil {
+		t.Fatalf("❌ unlock: %v, want it to run despite the limits", resp.Error)
+	}
+	if resp := <-locked; resp.Error != nil {
+		t.Errorf("❌ lock: %v", resp.Error)
+	}
+}
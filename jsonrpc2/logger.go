@@ -0,0 +1,122 @@
+package jsonrpc2
+
+// 这个文件实现可注入的结构化日志 (Logger)：Server 与 Client 各自由 WithLogger 设置，
+// 日志带有 id、method、duration、error 等字段，便于接入 log/slog、zap 等日志库。
+// 未设置时由 StdLogger 经标准库 log 输出。
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// LogLevel is the severity of a log record. The levels have the values
+// of those of log/slog, so that slog.Level(level) converts them.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = -4
+	LevelInfo  LogLevel = 0
+	LevelWarn  LogLevel = 4
+	LevelError LogLevel = 8
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// Field is a key-value of a log record, e.g. the "method" of a request.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Logger logs the records of the Servers and Clients, and of their
+// transports, see Server.WithLogger and Client.WithLogger. The records
+// about requests have the fields "method", "id", "duration" and "error".
+//
+// Implement it to log by another library, e.g. log/slog:
+//
+//	type slogLogger struct{ l *slog.Logger }
+//
+//	func (s slogLogger) Log(level jsonrpc2.LogLevel, msg string, fields ...jsonrpc2.Field) {
+//		attrs := make([]slog.Attr, len(fields))
+//		for i, f := range fields {
+//			attrs[i] = slog.Any(f.Key, f.Value)
+//		}
+//		s.l.LogAttrs(context.Background(), slog.Level(level), msg, attrs...)
+//	}
+//
+// It must be safe for concurrent use.
+type Logger interface {
+	Log(level LogLevel, msg string, fields ...Field)
+}
+
+// StdLogger logs the records of Level and above by Logger, as
+// "LEVEL msg key=value ...". The zero StdLogger logs those of LevelInfo
+// and above by the standard logger of package log.
+type StdLogger struct {
+	Logger *log.Logger // nil means log.Default()
+	Level  LogLevel
+}
+
+func (l *StdLogger) Log(level LogLevel, msg string, fields ...Field) {
+	if level < l.Level {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for _, f := range fields {
+		b.WriteByte(' ')
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		s := fmt.Sprint(f.Value)
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+
+	logger := l.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Print(b.String())
+}
+
+// defaultLogger logs for those not given a Logger.
+var defaultLogger Logger = &StdLogger{}
+
+// loggerOr returns l, or the default logger if it's nil.
+func loggerOr(l Logger) Logger {
+	if l == nil {
+		return defaultLogger
+	}
+	return l
+}
+
+// loggerOf returns the logger of s, see Server.WithLogger.
+func loggerOf(s Server) Logger {
+	switch srv := s.(type) {
+	case *server:
+		return loggerOr(srv.logger)
+	case *ProxyServer:
+		return loggerOf(srv.Server)
+	default:
+		return defaultLogger
+	}
+}
@@ -0,0 +1,157 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingLogger records the log records, for the tests to inspect.
+type recordingLogger struct {
+	mu      sync.Mutex
+	records []logRecord
+}
+
+type logRecord struct {
+	level  LogLevel
+	msg    string
+	fields map[string]any
+}
+
+func (l *recordingLogger) Log(level LogLevel, msg string, fields ...Field) {
+	r := logRecord{level: level, msg: msg, fields: make(map[string]any, len(fields))}
+	for _, f := range fields {
+		r.fields[f.Key] = f.Value
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, r)
+}
+
+func (l *recordingLogger) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = nil
+}
+
+// count the records of msg.
+func (l *recordingLogger) count(msg string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, r := range l.records {
+		if r.msg == msg {
+			n++
+		}
+	}
+	return n
+}
+
+// find the first record of msg, nil if none.
+func (l *recordingLogger) find(msg string) *logRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.records {
+		if l.records[i].msg == msg {
+			return &l.records[i]
+		}
+	}
+	return nil
+}
+
+func (l *recordingLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var b strings.Builder
+	for _, r := range l.records {
+		fmt.Fprintln(&b, r.level, r.msg, r.fields)
+	}
+	return b.String()
+}
+
+func Test_StdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := &StdLogger{Logger: log.New(&buf, "", 0), Level: LevelInfo}
+
+	l.Log(LevelDebug, "call", Field{"method", "add"})
+	l.Log(LevelWarn, "response", Field{"method", "add"}, Field{"id", "1"}, Field{"error", errors.New("boom: it failed")}, Field{"params", ""})
+
+	want := `WARN response method=add id=1 error="boom: it failed" params=""` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("❌ logged %q, want %q", got, want)
+	}
+}
+
+func Test_server_WithLogger(t *testing.T) {
+	var logs recordingLogger
+	s := NewServer().WithLogSampling(LogSampling{Every: 1}).WithLogger(&logs)
+	s.MustRegister("add", func(arg [2]int) (int, error) { return arg[0] + arg[1], nil })
+	s.MustRegister("panic", func(arg int) (int, error) { panic("boom") })
+
+	id := int64(7)
+	s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "add", Params: []byte(`[1,2]`), Id: Int64ID(id)})
+	resp := logs.find("response")
+	if resp == nil || resp.level != LevelInfo || resp.fields["method"] != "add" || resp.fields["id"] != "7" ||
+		resp.fields["result"] != "3" || resp.fields["duration"] == nil {
+		t.Errorf("❌ response logged %+v", resp)
+	}
+
+	// notifications have no id, nor a response to send
+	logs.reset()
+	s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "add", Params: []byte(`[1,2]`)})
+	if resp := logs.find("response"); resp == nil || resp.fields["id"] != "null" {
+		t.Errorf("❌ notification logged %+v", resp)
+	}
+
+	logs.reset()
+	s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "panic", Params: []byte(`1`), Id: Int64ID(id)})
+	if r := logs.find("recovered from method call"); r == nil || r.level != LevelError || r.fields["panic"] != "boom" {
+		t.Errorf("❌ panic logged %+v\n%s", r, logs.String())
+	}
+	if r := logs.find("response"); r == nil || r.level != LevelWarn || r.fields["error"] == nil {
+		t.Errorf("❌ failed response logged %+v", r)
+	}
+}
+
+func Test_client_WithLogger(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("add", func(arg [2]int) (int, error) { return arg[0] + arg[1], nil })
+
+	var logs recordingLogger
+	c := NewClient(&serverTransport{server: s}).WithLogger(&logs)
+	var sum int
+	if err := c.Call("add", [2]int{1, 2}, &sum); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Call("sub", [2]int{1, 2}, &sum)
+
+	if n := logs.count("call"); n != 2 {
+		t.Fatalf("❌ %d calls logged, want 2\n%s", n, logs.String())
+	}
+	ok, failed := logs.records[0], logs.records[1]
+	if ok.level != LevelDebug || ok.fields["method"] != "add" || ok.fields["error"] != error(nil) {
+		t.Errorf("❌ call logged %+v", ok)
+	}
+	if failed.fields["method"] != "sub" || failed.fields["error"] == nil {
+		t.Errorf("❌ failed call logged %+v", failed)
+	}
+
+	// the transport takes the logger of the client, unless it has one
+	tcp := NewTcpClientTransport("localhost:0")
+	NewClient(tcp).WithLogger(&logs)
+	if tcp.Logger != &logs {
+		t.Errorf("❌ transport logger = %v, want that of the client", tcp.Logger)
+	}
+	own := &StdLogger{}
+	tcp = NewTcpClientTransport("localhost:0")
+	tcp.Logger = own
+	NewClient(tcp).WithLogger(&logs)
+	if tcp.Logger != own {
+		t.Errorf("❌ transport logger replaced by that of the client")
+	}
+}
@@ -0,0 +1,72 @@
+package jsonrpc2
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LogSampling tells which requests a server logs (see Server.WithLogSampling),
+// so that production servers keep useful logs without drowning in them.
+type LogSampling struct {
+	// Every logs 1 in Every requests, the first one included.
+	// 0 logs none, 1 all of them.
+	Every int
+
+	// Errors logs every request answered with an error, sampled or not.
+	Errors bool
+}
+
+// logSampler decides which requests are logged.
+type logSampler struct {
+	sampling atomic.Pointer[LogSampling] // nil: none
+	seq      atomic.Uint64
+}
+
+func (l *logSampler) set(s LogSampling) {
+	l.sampling.Store(&s)
+}
+
+// sample decides whether the next request is logged as it starts.
+func (l *logSampler) sample() bool {
+	s := l.sampling.Load()
+	if s == nil || s.Every <= 0 {
+		return false
+	}
+	return (l.seq.Add(1)-1)%uint64(s.Every) == 0
+}
+
+// logsErrors tells whether the requests answered with errors are all logged.
+func (l *logSampler) logsErrors() bool {
+	s := l.sampling.Load()
+	return s != nil && s.Errors
+}
+
+// logRequest logs req, served by s.
+func (s *server) logRequest(req *Request, pretty bool) {
+	loggerOr(s.logger).Log(LevelInfo, "request",
+		Field{"method", req.Method}, Field{"id", formatId(req.Id)}, Field{"params", string(indentJSON(req.Params, pretty))})
+}
+
+// logResponse logs resp to req, if sampled, or if it's an error and
+// errors are all logged: then req is logged as well, unless it's been
+// already (sampled).
+// A nil resp (e.g. of a Handler answering nothing) is logged as such.
+func (s *server) logResponse(req *Request, resp *Response, sampled, pretty bool, duration time.Duration) {
+	failed := resp != nil && resp.Error != nil
+	if !sampled {
+		if !failed || !s.logSampler.logsErrors() {
+			return
+		}
+		s.logRequest(req, pretty)
+	}
+
+	fields := []Field{{"method", req.Method}, {"id", formatId(req.Id)}, {"duration", duration}}
+	switch {
+	case resp == nil:
+		loggerOr(s.logger).Log(LevelInfo, "no response", fields...)
+	case failed:
+		loggerOr(s.logger).Log(LevelWarn, "response", append(fields, Field{"error", resp.Error})...)
+	default:
+		loggerOr(s.logger).Log(LevelInfo, "response", append(fields, Field{"result", string(indentJSON(resp.Result, pretty))})...)
+	}
+}
@@ -0,0 +1,55 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_server_WithLogSampling(t *testing.T) {
+	var logs recordingLogger
+	tests := []struct {
+		name         string
+		sampling     LogSampling
+		wantRequests int // request lines out of 10 requests, 3 of them failing
+		wantErrors   int // response lines with an error
+	}{
+		{"none", LogSampling{}, 0, 0},
+		{"all", LogSampling{Every: 1}, 10, 3},
+		{"oneInFive", LogSampling{Every: 5}, 2, 1}, // the 1st and the 6th, failing
+		{"errors", LogSampling{Errors: true}, 3, 3},
+		{"oneInFiveAndErrors", LogSampling{Every: 5, Errors: true}, 4, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.reset()
+
+			s := NewServer().WithLogSampling(tt.sampling).WithLogger(&logs)
+			s.MustRegister("div", func(arg [2]int) (int, error) {
+				if arg[1] == 0 {
+					return 0, errors.New("division by zero")
+				}
+				return arg[0] / arg[1], nil
+			})
+			for i := 0; i < 10; i++ {
+				divisor := 1
+				if i%3 == 2 { // 2, 5, 8
+					divisor = 0
+				}
+				id := int64(i)
+				s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "div",
+					Params: []byte(fmt.Sprintf("[6,%d]", divisor)), Id: Int64ID(id)})
+			}
+
+			requests := logs.count("request")
+			errs := strings.Count(logs.String(), "division by zero")
+			if requests != tt.wantRequests || errs != tt.wantErrors {
+				t.Fatalf("❌ %d requests, %d errors logged; want %d, %d\n%s",
+					requests, errs, tt.wantRequests, tt.wantErrors, logs.String())
+			}
+			t.Logf("✅ %d requests, %d errors logged", requests, errs)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package jsonrpc2
+
+// 这个文件实现响应的元数据 (metadata)：方法在结果之外告诉调用方的信息，
+// 如服务端耗时、剩余的限流额度等。
+//
+// 元数据在 HTTP 上作为 Rpc-Meta-* 响应头传递 (批量请求中的响应除外)，
+// 在流式传输层 (TCP、WebSocket 等) 上作为响应对象的扩展成员 "meta" 传递。
+// 客户端通过 WithCallInfo 取得每次调用的元数据。
+//
+// 请求也可以带元数据 (请求对象的扩展成员 "meta")：调用方由 WithRequestMeta 设置，
+// 方法由 RequestMetaFromContext 读取。其中 MetaTimeout 传递调用的剩余时间，
+// 方法作为客户端再调用其他服务时 (网关)，由 ForwardContext 把截止时间与关联 id 传下去。
+
+import (
+	"context"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetaHeaderPrefix prefixes the names of the HTTP headers carrying the
+// response metadata, e.g. "Rpc-Meta-Server-Timing".
+const MetaHeaderPrefix = "Rpc-Meta-"
+
+// responseMeta collects the metadata of a response while its method runs.
+type responseMeta struct {
+	mu   sync.Mutex
+	meta map[string]string
+}
+
+type responseMetaKey struct{}
+
+// withResponseMeta returns a copy of ctx collecting the metadata of a response.
+func withResponseMeta(ctx context.Context) (context.Context, *responseMeta) {
+	m := &responseMeta{}
+	return context.WithValue(ctx, responseMetaKey{}, m), m
+}
+
+// SetResponseMeta sets the metadata key: value of the response to the call
+// of ctx, e.g. SetResponseMeta(ctx, "RateLimit-Remaining", "42"), for the
+// client to get it by WithCallInfo, whether the call succeeds or fails.
+//
+// Keys are case-insensitive, like HTTP header names, and are canonicalized
+// (by textproto.CanonicalMIMEHeaderKey). It's false if ctx is not of a call
+// served by a Server, or key is not a valid header name.
+func SetResponseMeta(ctx context.Context, key, value string) bool {
+	m, ok := ctx.Value(responseMetaKey{}).(*responseMeta)
+	if !ok || !validMetaKey(key) {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.meta == nil {
+		m.meta = make(map[string]string)
+	}
+	m.meta[textproto.CanonicalMIMEHeaderKey(key)] = value
+	return true
+}
+
+// validMetaKey tells whether key is a valid HTTP header name.
+func validMetaKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if c >= 0x7f || c <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot returns a copy of the metadata collected, nil if none.
+func (m *responseMeta) snapshot() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.meta) == 0 {
+		return nil
+	}
+	meta := make(map[string]string, len(m.meta))
+	for k, v := range m.meta {
+		meta[k] = v
+	}
+	return meta
+}
+
+// writeMetaHeaders writes meta as the MetaHeaderPrefix headers of h.
+func writeMetaHeaders(h http.Header, meta map[string]string) {
+	for k, v := range meta {
+		h.Set(MetaHeaderPrefix+k, v)
+	}
+}
+
+// readMetaHeaders reads the metadata from the MetaHeaderPrefix headers of h, nil if none.
+func readMetaHeaders(h http.Header) map[string]string {
+	var meta map[string]string
+	for name, values := range h {
+		key := strings.TrimPrefix(name, MetaHeaderPrefix)
+		if key == name || key == "" || len(values) == 0 {
+			continue
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[key] = values[0]
+	}
+	return meta
+}
+
+// CallInfo is what the server tells about a call besides its result,
+// see WithCallInfo.
+type CallInfo struct {
+	// Meta is the metadata of the response, set by the method with
+	// SetResponseMeta. nil if none.
+	Meta map[string]string
+}
+
+type callInfoKey struct{}
+
+// WithCallInfo returns a copy of ctx in which the call made with it
+// (by Client.CallContext) fills info once its response arrives:
+//
+//	var info jsonrpc2.CallInfo
+//	err := c.CallContext(jsonrpc2.WithCallInfo(ctx, &info), "add", []int{1, 2}, &sum)
+//	remaining := info.Meta["Ratelimit-Remaining"]
+func WithCallInfo(ctx context.Context, info *CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// fillCallInfo fills the CallInfo of ctx, if any, from resp.
+func fillCallInfo(ctx context.Context, resp *Response) {
+	if info, ok := ctx.Value(callInfoKey{}).(*CallInfo); ok && info != nil {
+		info.Meta = resp.Meta
+	}
+}
+
+// MetaCorrelationID is the request metadata correlating the calls of a
+// chain, e.g. of a gateway and of the services it calls, carried on by
+// ForwardContext.
+const MetaCorrelationID = "Correlation-Id"
+
+// MetaTimeout is the request metadata telling the time left until the
+// deadline of the call, as for time.ParseDuration, e.g. "1.5s". Clients
+// send it for the calls whose ctx has a deadline; servers bound the ctx
+// of the method by it.
+const MetaTimeout = "Timeout"
+
+type outgoingMetaKey struct{}
+
+// WithRequestMeta returns a copy of ctx in which the calls made with it
+// (by Client.CallContext, CallBatchContext and NotifyContext) carry the
+// request metadata key: value, see RequestMetaFromContext.
+//
+// Keys are canonicalized as for SetResponseMeta. It panics if key is not
+// a valid header name.
+func WithRequestMeta(ctx context.Context, key, value string) context.Context {
+	if !validMetaKey(key) {
+		panic("jsonrpc2: bad request meta key " + key)
+	}
+	parent, _ := ctx.Value(outgoingMetaKey{}).(map[string]string)
+	meta := make(map[string]string, len(parent)+1)
+	for k, v := range parent {
+		meta[k] = v
+	}
+	meta[textproto.CanonicalMIMEHeaderKey(key)] = value
+	return context.WithValue(ctx, outgoingMetaKey{}, meta)
+}
+
+// outgoingMeta is the metadata of the requests of the calls made with
+// ctx: those of WithRequestMeta, and MetaTimeout if ctx has a deadline.
+// nil if none.
+func outgoingMeta(ctx context.Context) map[string]string {
+	meta, _ := ctx.Value(outgoingMetaKey{}).(map[string]string)
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return meta
+	}
+	withTimeout := make(map[string]string, len(meta)+1)
+	for k, v := range meta {
+		withTimeout[k] = v
+	}
+	withTimeout[MetaTimeout] = time.Until(deadline).String()
+	return withTimeout
+}
+
+type incomingMetaKey struct{}
+
+// RequestMetaFromContext returns the metadata of the request served with
+// ctx, set by its caller by WithRequestMeta. nil if none.
+func RequestMetaFromContext(ctx context.Context) map[string]string {
+	meta, _ := ctx.Value(incomingMetaKey{}).(map[string]string)
+	return meta
+}
+
+// ForwardContext returns a copy of ctx, that of a method, for the calls
+// the method makes as a client (e.g. a gateway) to carry on the chain:
+// they are bound by the deadline of the request (as they are by ctx
+// anyway), and carry on its MetaCorrelationID and the metadata of keys.
+//
+//	func (g *Gateway) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+//		var resp GetResponse
+//		err := g.backend.CallContext(jsonrpc2.ForwardContext(ctx), "Store.Get", req, &resp)
+//		return &resp, err
+//	}
+func ForwardContext(ctx context.Context, keys ...string) context.Context {
+	incoming := RequestMetaFromContext(ctx)
+	for _, key := range append([]string{MetaCorrelationID}, keys...) {
+		if value, ok := incoming[textproto.CanonicalMIMEHeaderKey(key)]; ok {
+			ctx = WithRequestMeta(ctx, key, value)
+		}
+	}
+	return ctx
+}
+
+// withRequestMeta returns a copy of ctx serving req: with its metadata,
+// bounded by its MetaTimeout, if any. cancel must be called once served.
+func withRequestMeta(ctx context.Context, req *Request) (_ context.Context, cancel context.CancelFunc) {
+	if len(req.Meta) == 0 {
+		return ctx, func() {}
+	}
+	ctx = context.WithValue(ctx, incomingMetaKey{}, req.Meta)
+	if d, ok := req.timeout(); ok {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
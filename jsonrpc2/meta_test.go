@@ -0,0 +1,157 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ResponseMeta(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("add", func(ctx context.Context, arg []int) (int, error) {
+		SetResponseMeta(ctx, "ratelimit-remaining", "42")
+		SetResponseMeta(ctx, "Server-Timing", "db;dur=53")
+		return arg[0] + arg[1], nil
+	})
+	s.MustRegister("fail", func(ctx context.Context, arg int) (int, error) {
+		SetResponseMeta(ctx, "Retry-In", "1s")
+		return 0, errors.New("boom")
+	})
+	s.MustRegister("none", func(arg int) (int, error) { return arg, nil })
+
+	st := NewHttpServerTransport("")
+	st.AllowGob = true
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+	tcp := NewTcpClientTransport(l.Addr().String())
+	defer tcp.Close()
+
+	transports := map[string]ClientTransport{
+		"http":       NewHttpClientTransport(ts.URL),
+		"gob":        NewGobHttpClientTransport(ts.URL),
+		"tcp":        tcp,
+		"in process": &serverTransport{server: s},
+	}
+	tests := []struct {
+		method string
+		arg    any
+		want   map[string]string
+	}{
+		{"add", []int{1, 2}, map[string]string{"Ratelimit-Remaining": "42", "Server-Timing": "db;dur=53"}},
+		{"fail", 1, map[string]string{"Retry-In": "1s"}},
+		{"none", 1, nil},
+	}
+	for name, transport := range transports {
+		c := NewClient(transport)
+		for _, tt := range tests {
+			info := CallInfo{Meta: map[string]string{"stale": "x"}}
+			_ = c.CallContext(WithCallInfo(context.Background(), &info), tt.method, tt.arg, nil)
+			if !reflect.DeepEqual(info.Meta, tt.want) {
+				t.Errorf("❌ %s %s: meta = %v, want %v", name, tt.method, info.Meta, tt.want)
+			}
+		}
+	}
+
+	if SetResponseMeta(context.Background(), "Foo", "bar") {
+		t.Errorf("❌ SetResponseMeta out of a call should be false")
+	}
+	ctx, _ := withResponseMeta(context.Background())
+	if SetResponseMeta(ctx, "Bad Key", "bar") {
+		t.Errorf("❌ SetResponseMeta of an invalid key should be false")
+	}
+}
+
+func Test_ForwardContext(t *testing.T) {
+	// backend <-gob- gateway <-http- client
+	type seen struct {
+		meta     map[string]string
+		deadline time.Duration // left
+	}
+	seenBy := make(chan seen, 1)
+	backend := NewServer()
+	backend.MustRegister("get", func(ctx context.Context, arg int) (int, error) {
+		var left time.Duration
+		if deadline, ok := ctx.Deadline(); ok {
+			left = time.Until(deadline)
+		}
+		seenBy <- seen{RequestMetaFromContext(ctx), left}
+		return arg, nil
+	})
+	bst := NewHttpServerTransport("")
+	bst.AllowGob = true
+	bst.Use(backend)
+	bts := httptest.NewServer(bst)
+	defer bts.Close()
+	toBackend := NewClient(NewGobHttpClientTransport(bts.URL))
+
+	gateway := NewServer()
+	gateway.MustRegister("get", func(ctx context.Context, arg int) (int, error) {
+		var ret int
+		err := toBackend.CallContext(ForwardContext(ctx, "tenant"), "get", arg, &ret)
+		return ret, err
+	})
+	gst := NewHttpServerTransport("")
+	gst.Use(gateway)
+	gts := httptest.NewServer(gst)
+	defer gts.Close()
+	c := NewClient(NewHttpClientTransport(gts.URL))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = WithRequestMeta(ctx, "correlation-id", "abc")
+	ctx = WithRequestMeta(ctx, "Tenant", "acme")
+	ctx = WithRequestMeta(ctx, "Authorization", "secret")
+	var ret int
+	if err := c.CallContext(ctx, "get", 7, &ret); err != nil || ret != 7 {
+		t.Fatalf("❌ call = %v, %v; want 7", ret, err)
+	}
+
+	got := <-seenBy
+	if got.deadline <= 0 || got.deadline > time.Minute {
+		t.Errorf("❌ backend deadline in %v, want the minute of the client", got.deadline)
+	}
+	want := map[string]string{MetaCorrelationID: "abc", "Tenant": "acme"}
+	delete(got.meta, MetaTimeout)
+	if !reflect.DeepEqual(got.meta, want) {
+		t.Errorf("❌ backend meta = %v, want %v", got.meta, want)
+	}
+
+	// no deadline, no meta: nothing to forward
+	if err := c.Call("get", 8, &ret); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-seenBy; got.meta != nil || got.deadline != 0 {
+		t.Errorf("❌ backend got %v, want no meta nor deadline", got)
+	}
+}
+
+func Test_Request_timeout(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("wait", func(ctx context.Context, arg int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	id := int64(1)
+
+	req := &Request{JsonRpc: JsonRpc2, Method: "wait", Params: []byte(`1`), Id: Int64ID(id), Meta: map[string]string{MetaTimeout: "10ms"}}
+	if resp := s.ServeRPC(context.Background(), req); resp.Error == nil {
+		t.Errorf("❌ want the call bounded by its %s", MetaTimeout)
+	}
+
+	req.Meta[MetaTimeout] = "soon"
+	if err := req.validate(); err == nil {
+		t.Errorf("❌ want a bad %s invalid", MetaTimeout)
+	}
+}
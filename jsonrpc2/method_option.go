@@ -0,0 +1,151 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MethodOption configures a method when it's registered, e.g.
+//
+//	s.Register("add", add, jsonrpc2.Deprecated("sum"))
+type MethodOption func(*methodInfo)
+
+// methodInfo is what the MethodOptions tell about a method.
+type methodInfo struct {
+	deprecated  bool
+	replacement string // the method to use instead, if deprecated
+
+	marshalResult func(any) ([]byte, error) // nil: the default, see MarshalResultWith
+
+	unlimited bool          // not bounded by the concurrency limits, see Unlimited
+	timeout   time.Duration // of the calls, 0: none, see WithTimeout
+
+	doc       string            // see WithDoc
+	paramDocs map[string]string // by field of the params, "" for the params as a whole, see WithParamDoc
+}
+
+// Deprecated marks a method deprecated, with the method to use instead
+// (may be empty).
+//
+// Calls to it keep working, but are counted in Metrics ("deprecated.calls"
+// and "deprecated.calls.<method>") and warned: in the Data of error
+// responses, and in a Warning header of HTTP responses. rpc.discover
+// reports it deprecated as well.
+func Deprecated(replacement string) MethodOption {
+	return func(info *methodInfo) {
+		info.deprecated = true
+		info.replacement = replacement
+	}
+}
+
+// MarshalResultWith makes a method marshal its results by marshal, instead of
+// their ResultMarshaler or json.Marshal, e.g. to control the wire format of
+// results of types the service doesn't own.
+func MarshalResultWith(marshal func(v any) ([]byte, error)) MethodOption {
+	return func(info *methodInfo) {
+		info.marshalResult = marshal
+	}
+}
+
+// Unlimited exempts a method from the concurrency limits of the server
+// (WithMaxConcurrency and WithTenantMaxConcurrency): its calls neither
+// take a slot nor wait in the queue. It's for the cheap methods that
+// others wait for, e.g. the Unlock of a lock: were all the slots taken
+// by calls of Lock waiting for it, it would never run.
+func Unlimited() MethodOption {
+	return func(info *methodInfo) {
+		info.unlimited = true
+	}
+}
+
+// WithDoc documents a method, for MethodDiscover and MethodDescribe to
+// tell: the first line of doc is the summary of the method, the whole of
+// it its description.
+func WithDoc(doc string) MethodOption {
+	return func(info *methodInfo) {
+		info.doc = doc
+	}
+}
+
+// WithParamDoc documents a field of the params of a method (by its name in
+// JSON), or the params as a whole if field is "", for MethodDiscover and
+// MethodDescribe to tell. Register fails if the params have no such field.
+func WithParamDoc(field, doc string) MethodOption {
+	return func(info *methodInfo) {
+		if info.paramDocs == nil {
+			info.paramDocs = make(map[string]string)
+		}
+		info.paramDocs[field] = doc
+	}
+}
+
+// WithTimeout bounds the calls of a method by d: their ctx is done once
+// d passes, and if the method is not done by then, the call is answered
+// ErrRequestTimeout at once, even if the method ignores ctx (it keeps
+// running meanwhile, holding its slot of WithMaxConcurrency until it
+// returns). The streaming methods (StreamFunc, ResultWriter) are waited
+// for instead, not to write past their response: once their ctx is done,
+// they should return. d <= 0 means no timeout, the default.
+func WithTimeout(d time.Duration) MethodOption {
+	return func(info *methodInfo) {
+		info.timeout = d
+	}
+}
+
+type resultMarshalKey struct{}
+
+// withResultMarshal returns a copy of ctx marshaling results by marshal.
+func withResultMarshal(ctx context.Context, marshal func(any) ([]byte, error)) context.Context {
+	return context.WithValue(ctx, resultMarshalKey{}, marshal)
+}
+
+// resultMarshalFromContext returns the marshal function attached by withResultMarshal, or nil.
+func resultMarshalFromContext(ctx context.Context) func(any) ([]byte, error) {
+	marshal, _ := ctx.Value(resultMarshalKey{}).(func(any) ([]byte, error))
+	return marshal
+}
+
+// newMethodInfo applies opts.
+func newMethodInfo(opts []MethodOption) *methodInfo {
+	info := &methodInfo{}
+	for _, opt := range opts {
+		opt(info)
+	}
+	return info
+}
+
+// deprecation returns the warning for calling the deprecated method name.
+func (info *methodInfo) deprecation(name string) string {
+	if info.replacement == "" {
+		return fmt.Sprintf("method %s is deprecated", name)
+	}
+	return fmt.Sprintf("method %s is deprecated, use %s instead", name, info.replacement)
+}
+
+// warnDeprecated records a call to the deprecated method name into resp.
+func (s *server) warnDeprecated(name string, info *methodInfo, resp *Response) {
+	s.metrics.Add("deprecated.calls", 1)
+	s.metrics.Add("deprecated.calls."+name, 1)
+
+	warning := info.deprecation(name)
+	resp.warnings = append(resp.warnings, warning)
+	if resp.Error != nil {
+		resp.Error.withDataField("deprecated", warning)
+	}
+}
+
+// withDataField adds key: value to the Data object of e, if Data is an
+// object or empty. The modifying is done in-place, like WithReason.
+func (e *Error) withDataField(key string, value any) *Error {
+	data := map[string]any{}
+	if len(e.Data) > 0 {
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return e // not an object: leave it alone
+		}
+	}
+	data[key] = value
+	e.Data, _ = json.Marshal(data)
+	return e
+}
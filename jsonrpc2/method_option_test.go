@@ -0,0 +1,274 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Deprecated(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil }, Deprecated("sum"))
+
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{"ok", `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}`,
+			`{"jsonrpc":"2.0","result":3,"id":1}`},
+		{"error", `{"jsonrpc": "2.0", "method": "add", "params": "x", "id": 2}`,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":{"deprecated":"method add is deprecated, use sum instead","reason":"json: cannot unmarshal string into Go value of type []int"}},"id":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(ts.URL, "application/json", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+
+			warning := resp.Header.Get("Warning")
+			wantWarning := `299 - "method add is deprecated, use sum instead"`
+			if got := string(bytes.TrimSpace(b)); got != tt.wantBody || warning != wantWarning {
+				t.Errorf("❌ got %s, Warning: %s\nwant %s, Warning: %s", got, warning, tt.wantBody, wantWarning)
+			} else {
+				t.Logf("✅ %s, Warning: %s", got, warning)
+			}
+		})
+	}
+
+	if got := s.Stats()["deprecated.calls.add"]; got != 2 {
+		t.Errorf("❌ deprecated.calls.add = %d, want 2", got)
+	}
+
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: MethodDiscover, Id: Int64ID(1)})
+	var doc DiscoverResult
+	_ = json.Unmarshal(resp.Result, &doc)
+	for _, m := range doc.Methods {
+		if m.Deprecated != (m.Name == "add") {
+			t.Errorf("❌ %s: deprecated = %v", m.Name, m.Deprecated)
+		}
+	}
+}
+
+// celsius is a result with its own wire format.
+type celsius float64
+
+func (c celsius) MarshalResult() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"value":%g,"unit":"C"}`, float64(c))), nil
+}
+
+type badResult struct{}
+
+func (badResult) MarshalResult() ([]byte, error) {
+	return []byte(`{`), nil
+}
+
+func Test_MarshalResult(t *testing.T) {
+	m := NewMemoryMetrics()
+	s := NewServer().WithMetrics(m)
+	s.MustRegister("temp", func(arg float64) (celsius, error) { return celsius(arg), nil })
+	s.MustRegister("typed", Typed(func(arg int) (celsius, error) { return celsius(arg), nil }))
+	s.MustRegister("bad", func(arg int) (badResult, error) { return badResult{}, nil })
+	s.MustRegister("upper", func(arg string) (string, error) { return arg, nil },
+		MarshalResultWith(func(v any) ([]byte, error) {
+			return json.Marshal(strings.ToUpper(v.(string)))
+		}))
+	s.MustRegister("override", func(arg int) (celsius, error) { return celsius(arg), nil },
+		MarshalResultWith(json.Marshal))
+
+	tests := []struct {
+		method string
+		params string
+		want   string
+	}{
+		{"temp", `21.5`, `{"jsonrpc":"2.0","result":{"value":21.5,"unit":"C"},"id":1}`},
+		{"typed", `3`, `{"jsonrpc":"2.0","result":{"value":3,"unit":"C"},"id":1}`},
+		{"bad", `1`, `{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error","data":{"method":"bad","reason":"invalid JSON result","type":"jsonrpc2.badResult"}},"id":1}`},
+		{"upper", `"abc"`, `{"jsonrpc":"2.0","result":"ABC","id":1}`},
+		{"override", `3`, `{"jsonrpc":"2.0","result":3,"id":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: tt.method, Params: json.RawMessage(tt.params), Id: Int64ID(1)})
+			got, _ := json.Marshal(resp)
+			if string(got) != tt.want {
+				t.Errorf("❌ got %s\nwant %s", got, tt.want)
+			} else {
+				t.Logf("✅ %s", got)
+			}
+		})
+	}
+	if got := m.Snapshot()["results.marshal_failed"]; got != 1 {
+		t.Errorf("❌ results.marshal_failed = %d, want 1", got)
+	}
+}
+
+func Test_WithDoc(t *testing.T) {
+	type Range struct {
+		From int `json:"from"`
+		To   int `json:"to"`
+	}
+	s := NewServer()
+	s.MustRegister("sum", func(r Range) (int, error) { return (r.From + r.To) * (r.To - r.From + 1) / 2, nil },
+		WithDoc("Sum sums the integers of a range.\nBoth ends are included."),
+		WithParamDoc("", "the range to sum"),
+		WithParamDoc("from", "the first integer"),
+	)
+
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: MethodDescribe, Params: []byte(`"sum"`), Id: Int64ID(0)})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	var desc MethodDescriptor
+	if err := json.Unmarshal(resp.Result, &desc); err != nil {
+		t.Fatal(err)
+	}
+	if desc.Summary != "Sum sums the integers of a range." || desc.Description != "Sum sums the integers of a range.\nBoth ends are included." {
+		t.Errorf("❌ summary %q, description %q", desc.Summary, desc.Description)
+	}
+	params := desc.Params[0]
+	if params.Description != "the range to sum" || params.Schema.Properties["from"].Description != "the first integer" ||
+		params.Schema.Properties["to"].Description != "" {
+		t.Errorf("❌ params documented as %s", mustJSON(params))
+	}
+
+	err := s.Register("bad", func(r Range) (int, error) { return 0, nil }, WithParamDoc("length", "not a field"))
+	if err == nil {
+		t.Error("❌ registered the doc of a param field not there")
+	}
+}
+
+func Test_WithTimeout(t *testing.T) {
+	block := make(chan struct{})
+	s := NewServer().WithMaxConcurrency(1).WithMaxQueue(0)
+	s.MustRegister("hang", func(arg int) (int, error) {
+		<-block // ignoring any ctx
+		return arg, nil
+	}, WithTimeout(20*time.Millisecond))
+	s.MustRegister("wait", func(ctx context.Context, arg int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, WithTimeout(20*time.Millisecond))
+	s.MustRegister("quick", func(arg int) (int, error) { return arg, nil }, WithTimeout(time.Second))
+
+	call := func(method string) *Response {
+		id := int64(1)
+		return s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: method, Params: []byte(`1`), Id: Int64ID(id)})
+	}
+	for _, method := range []string{"wait", "hang"} {
+		for s.Stats()["concurrency.inflight"] != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		start := time.Now()
+		resp := call(method)
+		if resp.Error == nil || resp.Error.Code != ErrRequestTimeout().Code {
+			t.Errorf("❌ %s: %+v, want ErrRequestTimeout", method, resp.Error)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("❌ %s answered in %v", method, d)
+		}
+	}
+
+	// hang is still running: its slot is still held
+	if resp := call("quick"); resp.Error == nil || resp.Error.Code != ErrServerBusy().Code {
+		t.Errorf("❌ quick while hang runs: %+v, want ErrServerBusy", resp.Error)
+	}
+	close(block)
+	for s.Stats()["concurrency.inflight"] != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if resp := call("quick"); resp.Error != nil || string(resp.Result) != "1" {
+		t.Errorf("❌ quick: %+v", resp)
+	}
+}
+
+func Test_WithTimeout_Clock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	s := NewServer().WithClock(clock)
+	s.MustRegister("wait", func(ctx context.Context, arg int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, WithTimeout(time.Hour))
+
+	answered := make(chan *Response)
+	go func() {
+		answered <- s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "wait", Params: []byte(`1`), Id: Int64ID(1)})
+	}()
+
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case resp := <-answered:
+		t.Fatalf("❌ answered before the hour by the clock: %+v", resp)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Hour)
+	select {
+	case resp := <-answered:
+		if resp.Error == nil || resp.Error.Code != ErrRequestTimeout().Code {
+			t.Errorf("❌ %+v, want ErrRequestTimeout", resp.Error)
+		} else {
+			t.Logf("✅ timed out by the clock: %s", resp.Error.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("❌ not timed out by the clock")
+	}
+}
+
+func Test_WithTimeout_streaming(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("slowList", func(arg int, w ResultWriter) error {
+		_, _ = io.WriteString(w, "[1")
+		_ = w.Flush()
+		time.Sleep(100 * time.Millisecond) // past the timeout, ignoring any ctx
+		_, err := io.WriteString(w, ",2]")
+		return err
+	}, WithTimeout(20*time.Millisecond))
+	s.MustRegister("slowSend", Streaming(func(arg int, send Sender[int]) error {
+		time.Sleep(100 * time.Millisecond)
+		return send.Send(arg)
+	}), WithTimeout(20*time.Millisecond))
+
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	// waited for, not to write past the response
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{"slowList", `{"jsonrpc":"2.0","id":1,"result":[1,2]}`},
+		{"slowSend", `{"jsonrpc":"2.0","error":{"code":-32002,"message":"Request timeout","data":{"reason":"not done in 20ms"}},"id":1}`},
+	}
+	for _, tt := range tests {
+		body := `{"jsonrpc":"2.0","method":"` + tt.method + `","params":1,"id":1}`
+		resp, err := http.Post(ts.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || strings.TrimSpace(string(got)) != tt.want {
+			t.Errorf("❌ %s: %s, %v; want %s", tt.method, got, err, tt.want)
+		}
+	}
+}
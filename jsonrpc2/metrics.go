@@ -0,0 +1,102 @@
+package jsonrpc2
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics receives measurements from a Server.
+// Implementations must be safe for concurrent use.
+//
+// Names are dot-separated, e.g. "queue.depth" or "requests.shed".
+type Metrics interface {
+	Add(name string, delta int64)         // Add increases the counter name by delta.
+	Set(name string, value int64)         // Set sets the gauge name to value.
+	Observe(name string, d time.Duration) // Observe records a duration sample for name.
+}
+
+// MemoryMetrics is a Metrics that keeps everything in memory.
+// It's the default Metrics of a Server, and what DebugHandler shows.
+type MemoryMetrics struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	gauges   map[string]int64
+	timings  map[string]*timing
+}
+
+// timing is a tiny summary of the duration samples of a name.
+type timing struct {
+	count int64
+	total time.Duration
+	max   time.Duration
+}
+
+// NewMemoryMetrics creates an empty MemoryMetrics.
+func NewMemoryMetrics() *MemoryMetrics {
+	return &MemoryMetrics{
+		counters: make(map[string]int64),
+		gauges:   make(map[string]int64),
+		timings:  make(map[string]*timing),
+	}
+}
+
+func (m *MemoryMetrics) Add(name string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name] += delta
+}
+
+func (m *MemoryMetrics) Set(name string, value int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[name] = value
+}
+
+func (m *MemoryMetrics) Observe(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.timings[name]
+	if !ok {
+		t = new(timing)
+		m.timings[name] = t
+	}
+	t.count++
+	t.total += d
+	if d > t.max {
+		t.max = d
+	}
+}
+
+// Snapshot returns a copy of all values recorded so far.
+// Durations are flattened into name.count, name.total_us and name.max_us.
+func (m *MemoryMetrics) Snapshot() map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snap := make(map[string]int64, len(m.counters)+len(m.gauges)+3*len(m.timings))
+	for k, v := range m.counters {
+		snap[k] = v
+	}
+	for k, v := range m.gauges {
+		snap[k] = v
+	}
+	for k, t := range m.timings {
+		snap[k+".count"] = t.count
+		snap[k+".total_us"] = t.total.Microseconds()
+		snap[k+".max_us"] = t.max.Microseconds()
+	}
+	return snap
+}
+
+// snapshotter is implemented by Metrics that can report what they recorded,
+// e.g. MemoryMetrics.
+type snapshotter interface {
+	Snapshot() map[string]int64
+}
+
+// nopMetrics discards everything.
+type nopMetrics struct{}
+
+func (nopMetrics) Add(string, int64)             {}
+func (nopMetrics) Set(string, int64)             {}
+func (nopMetrics) Observe(string, time.Duration) {}
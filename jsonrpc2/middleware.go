@@ -0,0 +1,20 @@
+package jsonrpc2
+
+import "context"
+
+// HandlerFunc serves a request and makes its response, like Server.ServeRPC,
+// but for notifications too. See Server.Use.
+type HandlerFunc func(ctx context.Context, req *Request) *Response
+
+// Use 原址添加一层中间件，并返回 Server 以供链式
+func (s *server) Use(mw func(next HandlerFunc) HandlerFunc) Server {
+	s.middlewares = append(s.middlewares, mw)
+
+	// the first Use is the outermost
+	h := HandlerFunc(s.serveRPC)
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		h = s.middlewares[i](h)
+	}
+	s.handler = h
+	return s
+}
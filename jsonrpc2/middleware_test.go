@@ -0,0 +1,77 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_server_Use(t *testing.T) {
+	var mu sync.Mutex
+	var trace []string
+	record := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		trace = append(trace, s)
+	}
+
+	named := func(name string) func(next HandlerFunc) HandlerFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, req *Request) *Response {
+				record(name + " " + req.Method)
+				return next(ctx, req)
+			}
+		}
+	}
+	auth := func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) *Response {
+			if req.Method == "secret" {
+				return errorResponse(req.Id, NewError(401, "Unauthorized"))
+			}
+			return next(ctx, req)
+		}
+	}
+
+	s := NewServer().
+		Use(named("outer")).
+		Use(named("inner")).
+		Use(auth)
+	s.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil })
+	s.MustRegister("secret", func(arg int) (int, error) { return 42, nil })
+
+	tests := []struct {
+		req  *Request
+		want string
+	}{
+		{&Request{JsonRpc: JsonRpc2, Method: "add", Params: json.RawMessage(`[1,2]`), Id: Int64ID(1)},
+			`{"jsonrpc":"2.0","result":3,"id":1}`},
+		{&Request{JsonRpc: JsonRpc2, Method: "secret", Params: json.RawMessage(`1`), Id: Int64ID(2)},
+			`{"jsonrpc":"2.0","error":{"code":401,"message":"Unauthorized"},"id":2}`},
+		{&Request{JsonRpc: JsonRpc2, Method: "add", Params: json.RawMessage(`[1,2]`)}, `null`},
+	}
+	for _, tt := range tests {
+		got, _ := json.Marshal(s.ServeRPC(context.Background(), tt.req))
+		if string(got) != tt.want {
+			t.Errorf("❌ got %s, want %s", got, tt.want)
+		}
+	}
+
+	// batch entries go through the middlewares as well
+	s.ServeBatch(context.Background(), []json.RawMessage{
+		json.RawMessage(`{"jsonrpc":"2.0","method":"add","params":[3,4],"id":3}`),
+	})
+
+	want := []string{
+		"outer add", "inner add",
+		"outer secret", "inner secret",
+		"outer add", "inner add", // the notification
+		"outer add", "inner add",
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("❌ trace = %v\nwant %v", trace, want)
+	} else {
+		t.Logf("✅ trace = %v", trace)
+	}
+}
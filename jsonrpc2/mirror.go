@@ -0,0 +1,194 @@
+package jsonrpc2
+
+// 这个文件实现影子流量 (shadow traffic)：由 Server.Use 的中间件 (Mirror.Middleware)
+// 把一部分请求异步地复制给另一个服务端 (如重写后的新实现)，其响应被丢弃，不影响调用方；
+// 可选地记录它与本服务端的响应的差异，以便安全地验证新实现。
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMirrorTimeout bounds the requests mirrored, if Mirror.Timeout is not set.
+const DefaultMirrorTimeout = 5 * time.Second
+
+// DefaultMirrorMaxInFlight bounds the requests mirrored at once, if
+// Mirror.MaxInFlight is not set.
+const DefaultMirrorMaxInFlight = 100
+
+// Mirror sends copies of some of the requests served to a shadow server,
+// e.g. a rewritten implementation of the service, to validate it with
+// the real traffic:
+//
+//	m := &jsonrpc2.Mirror{Shadow: &jsonrpc2.HttpClientTransport{Addr: "http://v2:6666"}, Percent: 10, LogDiffs: true}
+//	s.Use(m.Middleware())
+//
+// The copies are sent asynchronously, once the request is served, and
+// the responses of the shadow are discarded: the callers get those of the
+// server only, as slow as ever, whatever the shadow does. Mind that the
+// shadow executes the requests mirrored, e.g. against a copy of the data.
+//
+// The built-in methods (see ReservedPrefix) are not mirrored.
+type Mirror struct {
+	Shadow  ClientTransport
+	Percent int // of the requests mirrored, 100 for all of them
+
+	// LogDiffs logs the calls whose responses of the shadow differ from
+	// those of the server (results as JSON values, or error codes), at
+	// LevelWarn, and counts them as "mirror.diffs".
+	LogDiffs bool
+	Logger   Logger // nil means a StdLogger
+
+	Timeout     time.Duration // of the requests mirrored, 0 means DefaultMirrorTimeout
+	MaxInFlight int           // requests mirrored at once, beyond are dropped; 0 means DefaultMirrorMaxInFlight
+
+	once     sync.Once
+	inflight chan struct{} // semaphore of MaxInFlight
+	nextId   atomic.Int64  // of the requests mirrored
+
+	sent, dropped, failed, diffs atomic.Int64
+}
+
+// Middleware returns the middleware mirroring the requests, for Server.Use.
+func (m *Mirror) Middleware() func(next HandlerFunc) HandlerFunc {
+	m.init()
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) *Response {
+			resp := next(ctx, req)
+			if !isReserved(req.Method) && m.sample() {
+				m.mirror(req, resp)
+			}
+			return resp
+		}
+	}
+}
+
+func (m *Mirror) init() {
+	m.once.Do(func() {
+		n := m.MaxInFlight
+		if n <= 0 {
+			n = DefaultMirrorMaxInFlight
+		}
+		m.inflight = make(chan struct{}, n)
+	})
+}
+
+func (m *Mirror) sample() bool {
+	return m.Percent >= 100 || (m.Percent > 0 && rand.Intn(100) < m.Percent)
+}
+
+// mirror req, answered resp by the server, to the shadow in the background,
+// unless MaxInFlight of them are being sent already.
+func (m *Mirror) mirror(req *Request, resp *Response) {
+	select {
+	case m.inflight <- struct{}{}:
+	default:
+		m.dropped.Add(1)
+		return
+	}
+
+	shadowReq := *req
+	if !req.IsNotification() {
+		// the callers of the server may send the same ids
+		shadowReq.Id = Int64ID(m.nextId.Add(1))
+	}
+	go func() {
+		defer func() { <-m.inflight }()
+		m.sent.Add(1)
+
+		timeout := m.Timeout
+		if timeout <= 0 {
+			timeout = DefaultMirrorTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		if req.IsNotification() {
+			var err error
+			if nt, ok := m.Shadow.(NotifyClientTransport); ok {
+				err = nt.Notify(ctx, &shadowReq)
+			} else {
+				_, err = m.Shadow.SendAndReceive(ctx, &shadowReq)
+			}
+			if err != nil {
+				m.fail(req, err)
+			}
+			return
+		}
+
+		shadowResp, err := m.Shadow.SendAndReceive(ctx, &shadowReq)
+		if err != nil {
+			m.fail(req, err)
+			return
+		}
+		if m.LogDiffs && resp != nil && !sameResponse(resp, shadowResp) {
+			m.diffs.Add(1)
+			loggerOr(m.Logger).Log(LevelWarn, "mirror: shadow response differs",
+				Field{"method", req.Method}, Field{"id", formatId(req.Id)},
+				Field{"response", responseSummary(resp)}, Field{"shadow", responseSummary(shadowResp)})
+		}
+	}()
+}
+
+func (m *Mirror) fail(req *Request, err error) {
+	m.failed.Add(1)
+	loggerOr(m.Logger).Log(LevelDebug, "mirror: failed to send to the shadow",
+		Field{"method", req.Method}, Field{"id", formatId(req.Id)}, Field{"error", err})
+}
+
+// Wait for the requests being mirrored to finish, e.g. before exiting.
+func (m *Mirror) Wait() {
+	m.init()
+	for i := 0; i < cap(m.inflight); i++ {
+		m.inflight <- struct{}{}
+	}
+	for i := 0; i < cap(m.inflight); i++ {
+		<-m.inflight
+	}
+}
+
+// Stats reports the counters of the requests mirrored:
+//   - "mirror.sent": sent to the shadow;
+//   - "mirror.dropped": not sent, for MaxInFlight of them were being sent;
+//   - "mirror.failed": not answered by the shadow, e.g. it's down;
+//   - "mirror.diffs": answered differently by the shadow, if LogDiffs.
+func (m *Mirror) Stats() map[string]int64 {
+	return map[string]int64{
+		"mirror.sent":    m.sent.Load(),
+		"mirror.dropped": m.dropped.Load(),
+		"mirror.failed":  m.failed.Load(),
+		"mirror.diffs":   m.diffs.Load(),
+	}
+}
+
+// sameResponse tells whether a and b are alike: the same results as JSON
+// values (regardless of the spacing and the order of the keys), or errors
+// of the same codes.
+func sameResponse(a, b *Response) bool {
+	if a.Error != nil || b.Error != nil {
+		return a.Error != nil && b.Error != nil && a.Error.Code == b.Error.Code
+	}
+	if bytes.Equal(a.Result, b.Result) {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal(a.Result, &va) != nil || json.Unmarshal(b.Result, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// responseSummary is the result of resp, or its error, for logs.
+func responseSummary(resp *Response) string {
+	if resp.Error != nil {
+		return resp.Error.Error()
+	}
+	return string(resp.Result)
+}
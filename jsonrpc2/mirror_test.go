@@ -0,0 +1,102 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func newMirrorTest(m *Mirror) (Server, *upstreamTransport) {
+	v2 := NewServer()
+	v2.MustRegister("double", func(arg int) (int, error) { return arg + arg, nil })
+	v2.MustRegister("square", func(arg int) (int, error) { return arg + arg, nil }) // buggy rewrite
+	shadow := &upstreamTransport{server: v2}
+	m.Shadow = shadow
+
+	s := NewServer()
+	s.MustRegister("double", func(arg int) (int, error) { return 2 * arg, nil })
+	s.MustRegister("square", func(arg int) (int, error) { return arg * arg, nil })
+	s.Use(m.Middleware())
+	return s, shadow
+}
+
+func Test_Mirror(t *testing.T) {
+	logs := &recordingLogger{}
+	m := &Mirror{Percent: 100, LogDiffs: true, Logger: logs}
+	s, shadow := newMirrorTest(m)
+	c := NewClient(&serverTransport{server: s})
+
+	var ret int
+	if err := c.Call("double", 3, &ret); err != nil || ret != 6 {
+		t.Errorf("❌ double = %d, %v; want 6", ret, err)
+	}
+	if err := c.Call("square", 3, &ret); err != nil || ret != 9 {
+		t.Errorf("❌ square = %d, %v; want 9, of the server", ret, err)
+	}
+	s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "double", Params: json.RawMessage(`1`)})
+	id := int64(1)
+	s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: MethodHealth, Params: []byte(`{}`), Id: Int64ID(id)})
+	m.Wait()
+
+	if got := shadow.methods(); len(got) != 3 {
+		t.Errorf("❌ mirrored %v, want double, square and the notification", got)
+	}
+	stats := m.Stats()
+	if stats["mirror.sent"] != 3 || stats["mirror.diffs"] != 1 || stats["mirror.failed"] != 0 {
+		t.Errorf("❌ stats %v, want 3 sent, 1 diff", stats)
+	}
+	if logs.count("mirror: shadow response differs") != 1 {
+		t.Error("❌ the diff of square is not logged")
+	}
+}
+
+func Test_Mirror_Percent(t *testing.T) {
+	m := &Mirror{Percent: 0}
+	s, shadow := newMirrorTest(m)
+	c := NewClient(&serverTransport{server: s})
+	for i := 0; i < 10; i++ {
+		if err := c.Call("double", i, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Wait()
+	if got := shadow.methods(); len(got) != 0 {
+		t.Errorf("❌ mirrored %v at 0%%", got)
+	}
+}
+
+// blockingTransport blocks the requests until release is closed.
+type blockingTransport struct {
+	release chan struct{}
+}
+
+func (t *blockingTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
+	select {
+	case <-t.release:
+		return &Response{JsonRpc: JsonRpc2, Id: req.Id, Result: json.RawMessage(`0`)}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func Test_Mirror_MaxInFlight(t *testing.T) {
+	shadow := &blockingTransport{release: make(chan struct{})}
+	m := &Mirror{Shadow: shadow, Percent: 100, MaxInFlight: 2}
+
+	s := NewServer()
+	s.MustRegister("double", func(arg int) (int, error) { return 2 * arg, nil })
+	s.Use(m.Middleware())
+	c := NewClient(&serverTransport{server: s})
+	for i := 0; i < 5; i++ {
+		var ret int
+		if err := c.Call("double", i, &ret); err != nil || ret != 2*i {
+			t.Fatalf("❌ double = %d, %v; want %d, the shadow blocking", ret, err, 2*i)
+		}
+	}
+	close(shadow.release)
+	m.Wait()
+
+	if stats := m.Stats(); stats["mirror.sent"] != 2 || stats["mirror.dropped"] != 3 {
+		t.Errorf("❌ stats %v, want 2 sent, 3 dropped", stats)
+	}
+}
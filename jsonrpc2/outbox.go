@@ -0,0 +1,271 @@
+package jsonrpc2
+
+// 这个文件实现客户端的 store-and-forward 模式 (Outbox)，用于边缘/IoT 设备：
+// 调用先持久化到本地，再在连接可用时按序投递，失败则退避重试，
+// 进程重启后继续投递。
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultOutboxRetryInterval is the first wait of an Outbox after a failed delivery.
+	DefaultOutboxRetryInterval = time.Second
+	// DefaultOutboxMaxRetryInterval bounds the waits of an Outbox, which double on each failure.
+	DefaultOutboxMaxRetryInterval = time.Minute
+)
+
+// outboxExt is the extension of the files of the entries in an Outbox.
+const outboxExt = ".json"
+
+// Outbox is a persistent queue of outbound calls (store-and-forward).
+//
+// Send stores a call in Dir and returns at once; Run delivers the stored
+// calls in order through Transport, retrying while the server is
+// unreachable, and across restarts of the process.
+//
+// Each call gets a random id when it's stored, and keeps it for all the
+// attempts. A server WithAtMostOnce executes it once even if an attempt
+// reached it but its response got lost; such a retry is answered by
+// ErrAtMostOnce, which counts as delivered.
+type Outbox struct {
+	Transport ClientTransport
+	Dir       string
+
+	RetryInterval    time.Duration // 0 means DefaultOutboxRetryInterval
+	MaxRetryInterval time.Duration // 0 means DefaultOutboxMaxRetryInterval
+	Jitter           Jitter        // randomizes the retry waits, nil means none
+	Clock            Clock         // nil means SystemClock
+
+	// OnDelivered, if not nil, is called with each delivered call and its
+	// response, which may be an error response.
+	OnDelivered func(req *Request, resp *Response)
+
+	Logger Logger // logs the broken entries dropped, nil means a StdLogger
+
+	mu      sync.Mutex // serializes deliveries
+	storeMu sync.Mutex // serializes stores, not to wait for a delivery; guards seq
+	seq     uint64     // sequence number of the last stored entry
+	wake    chan struct{}
+}
+
+// NewOutbox makes an Outbox storing calls in dir, created if not existing.
+// Calls stored there by an earlier Outbox are delivered as well.
+func NewOutbox(transport ClientTransport, dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	o := &Outbox{
+		Transport: transport,
+		Dir:       dir,
+		wake:      make(chan struct{}, 1),
+	}
+
+	names, err := o.entries()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		o.seq, _ = strconv.ParseUint(strings.TrimSuffix(names[len(names)-1], outboxExt), 10, 64)
+	}
+	return o, nil
+}
+
+// Send stores a call of method with arg, to be delivered by Run.
+// It returns the id of the request.
+func (o *Outbox) Send(method string, arg any) (int64, error) {
+	params, err := json.Marshal(arg)
+	if err != nil {
+		return 0, err
+	}
+	id, err := randomID()
+	if err != nil {
+		return 0, err
+	}
+	req := Request{
+		JsonRpc: JsonRpc2,
+		Method:  method,
+		Params:  params,
+		Id:      Int64ID(id),
+	}
+	if err := req.validate(); err != nil {
+		return 0, err
+	}
+	data, err := req.toJSON()
+	if err != nil {
+		return 0, err
+	}
+
+	// one by one, for the entries stored to show up in order to Flush
+	o.storeMu.Lock()
+	o.seq++
+	name := fmt.Sprintf("%020d%s", o.seq, outboxExt)
+	err = writeFileSync(filepath.Join(o.Dir, name), data)
+	o.storeMu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+// Pending returns how many stored calls are not delivered yet.
+func (o *Outbox) Pending() (int, error) {
+	names, err := o.entries()
+	return len(names), err
+}
+
+// Flush tries to deliver all the stored calls now, in order.
+// It stops at the first one the Transport fails to deliver, returning the error.
+func (o *Outbox) Flush(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	names, err := o.entries()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := o.deliver(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run delivers the stored calls, now and as they're sent, until ctx is done.
+// Failed deliveries are retried with exponential backoff.
+func (o *Outbox) Run(ctx context.Context) error {
+	interval := o.retryInterval()
+	for {
+		wait := interval
+		if err := o.Flush(ctx); err != nil {
+			interval *= 2
+			if interval > o.maxRetryInterval() {
+				interval = o.maxRetryInterval()
+			}
+		} else {
+			interval = o.retryInterval()
+			wait = -1 // until something is sent
+		}
+
+		var timer Timer
+		var timeout <-chan time.Time
+		if wait >= 0 {
+			timer = clockOrSystem(o.Clock).NewTimer(o.Jitter.apply(wait))
+			timeout = timer.C()
+		}
+		select {
+		case <-ctx.Done():
+		case <-o.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// deliver the stored call name. o.mu must be held.
+func (o *Outbox) deliver(ctx context.Context, name string) error {
+	path := filepath.Join(o.Dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		// a broken entry can never be delivered: drop it, not to block the others
+		loggerOr(o.Logger).Log(LevelWarn, "outbox: dropping broken entry", Field{"entry", name}, Field{"error", err})
+		return os.Remove(path)
+	}
+
+	resp, err := o.Transport.SendAndReceive(ctx, &req)
+	if err != nil {
+		return err
+	}
+	if o.OnDelivered != nil {
+		o.OnDelivered(&req, resp)
+	}
+	return os.Remove(path)
+}
+
+// entries returns the file names of the stored calls, in order.
+func (o *Outbox) entries() ([]string, error) {
+	files, err := os.ReadDir(o.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), outboxExt) {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names) // zero padded: lexical order is the order sent
+	return names, nil
+}
+
+func (o *Outbox) retryInterval() time.Duration {
+	if o.RetryInterval > 0 {
+		return o.RetryInterval
+	}
+	return DefaultOutboxRetryInterval
+}
+
+func (o *Outbox) maxRetryInterval() time.Duration {
+	if o.MaxRetryInterval > 0 {
+		return o.MaxRetryInterval
+	}
+	return DefaultOutboxMaxRetryInterval
+}
+
+// randomID returns a random positive id, unlikely to collide with the ids
+// of other processes or earlier runs.
+func randomID() (int64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1), nil
+}
+
+// writeFileSync writes data into a new file at path atomically and durably:
+// it's written into a temporary file, synced, then renamed into place.
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,161 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyTransport fails while down is set, else serves with server.
+type flakyTransport struct {
+	server Server
+	down   atomic.Bool
+}
+
+func (t *flakyTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
+	if t.down.Load() {
+		return nil, errors.New("server unreachable")
+	}
+	return t.server.ServeRPC(ctx, req), nil
+}
+
+func Test_Outbox(t *testing.T) {
+	s := NewServer().WithAtMostOnce()
+	var mu sync.Mutex
+	var got []int
+	s.MustRegister("record", func(arg int) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, arg)
+		return arg, nil
+	})
+
+	transport := &flakyTransport{server: s}
+	transport.down.Store(true)
+
+	dir := t.TempDir()
+	o, err := NewOutbox(transport, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		if _, err := o.Send("record", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := o.Flush(context.Background()); err == nil {
+		t.Fatal("❌ Flush should fail while the server is unreachable")
+	}
+	if n, _ := o.Pending(); n != 4 {
+		t.Fatalf("❌ Pending() = %d, want 4", n)
+	}
+
+	// restarted: an Outbox on the same dir goes on
+	o, err = NewOutbox(transport, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewFakeClock(time.Now())
+	o.Clock = clock
+	delivered := make(chan *Response, 10)
+	o.OnDelivered = func(req *Request, resp *Response) { delivered <- resp }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- o.Run(ctx) }()
+
+	// failed attempts back off: 1s, 2s, 4s, ...
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		for clock.Timers() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(wait - time.Nanosecond)
+		if clock.Timers() != 1 {
+			t.Fatalf("❌ retried before %v", wait)
+		}
+		clock.Advance(time.Nanosecond)
+	}
+
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	transport.down.Store(false)
+	clock.Advance(8 * time.Second)
+
+	for i := 0; i < 4; i++ {
+		select {
+		case resp := <-delivered:
+			if resp.Error != nil {
+				t.Errorf("❌ delivered with error: %v", resp.Error)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("❌ calls not delivered")
+		}
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []int{1, 2, 3, 4}
+	if len(got) != len(want) {
+		t.Fatalf("❌ executed %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("❌ executed %v, want %v", got, want)
+		}
+	}
+	t.Logf("✅ executed %v", got)
+
+	if n, _ := o.Pending(); n != 0 {
+		t.Errorf("❌ Pending() = %d, want 0", n)
+	}
+}
+
+// stuckTransport hangs each delivery until its ctx is done.
+type stuckTransport struct {
+	sending chan struct{}
+}
+
+func (t *stuckTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
+	t.sending <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func Test_Outbox_Send_whileDelivering(t *testing.T) {
+	transport := &stuckTransport{sending: make(chan struct{}, 1)}
+	o, err := NewOutbox(transport, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Send("record", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- o.Run(ctx) }()
+	defer func() { cancel(); <-done }()
+	<-transport.sending
+
+	// stored at once, not waiting for the delivery stuck
+	sent := make(chan error)
+	go func() { _, err := o.Send("record", 2); sent <- err }()
+	select {
+	case err := <-sent:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("❌ Send waits for the delivery")
+	}
+	if n, _ := o.Pending(); n != 2 {
+		t.Errorf("❌ Pending() = %d, want 2", n)
+	}
+}
@@ -0,0 +1,97 @@
+package jsonrpc2
+
+// 这个文件标准化分页的 RPC：方法的参数带一个 cursor 字段 (可嵌入 PageParams)，
+// 结果是一个 Page；客户端的 Paginate 反复调用方法直到最后一页，
+// 并把条目逐个送进 channel。
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// CursorField is the field of the params of a paginated method carrying
+// the cursor of the page to get.
+const CursorField = "cursor"
+
+// PageParams is to be embedded in the params of paginated methods.
+// An empty Cursor asks for the first page.
+type PageParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// Page is the result of a paginated method.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// Pages streams the items of a paginated method, see Paginate.
+type Pages[T any] struct {
+	ch   chan T
+	done chan struct{}
+	err  error
+}
+
+// C returns the channel of the items, closed after the last one,
+// or when a call fails or the ctx of Paginate is done.
+func (p *Pages[T]) C() <-chan T {
+	return p.ch
+}
+
+// Err waits until C is closed, and returns why: nil after the last page,
+// else the error of the failed call or ctx.Err().
+func (p *Pages[T]) Err() error {
+	<-p.done
+	return p.err
+}
+
+// Paginate calls method with arg page by page, setting the CursorField of arg
+// to the NextCursor of the previous Page, until the last page, and sends the
+// items over Pages.C as they come. arg must marshal into a JSON object (or null).
+//
+// The next page is requested once the items of the previous one are all
+// taken: cancel ctx to stop early.
+func Paginate[T any](ctx context.Context, c Client, method string, arg any) *Pages[T] {
+	p := &Pages[T]{
+		ch:   make(chan T),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(p.done)
+		defer close(p.ch)
+		p.err = p.run(ctx, c, method, arg)
+	}()
+	return p
+}
+
+func (p *Pages[T]) run(ctx context.Context, c Client, method string, arg any) error {
+	params := make(map[string]json.RawMessage)
+	if b, err := json.Marshal(arg); err != nil {
+		return err
+	} else if err := json.Unmarshal(b, &params); err != nil {
+		return errors.New("paginate: arg should marshal into a JSON object")
+	}
+	if params == nil { // arg was null
+		params = make(map[string]json.RawMessage)
+	}
+
+	delete(params, CursorField)
+	for {
+		var page Page[T]
+		if err := c.CallContext(ctx, method, params, &page); err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			select {
+			case p.ch <- item:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		params[CursorField], _ = json.Marshal(page.NextCursor)
+	}
+}
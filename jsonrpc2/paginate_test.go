@@ -0,0 +1,71 @@
+package jsonrpc2
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func Test_Paginate(t *testing.T) {
+	type ListParams struct {
+		PageParams
+		Limit int `json:"limit"`
+	}
+	const total = 7
+
+	s := NewServer()
+	s.MustRegister("list", func(p ListParams) (*Page[int], error) {
+		start, _ := strconv.Atoi(p.Cursor)
+		page := &Page[int]{}
+		for i := start; i < total && i < start+p.Limit; i++ {
+			page.Items = append(page.Items, i)
+		}
+		if next := start + p.Limit; next < total {
+			page.NextCursor = strconv.Itoa(next)
+		}
+		return page, nil
+	})
+	c := NewClient(&serverTransport{server: s})
+
+	tests := []struct {
+		name    string
+		method  string
+		limit   int
+		want    int // number of items
+		wantErr bool
+	}{
+		{"pages", "list", 3, total, false},
+		{"one page", "list", 10, total, false},
+		{"not found", "nope", 3, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := Paginate[int](context.Background(), c, tt.method, ListParams{Limit: tt.limit})
+			var got []int
+			for item := range pages.C() {
+				got = append(got, item)
+			}
+			err := pages.Err()
+			if len(got) != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("❌ got %v, err = %v; want %d items, error: %v", got, err, tt.want, tt.wantErr)
+				return
+			}
+			for i, item := range got {
+				if item != i {
+					t.Errorf("❌ got %v, want items in order", got)
+					return
+				}
+			}
+			t.Logf("✅ got %v, err = %v", got, err)
+		})
+	}
+
+	// stop early
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := Paginate[int](ctx, c, "list", ListParams{Limit: 3})
+	<-pages.C()
+	cancel()
+	if err := pages.Err(); err != context.Canceled {
+		t.Errorf("❌ Err() = %v, want context.Canceled", err)
+	}
+}
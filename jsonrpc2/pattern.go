@@ -0,0 +1,132 @@
+package jsonrpc2
+
+// 这个文件实现方法名的模式匹配：Register 的名字可以是模式，如 "kv.*" 或 "tenant/{id}/lock"，
+// 匹配的请求都由它的方法处理，以便在其上构建通用的路由。捕获的段 ({id}) 作为请求的元数据
+// 传给方法，见 MethodParam。
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// MetaMethodParamPrefix prefixes the keys of the request metadata carrying
+// the segments of the method name captured by its pattern, e.g.
+// "Method-Param-Id" for {id}, see MethodParam. The keys of the callers
+// starting with it are dropped.
+const MetaMethodParamPrefix = "Method-Param-"
+
+// methodPattern is a method name registered as a pattern:
+//   - "*" matches anything, e.g. "kv.*" matches "kv.get" and "kv.a.b";
+//   - "{name}" matches a segment, i.e. anything but "" and "." or "/",
+//     captured as the name, e.g. "tenant/{id}/lock" matches "tenant/42/lock".
+type methodPattern struct {
+	name string // as registered
+	re   *regexp.Regexp
+	keys []string // of the metadata of the captures, in order
+}
+
+// isPattern tells whether the method name is a pattern.
+func isPattern(name string) bool {
+	return strings.ContainsAny(name, "*{}")
+}
+
+func compilePattern(name string) (*methodPattern, error) {
+	p := &methodPattern{name: name}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for rest := name; rest != ""; {
+		i := strings.IndexAny(rest, "*{}")
+		if i < 0 {
+			expr.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		expr.WriteString(regexp.QuoteMeta(rest[:i]))
+		switch rest[i] {
+		case '*':
+			expr.WriteString(".*")
+			rest = rest[i+1:]
+		case '{':
+			end := strings.IndexByte(rest[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("bad pattern %s: unclosed {", name)
+			}
+			param := rest[i+1 : i+end]
+			if !validMetaKey(param) {
+				return nil, fmt.Errorf("bad pattern %s: bad segment name %q", name, param)
+			}
+			key := textproto.CanonicalMIMEHeaderKey(MetaMethodParamPrefix + param)
+			for _, k := range p.keys {
+				if k == key {
+					return nil, fmt.Errorf("bad pattern %s: segment %s captured twice", name, param)
+				}
+			}
+			p.keys = append(p.keys, key)
+			expr.WriteString(`([^./]+)`)
+			rest = rest[i+end+1:]
+		case '}':
+			return nil, fmt.Errorf("bad pattern %s: unopened }", name)
+		}
+	}
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern %s: %w", name, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// match the method name, returning the metadata of the segments captured.
+func (p *methodPattern) match(method string) (map[string]string, bool) {
+	m := p.re.FindStringSubmatch(method)
+	if m == nil {
+		return nil, false
+	}
+	if len(p.keys) == 0 {
+		return nil, true
+	}
+	captures := make(map[string]string, len(p.keys))
+	for i, key := range p.keys {
+		captures[key] = m[i+1]
+	}
+	return captures, true
+}
+
+// MethodParam returns the segment name of the method name captured by the
+// pattern it's registered as, e.g. "42" for "id" when "tenant/42/lock" is
+// served by "tenant/{id}/lock". "" if there is none.
+func MethodParam(ctx context.Context, name string) string {
+	return RequestMetaFromContext(ctx)[textproto.CanonicalMIMEHeaderKey(MetaMethodParamPrefix+name)]
+}
+
+// withMethodParams returns a copy of ctx whose request metadata has the
+// captures of the pattern of the method, those of the caller dropped.
+func withMethodParams(ctx context.Context, captures map[string]string) context.Context {
+	incoming := RequestMetaFromContext(ctx)
+	if len(captures) == 0 && !hasMethodParams(incoming) {
+		return ctx
+	}
+	meta := make(map[string]string, len(incoming)+len(captures))
+	for k, v := range incoming {
+		if !strings.HasPrefix(k, MetaMethodParamPrefix) {
+			meta[k] = v
+		}
+	}
+	for k, v := range captures {
+		meta[k] = v
+	}
+	return context.WithValue(ctx, incomingMetaKey{}, meta)
+}
+
+func hasMethodParams(meta map[string]string) bool {
+	for k := range meta {
+		if strings.HasPrefix(k, MetaMethodParamPrefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func Test_compilePattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		method   string
+		want     bool
+		captures map[string]string
+	}{
+		{"kv.*", "kv.get", true, nil},
+		{"kv.*", "kv.a.b", true, nil},
+		{"kv.*", "kvx.get", false, nil},
+		{"tenant/{id}/lock", "tenant/42/lock", true, map[string]string{"Method-Param-Id": "42"}},
+		{"tenant/{id}/lock", "tenant//lock", false, nil},
+		{"tenant/{id}/lock", "tenant/4/2/lock", false, nil},
+		{"{svc}.{method}", "lock.Lock", true, map[string]string{"Method-Param-Svc": "lock", "Method-Param-Method": "Lock"}},
+		{"a+b.*", "a+b.c", true, nil},
+		{"a+b.*", "aab.c", false, nil},
+	}
+	for _, tt := range tests {
+		p, err := compilePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("❌ compile %s: %v", tt.pattern, err)
+		}
+		captures, ok := p.match(tt.method)
+		if ok != tt.want || len(captures) != len(tt.captures) {
+			t.Errorf("❌ %s match %s = %v, %v; want %v, %v", tt.pattern, tt.method, captures, ok, tt.captures, tt.want)
+			continue
+		}
+		for k, v := range tt.captures {
+			if captures[k] != v {
+				t.Errorf("❌ %s match %s: %s = %q, want %q", tt.pattern, tt.method, k, captures[k], v)
+			}
+		}
+	}
+
+	for _, bad := range []string{"a/{id", "a/id}", "a/{}", "{id}/{id}", "{i d}"} {
+		if _, err := compilePattern(bad); err == nil {
+			t.Errorf("❌ compiled the bad pattern %s", bad)
+		}
+	}
+}
+
+func Test_server_RegisterPattern(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("kv.get", func(arg int) (string, error) { return "exact", nil })
+	s.MustRegister("kv.*", func(ctx context.Context, arg int) (string, error) { return "any", nil })
+	s.MustRegister("tenant/{id}/lock", func(ctx context.Context, arg int) (string, error) {
+		return MethodParam(ctx, "id"), nil
+	})
+	if err := s.Register("tenant/{id", func(arg int) (int, error) { return arg, nil }); err == nil {
+		t.Error("❌ registered a bad pattern")
+	}
+	if err := s.Register("kv.*", func(arg int) (int, error) { return arg, nil }); err == nil {
+		t.Error("❌ registered a pattern twice")
+	}
+	c := NewClient(&serverTransport{server: s})
+
+	for method, want := range map[string]string{"kv.get": "exact", "kv.put": "any", "tenant/42/lock": "42"} {
+		var got string
+		if err := c.Call(method, 1, &got); err != nil || got != want {
+			t.Errorf("❌ %s = %q, %v; want %q", method, got, err, want)
+		}
+	}
+
+	// the captures can't be spoofed by the caller
+	id := int64(1)
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "tenant/7/lock", Params: json.RawMessage(`1`), Id: Int64ID(id),
+		Meta: map[string]string{"Method-Param-Id": "admin", "Method-Param-Role": "root"}})
+	if string(resp.Result) != `"7"` {
+		t.Errorf("❌ tenant/7/lock = %s, want the captured id", resp.Result)
+	}
+
+	var rpcErr *Error
+	if err := c.Call("tenant/42/unlock", 1, nil); !errors.As(err, &rpcErr) || rpcErr.Code != ErrMethodNotFound().Code {
+		t.Errorf("❌ tenant/42/unlock: %v, want ErrMethodNotFound", err)
+	}
+}
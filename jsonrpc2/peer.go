@@ -0,0 +1,236 @@
+package jsonrpc2
+
+// 这个文件实现双向 RPC：在面向连接的传输 (TCP、Unix、WebSocket) 上，服务端的方法可以通过
+// 连接的 Peer 反过来调用客户端注册的方法 (回调)，如推送订阅的消息、通知锁被撤销。
+//
+// 两个方向的请求共用一条连接，各自的 id 互不相干：服务端把读到的没有 method 的消息当作
+// 它发起的调用的响应，客户端把读到的有 method 的消息当作服务端发起的调用，交给
+// StreamClientTransport.Callbacks 处理。
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrPeerGone fails the calls of a Peer whose connection is closed.
+var ErrPeerGone = errors.New("jsonrpc2: peer connection closed")
+
+// Peer is the client at the other end of a connection, as seen by the
+// server: the methods served on the connection get it by PeerFromContext,
+// to call back the methods the client serves (see
+// StreamClientTransport.Callbacks), e.g. to push events to a subscriber:
+//
+//	func (s *Service) Subscribe(ctx context.Context, arg *SubscribeArg) (*SubscribeRet, error) {
+//		peer, ok := jsonrpc2.PeerFromContext(ctx)
+//		if !ok {
+//			return nil, errors.New("subscribe over a connection")
+//		}
+//		go s.push(peer) // peer.Notify(ctx, "events.Publish", event) until peer.Done()
+//		...
+//	}
+//
+// A Peer may be kept and used after the method returns, as long as the
+// connection lives. It's safe for concurrent use.
+type Peer struct {
+	write func([]byte) error // a message, safe for concurrent use
+
+	nextId  atomic.Int64 // of the calls made, 0: none yet
+	mu      sync.Mutex
+	pending map[ID]chan *Response
+	err     error // why the connection is gone, nil while it's not
+	done    chan struct{}
+}
+
+func newPeer(write func([]byte) error) *Peer {
+	return &Peer{
+		write:   write,
+		pending: make(map[ID]chan *Response),
+		done:    make(chan struct{}),
+	}
+}
+
+type peerKey struct{}
+
+// withPeer returns a copy of ctx carrying peer.
+func withPeer(ctx context.Context, peer *Peer) context.Context {
+	return context.WithValue(ctx, peerKey{}, peer)
+}
+
+// PeerFromContext returns the Peer of the connection the request served
+// in ctx came from, if it came from a connection (i.e. not over HTTP).
+func PeerFromContext(ctx context.Context) (*Peer, bool) {
+	peer, ok := ctx.Value(peerKey{}).(*Peer)
+	return peer, ok
+}
+
+// Call the method of the client with arg, parsing its result into ret
+// (unless ret is nil). It fails with ErrPeerGone once the connection is
+// closed, and with an ErrMethodNotFound if the client doesn't serve method.
+func (p *Peer) Call(ctx context.Context, method string, arg any, ret any) error {
+	params, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+	id := Int64ID(p.nextId.Add(1))
+	reqJson, err := Request{JsonRpc: JsonRpc2, Method: method, Params: params, Id: id}.toJSON()
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan *Response, 1)
+	p.mu.Lock()
+	if p.err != nil {
+		p.mu.Unlock()
+		return p.err
+	}
+	p.pending[*id] = ch
+	p.mu.Unlock()
+
+	if err := p.write(reqJson); err != nil {
+		p.forget(*id)
+		return err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return p.Err()
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if ret == nil {
+			return nil
+		}
+		return resp.unmarshalResult(ret)
+	case <-ctx.Done():
+		p.forget(*id)
+		return ctx.Err()
+	}
+}
+
+// Notify the method of the client with arg, without waiting for anything.
+func (p *Peer) Notify(ctx context.Context, method string, arg any) error {
+	if err := p.Err(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	params, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+	reqJson, err := Request{JsonRpc: JsonRpc2, Method: method, Params: params}.toJSON()
+	if err != nil {
+		return err
+	}
+	return p.write(reqJson)
+}
+
+// Done is closed once the connection is closed.
+func (p *Peer) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err is ErrPeerGone once the connection is closed, else nil.
+func (p *Peer) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *Peer) forget(id ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, id)
+}
+
+// deliver the message body to the pending call it answers, if it's a
+// response, returning whether it is.
+//
+// Requests are told from responses by their method. It's only checked
+// once the server made calls: a message without method is a response
+// then, else an invalid request, answered as such.
+func (p *Peer) deliver(body []byte) bool {
+	if p.nextId.Load() == 0 || isBatch(body) {
+		return false
+	}
+	var msg struct {
+		Method *string `json:"method"`
+		Id     *ID     `json:"id"`
+	}
+	if bytes.Contains(body, []byte(`"method"`)) && json.Unmarshal(body, &msg) == nil && msg.Method != nil {
+		return false
+	}
+	var resp Response
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Id == nil || resp.JsonRpc != JsonRpc2 {
+		return false
+	}
+
+	p.mu.Lock()
+	ch, ok := p.pending[*resp.Id]
+	delete(p.pending, *resp.Id)
+	p.mu.Unlock()
+	if ok {
+		ch <- &resp
+	}
+	return true // late ones are dropped
+}
+
+// close the peer once the connection is gone, failing the pending calls.
+func (p *Peer) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	p.err = ErrPeerGone
+	for id, ch := range p.pending {
+		close(ch)
+		delete(p.pending, id)
+	}
+	close(p.done)
+}
+
+// isRequest tells whether the message body read by a client is a request
+// of the server (see Peer), not a response.
+func isRequest(body []byte) bool {
+	if !bytes.Contains(body, []byte(`"method"`)) {
+		return false // the responses, mostly
+	}
+	var msg struct {
+		Method *string `json:"method"`
+	}
+	return json.Unmarshal(body, &msg) == nil && msg.Method != nil
+}
+
+// serveCallback serves the request body of the server by the Callbacks of
+// the client, writing back the response.
+func (c *streamConn) serveCallback(body []byte) {
+	if c.callbacks == nil {
+		c.answerCallback(rejectMessage(body, ErrMethodNotFound))
+		return
+	}
+	serveIsolated(c.callbacksCtx, 0, nil, c.callbacks, body, c.answerCallback)
+}
+
+// answerCallback writes back the response out of a callback.
+func (c *streamConn) answerCallback(out []byte, err error) {
+	if err == context.Canceled {
+		return // the connection is gone
+	}
+	if err != nil {
+		c.logger.Log(LevelWarn, "failed to serve callback", Field{"error", err})
+		return
+	}
+	if out != nil {
+		if err := c.conn.writeMessage(out); err != nil {
+			_ = c.close(err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Peer(t *testing.T) {
+	peers := make(chan *Peer, 1)
+	s := NewServer()
+	s.MustRegister("greet", func(ctx context.Context, name string) (string, error) {
+		peer, ok := PeerFromContext(ctx)
+		if !ok {
+			return "", errors.New("no peer")
+		}
+		if err := peer.Notify(ctx, "event", "greeting "+name); err != nil {
+			return "", err
+		}
+		var title string
+		if err := peer.Call(ctx, "title", name, &title); err != nil {
+			return "", err
+		}
+		peers <- peer
+		return "hello, " + title + " " + name, nil
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+
+	events := make(chan string, 1)
+	callbacks := NewServer()
+	callbacks.MustRegister("title", func(name string) (string, error) { return "Dr.", nil })
+	callbacks.MustRegister("event", func(e string) (int, error) {
+		events <- e
+		return 0, nil
+	})
+	ct := NewTcpClientTransport(l.Addr().String())
+	ct.Callbacks = callbacks
+	c := NewClient(ct)
+
+	var got string
+	if err := c.Call("greet", "Who", &got); err != nil || got != "hello, Dr. Who" {
+		t.Fatalf("❌ greet = %q, %v; want the title called back", got, err)
+	}
+	if e := <-events; e != "greeting Who" {
+		t.Errorf("❌ event %q", e)
+	}
+
+	// the peer outlives the call, not the connection
+	peer := <-peers
+	var title string
+	if err := peer.Call(context.Background(), "title", "Watson", &title); err != nil || title != "Dr." {
+		t.Errorf("❌ title = %q, %v; called later", title, err)
+	}
+	var rpcErr *Error
+	if err := peer.Call(context.Background(), "nothing", 1, nil); !errors.As(err, &rpcErr) || rpcErr.Code != ErrMethodNotFound().Code {
+		t.Errorf("❌ nothing: %v, want ErrMethodNotFound", err)
+	}
+	c.Close()
+	select {
+	case <-peer.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("❌ the peer is not done once the connection is closed")
+	}
+	if err := peer.Call(context.Background(), "title", "Watson", &title); err != ErrPeerGone {
+		t.Errorf("❌ calling a peer gone: %v, want ErrPeerGone", err)
+	}
+
+	// without callbacks, the server is answered ErrMethodNotFound
+	c = NewClient(NewTcpClientTransport(l.Addr().String()))
+	defer c.Close()
+	if err := c.Call("greet", "Who", &got); !errors.As(err, &rpcErr) || rpcErr.Code != ErrMethodNotFound().Code {
+		t.Errorf("❌ greet without callbacks: %v, want ErrMethodNotFound", err)
+	}
+
+	// nor is there a peer over HTTP
+	if err := NewClient(&serverTransport{server: s}).Call("greet", "Who", &got); err == nil {
+		t.Error("❌ a peer of no connection")
+	}
+}
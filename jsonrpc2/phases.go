@@ -0,0 +1,80 @@
+package jsonrpc2
+
+// 这个文件实现按阶段计时 (WithPhaseTimings)：分别记录每个方法解码参数、执行方法、编码结果
+// 所花的时间，写入 Metrics (于是也在 Stats 与 DebugHandler 中)，以找出 (反) 序列化的热点，
+// 调优具体的服务。默认关闭，不为每个请求多读几次时钟。
+
+import (
+	"context"
+	"time"
+)
+
+// phase is a step of serving a request, timed by WithPhaseTimings.
+type phase int
+
+const (
+	phaseDecode phase = iota // unmarshaling the params
+	phaseCall                // running the method
+	phaseEncode              // marshaling the result
+	numPhases
+)
+
+var phaseNames = [numPhases]string{"decode", "call", "encode"}
+
+// phaseTimer times the phases of serving a request, by the handler. The
+// nil *phaseTimer times nothing: the handlers call it all the same.
+type phaseTimer struct {
+	clock Clock
+	last  time.Time
+	took  [numPhases]time.Duration
+	timed [numPhases]bool
+}
+
+type phaseTimerKey struct{}
+
+func withPhaseTimer(ctx context.Context, t *phaseTimer) context.Context {
+	return context.WithValue(ctx, phaseTimerKey{}, t)
+}
+
+// phaseTimerFromContext returns the phaseTimer of the request, nil if
+// it's not timed.
+func phaseTimerFromContext(ctx context.Context) *phaseTimer {
+	t, _ := ctx.Value(phaseTimerKey{}).(*phaseTimer)
+	return t
+}
+
+// start the first phase.
+func (t *phaseTimer) start() {
+	if t == nil {
+		return
+	}
+	t.last = t.clock.Now()
+}
+
+// done ends the phase p, starting the next one.
+func (t *phaseTimer) done(p phase) {
+	if t == nil {
+		return
+	}
+	now := t.clock.Now()
+	t.took[p] += now.Sub(t.last)
+	t.timed[p] = true
+	t.last = now
+}
+
+// observe the phases timed into metrics, as "phase.decode.<method>",
+// "phase.call.<method>" and "phase.encode.<method>". The phases not
+// reached (e.g. encode, for the params invalid) are not observed.
+func (t *phaseTimer) observe(metrics Metrics, method string) {
+	for p, took := range t.took {
+		if t.timed[p] {
+			metrics.Observe("phase."+phaseNames[p]+"."+method, took)
+		}
+	}
+}
+
+// WithPhaseTimings 原址设置是否按阶段计时，并返回 Server 以供链式
+func (s *server) WithPhaseTimings(on bool) Server {
+	s.phaseTimings.Store(on)
+	return s
+}
@@ -0,0 +1,78 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowJSON takes slowClock a while to (un)marshal.
+type slowJSON struct{}
+
+var slowClock *FakeClock
+
+func (*slowJSON) UnmarshalJSON(data []byte) error {
+	slowClock.Advance(time.Millisecond)
+	if string(data) != "{}" {
+		return errors.New("not {}")
+	}
+	return nil
+}
+
+func (slowJSON) MarshalJSON() ([]byte, error) {
+	slowClock.Advance(2 * time.Millisecond)
+	return []byte(`"slow"`), nil
+}
+
+func Test_server_WithPhaseTimings(t *testing.T) {
+	slowClock = NewFakeClock(time.Unix(0, 0))
+	s := NewServer().WithClock(slowClock)
+	s.MustRegister("work", func(arg *slowJSON) (slowJSON, error) {
+		slowClock.Advance(5 * time.Millisecond)
+		return slowJSON{}, nil
+	})
+	s.MustRegister("kv.*", TypedContext(func(ctx context.Context, arg *slowJSON) (slowJSON, error) {
+		return slowJSON{}, nil
+	}))
+
+	serve := func(method, params string) *Response {
+		return s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: method, Params: []byte(params), Id: Int64ID(1)})
+	}
+
+	serve("work", `{}`)
+	for name := range s.Stats() {
+		if strings.HasPrefix(name, "phase.") {
+			t.Errorf("❌ %s timed by default", name)
+		}
+	}
+
+	s.WithPhaseTimings(true)
+	serve("work", `{}`)
+	serve("kv.get", `{}`)
+	if resp := serve("work", `[]`); resp.Error == nil {
+		t.Fatal("❌ served the params invalid")
+	}
+	stats := s.Stats()
+	for name, want := range map[string]int64{
+		"phase.decode.work.count":    2,
+		"phase.decode.work.total_us": 2000,
+		"phase.call.work.count":      1, // not for the params invalid
+		"phase.call.work.total_us":   5000,
+		"phase.encode.work.total_us": 2000,
+		"phase.decode.kv.*.total_us": 1000,
+		"phase.call.kv.*.total_us":   0,
+		"phase.encode.kv.*.count":    1,
+	} {
+		if got, ok := stats[name]; !ok || got != want {
+			t.Errorf("❌ %s = %d (%v), want %d", name, got, ok, want)
+		}
+	}
+
+	s.WithPhaseTimings(false)
+	serve("work", `{}`)
+	if s.Stats()["phase.decode.work.count"] != 2 {
+		t.Error("❌ timed after WithPhaseTimings(false)")
+	}
+}
@@ -0,0 +1,57 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// prettyIndent is the indent of pretty JSON, see Server.WithPretty.
+const prettyIndent = "  "
+
+type prettyKey struct{}
+
+// withPretty returns a copy of ctx asking for a pretty response.
+func withPretty(ctx context.Context) context.Context {
+	return context.WithValue(ctx, prettyKey{}, true)
+}
+
+// prettyOf tells whether s is WithPretty.
+func prettyOf(s Server) bool {
+	switch srv := s.(type) {
+	case *server:
+		srv.mu.RLock()
+		defer srv.mu.RUnlock()
+		return srv.pretty
+	case *ProxyServer:
+		return prettyOf(srv.Server)
+	default:
+		return false
+	}
+}
+
+func prettyFromContext(ctx context.Context) bool {
+	pretty, _ := ctx.Value(prettyKey{}).(bool)
+	return pretty
+}
+
+// prettyRequested tells whether the HTTP request r asks for a pretty response
+// by the query ?pretty=1 (or any true value of strconv.ParseBool).
+func prettyRequested(r *http.Request) bool {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return pretty
+}
+
+// indentJSON returns data indented if pretty, or as it is if it can't.
+func indentJSON(data []byte, pretty bool) []byte {
+	if !pretty || len(data) == 0 {
+		return data
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", prettyIndent); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
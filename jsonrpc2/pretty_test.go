@@ -0,0 +1,130 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_pretty(t *testing.T) {
+	newServer := func(pretty bool) Server {
+		s := NewServer().WithPretty(pretty)
+		s.MustRegister("echo", func(arg []int) ([]int, error) { return arg, nil })
+		return s
+	}
+
+	const call = `{"jsonrpc": "2.0", "method": "echo", "params": [1, 2], "id": 1}`
+
+	tests := []struct {
+		name   string
+		pretty bool // WithPretty
+		query  string
+		body   string
+		want   string
+	}{
+		{"compact", false, "", call, `{"jsonrpc":"2.0","result":[1,2],"id":1}
+`},
+		{"query", false, "?pretty=1", call, `{
+  "jsonrpc": "2.0",
+  "result": [
+    1,
+    2
+  ],
+  "id": 1
+}
+`},
+		{"server option", true, "", call, `{
+  "jsonrpc": "2.0",
+  "result": [
+    1,
+    2
+  ],
+  "id": 1
+}
+`},
+		{"parse error", false, "?pretty=true", `{`, `{
+  "jsonrpc": "2.0",
+  "error": {
+    "code": -32700,
+    "message": "Parse error",
+    "data": {
+      "reason": "unexpected EOF"
+    }
+  },
+  "id": null
+}
+`},
+		{"batch", false, "?pretty=1", `[` + call + `]`, `[
+  {
+    "jsonrpc": "2.0",
+    "result": [
+      1,
+      2
+    ],
+    "id": 1
+  }
+]
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := NewHttpServerTransport("")
+			st.Use(newServer(tt.pretty))
+			ts := httptest.NewServer(st)
+			defer ts.Close()
+
+			resp, err := http.Post(ts.URL+tt.query, "application/json", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			got, _ := io.ReadAll(resp.Body)
+			if string(got) != tt.want {
+				t.Errorf("❌ got %s\nwant %s", got, tt.want)
+			} else {
+				t.Logf("✅ %s", got)
+			}
+		})
+	}
+}
+
+func Test_pretty_stream(t *testing.T) {
+	s := NewServer().WithPretty(true)
+	s.MustRegister("echo", func(arg []int) ([]int, error) { return arg, nil })
+
+	const call = `{"jsonrpc": "2.0", "method": "echo", "params": [1, 2], "id": 1}`
+	const want = `{
+  "jsonrpc": "2.0",
+  "result": [
+    1,
+    2
+  ],
+  "id": 1
+}`
+
+	for _, body := range []string{call, `{`, `[` + call + `]`} {
+		server, client := net.Pipe()
+		go (&StreamServerTransport{}).ServeConn(server, s)
+
+		go func() { _ = writeFrame(client, []byte(body)) }()
+		got, err := readFrame(bufio.NewReader(client), 0)
+		client.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var compact bytes.Buffer
+		_ = json.Compact(&compact, got)
+		if !bytes.Equal(got, indentJSON(compact.Bytes(), true)) || compact.Len() == len(got) {
+			t.Errorf("❌ %s: not indented: %s", body, got)
+		} else if body == call && string(got) != want {
+			t.Errorf("❌ %s: got %s\nwant %s", body, got, want)
+		} else {
+			t.Logf("✅ %s", got)
+		}
+	}
+}
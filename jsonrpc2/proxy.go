@@ -0,0 +1,127 @@
+package jsonrpc2
+
+// 这个文件实现内嵌的反向代理 (ProxyServer)：本地没有注册的方法转发给上游的 jsonrpc2 服务端，
+// 便于把方法逐个从旧服务迁移到新服务；还可以让某个方法只有一部分调用由本地执行 (金丝雀)，其余仍转发给上游。
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// ProxyServer is a Server forwarding the requests of the methods it
+// doesn't have to an upstream server, e.g. to migrate a service method
+// by method: the methods registered are served here, all the others by
+// the old server behind.
+//
+//	s := jsonrpc2.NewProxyServer(jsonrpc2.NewServer(), &jsonrpc2.HttpClientTransport{Addr: "http://legacy:6666"})
+//	s.MustRegister("lock.Lock", newLock) // migrated
+//	s.WithCanary("lock.Lock", 10)        // but served here by 1 call in 10 only
+//	jsonrpc2.NewHttpServerTransport(":6666").Serve(s)
+//
+// The forwarding is a middleware of the local server (see Server.Use),
+// installed by NewProxyServer: the middlewares Use'd before it see all the
+// requests, those Use'd after it only the ones served here.
+type ProxyServer struct {
+	Server
+
+	upstream ClientTransport
+	nextId   atomic.Int64 // of the requests forwarded
+
+	mu     sync.RWMutex
+	rename func(method string) string // nil: the same names
+	canary map[string]int             // method -> percentage of the calls served here
+}
+
+// NewProxyServer makes local a ProxyServer forwarding to upstream.
+func NewProxyServer(local Server, upstream ClientTransport) *ProxyServer {
+	p := &ProxyServer{Server: local, upstream: upstream, canary: make(map[string]int)}
+	local.Use(p.middleware)
+	return p
+}
+
+// WithRename 原址设置转发给上游时方法名的改写 (例如加上前缀)，并返回 ProxyServer 以供链式
+func (p *ProxyServer) WithRename(f func(method string) string) *ProxyServer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rename = f
+	return p
+}
+
+// WithCanary 原址设置 method 只有 percent% 的调用由本地执行、其余转发给上游，并返回 ProxyServer 以供链式
+//
+// percent >= 100 removes the canary: all the calls are served here (if
+// the method is registered). percent <= 0 forwards them all. It's safe
+// to call while serving, to roll the method out step by step.
+func (p *ProxyServer) WithCanary(method string, percent int) *ProxyServer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if percent >= 100 {
+		delete(p.canary, method)
+	} else {
+		p.canary[method] = percent
+	}
+	return p
+}
+
+// middleware forwards the requests not to be served here: those of the
+// canaries out of their percentage, and those of methods not found.
+func (p *ProxyServer) middleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req *Request) *Response {
+		p.mu.RLock()
+		percent, isCanary := p.canary[req.Method]
+		p.mu.RUnlock()
+		if isCanary && (percent <= 0 || rand.Intn(100) >= percent) {
+			return p.forward(ctx, req)
+		}
+
+		resp := next(ctx, req)
+		if resp != nil && resp.Error != nil && resp.Error.Code == ErrMethodNotFound().Code {
+			return p.forward(ctx, req)
+		}
+		return resp
+	}
+}
+
+// forward req to the upstream, answering its response.
+//
+// The id of req is replaced by one of the proxy for the upstream, since
+// the callers of the proxy may send the same ids, and restored in the
+// response. Failures to reach the upstream are answered ErrServerError.
+func (p *ProxyServer) forward(ctx context.Context, req *Request) *Response {
+	up := *req
+	p.mu.RLock()
+	if p.rename != nil {
+		up.Method = p.rename(req.Method)
+	}
+	p.mu.RUnlock()
+
+	if req.IsNotification() {
+		var err error
+		if nt, ok := p.upstream.(NotifyClientTransport); ok {
+			err = nt.Notify(ctx, &up)
+		} else {
+			_, err = p.upstream.SendAndReceive(ctx, &up)
+		}
+		if err != nil {
+			p.logFailure(req, err)
+		}
+		return &Response{JsonRpc: JsonRpc2}
+	}
+
+	up.Id = Int64ID(p.nextId.Add(1))
+	resp, err := p.upstream.SendAndReceive(ctx, &up)
+	if err != nil {
+		p.logFailure(req, err)
+		return errorResponse(req.Id, ErrServerError().WithReason("upstream: "+err.Error()))
+	}
+	out := *resp
+	out.Id = req.Id
+	return &out
+}
+
+func (p *ProxyServer) logFailure(req *Request, err error) {
+	loggerOf(p.Server).Log(LevelWarn, "failed to forward to the upstream",
+		Field{"method", req.Method}, Field{"id", formatId(req.Id)}, Field{"error", err})
+}
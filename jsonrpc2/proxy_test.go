@@ -0,0 +1,160 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// upstreamTransport serves the requests forwarded by the server, recording them.
+type upstreamTransport struct {
+	server Server
+	err    error // fails every request if set
+
+	mu       sync.Mutex
+	received []Request
+}
+
+func (t *upstreamTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
+	t.mu.Lock()
+	t.received = append(t.received, *req)
+	t.mu.Unlock()
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.server.ServeRPC(ctx, req), nil
+}
+
+func (t *upstreamTransport) methods() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var methods []string
+	for _, req := range t.received {
+		methods = append(methods, req.Method)
+	}
+	return methods
+}
+
+func newProxyTest(t *testing.T) (*ProxyServer, *upstreamTransport) {
+	legacy := NewServer()
+	legacy.MustRegister("who", func(arg int) (string, error) { return "upstream", nil })
+	legacy.MustRegister("legacy.who", func(arg int) (string, error) { return "renamed", nil })
+	legacy.MustRegister("old", func(arg int) (int, error) { return arg * 2, nil })
+	upstream := &upstreamTransport{server: legacy}
+
+	local := NewServer()
+	local.MustRegister("who", func(arg int) (string, error) { return "local", nil })
+	return NewProxyServer(local, upstream), upstream
+}
+
+func Test_ProxyServer(t *testing.T) {
+	p, upstream := newProxyTest(t)
+	c := NewClient(&serverTransport{server: p})
+
+	var who string
+	if err := c.Call("who", 1, &who); err != nil || who != "local" {
+		t.Errorf("❌ who = %q, %v; want local, served here", who, err)
+	}
+	var old int
+	if err := c.Call("old", 21, &old); err != nil || old != 42 {
+		t.Errorf("❌ old = %d, %v; want 42, forwarded", old, err)
+	}
+	if err := c.Call("nope", 1, nil); err == nil || !strings.Contains(err.Error(), "Method not found") {
+		t.Errorf("❌ nope: %v; want Method not found of the upstream", err)
+	}
+	if got := upstream.methods(); len(got) != 2 || got[0] != "old" || got[1] != "nope" {
+		t.Errorf("❌ forwarded %v, want [old nope]", got)
+	}
+}
+
+func Test_ProxyServer_ids(t *testing.T) {
+	p, upstream := newProxyTest(t)
+
+	// callers sending the same id: distinct ids upstream, the callers' in the responses
+	id := int64(7)
+	for i := 0; i < 2; i++ {
+		resp := p.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "old", Params: json.RawMessage(`1`), Id: Int64ID(id)})
+		if resp.Error != nil || resp.Id == nil || *resp.Id != *Int64ID(id) {
+			t.Fatalf("❌ response %+v, want the id %d", resp, id)
+		}
+	}
+	upstream.mu.Lock()
+	defer upstream.mu.Unlock()
+	if a, b := upstream.received[0].Id, upstream.received[1].Id; *a == *b {
+		t.Errorf("❌ forwarded both with the id %s", a)
+	}
+}
+
+func Test_ProxyServer_WithRename(t *testing.T) {
+	p, _ := newProxyTest(t)
+	p.WithRename(func(method string) string { return "legacy." + method }).WithCanary("who", 0)
+
+	var who string
+	if err := NewClient(&serverTransport{server: p}).Call("who", 1, &who); err != nil || who != "renamed" {
+		t.Errorf("❌ who = %q, %v; want renamed", who, err)
+	}
+}
+
+func Test_ProxyServer_WithCanary(t *testing.T) {
+	p, upstream := newProxyTest(t)
+	c := NewClient(&serverTransport{server: p})
+
+	tests := []struct {
+		percent int
+		want    string
+	}{
+		{0, "upstream"},
+		{100, "local"},
+		{-1, "upstream"},
+	}
+	for _, tt := range tests {
+		p.WithCanary("who", tt.percent)
+		for i := 0; i < 10; i++ {
+			var who string
+			if err := c.Call("who", 1, &who); err != nil || who != tt.want {
+				t.Fatalf("❌ canary %d%%: who = %q, %v; want %s", tt.percent, who, err, tt.want)
+			}
+		}
+	}
+	if got := len(upstream.methods()); got != 20 {
+		t.Errorf("❌ forwarded %d calls, want 20", got)
+	}
+}
+
+func Test_ProxyServer_batch(t *testing.T) {
+	p, _ := newProxyTest(t)
+
+	responses := p.ServeBatch(context.Background(), []json.RawMessage{
+		[]byte(`{"jsonrpc": "2.0", "method": "who", "params": 1, "id": 1}`),
+		[]byte(`{"jsonrpc": "2.0", "method": "old", "params": 2, "id": 2}`),
+		[]byte(`{"jsonrpc": "2.0", "method": "old", "params": 3}`),
+	})
+	if len(responses) != 2 {
+		t.Fatalf("❌ %d responses, want 2", len(responses))
+	}
+	if string(responses[0].Result) != `"local"` || *responses[0].Id != *Int64ID(1) {
+		t.Errorf("❌ responses[0] = %+v, want local of id 1", responses[0])
+	}
+	if string(responses[1].Result) != `4` || *responses[1].Id != *Int64ID(2) {
+		t.Errorf("❌ responses[1] = %+v, want 4 of id 2", responses[1])
+	}
+}
+
+func Test_ProxyServer_upstreamDown(t *testing.T) {
+	p, upstream := newProxyTest(t)
+	upstream.err = errors.New("connection refused")
+	logs := &recordingLogger{}
+	p.WithLogger(logs)
+
+	id := int64(1)
+	resp := p.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "old", Params: json.RawMessage(`1`), Id: Int64ID(id)})
+	if resp.Error == nil || resp.Error.Code != ErrServerError().Code {
+		t.Errorf("❌ response %+v, want ErrServerError", resp)
+	}
+	if logs.count("failed to forward to the upstream") != 1 {
+		t.Error("❌ the failure is not logged")
+	}
+}
@@ -0,0 +1,200 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// BufferPolicy tells what Topic.Publish does to a subscriber whose buffer is full,
+// i.e. a consumer slower than the publisher.
+type BufferPolicy int
+
+const (
+	// DropOldest discards the oldest buffered message to make room for the new one.
+	// The publisher never waits. This is the default.
+	DropOldest BufferPolicy = iota
+	// Block waits until the subscriber has room. Nothing is lost, but the
+	// publisher (and so every other subscriber) is held back by the slowest one.
+	Block
+	// Disconnect closes the subscription, see Subscription.Err.
+	// The publisher never waits.
+	Disconnect
+)
+
+// ErrSlowSubscriber is the Subscription.Err of a subscriber disconnected
+// by the Disconnect policy.
+var ErrSlowSubscriber = errors.New("jsonrpc2: subscriber too slow, disconnected")
+
+// Topic fans out published messages to its subscribers.
+// Each subscriber has its own buffer and BufferPolicy,
+// so one slow consumer can't stall the publishing of the others.
+type Topic struct {
+	name string
+
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewTopic creates a Topic with no subscribers.
+func NewTopic(name string) *Topic {
+	return &Topic{
+		name: name,
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Name of the topic.
+func (t *Topic) Name() string {
+	return t.name
+}
+
+// Subscribe adds a subscriber buffering up to buffer messages,
+// handling overflows with policy.
+func (t *Topic) Subscribe(buffer int, policy BufferPolicy) *Subscription {
+	sub := &Subscription{
+		topic:  t,
+		ch:     make(chan json.RawMessage, buffer),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+
+	t.mu.Lock()
+	t.subs[sub] = struct{}{}
+	t.mu.Unlock()
+
+	return sub
+}
+
+// Publish marshals v once and delivers it to every subscriber,
+// according to their BufferPolicy.
+func (t *Topic) Publish(v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	subs := make([]*Subscription, 0, len(t.subs))
+	for sub := range t.subs {
+		subs = append(subs, sub)
+	}
+	t.mu.RUnlock()
+
+	for _, sub := range subs {
+		sub.deliver(msg)
+	}
+	return nil
+}
+
+// Subscribers returns the number of current subscribers.
+func (t *Topic) Subscribers() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.subs)
+}
+
+// Subscription is a subscriber of a Topic.
+type Subscription struct {
+	topic  *Topic
+	ch     chan json.RawMessage
+	policy BufferPolicy
+
+	mu      sync.RWMutex // held for writing only to close ch
+	done    chan struct{}
+	once    sync.Once
+	err     error
+	dropped atomic.Int64
+}
+
+// C returns the channel of messages. It's closed when the subscription is closed.
+func (s *Subscription) C() <-chan json.RawMessage {
+	return s.ch
+}
+
+// Dropped returns how many messages were discarded by the DropOldest policy.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Err returns why the subscription was closed: nil for Close,
+// ErrSlowSubscriber for the Disconnect policy.
+func (s *Subscription) Err() error {
+	<-s.done
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
+
+// Close unsubscribes. Buffered messages are discarded.
+func (s *Subscription) Close() {
+	s.close(nil)
+}
+
+func (s *Subscription) close(err error) {
+	s.once.Do(func() {
+		s.topic.mu.Lock()
+		delete(s.topic.subs, s)
+		s.topic.mu.Unlock()
+
+		close(s.done) // unblocks a Block-ing deliver, which holds mu.RLock
+
+		s.mu.Lock()
+		s.err = err
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+// deliver msg to the subscriber following its policy.
+func (s *Subscription) deliver(msg json.RawMessage) {
+	s.mu.RLock()
+
+	select {
+	case <-s.done:
+		s.mu.RUnlock()
+		return
+	default:
+	}
+
+	switch s.policy {
+	case Block:
+		select {
+		case s.ch <- msg:
+		case <-s.done:
+		}
+	case Disconnect:
+		select {
+		case s.ch <- msg:
+		default:
+			s.mu.RUnlock()
+			s.close(ErrSlowSubscriber)
+			return
+		}
+	default: // DropOldest
+		if cap(s.ch) == 0 { // nothing to drop: the new message is the oldest
+			select {
+			case s.ch <- msg:
+			default:
+				s.dropped.Add(1)
+			}
+			break
+		}
+		for {
+			select {
+			case s.ch <- msg:
+				s.mu.RUnlock()
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	}
+
+	s.mu.RUnlock()
+}
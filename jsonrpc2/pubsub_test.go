@@ -0,0 +1,88 @@
+package jsonrpc2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopic_DropOldest(t *testing.T) {
+	topic := NewTopic("ticks")
+	sub := topic.Subscribe(2, DropOldest)
+
+	for i := 1; i <= 5; i++ {
+		if err := topic.Publish(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := sub.Dropped(); got != 3 {
+		t.Errorf("Dropped() = %d, want 3", got)
+	}
+	for _, want := range []string{"4", "5"} {
+		if got := string(<-sub.C()); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestTopic_Disconnect(t *testing.T) {
+	topic := NewTopic("ticks")
+	slow := topic.Subscribe(1, Disconnect)
+	fast := topic.Subscribe(10, Disconnect)
+
+	for i := 1; i <= 3; i++ {
+		if err := topic.Publish(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := slow.Err(); err != ErrSlowSubscriber {
+		t.Errorf("slow.Err() = %v, want ErrSlowSubscriber", err)
+	}
+	if n := topic.Subscribers(); n != 1 {
+		t.Errorf("Subscribers() = %d, want 1", n)
+	}
+	if got := len(fast.C()); got != 3 {
+		t.Errorf("fast subscriber got %d messages, want 3", got)
+	}
+
+	// the channel of a disconnected subscriber is closed
+	for range slow.C() {
+	}
+}
+
+func TestTopic_Block(t *testing.T) {
+	topic := NewTopic("ticks")
+	sub := topic.Subscribe(1, Block)
+
+	published := make(chan struct{})
+	go func() {
+		for i := 1; i <= 3; i++ {
+			_ = topic.Publish(i)
+		}
+		close(published)
+	}()
+
+	for _, want := range []string{"1", "2", "3"} {
+		select {
+		case got := <-sub.C():
+			if string(got) != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	<-published
+
+	// Close unblocks a blocked publisher
+	_ = topic.Publish(4)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		sub.Close()
+	}()
+	_ = topic.Publish(5)
+	if err := sub.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
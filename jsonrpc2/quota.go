@@ -0,0 +1,189 @@
+package jsonrpc2
+
+// 这个文件实现配额 (quota)：每个身份 (identity，或 tenant) 对每个方法有一份请求预算，
+// 如每天 1000 次，按令牌桶 (token bucket) 持续补充。
+// 配额由 Server.Use 的中间件 (Quotas.Middleware) 执行，用量记在可替换的 QuotaStore 里，
+// 如多个服务端实例共享的外部存储。配额用尽的请求以 ErrQuotaExceeded 拒绝，其 Data 带有配额状态。
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Quota is a budget of requests: Limit requests per Period, e.g. 1000 per
+// 24 hours. It's a token bucket of Limit tokens, refilled continuously, so
+// unused requests don't pile up beyond Limit.
+// A Quota of Limit <= 0 is no quota: requests are not limited.
+type Quota struct {
+	Limit  int64
+	Period time.Duration
+}
+
+// QuotaStatus tells the state of a quota after a request took from it.
+// It's in the Data of ErrQuotaExceeded.
+type QuotaStatus struct {
+	Limit      int64         `json:"limit"`
+	Remaining  int64         `json:"remaining"` // requests left right now
+	RetryAfter time.Duration `json:"-"`         // until a request is allowed again, if exceeded
+}
+
+// QuotaStore keeps the token buckets of the quotas, by key.
+// Implementations must be safe for concurrent use, and take atomically.
+type QuotaStore interface {
+	// Take a token from the bucket of key for quota q at now, telling
+	// whether there was one. A bucket not seen yet is full.
+	Take(key string, q Quota, now time.Time) (QuotaStatus, bool)
+}
+
+// Quotas enforces the quotas of the callers to the methods of a Server:
+//
+//	q := &jsonrpc2.Quotas{Methods: map[string]jsonrpc2.Quota{"search": {Limit: 1000, Period: 24 * time.Hour}}}
+//	s.Use(q.Middleware())
+//
+// Callers are told apart by their identity (see IdentityFromContext), else
+// their tenant (see TenantFromContext). Requests of neither, and of the
+// built-in methods, are not limited.
+type Quotas struct {
+	Methods map[string]Quota // by method
+	Default Quota            // of the methods not in Methods, none by default
+
+	Store QuotaStore // nil means a MemoryQuotaStore of the Quotas
+	Clock Clock      // nil means SystemClock
+
+	once  sync.Once
+	store QuotaStore
+}
+
+// Middleware returns the middleware enforcing the quotas, for Server.Use.
+// Requests over their quota are answered with ErrQuotaExceeded, whose Data
+// carries the QuotaStatus and a retry_after_ms hint.
+func (q *Quotas) Middleware() func(next HandlerFunc) HandlerFunc {
+	q.once.Do(func() {
+		q.store = q.Store
+		if q.store == nil {
+			q.store = NewMemoryQuotaStore()
+		}
+	})
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) *Response {
+			quota := q.quota(req.Method)
+			caller, ok := quotaCaller(ctx)
+			if quota.Limit <= 0 || !ok || isReserved(req.Method) {
+				return next(ctx, req)
+			}
+
+			status, ok := q.store.Take(caller+"\x00"+req.Method, quota, clockOrSystem(q.Clock).Now())
+			if !ok {
+				rpcErr := ErrQuotaExceeded().withRetryAfter(
+					fmt.Sprintf("quota of %s exceeded: %d requests per %v", req.Method, quota.Limit, quota.Period),
+					status.RetryAfter)
+				rpcErr.withDataField("limit", status.Limit).withDataField("remaining", status.Remaining)
+				return errorResponse(req.Id, rpcErr)
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// quota of method.
+func (q *Quotas) quota(method string) Quota {
+	if quota, ok := q.Methods[method]; ok {
+		return quota
+	}
+	return q.Default
+}
+
+// quotaCaller tells whose quota a request of ctx takes from.
+func quotaCaller(ctx context.Context) (string, bool) {
+	if identity, ok := IdentityFromContext(ctx); ok {
+		return "identity:" + fmt.Sprint(identity), true
+	}
+	if tenant, ok := TenantFromContext(ctx); ok {
+		return "tenant:" + tenant, true
+	}
+	return "", false
+}
+
+// MemoryQuotaStore is a QuotaStore keeping the buckets in memory,
+// for a single server. Full buckets are forgotten from time to time.
+type MemoryQuotaStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	nextSweep int // size of buckets to sweep the full ones at
+}
+
+// tokenBucket is a bucket of a MemoryQuotaStore.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // when tokens was counted
+	quota  Quota
+}
+
+// minQuotaSweep is the size of buckets of a MemoryQuotaStore to start sweeping at.
+const minQuotaSweep = 1024
+
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{
+		buckets:   make(map[string]*tokenBucket),
+		nextSweep: minQuotaSweep,
+	}
+}
+
+func (m *MemoryQuotaStore) Take(key string, q Quota, now time.Time) (QuotaStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		if len(m.buckets) >= m.nextSweep {
+			m.sweep(now)
+		}
+		b = &tokenBucket{tokens: float64(q.Limit), last: now}
+		m.buckets[key] = b
+	}
+	b.quota = q
+	b.refill(now)
+
+	taken := b.tokens >= 1
+	if taken {
+		b.tokens--
+	}
+	status := QuotaStatus{Limit: q.Limit, Remaining: int64(b.tokens)}
+	if !taken {
+		status.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / b.rate()))
+	}
+	return status, taken
+}
+
+// sweep forgets the full buckets, which are the same as new ones.
+func (m *MemoryQuotaStore) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if b.refill(now); b.tokens >= float64(b.quota.Limit) {
+			delete(m.buckets, key)
+		}
+	}
+	m.nextSweep = 2 * len(m.buckets)
+	if m.nextSweep < minQuotaSweep {
+		m.nextSweep = minQuotaSweep
+	}
+}
+
+// rate of refilling, in tokens per nanosecond.
+func (b *tokenBucket) rate() float64 {
+	if b.quota.Period <= 0 {
+		return math.Inf(1)
+	}
+	return float64(b.quota.Limit) / float64(b.quota.Period)
+}
+
+// refill the tokens for the time passed until now.
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(b.quota.Limit), b.tokens+float64(elapsed)*b.rate())
+		b.last = now
+	}
+}
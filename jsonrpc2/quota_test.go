@@ -0,0 +1,85 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func Test_Quotas(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	q := &Quotas{
+		Methods: map[string]Quota{"search": {Limit: 2, Period: time.Minute}},
+		Clock:   clock,
+	}
+	s := NewServer().Use(q.Middleware())
+	s.MustRegister("search", func(arg string) (string, error) { return arg, nil })
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+
+	alice := WithIdentity(context.Background(), "alice")
+	bob := WithTenant(context.Background(), "bob")
+	id := int64(1)
+	call := func(ctx context.Context, method string) *Response {
+		return s.ServeRPC(ctx, &Request{JsonRpc: JsonRpc2, Method: method, Params: json.RawMessage(`"x"`), Id: Int64ID(id)})
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := call(alice, "search"); resp.Error != nil {
+			t.Fatalf("❌ call %d within the quota: %v", i, resp.Error)
+		}
+	}
+
+	resp := call(alice, "search")
+	if resp.Error == nil || resp.Error.Code != ErrQuotaExceeded().Code {
+		t.Fatalf("❌ want ErrQuotaExceeded over the quota, got %+v", resp)
+	}
+	var data struct {
+		Limit        int64 `json:"limit"`
+		Remaining    int64 `json:"remaining"`
+		RetryAfterMs int64 `json:"retry_after_ms"`
+	}
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil {
+		t.Fatalf("❌ bad error data %s: %v", resp.Error.Data, err)
+	}
+	if data.Limit != 2 || data.Remaining != 0 || data.RetryAfterMs != 30000 {
+		t.Errorf("❌ got error data %s, want limit 2, remaining 0 and retry_after_ms 30000", resp.Error.Data)
+	}
+
+	// other callers, other methods and unknown callers have their own budgets
+	if resp := call(bob, "search"); resp.Error != nil {
+		t.Errorf("❌ another caller is limited: %v", resp.Error)
+	}
+	if resp := call(alice, "echo"); resp.Error != nil {
+		t.Errorf("❌ a method without quota is limited: %v", resp.Error)
+	}
+	for i := 0; i < 3; i++ {
+		if resp := call(context.Background(), "search"); resp.Error != nil {
+			t.Errorf("❌ a request of no caller is limited: %v", resp.Error)
+		}
+	}
+	if resp := call(alice, MethodHealth); resp.Error != nil {
+		t.Errorf("❌ a built-in method is limited: %v", resp.Error)
+	}
+
+	clock.Advance(30 * time.Second)
+	if resp := call(alice, "search"); resp.Error != nil {
+		t.Errorf("❌ the quota is not refilled: %v", resp.Error)
+	}
+	if resp := call(alice, "search"); resp.Error == nil {
+		t.Errorf("❌ the quota is refilled beyond the time passed")
+	}
+}
+
+func TestMemoryQuotaStore_sweep(t *testing.T) {
+	m := NewMemoryQuotaStore()
+	now := time.Unix(0, 0)
+	quota := Quota{Limit: 1, Period: time.Second}
+	for i := 0; i < minQuotaSweep; i++ {
+		m.Take(string(rune('a'+i%26))+time.Duration(i).String(), quota, now)
+	}
+	m.Take("full", quota, now.Add(time.Second))
+	if len(m.buckets) != 1 {
+		t.Errorf("❌ got %d buckets after the sweep, want 1", len(m.buckets))
+	}
+}
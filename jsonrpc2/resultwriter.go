@@ -0,0 +1,242 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// ResultWriter is where a streaming method writes its result.
+//
+// A method taking a ResultWriter as its last parameter and returning only
+// an error is a streaming method:
+//
+//	func(arg *Arg, w jsonrpc2.ResultWriter) error
+//	func(ctx context.Context, arg *Arg, w jsonrpc2.ResultWriter) error
+//
+// It writes the JSON text of its result in as many chunks as it likes,
+// e.g. "[", item, ",", item, "]", so very large results (reports, exports)
+// never sit in memory as a whole. The transports that can (HttpServerTransport)
+// send the chunks to the client as they're written; others collect them
+// into the Result of an ordinary Response.
+//
+// If the method fails before writing anything, an ordinary error response is sent.
+// Once something is written, the response can no longer turn into an error:
+// the transport aborts the response instead, and the client sees a broken body.
+type ResultWriter interface {
+	io.Writer
+	// Flush sends what's written so far to the client, if the transport can.
+	Flush() error
+}
+
+var resultWriterInterface = reflect.TypeOf((*ResultWriter)(nil)).Elem()
+
+// streamMethod is the handler of a streaming method.
+type streamMethod struct {
+	function reflect.Value
+	inType   reflect.Type
+	withCtx  bool
+}
+
+// newStreamMethod makes a streamMethod of f if f is a streaming method.
+func newStreamMethod(f any) (*streamMethod, bool) {
+	fv := reflect.ValueOf(f)
+	if f == nil || fv.Kind() != reflect.Func {
+		return nil, false
+	}
+	ft := fv.Type()
+
+	errorInterface := reflect.TypeOf((*error)(nil)).Elem()
+	if ft.NumOut() != 1 || ft.Out(0) != errorInterface {
+		return nil, false
+	}
+
+	switch {
+	case ft.NumIn() == 2 && ft.In(1) == resultWriterInterface:
+		return &streamMethod{function: fv, inType: ft.In(0)}, true
+	case ft.NumIn() == 3 && ft.In(0) == contextInterface && ft.In(2) == resultWriterInterface:
+		return &streamMethod{function: fv, inType: ft.In(1), withCtx: true}, true
+	}
+	return nil, false
+}
+
+func (m *streamMethod) streams() {}
+
+func (m *streamMethod) serve(ctx context.Context, req *Request) (res *Response, err error) {
+	if req == nil {
+		return errorResponse(nil, ErrInvalidRequest().WithReason("nil request")), errors.New("nil request")
+	}
+
+	res = &Response{
+		JsonRpc: JsonRpc2,
+		Id:      req.Id,
+	}
+
+	if gobFromContext(ctx) {
+		res.Error = ErrInvalidRequest().WithReason(errGobUnsupported.Error())
+		return res, errGobUnsupported
+	}
+
+	timer := phaseTimerFromContext(ctx)
+	timer.start()
+	param, err := req.unmarshalParamContext(ctx, m.inType)
+	if err == nil {
+		err = validateParams(param)
+	}
+	timer.done(phaseDecode)
+	if err != nil {
+		res.Error = ErrInvalidParams().WithReason(err.Error())
+		return
+	}
+	// the result is marshaled as it's written, timed as the call
+	defer timer.done(phaseCall)
+
+	return serveResultWriter(ctx, req, res, func(w ResultWriter) error {
+		return m.call(ctx, param, w)
+	})
+}
+
+// serveResultWriter makes res the response of req, whose result call
+// writes: streamed by the resultSink of ctx, if any, else buffered.
+func serveResultWriter(ctx context.Context, req *Request, res *Response, call func(w ResultWriter) error) (*Response, error) {
+	sink, streaming := resultSinkFromContext(ctx)
+	if !streaming {
+		var buf bytes.Buffer
+		if err := call(&bufferResultWriter{&buf}); err != nil {
+			res.Error = methodError(err)
+			return res, err
+		}
+		if buf.Len() == 0 {
+			buf.WriteString("null")
+		}
+		if !json.Valid(buf.Bytes()) {
+			me := newResultMarshalError(req.Method, json.RawMessage(buf.Bytes()), errors.New("invalid JSON result"))
+			res.Error = me.rpcError()
+			return res, me
+		}
+		res.Result = buf.Bytes()
+		return res, nil
+	}
+
+	w := sink.open(req.Id)
+	err := call(w)
+	if w.finish(err) {
+		return res, err // the response is sent, or broken, by the writer
+	}
+	res.Error = methodError(err)
+	return res, err
+}
+
+// call the function, recovering panics like method.call.
+func (m *streamMethod) call(ctx context.Context, param reflect.Value, w ResultWriter) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r}
+		}
+	}()
+
+	in := []reflect.Value{param, reflect.ValueOf(&w).Elem()}
+	if m.withCtx {
+		in = append([]reflect.Value{reflect.ValueOf(&ctx).Elem()}, in...)
+	}
+	out := m.function.Call(in)
+	if e := out[0].Interface(); e != nil {
+		return e.(error)
+	}
+	return nil
+}
+
+// bufferResultWriter collects the result in memory.
+type bufferResultWriter struct {
+	*bytes.Buffer
+}
+
+func (bufferResultWriter) Flush() error { return nil }
+
+// resultSink is attached to the ctx by transports able to stream results.
+type resultSink interface {
+	// open a writer for the result of the request id.
+	open(id *ID) streamingResultWriter
+}
+
+type streamingResultWriter interface {
+	ResultWriter
+	// finish the response after the method returned err. It returns whether
+	// the response is taken care of (sent, or broken); if not, the caller
+	// should make an error response.
+	finish(err error) (handled bool)
+}
+
+type resultSinkKey struct{}
+
+// withResultSink returns a copy of ctx carrying sink. A nil sink disables streaming.
+func withResultSink(ctx context.Context, sink resultSink) context.Context {
+	return context.WithValue(ctx, resultSinkKey{}, sink)
+}
+
+func resultSinkFromContext(ctx context.Context) (resultSink, bool) {
+	sink, ok := ctx.Value(resultSinkKey{}).(resultSink)
+	return sink, ok && sink != nil
+}
+
+// httpResultSink streams a result into an http.ResponseWriter.
+// It's good for one response.
+type httpResultSink struct {
+	w       http.ResponseWriter
+	id      *ID
+	started bool // something is written
+	broken  bool // the method failed after something was written
+}
+
+func (s *httpResultSink) open(id *ID) streamingResultWriter {
+	s.id = id
+	return s
+}
+
+func (s *httpResultSink) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+
+	id, err := json.Marshal(s.id)
+	if err != nil {
+		return err
+	}
+	s.w.Header().Set("Content-Type", "application/json")
+	_, err = fmt.Fprintf(s.w, `{"jsonrpc":%q,"id":%s,"result":`, JsonRpc2, id)
+	return err
+}
+
+func (s *httpResultSink) Write(p []byte) (int, error) {
+	if err := s.start(); err != nil {
+		return 0, err
+	}
+	return s.w.Write(p)
+}
+
+func (s *httpResultSink) Flush() error {
+	if f, ok := s.w.(http.Flusher); ok && s.started {
+		f.Flush()
+	}
+	return nil
+}
+
+func (s *httpResultSink) finish(err error) bool {
+	switch {
+	case err != nil && !s.started:
+		return false
+	case err != nil:
+		s.broken = true
+		return true
+	case !s.started:
+		_, _ = s.Write([]byte("null"))
+	}
+	_, _ = io.WriteString(s.w, "}\n")
+	return true
+}
@@ -0,0 +1,132 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// export writes [0, 1, ..., n-1] in chunks, failing after failAt items if failAt >= 0.
+func newStreamTestServer(t *testing.T) Server {
+	s := NewServer()
+	s.MustRegister("export", func(ctx context.Context, arg *struct{ N, FailAt int }, w ResultWriter) error {
+		if arg.FailAt == 0 {
+			return errors.New("export failed")
+		}
+		io.WriteString(w, "[")
+		for i := 0; i < arg.N; i++ {
+			if i == arg.FailAt {
+				return errors.New("export failed")
+			}
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprint(w, i)
+			w.Flush()
+		}
+		io.WriteString(w, "]")
+		return nil
+	})
+	s.MustRegister("nothing", func(arg int, w ResultWriter) error { return nil })
+	return s
+}
+
+func Test_streamMethod(t *testing.T) {
+	s := newStreamTestServer(t)
+
+	// without a streaming transport the result is buffered
+	tests := []struct {
+		name     string
+		params   string
+		wantRes  string
+		wantCode int
+	}{
+		{"buffered", `{"N": 3, "FailAt": -1}`, `[0,1,2]`, 0},
+		{"failBeforeWrite", `{"N": 3, "FailAt": 0}`, ``, -1},
+		{"failAfterWrite", `{"N": 3, "FailAt": 2}`, ``, -1},
+		{"badParams", `"x"`, ``, ErrInvalidParams().Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "export", Params: []byte(tt.params), Id: Int64ID(1)})
+			if tt.wantCode != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.wantCode {
+					t.Errorf("❌ want error %d, got %#v", tt.wantCode, resp.Error)
+				}
+				return
+			}
+			if resp.Error != nil || string(resp.Result) != tt.wantRes {
+				t.Errorf("❌ got %s (%v), want %s", resp.Result, resp.Error, tt.wantRes)
+			}
+		})
+	}
+
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "nothing", Params: []byte(`1`), Id: Int64ID(1)})
+	if string(resp.Result) != "null" {
+		t.Errorf("❌ empty result should be null, got %s", resp.Result)
+	}
+}
+
+func Test_HttpServerTransport_stream(t *testing.T) {
+	st := NewHttpServerTransport("")
+	st.Use(newStreamTestServer(t))
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	doPost := func(body string) (string, error) {
+		resp, err := http.Post(ts.URL, "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(bufio.NewReader(resp.Body))
+		return string(bytes.TrimSpace(b)), err
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{"stream",
+			`{"jsonrpc": "2.0", "method": "export", "params": {"N": 3, "FailAt": -1}, "id": 1}`,
+			`{"jsonrpc":"2.0","id":1,"result":[0,1,2]}`, false},
+		{"nothing",
+			`{"jsonrpc": "2.0", "method": "nothing", "params": 1, "id": 2}`,
+			`{"jsonrpc":"2.0","id":2,"result":null}`, false},
+		{"failBeforeWrite",
+			`{"jsonrpc": "2.0", "method": "export", "params": {"N": 3, "FailAt": 0}, "id": 3}`,
+			`{"jsonrpc":"2.0","error":{"code":-1,"message":"export failed"},"id":3}`, false},
+		{"failAfterWrite",
+			`{"jsonrpc": "2.0", "method": "export", "params": {"N": 3, "FailAt": 2}, "id": 4}`,
+			``, true},
+		{"batchIsBuffered",
+			`[{"jsonrpc": "2.0", "method": "export", "params": {"N": 2, "FailAt": -1}, "id": 5}]`,
+			`[{"jsonrpc":"2.0","result":[0,1],"id":5}]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := doPost(tt.body)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("❌ want a broken response, got %s", got)
+				} else {
+					t.Logf("✅ broken: %v", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("❌\ngot  = %s (%v)\nwant = %s\n", got, err, tt.want)
+			} else {
+				t.Logf("✅ got  = %s\n", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package jsonrpc2
+
+import "syscall"
+
+// setReusePort fails: the system has no SO_REUSEPORT.
+func setReusePort(network, address string, c syscall.RawConn) error {
+	return errReusePortUnsupported
+}
@@ -0,0 +1,225 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a (subset of) JSON Schema, describing the params or result of a method.
+//
+// Fields of objects are never required: missing fields decode as zero values.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"` // empty means any value
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	PrefixItems          []*Schema          `json:"prefixItems,omitempty"` // of the first items, by position
+	Description          string             `json:"description,omitempty"` // see WithParamDoc
+}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	timeType       = reflect.TypeOf(time.Time{})
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaOf returns the Schema of the JSON encoding of values of type t,
+// as encoding/json does it. nil t means unknown: any value.
+func schemaOf(t reflect.Type) *Schema {
+	return schemaOfType(t, make(map[reflect.Type]bool))
+}
+
+// schemaOfType is schemaOf, with the struct types being described in
+// visiting, to stop at recursive types.
+func schemaOfType(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType, t.Implements(marshalerType), reflect.PointerTo(t).Implements(marshalerType):
+		return &Schema{} // anything it likes
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"} // base64
+		}
+		return &Schema{Type: "array", Items: schemaOfType(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOfType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return &Schema{Type: "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addStructFields(s, t, visiting)
+		return s
+	}
+	return &Schema{}
+}
+
+// addStructFields adds the fields of struct type t to s, like encoding/json
+// (roughly: names from json tags, embedded structs flattened).
+func addStructFields(s *Schema, t reflect.Type, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			addStructFields(s, ft, visiting)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = schemaOfType(f.Type, visiting)
+	}
+}
+
+// Validate checks that the JSON value data matches s, as far as decoding
+// it into the described type would succeed. null matches anything, like
+// encoding/json decodes it into zero values; unknown fields of objects are
+// ignored.
+//
+// The error tells where the mismatch is, e.g. `params.points[1].x: want integer, got "1"`.
+func (s *Schema) Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	return s.validate("params", v)
+}
+
+func (s *Schema) validate(path string, v any) error {
+	if s == nil || s.Type == "" || v == nil {
+		return nil
+	}
+
+	mismatch := func() error {
+		got, _ := json.Marshal(v)
+		if len(got) > 32 {
+			got = append(got[:29], "..."...)
+		}
+		return fmt.Errorf("%s: want %s, got %s", path, s.Type, got)
+	}
+
+	switch s.Type {
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch()
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return mismatch()
+		}
+		if _, err := n.Int64(); err != nil {
+			if _, err := strconv.ParseUint(n.String(), 10, 64); err != nil {
+				return mismatch()
+			}
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return mismatch()
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return mismatch()
+		}
+		switch s.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%s: want date-time (RFC 3339), got %q", path, str)
+			}
+		case "byte":
+			if _, err := base64.StdEncoding.DecodeString(str); err != nil {
+				return fmt.Errorf("%s: want base64, got %q", path, str)
+			}
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return mismatch()
+		}
+		for i, item := range items {
+			sub := s.Items
+			if i < len(s.PrefixItems) {
+				sub = s.PrefixItems[i]
+			}
+			if err := sub.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		for key, value := range obj {
+			sub := s.AdditionalProperties
+			if p, ok := s.property(key); ok {
+				sub = p
+			}
+			if err := sub.validate(path+"."+key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// property finds the property of s for the object key, matching names
+// case-insensitively as encoding/json does.
+func (s *Schema) property(key string) (*Schema, bool) {
+	if p, ok := s.Properties[key]; ok {
+		return p, true
+	}
+	for name, p := range s.Properties {
+		if strings.EqualFold(name, key) {
+			return p, true
+		}
+	}
+	return nil, false
+}
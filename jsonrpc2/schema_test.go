@@ -0,0 +1,56 @@
+package jsonrpc2
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchema_Validate(t *testing.T) {
+	type Point struct {
+		X, Y int
+	}
+	type Shape struct {
+		Name   string            `json:"name"`
+		Points []Point           `json:"points"`
+		Scale  float64           `json:"scale"`
+		Tags   map[string]bool   `json:"tags"`
+		At     time.Time         `json:"at"`
+		Raw    []byte            `json:"raw"`
+		Extra  map[string]string `json:"-"`
+	}
+	s := schemaOf(reflect.TypeOf(&Shape{}))
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"ok", `{"name": "tri", "points": [{"X": 1, "Y": 2}], "scale": 1.5, "tags": {"a": true}, "at": "2023-01-02T15:04:05Z", "raw": "AQI="}`, ``},
+		{"null", `null`, ``},
+		{"nullFields", `{"name": null, "points": [null]}`, ``},
+		{"unknownField", `{"color": "red"}`, ``},
+		{"caseInsensitive", `{"NAME": "tri", "points": [{"x": 1}]}`, ``},
+		{"notObject", `[1]`, `params: want object, got [1]`},
+		{"badItem", `{"points": [{"X": 1}, {"X": "1"}]}`, `params.points[1].X: want integer, got "1"`},
+		{"fraction", `{"points": [{"Y": 1.5}]}`, `params.points[0].Y: want integer, got 1.5`},
+		{"badMapValue", `{"tags": {"a": 1}}`, `params.tags.a: want boolean, got 1`},
+		{"badTime", `{"at": "yesterday"}`, `params.at: want date-time (RFC 3339), got "yesterday"`},
+		{"badBase64", `{"raw": "!!"}`, `params.raw: want base64, got "!!"`},
+		{"badJSON", `{`, `unexpected EOF`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate([]byte(tt.data))
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("❌ Validate(%s) = %q, want %q", tt.data, got, tt.wantErr)
+			} else {
+				t.Logf("✅ Validate(%s) = %q", tt.data, got)
+			}
+		})
+	}
+}
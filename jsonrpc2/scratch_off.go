@@ -0,0 +1,33 @@
+//go:build !naiverpc_scratch
+
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// scratchBuffers tells whether the buffers of the hot path are reused,
+// see scratch_on.go.
+const scratchBuffers = false
+
+// scratch is a buffer, allocated for each use.
+type scratch struct {
+	bytes.Buffer
+}
+
+func getScratch() *scratch {
+	return &scratch{}
+}
+
+func putScratch(*scratch) {}
+
+// encodeJSON writes v to w by a json.Encoder, indented if pretty.
+func encodeJSON(w io.Writer, v any, pretty bool) error {
+	enc := json.NewEncoder(w)
+	if pretty {
+		enc.SetIndent("", prettyIndent)
+	}
+	return enc.Encode(v)
+}
@@ -0,0 +1,67 @@
+//go:build naiverpc_scratch
+
+package jsonrpc2
+
+// 这个文件在构建标签 naiverpc_scratch 下 (go build -tags naiverpc_scratch) 复用 HTTP 服务端
+// 热路径上的临时缓冲区：读请求体、编码响应的缓冲区取自 sync.Pool，用完归还，而不是每个请求
+// 各分配一次，以减少小请求下的分配与 GC 压力。请求体解析后即不再被引用 (Params 等是复制出来的)，
+// 故可在响应写完后归还。Request 与 Response 本身不复用：超时的方法在响应之后仍可能持有它们。
+// 对比：go test -bench HttpServerTransport_ServeHTTP [-tags naiverpc_scratch]
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// scratchBuffers tells whether the buffers of the hot path are reused,
+// see scratch_on.go.
+const scratchBuffers = true
+
+// maxScratch is the largest buffer kept for reuse: a larger one, of a
+// large request or response, is left to the GC not to pin its memory.
+const maxScratch = 64 << 10
+
+// scratch is a buffer reused, with an encoder writing to it.
+type scratch struct {
+	bytes.Buffer
+	enc *json.Encoder
+}
+
+var scratchPool = sync.Pool{New: func() any {
+	s := &scratch{}
+	s.enc = json.NewEncoder(&s.Buffer)
+	return s
+}}
+
+func getScratch() *scratch {
+	return scratchPool.Get().(*scratch)
+}
+
+// putScratch gives s back for reuse: nothing must refer to its bytes
+// anymore.
+func putScratch(s *scratch) {
+	if s.Cap() > maxScratch {
+		return
+	}
+	s.Reset()
+	scratchPool.Put(s)
+}
+
+// encodeJSON writes v to w as json.Encoder does, indented if pretty, by
+// a single Write from a scratch buffer.
+func encodeJSON(w io.Writer, v any, pretty bool) error {
+	s := getScratch()
+	defer putScratch(s)
+	indent := ""
+	if pretty {
+		indent = prettyIndent
+	}
+	s.enc.SetIndent("", indent)
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(s.Bytes())
+	return err
+}
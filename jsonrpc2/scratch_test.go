@@ -0,0 +1,68 @@
+package jsonrpc2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Test_HttpServerTransport_scratch serves requests of all sizes at once,
+// for the buffers reused (-tags naiverpc_scratch) to be seen mixed up.
+func Test_HttpServerTransport_scratch(t *testing.T) {
+	t.Logf("scratch buffers: %v", scratchBuffers)
+	s := NewServer()
+	s.MustRegister("echo", Typed(func(arg string) (string, error) { return arg, nil }))
+	st := &HttpServerTransport{}
+	st.Use(s)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			arg := strings.Repeat(strconv.Itoa(i%10), i*i*32) // up to beyond maxScratch
+			for _, body := range []string{
+				`{"jsonrpc":"2.0","method":"echo","params":"` + arg + `","id":` + strconv.Itoa(i) + `}`,
+				`[{"jsonrpc":"2.0","method":"echo","params":"` + arg + `","id":` + strconv.Itoa(i) + `}]`,
+			} {
+				w := httptest.NewRecorder()
+				st.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+				want := `{"jsonrpc":"2.0","result":"` + arg + `","id":` + strconv.Itoa(i) + `}`
+				if body[0] == '[' {
+					want = "[" + want + "]"
+				}
+				if got := strings.TrimSpace(w.Body.String()); got != want {
+					t.Errorf("❌ response %d: got %d bytes, want %d", i, len(got), len(want))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Benchmark_HttpServerTransport_ServeHTTP compares the allocations of the
+// hot path, without and with -tags naiverpc_scratch.
+func Benchmark_HttpServerTransport_ServeHTTP(b *testing.B) {
+	s := NewServer()
+	s.MustRegister("echo", Typed(func(arg string) (string, error) { return arg, nil }))
+	st := &HttpServerTransport{}
+	st.Use(s)
+
+	for _, size := range []int{16, 4 << 10} {
+		arg := strings.Repeat("a", size)
+		body := `{"jsonrpc":"2.0","method":"echo","params":"` + arg + `","id":1}`
+		b.Run("params="+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				w := httptest.NewRecorder()
+				for pb.Next() {
+					w.Body.Reset()
+					st.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,531 @@
+package jsonrpc2
+
+// 这个文件实现流式结果 (server push)：方法通过 Sender 逐个发送部分结果 (如 tail 日志的每一行)，
+// 而不是一次返回整个结果。
+//
+// 结果是所发送的值组成的 JSON 数组，所以普通的 Call 也能调用这种方法。
+// 客户端用 Client.CallStream 在值到达时逐个处理：
+//   - HTTP: 结果数组以 chunked 的响应体边写边发 (见 resultwriter.go)，客户端增量解析；
+//   - TCP / Unix / WebSocket: 请求带上 MetaPartialResults 时，每个值作为一个 MethodPartial 通知发送，
+//     最后的响应结果为 null。
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Sender sends the values of a streaming result, see Streaming.
+// It's safe for concurrent use.
+type Sender[R any] interface {
+	// Send v to the client. It fails once the call is over, e.g. the
+	// client is gone: the method should return then.
+	Send(v R) error
+}
+
+// StreamFunc is a method function sending its result as a sequence of
+// values, e.g. the lines of a log being tailed:
+//
+//	s.Register("tail", jsonrpc2.Streaming(tail)) // tail: func(*TailArg, jsonrpc2.Sender[string]) error
+//
+// The result is the JSON array of the values sent, delivered value by
+// value by the transports that can (see Client.CallStream), all at once by
+// the others. If the method fails after sending something, the client
+// sees a broken stream rather than an error response.
+type StreamFunc[T, R any] func(ctx context.Context, arg T, s Sender[R]) error
+
+// Streaming wraps f as a StreamFunc.
+func Streaming[T, R any](f func(arg T, s Sender[R]) error) StreamFunc[T, R] {
+	return func(ctx context.Context, arg T, s Sender[R]) error {
+		return f(arg, s)
+	}
+}
+
+// StreamingContext wraps f (taking a context.Context) as a StreamFunc.
+func StreamingContext[T, R any](f func(ctx context.Context, arg T, s Sender[R]) error) StreamFunc[T, R] {
+	return f
+}
+
+func (f StreamFunc[T, R]) handler() handler {
+	return &streamingHandler[T, R]{f: f}
+}
+
+// MethodPartial is the notification sending a value of a streaming result
+// to the client, over the stream transports, if the request asks for it
+// by MetaPartialResults. The params is PartialParams.
+const MethodPartial = "rpc.partial"
+
+// MetaPartialResults is the request metadata asking for the values of a
+// streaming result to be sent by MethodPartial notifications, as they
+// come, over the stream transports. The result of the response is null then.
+const MetaPartialResults = "Partial-Results"
+
+// PartialParams is the params of MethodPartial.
+type PartialParams struct {
+	Id    ID              `json:"id"` // of the request
+	Value json.RawMessage `json:"value"`
+}
+
+// streamingHandler is the handler of a StreamFunc.
+type streamingHandler[T, R any] struct {
+	f StreamFunc[T, R]
+}
+
+func (h *streamingHandler[T, R]) streams() {}
+
+func (h *streamingHandler[T, R]) serve(ctx context.Context, req *Request) (res *Response, err error) {
+	if req == nil {
+		return errorResponse(nil, ErrInvalidRequest().WithReason("nil request")), errors.New("nil request")
+	}
+
+	res = &Response{
+		JsonRpc: JsonRpc2,
+		Id:      req.Id,
+	}
+
+	if gobFromContext(ctx) {
+		res.Error = ErrInvalidRequest().WithReason(errGobUnsupported.Error())
+		return res, errGobUnsupported
+	}
+
+	params := req.Params
+	if params == nil { // reflecting on T only then
+		params = paramsOrNull(nil, reflect.TypeOf((*T)(nil)).Elem())
+	}
+	if params == nil {
+		err = errors.New("params should not be nil")
+		res.Error = ErrInvalidParams().WithReason(err.Error())
+		return
+	}
+	timer := phaseTimerFromContext(ctx)
+	timer.start()
+	var arg T
+	err = decodeParams(ctx, params, &arg)
+	if err == nil {
+		err = validateArg(&arg)
+	}
+	timer.done(phaseDecode)
+	if err != nil {
+		res.Error = ErrInvalidParams().WithReason(err.Error())
+		return
+	}
+	// the results are marshaled as they're sent, timed as the call
+	defer timer.done(phaseCall)
+
+	if write, ok := partialSinkFromContext(ctx); ok && !req.IsNotification() && req.Meta[MetaPartialResults] != "" {
+		s := &partialSender[R]{ctx: ctx, id: *req.Id, write: write}
+		if err = h.call(ctx, arg, s); err != nil {
+			res.Error = methodError(err)
+			return
+		}
+		res.Result = json.RawMessage("null")
+		return res, nil
+	}
+
+	return serveResultWriter(ctx, req, res, func(w ResultWriter) error {
+		s := &arraySender[R]{ctx: ctx, w: w}
+		if err := h.call(ctx, arg, s); err != nil {
+			return err
+		}
+		return s.close()
+	})
+}
+
+// call f, recovering panics like method.call.
+func (h *streamingHandler[T, R]) call(ctx context.Context, arg T, s Sender[R]) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r}
+		}
+	}()
+	return h.f(ctx, arg, s)
+}
+
+func (h *streamingHandler[T, R]) signature() (params, result reflect.Type) {
+	return reflect.TypeOf((*T)(nil)).Elem(), reflect.TypeOf((*[]R)(nil)).Elem()
+}
+
+// arraySender writes the values sent as a JSON array to a ResultWriter.
+type arraySender[R any] struct {
+	ctx context.Context
+	w   ResultWriter
+
+	mu sync.Mutex
+	n  int // values sent
+}
+
+func (s *arraySender[R]) Send(v R) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sep := ","
+	if s.n == 0 {
+		sep = "["
+	}
+	if _, err := io.WriteString(s.w, sep); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(value); err != nil {
+		return err
+	}
+	s.n++
+	return s.w.Flush()
+}
+
+// close the array, once the method returned.
+func (s *arraySender[R]) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := "]"
+	if s.n == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(s.w, end)
+	return err
+}
+
+// partialSender sends the values by MethodPartial notifications.
+type partialSender[R any] struct {
+	ctx   context.Context
+	id    ID
+	write func([]byte) error
+}
+
+func (s *partialSender[R]) Send(v R) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	params, err := json.Marshal(PartialParams{Id: s.id, Value: value})
+	if err != nil {
+		return err
+	}
+	msg, err := Request{JsonRpc: JsonRpc2, Method: MethodPartial, Params: params}.toJSON()
+	if err != nil {
+		return err
+	}
+	return s.write(msg)
+}
+
+type partialSinkKey struct{}
+
+// withPartialSink returns a copy of ctx in which the MethodPartial
+// notifications are sent by write, a connection's safe for concurrent use.
+// A nil write disables them.
+func withPartialSink(ctx context.Context, write func([]byte) error) context.Context {
+	return context.WithValue(ctx, partialSinkKey{}, write)
+}
+
+func partialSinkFromContext(ctx context.Context) (func([]byte) error, bool) {
+	write, ok := ctx.Value(partialSinkKey{}).(func([]byte) error)
+	return write, ok && write != nil
+}
+
+// isPartial tells whether the message body is a MethodPartial
+// notification, returning its params.
+func isPartial(body []byte) (*PartialParams, bool) {
+	if !bytes.Contains(body, []byte(MethodPartial)) {
+		return nil, false // the responses, mostly
+	}
+	var msg struct {
+		Method string        `json:"method"`
+		Params PartialParams `json:"params"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Method != MethodPartial {
+		return nil, false
+	}
+	return &msg.Params, true
+}
+
+// StreamingClientTransport is a ClientTransport able to deliver the values
+// of streaming results (see StreamFunc) as they come, for Client.CallStream.
+type StreamingClientTransport interface {
+	ClientTransport
+
+	// SendAndStream sends req, calling each with the values of its result
+	// in order, as they come, and returns the response once it's done.
+	// The Result of the response is not set then. If each fails, the call
+	// is given up, returning its error.
+	SendAndStream(ctx context.Context, req *Request, each func(value json.RawMessage) error) (*Response, error)
+}
+
+// SendAndStream sends req asking for MethodPartial notifications of the
+// values of its result, see StreamingClientTransport.
+func (t *StreamClientTransport) SendAndStream(ctx context.Context, req *Request, each func(value json.RawMessage) error) (*Response, error) {
+	if req.IsNotification() {
+		return nil, errors.New("id should not be nil, use Notify to send a notification")
+	}
+
+	r := *req
+	r.Meta = make(map[string]string, len(req.Meta)+1)
+	for k, v := range req.Meta {
+		r.Meta[k] = v
+	}
+	r.Meta[MetaPartialResults] = "true"
+	reqJson, err := r.toJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.roundTripStream(ctx, *req.Id, reqJson, each)
+}
+
+// partialCall receives the MethodPartial values of a pending call.
+type partialCall struct {
+	values chan json.RawMessage
+	done   chan struct{} // closed once the call stops receiving them
+}
+
+// roundTripStream is roundTrip calling each with the values of the
+// MethodPartial notifications of the call, until its response comes.
+func (c *streamConn) roundTripStream(ctx context.Context, id ID, reqJson []byte, each func(json.RawMessage) error) (*Response, error) {
+	pc := &partialCall{values: make(chan json.RawMessage, 16), done: make(chan struct{})}
+	c.mu.Lock()
+	if c.partials == nil {
+		c.partials = make(map[ID]*partialCall)
+	}
+	if _, dup := c.partials[id]; dup {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("request id %s is already in flight", id)
+	}
+	c.partials[id] = pc
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.partials, id)
+		c.mu.Unlock()
+		close(pc.done)
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp *Response
+		err  error
+	}
+	returned := make(chan result, 1)
+	go func() {
+		resp, err := c.roundTrip(ctx, id, reqJson)
+		returned <- result{resp, err}
+	}()
+
+	for {
+		select {
+		case value := <-pc.values:
+			if err := each(value); err != nil {
+				cancel()
+				return nil, err
+			}
+		case r := <-returned:
+			if r.err != nil {
+				return nil, r.err
+			}
+			// the values are read before the response: those left are buffered
+			for {
+				select {
+				case value := <-pc.values:
+					if err := each(value); err != nil {
+						return nil, err
+					}
+				default:
+					return r.resp, nil
+				}
+			}
+		}
+	}
+}
+
+// deliverPartial hands the value of a MethodPartial notification read to
+// its pending call, if any, blocking the reading until it takes it.
+func (c *streamConn) deliverPartial(p *PartialParams) {
+	c.mu.Lock()
+	pc, ok := c.partials[p.Id]
+	c.mu.Unlock()
+	if !ok {
+		return // of a call given up
+	}
+	select {
+	case pc.values <- p.Value:
+	case <-pc.done:
+	}
+}
+
+// SendAndStream sends req, parsing the values of its result out of the
+// body as it comes, see StreamingClientTransport.
+func (t *HttpClientTransport) SendAndStream(ctx context.Context, req *Request, each func(value json.RawMessage) error) (*Response, error) {
+	reqJson, err := req.toJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Addr, bytes.NewReader(reqJson))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if !browserFetch {
+		httpReq.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	httpResp, err := t.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	var body io.Reader = httpResp.Body
+	encoding := strings.ToLower(httpResp.Header.Get("Content-Encoding"))
+	if browserFetch {
+		encoding = "" // decompressed by the browser already
+	}
+	switch encoding {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(httpResp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("jsonrpc2: bad gzip response body: %w", err)
+		}
+		body = zr
+	default:
+		return nil, fmt.Errorf("jsonrpc2: unsupported response Content-Encoding %q", encoding)
+	}
+
+	resp, err := decodeStreamingResponse(body, each)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: %v", ErrTruncatedResponse, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if meta := readMetaHeaders(httpResp.Header); meta != nil {
+		resp.Meta = meta
+	}
+	return resp, nil
+}
+
+// decodeStreamingResponse decodes the response read from r, calling each
+// with the elements of its result, an array (or null), as they're read.
+// A body ending too early fails with io.ErrUnexpectedEOF.
+func decodeStreamingResponse(r io.Reader, each func(json.RawMessage) error) (resp *Response, err error) {
+	er := &eofReader{r: r}
+	defer func() {
+		if err != nil && er.eof && !errors.Is(err, io.ErrUnexpectedEOF) {
+			err = fmt.Errorf("%w: %v", io.ErrUnexpectedEOF, err)
+		}
+	}()
+	return decodeStreamingBody(json.NewDecoder(er), each)
+}
+
+// eofReader tells whether its reader is read to the end.
+type eofReader struct {
+	r   io.Reader
+	eof bool
+}
+
+func (r *eofReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+func decodeStreamingBody(dec *json.Decoder, each func(json.RawMessage) error) (*Response, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var resp Response
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok {
+		case "result":
+			if err := decodeResultArray(dec, each); err != nil {
+				return nil, err
+			}
+		case "jsonrpc":
+			err = dec.Decode(&resp.JsonRpc)
+		case "id":
+			err = dec.Decode(&resp.Id)
+		case "error":
+			err = dec.Decode(&resp.Error)
+		case "meta":
+			err = dec.Decode(&resp.Meta)
+		default:
+			var skipped json.RawMessage
+			err = dec.Decode(&skipped)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// decodeResultArray decodes the result array (or null) next in dec,
+// calling each with its elements.
+func decodeResultArray(dec *json.Decoder, each func(json.RawMessage) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("jsonrpc2: streaming result should be an array, got %v", tok)
+	}
+	for dec.More() {
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		if err := each(value); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("jsonrpc2: bad response: want %v, got %v", delim, tok)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type tailArg struct {
+	Lines  int
+	FailAt int // fail after FailAt lines, if > 0
+}
+
+// newSenderTestServer serves "tail", sending Lines lines, and "count", not
+// a streaming method.
+func newSenderTestServer() Server {
+	s := NewServer()
+	s.MustRegister("tail", StreamingContext(func(ctx context.Context, arg *tailArg, out Sender[string]) error {
+		for i := 0; i < arg.Lines; i++ {
+			if arg.FailAt > 0 && i == arg.FailAt {
+				return errors.New("log rotated")
+			}
+			if err := out.Send(strings.Repeat("x", i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	s.MustRegister("count", func(arg int) ([]int, error) { return make([]int, arg), nil })
+	return s
+}
+
+// collect the values of a CallStream of method.
+func collect(c Client, method string, arg any) ([]string, error) {
+	var values []string
+	err := c.CallStream(context.Background(), method, arg, func(value json.RawMessage) error {
+		values = append(values, string(value))
+		return nil
+	})
+	return values, err
+}
+
+func Test_Client_CallStream(t *testing.T) {
+	s := newSenderTestServer()
+
+	ht := NewHttpServerTransport("")
+	ht.Use(s)
+	ts := httptest.NewServer(ht)
+	defer ts.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+	tcp := NewTcpClientTransport(l.Addr().String())
+	defer tcp.Close()
+
+	transports := []struct {
+		name      string
+		transport ClientTransport
+	}{
+		{"http", NewHttpClientTransport(ts.URL)},
+		{"tcp", tcp},
+		{"buffered", &serverTransport{server: s}},
+	}
+	for _, tt := range transports {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(tt.transport)
+
+			got, err := collect(c, "tail", &tailArg{Lines: 3})
+			if want := []string{`""`, `"x"`, `"xx"`}; err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("❌ tail = %v, %v; want %v", got, err, want)
+			}
+			if got, err := collect(c, "tail", &tailArg{}); err != nil || len(got) != 0 {
+				t.Errorf("❌ tail of nothing = %v, %v", got, err)
+			}
+			if got, err := collect(c, "count", 2); err != nil || len(got) != 2 {
+				t.Errorf("❌ count = %v, %v; want the elements of a plain result", got, err)
+			}
+
+			_, err = collect(c, "tail", &tailArg{Lines: 3, FailAt: 1})
+			var rpcErr *Error
+			if tt.name == "http" {
+				if !errors.Is(err, ErrTruncatedResponse) {
+					t.Errorf("❌ failing after a value: %v, want a truncated response", err)
+				}
+			} else if !errors.As(err, &rpcErr) || rpcErr.Message != "log rotated" {
+				t.Errorf("❌ failing after a value: %v, want the error of the method", err)
+			}
+
+			stop := errors.New("enough")
+			n := 0
+			err = c.CallStream(context.Background(), "tail", &tailArg{Lines: 100}, func(value json.RawMessage) error {
+				if n++; n == 2 {
+					return stop
+				}
+				return nil
+			})
+			if err != stop || n != 2 {
+				t.Errorf("❌ stopped by each: %v after %d values, want %v after 2", err, n, stop)
+			}
+
+			// a plain call gets all the values at once
+			var lines []string
+			if err := c.Call("tail", &tailArg{Lines: 2}, &lines); err != nil || !reflect.DeepEqual(lines, []string{"", "x"}) {
+				t.Errorf("❌ Call tail = %v, %v", lines, err)
+			}
+		})
+	}
+}
+
+func Test_decodeStreamingResponse(t *testing.T) {
+	var got []string
+	each := func(value json.RawMessage) error {
+		got = append(got, string(value))
+		return nil
+	}
+	resp, err := decodeStreamingResponse(strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":[1,{"a":[2]}],"x":3}`), each)
+	if err != nil || *resp.Id != *Int64ID(1) || !reflect.DeepEqual(got, []string{`1`, `{"a":[2]}`}) {
+		t.Errorf("❌ got %v, %v", got, err)
+	}
+
+	resp, err = decodeStreamingResponse(strings.NewReader(`{"jsonrpc":"2.0","error":{"code":-1,"message":"no"},"id":2}`), each)
+	if err != nil || resp.Error == nil || resp.Error.Code != -1 {
+		t.Errorf("❌ error response: %#v, %v", resp, err)
+	}
+
+	if _, err := decodeStreamingResponse(strings.NewReader(`{"jsonrpc":"2.0","id":3,"result":{}}`), each); err == nil {
+		t.Error("❌ decoded a result not an array")
+	}
+	if _, err := decodeStreamingResponse(strings.NewReader(`{"jsonrpc":"2.0","id":4,"result":[1,`), each); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("❌ truncated: %v, want io.ErrUnexpectedEOF", err)
+	}
+}
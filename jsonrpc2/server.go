@@ -1,110 +1,920 @@
 package jsonrpc2
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var Verbose = false
-
 // RemoteProcess is a function that will be called by remote.
 type RemoteProcess func(arg any) (ret any, err error)
 
 // Server register methods and Serve JSON-RPC 2.0 over HTTP.
+//
+// Concurrency contract:
+//
+//   - ServeRPC, ServeBatch, Stats, Events and Topic are safe for concurrent use.
+//   - Register, MustRegister and RegisterAll are safe for concurrent use,
+//     with each other and with the serving methods. A request served
+//     meanwhile sees a method either not registered yet (Method Not Found)
+//     or registered with all its MethodOptions, never half of it; and a
+//     name can't be taken twice, whichever registration comes first wins.
+//   - The With* options and Use configure the server before it serves: they must
+//     not be called concurrently with anything else, except WithMethodFilter,
+//     WithReservedNames, WithLogSampling and WithPhaseTimings, which may be
+//     switched while serving.
+//
+// Test_server_concurrency stresses this contract, run it with -race.
 type Server interface {
-	Register(name string, f any) error // register a method f with its name, while f is something like the RemoteProcess.
-	ServeRPC(req *Request) *Response
+	// Register a method f with its name, while f is something like the RemoteProcess.
+	// f may also take a context.Context as its first parameter:
+	//
+	//     func(ctx context.Context, arg *Arg) (*Ret, error)
+	//
+	// The ctx is the one given to ServeRPC, carrying the TransportInfo of the request.
+	//
+	// f may take more than 1 parameter (after the optional ctx), decoded
+	// positionally from params of an array of as many items:
+	//
+	//     func(a int, b string) (*Ret, error) // params: [1, "b"]
+	//
+	// f is called by reflection, unless it's a Func (see Typed) or a RawFunc,
+	// whose types are known at compile time.
+	//
+	// f may write a large result in chunks instead of returning it, see ResultWriter.
+	//
+	// name may be a pattern serving all the methods it matches, but those
+	// registered by their names: "*" matches anything, "{id}" a segment
+	// (anything but "" and "." or "/") passed to f as the request metadata,
+	// see MethodParam. e.g. "kv.*" or "tenant/{id}/lock". The patterns are
+	// tried in the order they're registered.
+	//
+	// opts configure the method, e.g. Deprecated.
+	Register(name string, f any, opts ...MethodOption) error
+
+	// RegisterFallback makes f serve the calls of the methods not
+	// registered, instead of failing them with ErrMethodNotFound, e.g.
+	// to dispatch them dynamically, proxy or mock them:
+	//
+	//	s.RegisterFallback(func(method string, params json.RawMessage) (json.RawMessage, error) {
+	//		return json.RawMessage(`"mocked ` + method + `"`), nil
+	//	})
+	//
+	// The calls are served like those of a RawFunc: limited, deduplicated,
+	// logged... as any method, but unknown to rpc.discover. The reserved
+	// names (see ReservedPrefix) and the methods hidden by WithMethodFilter
+	// are not served by f. nil (the default) removes the fallback.
+	RegisterFallback(f FallbackFunc) Server
+
+	// MustRegister is Register but panics on error, for startup code.
+	MustRegister(name string, f any, opts ...MethodOption)
+
+	// RegisterAll registers a whole service map atomically: either all the
+	// methods are registered, or (if any f is invalid or any name is taken)
+	// none of them.
+	RegisterAll(methods map[string]any) error
+
+	// RegisterService registers the exported methods of receiver (e.g. a
+	// *LockServer) that Register can take, as "name.Method", e.g. "lock.Lock",
+	// with opts. Methods of other shapes are skipped, but it's an error if
+	// none is left. Like RegisterAll, either all of them are registered, or none.
+	RegisterService(name string, receiver any, opts ...MethodOption) error
+
+	// ServeRPC serves a request. The ctx is passed down to the method,
+	// transports attach their TransportInfo to it.
+	// A notification (see Request.IsNotification) is executed as well, but
+	// nil is returned: the transport responds nothing.
+	ServeRPC(ctx context.Context, req *Request) *Response
+
+	// ServeBatch serves a batch of requests, given as the raw entries of the
+	// batch array. Each entry is parsed, validated and served on its own;
+	// the returned responses are ordered as configured by WithBatchOrder.
+	// Notifications have no responses: a batch of notifications only
+	// returns an empty slice, to be answered with nothing at all.
+	ServeBatch(ctx context.Context, batch []json.RawMessage) []*Response
+
+	// Topic returns the topic name of the server, created on first use.
+	// The clients subscribe to it over connections (see Client.Subscribe):
+	// the messages published are pushed to them by MethodEvent
+	// notifications, up to 64 buffered for each, the oldest dropped beyond.
+	//
+	//	prices := s.Topic("prices")
+	//	...
+	//	prices.Publish(&Price{Symbol: "ACME", Cents: 4200})
+	Topic(name string) *Topic
+
+	// Use wraps the serving of every request (by ServeRPC, including the
+	// entries of ServeBatch) by mw, e.g. for logging, auth or metrics:
+	//
+	//	s.Use(func(next jsonrpc2.HandlerFunc) jsonrpc2.HandlerFunc {
+	//		return func(ctx context.Context, req *jsonrpc2.Request) *jsonrpc2.Response {
+	//			start := time.Now()
+	//			defer func() { log.Println(req.Method, time.Since(start)) }()
+	//			return next(ctx, req)
+	//		}
+	//	})
+	//
+	// The handler made by mw may modify the request or the response, or not
+	// call next at all, answering with an error response instead. It must
+	// return a response, even for notifications, whose responses are dropped.
+	// The first Use is the outermost.
+	Use(mw func(next HandlerFunc) HandlerFunc) Server
+
+	// WithParamCoercion turns on lenient decoding of params, for clients
+	// (PHP, shell scripts, ...) that stringify everything: where the
+	// params don't decode as they are, numeric strings like "123" are taken
+	// as numbers, and 1/0, "1"/"0", "true"/"false" as booleans.
+	// It's off by default. RawFunc methods get their params as they are.
+	WithParamCoercion(on bool) Server
+
+	// WithPretty makes the server indent its JSON responses, and the params
+	// and results in its logs, for debugging by hand (e.g. with curl).
+	// Over HTTP, a single request can ask for it with ?pretty=1 as well.
+	WithPretty(on bool) Server
+
+	// WithLogSampling makes the server log some requests and their
+	// responses, e.g. 1 in 1000 and all the failed ones, or all of them
+	// (Every: 1). It's safe to call while serving, to turn logs up or down.
+	// The zero LogSampling (the default) logs none.
+	WithLogSampling(sampling LogSampling) Server
+
+	// WithLogger sets the Logger of the server and of its transports: the
+	// requests and responses sampled (see WithLogSampling) at LevelInfo,
+	// failed ones at LevelWarn, panics of methods at LevelError, and the
+	// failures of the transports (e.g. a response not written).
+	// nil (the default) means a StdLogger.
+	WithLogger(l Logger) Server
+
+	// WithClock sets the Clock timing requests (events, durations, the
+	// queue waits and retry hints of WithMaxConcurrency, the timeouts of
+	// the methods), e.g. a FakeClock in tests.
+	// The default is SystemClock.
+	WithClock(c Clock) Server
+
+	// WithReservedNames lets Register take names starting with ReservedPrefix
+	// ("rpc."), replacing the built-in methods of the same names, e.g. for
+	// tests to stub rpc.health. It's off by default.
+	WithReservedNames(allow bool) Server
+
+	// WithStrictSpec makes the server follow the JSON-RPC 2.0 spec to the
+	// letter, for new services to opt into full compliance; the default is
+	// lenient, not to break existing clients:
+	//   - requests with members unknown (but those of Request: client and
+	//     meta), a null id, or params neither an array nor an object are
+	//     rejected with ErrInvalidRequest, instead of served;
+	//   - the names starting with ReservedPrefix can't be registered, even
+	//     WithReservedNames;
+	//   - over HTTP, error responses have the status of their codes: 400
+	//     for the invalid requests and params, 404 for the methods not found,
+	//     401 for ErrUnauthorized, 429 for ErrQuotaExceeded, 503 for
+	//     ErrServerBusy and ErrNotReady, and 500 for the other internal and
+	//     server errors (but not 200).
+	WithStrictSpec() Server
+
+	// WithMethodFilter hides methods from some callers, e.g. admin.* methods
+	// from all tenants but one, see MethodFilter. nil (the default) shows
+	// all the methods to everyone.
+	WithMethodFilter(f MethodFilter) Server
+
+	// WithReadinessGate holds the server not ready until SetReady, e.g.
+	// while it loads its state: meanwhile every method but MethodHealth
+	// fails with ErrNotReady, which is retryable, and MethodHealth reports
+	// the status "starting", so load balancers don't route traffic to it yet.
+	// Without it (the default), the server is ready at once.
+	WithReadinessGate() Server
+
+	// SetReady opens the gate of WithReadinessGate: the server serves all
+	// its methods from now on. It's safe to call at any time, more than once.
+	SetReady()
 
 	// WithAtMostOnce 是一个 Option: 执行 at-most-once 语意，消除重复 RPC 请求。
 	//
 	// WithAtMostOnce 原址设置当前 Server 执行 at-most-once，为了方便，该函数还会返回该 Server。
 	//
+	// Duplicated requests are rejected with ErrAtMostOnce, or, with the
+	// option ReplayResults, answered the response of the original request.
+	// The ids are remembered forever, unless bounded by the options
+	// WithTTL and WithMaxEntries, as long-running servers should.
+	//
+	// The ids are those of each client session (see Request.Client) apart:
+	// two clients both sending the id 1 are not duplicates of each other.
+	//
+	// Lookups of the dedupe store are counted in Metrics as "dedupe.hits"
+	// (duplicates caught) and "dedupe.misses" (new ids), and its size is
+	// the gauge "dedupe.entries". Stats reports them with "dedupe.hit_rate_pct",
+	// telling whether retries are actually being absorbed.
+	//
 	// e.g.
-	//     s := NewServer().WithAtMostOnce()
+	//     s := NewServer().WithAtMostOnce(jsonrpc2.WithTTL(5*time.Minute), jsonrpc2.WithMaxEntries(100000))
 	//     s.Register(...)
 	//     st := NewHttpServerTransport(":6666")
 	//     st.Serve(s)
-	WithAtMostOnce() Server
+	WithAtMostOnce(opts ...AtMostOnceOption) Server
+
+	// WithIDKeyer sets how request ids are turned into at-most-once dedupe keys.
+	// The default is DefaultIDKeyer.
+	WithIDKeyer(k IDKeyer) Server
+
+	// WithMaxConcurrency bounds how many method calls execute simultaneously.
+	// Excess requests wait in a queue until a call finishes (see WithMaxQueue).
+	// n <= 0 removes the limit.
+	WithMaxConcurrency(n int) Server
+
+	// WithTenantMaxConcurrency bounds how many method calls of each tenant
+	// (see WithTenant) execute simultaneously, so one busy tenant can't take
+	// all the slots of WithMaxConcurrency. The queue of each tenant is bounded
+	// by WithMaxQueue as well. Requests without a tenant aren't limited by it.
+	// n <= 0 (the default) removes the limit.
+	WithTenantMaxConcurrency(n int) Server
+
+	// WithMaxQueue bounds how many requests may wait when WithMaxConcurrency is on.
+	// Requests beyond that are shed with ErrServerBusy, whose Data carries
+	// a retry_after_ms hint. n < 0 (the default) means an unbounded queue.
+	WithMaxQueue(n int) Server
+
+	// WithAdaptiveConcurrency bounds how many method calls execute
+	// simultaneously as WithMaxConcurrency does, but by a limit adjusted to
+	// their latencies (AIMD), not to tune by hand: each round of as many
+	// calls as the limit, it backs off (by 10% by default) if they were
+	// slower on average than twice the best latency seen, i.e. the server
+	// or what it depends on (a database, the channel of a lock...) is
+	// overloaded, else grows by 1 if they reached it. See AdaptiveConcurrency
+	// for the bounds and factors. The current limit is "concurrency.limit"
+	// of Stats. It replaces WithMaxConcurrency, as WithMaxConcurrency
+	// replaces it; WithMaxQueue bounds its queue all the same.
+	WithAdaptiveConcurrency(a AdaptiveConcurrency) Server
+
+	// WithWorkerPool makes the server run the entries of batches (see
+	// WithBatchParallelism) and the requests read from connections (see
+	// StreamServerTransport) by n long-lived workers, instead of a
+	// goroutine each, sparing the scheduler the churn of tens of thousands
+	// of small concurrent RPCs. Each worker has a queue of its own, the idle
+	// ones stealing the tasks of the others. When none is free, a task runs
+	// in a goroutine of its own all the same: never behind methods blocking
+	// (e.g. waiting for a lock), which can't deadlock the pool. Stats reports
+	// "workers.total", "workers.idle" and "workers.overflow", the count of
+	// the tasks no worker was free for: a hint to add workers.
+	// n <= 0 (the default) removes the pool.
+	WithWorkerPool(n int) Server
+
+	// WithMetrics sets where the server reports its measurements.
+	// By default, a Server records into a MemoryMetrics.
+	WithMetrics(m Metrics) Server
+
+	// WithPhaseTimings makes the server time the phases of serving each
+	// method apart, to find out where its time goes: unmarshaling the
+	// params, running the method and marshaling the result. They're
+	// observed in Metrics as "phase.decode.<method>", "phase.call.<method>"
+	// and "phase.encode.<method>", <method> as registered (the pattern of
+	// the names it matches, see Register), and shown by Stats and
+	// DebugHandler with a MemoryMetrics. The methods streaming results
+	// (see StreamFunc and ResultWriter) marshal them as they run, timed as
+	// call. It's off by default, and safe to call while serving, to time
+	// the methods of a live server for a while.
+	WithPhaseTimings(on bool) Server
+
+	// Stats returns a snapshot of the server's metrics (if the Metrics
+	// can report them, like MemoryMetrics does), together with the current
+	// queue depth and concurrency thresholds.
+	Stats() map[string]int64
+
+	// WithBatchParallelism lets ServeBatch run up to n entries of a batch
+	// simultaneously. n <= 1 (the default) runs the entries one by one.
+	WithBatchParallelism(n int) Server
+
+	// WithBatchOrder sets how ServeBatch orders the responses of a batch:
+	// as the requests (BatchOrderRequest, the default) or as the entries
+	// finish (BatchOrderCompletion, correlated by id).
+	WithBatchOrder(order BatchOrder) Server
+
+	// Events returns a channel of things happening in the server: methods
+	// registered, requests started and finished, panics recovered, duplicated
+	// requests rejected, ... to build custom monitoring or audit trails.
+	//
+	// Events are delivered without blocking the server: when the channel is
+	// full, new events are dropped (and counted as "events.dropped" in Metrics).
+	// All calls return the same channel.
+	Events() <-chan Event
 }
 
 // server is a Server implementation.
 type server struct {
-	mu      sync.RWMutex
-	methods map[string]*method
+	mu       sync.RWMutex
+	methods  map[string]handler
+	builtins map[string]bool // names of the built-in methods not replaced yet
+	infos    map[string]*methodInfo
+	patterns []*methodPattern // of the names registered as patterns, in order
+	fallback FallbackFunc     // of the methods not registered, nil: none
+
+	allowReserved bool // allow registering names starting with ReservedPrefix
+	strict        bool // follow the spec to the letter, see WithStrictSpec
+	coerceParams  bool // decode params leniently, see WithParamCoercion
+	methodFilter  MethodFilter
+	pretty        bool        // indent responses and logs, see WithPretty
+	phaseTimings  atomic.Bool // see WithPhaseTimings
+	notReady      atomic.Bool // see WithReadinessGate
+	clock         Clock
+	logSampler    logSampler
+	logger        Logger // nil: defaultLogger
+
+	atMostOnce       *dedupeStore // nil: disable, else: 执行 at-most-once 语意，消除重复 RPC 请求
+	atMostOnceConfig atMostOnceConfig
+	idKeyer          IDKeyer // keys of atMostOnce
+	dedupe           dedupeStats
+
+	limiter        *limiter // nil: no concurrency limit
+	tenantLimiters tenantLimiters
+	maxQueue       int
+	metrics        Metrics
+
+	events eventStream
+	topics topics
+
+	batchParallelism int
+	pool             *workerPool // of the batch entries and the requests of connections, nil: none
+	batchOrder       BatchOrder
+
+	inflight inflight
 
-	atMostOnce *sync.Map // nil: disable, else: 执行 at-most-once 语意，消除重复 RPC 请求
+	middlewares []func(next HandlerFunc) HandlerFunc // see Use
+	handler     HandlerFunc                          // serveRPC wrapped by the middlewares, nil: none
 }
 
 // NewServer creates JSON-RPC 2.0 Server.
 func NewServer() Server {
-	return &server{
-		methods: make(map[string]*method),
+	s := &server{
+		methods:  make(map[string]handler),
+		builtins: make(map[string]bool),
+		infos:    make(map[string]*methodInfo),
+		maxQueue: -1,
+		metrics:  NewMemoryMetrics(),
+		idKeyer:  DefaultIDKeyer,
+		clock:    SystemClock,
 	}
+	s.events.dropped = func() { s.metrics.Add("events.dropped", 1) }
+
+	s.registerBuiltin(MethodCancel, TypedContext(s.cancelRequest))
+	s.registerBuiltin(MethodDiscover, RawFunc(s.discover))
+	s.registerBuiltin(MethodDescribe, RawFunc(s.describeMethod))
+	s.registerBuiltin(MethodHealth, RawFunc(s.health))
+	s.registerBuiltin(MethodSubscribe, TypedContext(s.subscribe))
+	s.registerBuiltin(MethodUnsubscribe, TypedContext(s.unsubscribe))
+	return s
+}
+
+// registerBuiltin registers a method provided by the server itself.
+func (s *server) registerBuiltin(name string, f any) {
+	h, err := newHandler(f)
+	if err != nil {
+		panic("bad builtin method " + name + ": " + err.Error())
+	}
+	s.methods[name] = h
+	s.builtins[name] = true
+}
+
+// WithParamCoercion 原址设置是否宽松地解码参数，并返回 Server 以供链式
+func (s *server) WithParamCoercion(on bool) Server {
+	s.coerceParams = on
+	return s
+}
+
+// WithPretty 原址设置是否缩进 JSON 响应与日志，并返回 Server 以供链式
+func (s *server) WithPretty(on bool) Server {
+	s.pretty = on
+	return s
+}
+
+// WithLogSampling 原址设置日志采样，并返回 Server 以供链式
+func (s *server) WithLogSampling(sampling LogSampling) Server {
+	s.logSampler.set(sampling)
+	return s
+}
+
+// WithLogger 原址设置 Logger，并返回 Server 以供链式
+func (s *server) WithLogger(l Logger) Server {
+	s.logger = l
+	return s
+}
+
+// WithClock 原址设置 Clock，并返回 Server 以供链式
+func (s *server) WithClock(c Clock) Server {
+	c = clockOrSystem(c)
+	s.clock = c
+	s.events.clock = c
+	return s
+}
+
+// WithReservedNames 原址设置是否允许注册 rpc. 开头的方法，并返回 Server 以供链式
+func (s *server) WithReservedNames(allow bool) Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowReserved = allow
+	return s
+}
+
+// WithReadinessGate 原址设置 Server 在 SetReady 之前不就绪，并返回 Server 以供链式
+func (s *server) WithReadinessGate() Server {
+	s.notReady.Store(true)
+	return s
+}
+
+func (s *server) SetReady() {
+	s.notReady.Store(false)
+}
+
+// notReadyRetryAfter is the retry hint of ErrNotReady.
+const notReadyRetryAfter = time.Second
+
+// WithMethodFilter 原址设置方法的可见性过滤，并返回 Server 以供链式
+func (s *server) WithMethodFilter(f MethodFilter) Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methodFilter = f
+	return s
 }
 
 // WithAtMostOnce 原址设置当前 server 执行 at-most-once，并返回 Server 以供链式
-func (s *server) WithAtMostOnce() Server {
-	s.atMostOnce = new(sync.Map)
+func (s *server) WithAtMostOnce(opts ...AtMostOnceOption) Server {
+	s.atMostOnceConfig = atMostOnceConfig{}
+	for _, opt := range opts {
+		opt(&s.atMostOnceConfig)
+	}
+	s.atMostOnce = newDedupeStore(s.atMostOnceConfig, func() { s.dedupe.evict(s.metrics) })
+	s.dedupe.reset()
+	return s
+}
+
+// WithIDKeyer 原址设置 IDKeyer，并返回 Server 以供链式
+func (s *server) WithIDKeyer(k IDKeyer) Server {
+	if k == nil {
+		k = DefaultIDKeyer
+	}
+	s.idKeyer = k
+	return s
+}
+
+// WithMaxConcurrency 原址设置并发上限，并返回 Server 以供链式
+func (s *server) WithMaxConcurrency(n int) Server {
+	if n <= 0 {
+		s.limiter = nil
+		return s
+	}
+	s.limiter = newLimiter(n, s.maxQueue)
+	return s
+}
+
+// WithTenantMaxConcurrency 原址设置每个 tenant 的并发上限，并返回 Server 以供链式
+func (s *server) WithTenantMaxConcurrency(n int) Server {
+	s.tenantLimiters.setLimit(n)
+	return s
+}
+
+// WithMaxQueue 原址设置排队上限，并返回 Server 以供链式
+func (s *server) WithMaxQueue(n int) Server {
+	s.maxQueue = n
+	if s.limiter != nil {
+		s.limiter.mu.Lock()
+		s.limiter.maxQueue = n
+		s.limiter.mu.Unlock()
+	}
+	return s
+}
+
+// WithMetrics 原址设置 Metrics，并返回 Server 以供链式
+func (s *server) WithMetrics(m Metrics) Server {
+	if m == nil {
+		m = nopMetrics{}
+	}
+	s.metrics = m
+	return s
+}
+
+// WithBatchParallelism 原址设置 batch 并行度，并返回 Server 以供链式
+func (s *server) WithBatchParallelism(n int) Server {
+	s.batchParallelism = n
 	return s
 }
 
+// WithBatchOrder 原址设置 batch 响应顺序，并返回 Server 以供链式
+func (s *server) WithBatchOrder(order BatchOrder) Server {
+	s.batchOrder = order
+	return s
+}
+
+func (s *server) Stats() map[string]int64 {
+	stats := make(map[string]int64)
+	if snap, ok := s.metrics.(snapshotter); ok {
+		for k, v := range snap.Snapshot() {
+			stats[k] = v
+		}
+	}
+	if s.pool != nil {
+		for k, v := range s.pool.stats() {
+			stats[k] = v
+		}
+	}
+	if s.limiter != nil {
+		for k, v := range s.limiter.stats() {
+			stats[k] = v
+		}
+	}
+	if s.atMostOnce != nil {
+		for k, v := range s.dedupe.stats() {
+			stats[k] = v
+		}
+	}
+	return stats
+}
+
+func (s *server) Events() <-chan Event {
+	return s.events.channel()
+}
+
 // Register registers a method f with its name.
-func (s *server) Register(name string, f any) error {
-	if _, exists := s.methods[name]; exists {
-		return errors.New(fmt.Sprintf("multiple registrations for %s", name))
+func (s *server) Register(name string, f any, opts ...MethodOption) error {
+	return s.registerAll(map[string]any{name: f}, opts)
+}
+
+// MustRegister is Register but panics on error.
+func (s *server) MustRegister(name string, f any, opts ...MethodOption) {
+	if err := s.Register(name, f, opts...); err != nil {
+		panic(err)
 	}
+}
 
-	rp, err := newMethod(f)
-	if err != nil {
-		return err
+// RegisterAll registers all the methods, or none of them if any fails.
+func (s *server) RegisterAll(methods map[string]any) error {
+	return s.registerAll(methods, nil)
+}
+
+// RegisterService registers the methods of receiver as name.Method.
+func (s *server) RegisterService(name string, receiver any, opts ...MethodOption) error {
+	rv := reflect.ValueOf(receiver)
+	if !rv.IsValid() {
+		return fmt.Errorf("register service %s: nil receiver", name)
 	}
 
+	methods := make(map[string]any)
+	for i := 0; i < rv.NumMethod(); i++ { // the exported ones
+		f := rv.Method(i).Interface()
+		if _, err := newHandler(f); err != nil {
+			continue // not a method to serve, e.g. a helper
+		}
+		methods[name+"."+rv.Type().Method(i).Name] = f
+	}
+	if len(methods) == 0 {
+		return fmt.Errorf("register service %s: %T has no method to register", name, receiver)
+	}
+	return s.registerAll(methods, opts)
+}
+
+// methodWithOptions is a method given to RegisterAll with MethodOptions
+// of its own, e.g. by RegisterDesc.
+type methodWithOptions struct {
+	f    any
+	opts []MethodOption
+}
+
+// registerAll registers all the methods with opts (and the options of
+// each methodWithOptions), or none of them if any fails.
+func (s *server) registerAll(methods map[string]any, opts []MethodOption) error {
+	handlers := make(map[string]handler, len(methods))
+	infos := make(map[string]*methodInfo, len(methods))
+	var patterns []*methodPattern
+	for name, f := range methods {
+		methodOpts := opts
+		if mo, ok := f.(methodWithOptions); ok {
+			f, methodOpts = mo.f, append(opts[:len(opts):len(opts)], mo.opts...)
+		}
+		infos[name] = newMethodInfo(methodOpts)
+		h, err := newHandler(f)
+		if err != nil {
+			return fmt.Errorf("register %s: %w", name, err)
+		}
+		if err := checkParamDocs(h, infos[name]); err != nil {
+			return fmt.Errorf("register %s: %w", name, err)
+		}
+		handlers[name] = h
+		if isPattern(name) {
+			p, err := compilePattern(name)
+			if err != nil {
+				return fmt.Errorf("register %s: %w", name, err)
+			}
+			patterns = append(patterns, p)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool { return patterns[i].name < patterns[j].name })
+
+	// check and register under the same lock,
+	// so that concurrent registrations of a name can't both succeed.
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	for name := range handlers {
+		if isReserved(name) && (!s.allowReserved || s.strict) {
+			s.mu.Unlock()
+			return fmt.Errorf("register %s: names starting with %q are reserved", name, ReservedPrefix)
+		}
+		if _, exists := s.methods[name]; exists && !s.builtins[name] {
+			s.mu.Unlock()
+			return errors.New(fmt.Sprintf("multiple registrations for %s", name))
+		}
+	}
+	for name, h := range handlers {
+		s.methods[name] = h
+		s.infos[name] = infos[name]
+		delete(s.builtins, name)
+	}
+	s.patterns = append(s.patterns, patterns...)
+	s.mu.Unlock()
 
-	s.methods[name] = rp
+	for name := range handlers {
+		s.events.emit(Event{Kind: EventMethodRegistered, Method: name})
+	}
 	return nil
 }
 
-func (s *server) ServeRPC(req *Request) *Response {
-	// find method
+func (s *server) ServeRPC(ctx context.Context, req *Request) *Response {
+	serve := HandlerFunc(s.serveRPC)
+	if s.handler != nil {
+		serve = s.handler
+	}
+
+	if req.IsNotification() {
+		// nothing to respond to: no streaming either
+		serve(withResultSink(ctx, nil), req)
+		return nil
+	}
+	return serve(ctx, req)
+}
+
+// serveRPC serves req and makes its response, even for a notification.
+func (s *server) serveRPC(ctx context.Context, req *Request) (resp *Response) {
+	info, _ := TransportInfoFromContext(ctx)
+	if s.coerceParams {
+		ctx = withParamCoercion(ctx)
+	}
+	pretty := s.pretty || prettyFromContext(ctx)
+	defer func() {
+		if resp != nil {
+			resp.pretty = pretty
+		}
+	}()
+	ctx, cancel := withRequestMeta(ctx, req)
+	defer cancel()
+
+	start := s.clock.Now()
+	sampled := s.logSampler.sample()
+	if sampled {
+		s.logRequest(req, pretty)
+	}
+	defer func() { s.logResponse(req, resp, sampled, pretty, since(s.clock, start)) }()
+
+	s.events.emit(Event{Kind: EventRequestStarted, Time: start, Method: req.Method, Id: req.Id, Transport: info})
+	defer func() {
+		s.events.emit(Event{Kind: EventRequestFinished, Method: req.Method, Id: req.Id, Transport: info,
+			Duration: since(s.clock, start), Error: resp.Error})
+	}()
+
+	// find method: the handler, its info and the filter of the same moment
 	s.mu.RLock()
 	m, exists := s.methods[req.Method]
+	mi := s.infos[req.Method]
+	name := req.Method // as registered, of the phase timings
+	var captures map[string]string
+	for i := 0; !exists && i < len(s.patterns); i++ {
+		if captures, exists = s.patterns[i].match(req.Method); exists {
+			name = s.patterns[i].name
+			m, mi = s.methods[name], s.infos[name]
+		}
+	}
+	filter := s.methodFilter
+	fallback := s.fallback
 	s.mu.RUnlock()
+	ctx = withMethodParams(ctx, captures)
 
-	if !exists {
+	if !exists && fallback != nil && !isReserved(req.Method) {
+		m, exists = fallbackHandler(fallback, req.Method), true
+		name = "" // not timed, whatever the names called
+	}
+
+	if !exists || (filter != nil && !filter(ctx, req.Method)) {
 		return errorResponse(req.Id, ErrMethodNotFound())
 	}
+	if s.notReady.Load() && req.Method != MethodHealth {
+		return errorResponse(req.Id, ErrNotReady().withRetryAfter("the server is starting", notReadyRetryAfter))
+	}
+
+	// scope by tenant
+	metrics := s.metrics
+	tenant, hasTenant := TenantFromContext(ctx)
+	if hasTenant {
+		tm := tenantMetrics{s.metrics, tenant}
+		s.metrics.Add(tm.label("requests"), 1)
+		metrics = tm
+	}
+
+	// forgetDedupe forgets the request in the dedupe store, if it's there:
+	// for a request cancelled before its method ran, which may be retried.
+	forgetDedupe := func() {}
+	ran := false // whether the method was called, see ReplayResults
+	if key, ok := s.idKeyer.Key(req.Id); ok && s.atMostOnce != nil {
+		if req.Client != "" {
+			key = clientDedupeKey(req.Client, key)
+		}
+		if hasTenant {
+			key = tenantDedupeKey(tenant, key)
+		}
+		entry, dupResp := s.dedupeRequest(ctx, key, req, metrics, info)
+		if dupResp != nil {
+			return dupResp
+		}
+		forgetDedupe = func() {
+			if s.atMostOnce.delete(key, entry) {
+				s.dedupe.forget(s.metrics)
+			}
+		}
+		if replay := entry; s.atMostOnceConfig.replay {
+			// the response is kept to replay if the method ran, else the
+			// request is forgotten, whatever stopped it (cancelled, shed).
+			defer func() {
+				_, streams := m.(streamer)
+				switch {
+				case ran && resp != nil && streams:
+					replay.streamed = true // maybe, not to replay in part
+				case ran && resp != nil:
+					kept := *resp
+					replay.resp = &kept
+				default:
+					forgetDedupe()
+				}
+				close(replay.done)
+			}()
+			forgetDedupe = func() {}
+		}
+	}
 
-	if Verbose {
-		log.Printf("ServeRPC request: method=%s, id=%d, params=%s\n", req.Method, *req.Id, req.Params)
+	if req.Id != nil {
+		var done func()
+		ctx, done = s.inflight.track(ctx, req)
+		defer done()
 	}
 
-	if s.atMostOnce != nil && req.Id != nil {
-		_, dup := s.atMostOnce.LoadOrStore(*req.Id, struct{}{})
-		if dup {
-			return errorResponse(req.Id, ErrAtMostOnce())
+	// the slots of the limiters are released once the method returns,
+	// even if it's answered ErrRequestTimeout before, see WithTimeout.
+	var returned <-chan struct{} // nil: it returned already
+	releaseOnReturn := func(release func()) {
+		if returned == nil {
+			release()
+			return
 		}
+		go func() { <-returned; release() }()
 	}
 
-	// call method
-	resp := m.serveRequest(req)
+	unlimited := mi != nil && mi.unlimited
+	var l *limiter
+	if hasTenant && !unlimited {
+		l = s.tenantLimiters.get(tenant, s.maxQueue)
+	}
+	if l != nil {
+		waited, ok := l.acquire(ctx, s.clock, metrics)
+		if !ok && ctx.Err() != nil {
+			forgetDedupe()
+			return errorResponse(req.Id, ErrRequestCancelled().WithReason(ctx.Err().Error()))
+		}
+		if !ok {
+			forgetDedupe() // for the retry invited to be served
+			metrics.Add("requests.shed", 1)
+			return errorResponse(req.Id, ErrServerBusy().withRetryAfter(
+				"too many concurrent requests of the tenant", l.retryAfter()))
+		}
+		metrics.Observe("queue.wait", waited)
 
-	if Verbose {
-		log.Printf("ServeRPC response: id=%d, result=%s, error=%v\n", *resp.Id, resp.Result, resp.Error)
+		start := s.clock.Now()
+		defer releaseOnReturn(func() { l.release(metrics, since(s.clock, start)) })
+	}
+
+	if s.limiter != nil && !unlimited {
+		waited, ok := s.limiter.acquire(ctx, s.clock, s.metrics)
+		if !ok && ctx.Err() != nil {
+			forgetDedupe()
+			return errorResponse(req.Id, ErrRequestCancelled().WithReason(ctx.Err().Error()))
+		}
+		if !ok {
+			forgetDedupe() // for the retry invited to be served
+			metrics.Add("requests.shed", 1)
+			return errorResponse(req.Id, ErrServerBusy().withRetryAfter(
+				"too many concurrent requests", s.limiter.retryAfter()))
+		}
+		s.metrics.Observe("queue.wait", waited)
+
+		start := s.clock.Now()
+		defer releaseOnReturn(func() { s.limiter.release(s.metrics, since(s.clock, start)) })
+	}
+
+	if mi != nil && mi.marshalResult != nil {
+		ctx = withResultMarshal(ctx, mi.marshalResult)
+	}
+
+	var timer *phaseTimer
+	if name != "" && s.phaseTimings.Load() {
+		timer = &phaseTimer{clock: s.clock}
+		ctx = withPhaseTimer(ctx, timer)
+	}
+
+	// call method
+	ran = true
+	ctx, meta := withResponseMeta(ctx)
+	var err error
+	if mi != nil && mi.timeout > 0 {
+		resp, returned, err = serveWithTimeout(ctx, s.clock, m, req, mi.timeout)
+	} else {
+		resp, err = m.serve(ctx, req)
+	}
+	if timer != nil && returned == nil { // else, the method is still timed
+		timer.observe(metrics, name)
+	}
+	if resp != nil {
+		resp.Meta = meta.snapshot()
+	}
+	if pe, ok := err.(*panicError); ok {
+		s.events.emit(Event{Kind: EventPanicRecovered, Method: req.Method, Id: req.Id, Transport: info, Panic: pe.value})
+		loggerOr(s.logger).Log(LevelError, "recovered from method call",
+			Field{"method", req.Method}, Field{"id", formatId(req.Id)}, Field{"panic", pe.value})
+	}
+	var me *resultMarshalError
+	if errors.As(err, &me) {
+		metrics.Add("results.marshal_failed", 1)
+		loggerOr(s.logger).Log(LevelError, "failed to marshal result",
+			Field{"method", req.Method}, Field{"id", formatId(req.Id)}, Field{"error", me})
+	}
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		resp.Error = ErrRequestCancelled().WithReason(err.Error())
+	}
+	if mi != nil && mi.deprecated {
+		s.warnDeprecated(req.Method, mi, resp)
 	}
 
 	return resp
 }
 
+// serveWithTimeout serves req by m, bounding ctx by timeout by c: if m is not
+// done in time, ErrRequestTimeout is answered at once, even if m ignores
+// ctx. returned is closed once m returns, nil if it did already.
+//
+// A streamer m is waited for all the same, its ctx done: it may be
+// writing its response, which must not outlive the call.
+func serveWithTimeout(ctx context.Context, c Clock, m handler, req *Request, timeout time.Duration) (resp *Response, returned <-chan struct{}, err error) {
+	_, streams := m.(streamer)
+	parent := ctx
+	ctx, cancel := withTimeout(ctx, c, timeout)
+
+	type result struct {
+		resp *Response
+		err  error
+	}
+	done := make(chan result, 1)
+	methodReturned := make(chan struct{})
+	go func() {
+		defer close(methodReturned)
+		defer cancel()
+		resp, err := m.serve(ctx, req)
+		done <- result{resp, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		select {
+		case r = <-done: // done just in time
+		default:
+			if parent.Err() != nil || streams {
+				r = <-done // cancelled, or streaming: the method is waited for
+				break
+			}
+			return timeoutResponse(req, timeout), methodReturned, nil
+		}
+	}
+	if errors.Is(r.err, context.DeadlineExceeded) && parent.Err() == nil {
+		return timeoutResponse(req, timeout), nil, nil // gave up by the timeout
+	}
+	return r.resp, nil, r.err
+}
+
+func timeoutResponse(req *Request, timeout time.Duration) *Response {
+	return errorResponse(req.Id, ErrRequestTimeout().WithReason(fmt.Sprintf("not done in %v", timeout)))
+}
+
 // method is the inner representation for a RemoteProcess.
 type method struct {
 	function reflect.Value
-	inType   reflect.Type
+	inType   reflect.Type   // of the single parameter, nil for positional ones
+	inTypes  []reflect.Type // of the positional parameters, in order, if more than 1
 	outType  reflect.Type
 }
 
@@ -145,20 +955,47 @@ func (p *method) makeFunction(f any) error {
 	return nil
 }
 
-// makeInType fills the inType field of the method.
+// makeInType fills the inType field of the method, or the inTypes field
+// if it takes more than 1 parameter, decoded positionally from an array.
 // It should be called after makeFunction.
+//
+// A leading context.Context parameter is allowed and not counted.
 func (p *method) makeInType() error {
 	ft := p.function.Type()
 
-	if ft.NumIn() != 1 {
-		return errors.New("exactly 1 parameter expected")
+	numIn, first := ft.NumIn(), 0
+	if p.takesContext() {
+		numIn, first = numIn-1, 1
+	}
+
+	if numIn < 1 {
+		return errors.New("at least 1 parameter (after an optional context.Context) expected")
+	}
+	ins := make([]reflect.Type, 0, numIn)
+	for i := first; i < ft.NumIn(); i++ {
+		if ft.In(i) == contextInterface {
+			return errors.New("context.Context is only allowed as the 1st parameter")
+		}
+		ins = append(ins, ft.In(i))
 	}
-	at := ft.In(0)
 
-	p.inType = at
+	if len(ins) == 1 {
+		p.inType = ins[0]
+	} else {
+		p.inTypes = ins
+	}
 	return nil
 }
 
+var contextInterface = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// takesContext reports whether the function takes a context.Context as its
+// first parameter, besides the params.
+func (p *method) takesContext() bool {
+	ft := p.function.Type()
+	return ft.NumIn() >= 2 && ft.In(0) == contextInterface
+}
+
 // makeOutType fills the outType field of the method.
 // It should be called after makeFunction.
 func (p *method) makeOutType() error {
@@ -193,18 +1030,30 @@ func (p *method) unmarshalParam(params json.RawMessage) (reflect.Value, error) {
 // Return values are NOT reflect.Value. They are the actual values (outType.Interface(), error).
 // Panic will be recovered and returned as error.
 func (p *method) call(param reflect.Value) (ret any, err error) {
+	return p.callContext(context.Background(), param)
+}
+
+// callContext is call with the ctx passed to functions taking a context.Context.
+func (p *method) callContext(ctx context.Context, param reflect.Value) (ret any, err error) {
 	if param.Type() != p.inType {
 		return nil, errors.New("param type mismatch")
 	}
+	return p.callArgs(ctx, []reflect.Value{param})
+}
 
+// callArgs is callContext with the params of a positional method.
+func (p *method) callArgs(ctx context.Context, args []reflect.Value) (ret any, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Println("Recovered from method call: ", r)
-			err = errors.New(fmt.Sprintf("panic: %v", r))
+			err = &panicError{value: r}
 		}
 	}()
 
-	out := p.function.Call([]reflect.Value{param})
+	in := args
+	if p.takesContext() {
+		in = append([]reflect.Value{reflect.ValueOf(&ctx).Elem()}, args...)
+	}
+	out := p.function.Call(in)
 
 	if len(out) != 2 {
 		return nil, errors.New("exactly 2 return value (ret, err) expected")
@@ -222,10 +1071,56 @@ func (p *method) call(param reflect.Value) (ret any, err error) {
 	return ret, nil
 }
 
+// unmarshalArgs unmarshals the params of req into the parameters of the
+// method: the single one, or the positional ones from the items of an
+// array, in order, e.g. [1, "a"] for func(a int, b string) (R, error).
+func (p *method) unmarshalArgs(ctx context.Context, req *Request) ([]reflect.Value, error) {
+	if p.inTypes == nil {
+		param, err := req.unmarshalParamContext(ctx, p.inType)
+		if err == nil {
+			err = validateParams(param)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []reflect.Value{param}, nil
+	}
+
+	if gobFromContext(ctx) {
+		return nil, errGobUnsupported
+	}
+	if req.Params == nil {
+		return nil, errors.New("params should not be nil")
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(req.Params, &items); err != nil || len(items) != len(p.inTypes) {
+		return nil, fmt.Errorf("params should be an array of %d", len(p.inTypes))
+	}
+	args := make([]reflect.Value, len(items))
+	for i, item := range items {
+		arg, err := Request{Params: item}.unmarshalParamContext(ctx, p.inTypes[i])
+		if err == nil {
+			err = validateParams(arg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("params[%d]: %w", i, err)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
 // serveRequest do unmarshalParam and call for a given request, returning the response.
 func (p *method) serveRequest(req *Request) (res *Response) {
+	res, _ = p.serve(context.Background(), req)
+	return res
+}
+
+// serve is serveRequest with a ctx, that also returns the error (if any)
+// behind the error response, e.g. the *panicError of a panicked call.
+func (p *method) serve(ctx context.Context, req *Request) (res *Response, err error) {
 	if req == nil {
-		return errorResponse(nil, ErrInvalidRequest().withReason("nil request"))
+		return errorResponse(nil, ErrInvalidRequest().WithReason("nil request")), errors.New("nil request")
 	}
 
 	res = &Response{
@@ -233,27 +1128,41 @@ func (p *method) serveRequest(req *Request) (res *Response) {
 		Id:      req.Id,
 	}
 
+	timer := phaseTimerFromContext(ctx)
+	timer.start()
+
 	// param, err := p.unmarshalParam(req.Params)  // deprecated
-	param, err := req.unmarshalParam(p.inType)
+	args, err := p.unmarshalArgs(ctx, req)
+	timer.done(phaseDecode)
 	if err != nil {
-		res.Error = ErrInvalidParams().withReason(err.Error())
+		res.Error = ErrInvalidParams().WithReason(err.Error())
 		return
 	}
 
-	ret, err := p.call(param)
+	ret, err := p.callArgs(ctx, args)
+	timer.done(phaseCall)
 	if err != nil {
-		res.Error = &Error{
-			Code:    -1,
-			Message: err.Error(),
-		}
+		res.Error = methodError(err)
 		return
 	}
 
-	if err = res.marshalResult(ret); err != nil {
+	err = res.marshalResultContext(ctx, ret)
+	timer.done(phaseEncode)
+	if err != nil {
+		me := newResultMarshalError(req.Method, ret, err)
 		res.Result = nil
-		res.Error = ErrInternalError().withReason(err.Error())
-		return
+		res.Error = me.rpcError()
+		return res, me
 	}
 
-	return res
+	return res, nil
+}
+
+// panicError is returned by method.call when the function panicked.
+type panicError struct {
+	value any // the recovered value
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
 }
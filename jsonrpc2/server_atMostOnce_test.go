@@ -2,11 +2,15 @@ package jsonrpc2
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_server_AtMostOnce(t *testing.T) {
@@ -56,10 +60,6 @@ func Test_server_AtMostOnce(t *testing.T) {
 		return &res
 	}
 
-	intPtr := func(i int64) *int64 {
-		return &i
-	}
-
 	type args struct {
 		json string
 	}
@@ -70,19 +70,19 @@ func Test_server_AtMostOnce(t *testing.T) {
 	}{
 		{"good1",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 1}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(1), Result: []byte(`{"C":3}`)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(1), Result: []byte(`{"C":3}`)}},
 		{"dup1",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 2, "B": 3}, "id": 1}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(1), Error: ErrAtMostOnce()}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(1), Error: ErrAtMostOnce()}},
 		{"good2",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 2}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(2), Result: []byte(`{"C":3}`)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(2), Result: []byte(`{"C":3}`)}},
 		{"dup2",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 2, "B": 3}, "id": 2}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(2), Error: ErrAtMostOnce()}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(2), Error: ErrAtMostOnce()}},
 		{"dup1_again",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 2, "B": 3}, "id": 1}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(1), Error: ErrAtMostOnce()}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(1), Error: ErrAtMostOnce()}},
 	}
 
 	<-chStart
@@ -99,6 +99,21 @@ func Test_server_AtMostOnce(t *testing.T) {
 		})
 	}
 	close(chDoneTest)
+
+	// 2 misses (good1, good2), 3 hits (dup1, dup2, dup1_again)
+	stats := s.Stats()
+	for name, want := range map[string]int64{
+		"dedupe.hits":         3,
+		"dedupe.misses":       2,
+		"dedupe.entries":      2,
+		"dedupe.hit_rate_pct": 60,
+	} {
+		if got := stats[name]; got != want {
+			t.Errorf("❌ %s = %d, want %d", name, got, want)
+		} else {
+			t.Logf("✅ %s = %d", name, got)
+		}
+	}
 }
 
 func Test_server_NoAtMostOnce(t *testing.T) {
@@ -148,10 +163,6 @@ func Test_server_NoAtMostOnce(t *testing.T) {
 		return &res
 	}
 
-	intPtr := func(i int64) *int64 {
-		return &i
-	}
-
 	type args struct {
 		json string
 	}
@@ -162,19 +173,19 @@ func Test_server_NoAtMostOnce(t *testing.T) {
 	}{
 		{"good1",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 1}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(1), Result: []byte(`{"C":3}`)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(1), Result: []byte(`{"C":3}`)}},
 		{"dup1",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 2, "B": 3}, "id": 1}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(1), Result: []byte(`{"C":5}`)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(1), Result: []byte(`{"C":5}`)}},
 		{"good2",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 2}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(2), Result: []byte(`{"C":3}`)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(2), Result: []byte(`{"C":3}`)}},
 		{"dup2",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 2, "B": 3}, "id": 2}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(2), Result: []byte(`{"C":5}`)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(2), Result: []byte(`{"C":5}`)}},
 		{"dup1_again",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 2, "B": 3}, "id": 1}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(1), Result: []byte(`{"C":5}`)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(1), Result: []byte(`{"C":5}`)}},
 	}
 
 	<-chStart
@@ -192,3 +203,205 @@ func Test_server_NoAtMostOnce(t *testing.T) {
 	}
 	close(chDoneTest)
 }
+
+func Test_server_AtMostOnce_ReplayResults(t *testing.T) {
+	executed := 0
+	release := make(chan struct{})
+	s := NewServer().WithAtMostOnce(ReplayResults())
+	s.MustRegister("add", func(arg *struct{ A, B int }) (*struct{ C int }, error) {
+		executed++
+		return &struct{ C int }{C: arg.A + arg.B}, nil
+	})
+	s.MustRegister("slow", func(arg int) (int, error) {
+		<-release
+		return arg, nil
+	})
+
+	request := func(id int64, method, params string) *Response {
+		return s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: method, Params: []byte(params), Id: Int64ID(id)})
+	}
+
+	// the duplicate gets the result of the original, even with other params
+	first := request(1, "add", `{"A": 1, "B": 2}`)
+	dup := request(1, "add", `{"A": 2, "B": 3}`)
+	if string(first.Result) != `{"C":3}` || string(dup.Result) != string(first.Result) || dup.Error != nil {
+		t.Errorf("❌ first = %s, dup = %s %v; want the same result", first.Result, dup.Result, dup.Error)
+	}
+	if executed != 1 {
+		t.Errorf("❌ executed %d times, want once", executed)
+	}
+
+	// a duplicate of a request in flight waits for its response
+	responses := make(chan *Response, 2)
+	for i := 0; i < 2; i++ {
+		go func() { responses <- request(2, "slow", `42`) }()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if resp := <-responses; string(resp.Result) != `42` {
+			t.Errorf("❌ response %d of slow = %s %v, want 42", i, resp.Result, resp.Error)
+		}
+	}
+
+	// errors of the method are replayed too
+	if resp := request(3, "add", `"not an object"`); resp.Error == nil {
+		t.Fatal("❌ want invalid params")
+	}
+	if resp := request(3, "add", `{"A": 1, "B": 2}`); resp.Error == nil || resp.Error.Code != ErrInvalidParams().Code {
+		t.Errorf("❌ duplicate of an invalid request = %s %v, want the same error", resp.Result, resp.Error)
+	}
+
+	stats := s.Stats()
+	if stats["dedupe.hits"] != 3 || stats["dedupe.entries"] != 3 {
+		t.Errorf("❌ stats = %v, want 3 hits of 3 entries", stats)
+	}
+}
+
+func Test_server_AtMostOnce_ReplayResults_streamed(t *testing.T) {
+	executed := 0
+	s := NewServer().WithAtMostOnce(ReplayResults())
+	s.MustRegister("list", func(arg int, w ResultWriter) error {
+		executed++
+		_, err := io.WriteString(w, "[1,2]")
+		return err
+	})
+	st := NewHttpServerTransport("")
+	st.Use(s)
+
+	// the duplicate of a response streamed is not replayed in part
+	post := func() string {
+		w := httptest.NewRecorder()
+		st.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","method":"list","params":1,"id":1}`)))
+		return strings.TrimSpace(w.Body.String())
+	}
+	if got, want := post(), `{"jsonrpc":"2.0","id":1,"result":[1,2]}`; got != want {
+		t.Errorf("❌ first = %s, want %s", got, want)
+	}
+	dup := post()
+	var resp Response
+	if err := json.Unmarshal([]byte(dup), &resp); err != nil || resp.Error == nil || resp.Error.Code != ErrAtMostOnce().Code {
+		t.Errorf("❌ dup = %s, want ErrAtMostOnce", dup)
+	}
+	if executed != 1 {
+		t.Errorf("❌ executed %d times, want once", executed)
+	}
+}
+
+func Test_server_AtMostOnce_bounded(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	executed := 0
+	s := NewServer().WithClock(clock).WithAtMostOnce(WithTTL(time.Minute), WithMaxEntries(2))
+	s.MustRegister("incr", func(n int) (int, error) { executed++; return n + 1, nil })
+
+	request := func(id int64) *Response {
+		return s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "incr", Params: []byte(`1`), Id: Int64ID(id)})
+	}
+
+	tests := []struct {
+		name    string
+		id      int64
+		advance time.Duration // before the request
+		dup     bool
+	}{
+		{"new 1", 1, 0, false},
+		{"dup 1", 1, 0, true},
+		{"new 2", 2, 30 * time.Second, false},
+		{"1 expired", 1, 30 * time.Second, false}, // 1 minute old
+		{"dup 2", 2, 0, true},
+		{"new 3, evicting 2", 3, 0, false},
+		{"2 evicted", 2, 0, false},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		before := executed
+		resp := request(tt.id)
+		if dup := resp.Error != nil && resp.Error.Code == ErrAtMostOnce().Code; dup != tt.dup {
+			t.Errorf("❌ %s: duplicate = %v, want %v (%v)", tt.name, dup, tt.dup, resp.Error)
+		}
+		if ran := executed > before; ran == tt.dup {
+			t.Errorf("❌ %s: executed = %v", tt.name, ran)
+		}
+	}
+
+	stats := s.Stats()
+	// expired 1, evicted 2 by 3, then 1 by 2
+	if stats["dedupe.entries"] != 2 || stats["dedupe.evictions"] != 3 {
+		t.Errorf("❌ stats = %v, want 2 entries after 3 evictions", stats)
+	}
+}
+
+func Test_server_AtMostOnce_perClient(t *testing.T) {
+	executed := 0
+	s := NewServer().WithAtMostOnce()
+	s.MustRegister("incr", func(n int) (int, error) { executed++; return n + 1, nil })
+
+	// two clients, both starting with the id 1, are not duplicates of each other
+	a := NewClient(&serverTransport{server: s})
+	b := NewClient(&serverTransport{server: s})
+	for _, c := range []Client{a, b} {
+		var ret int
+		if err := c.Call("incr", 1, &ret); err != nil || ret != 2 {
+			t.Errorf("❌ call = %v, %v; want 2", ret, err)
+		}
+	}
+	if executed != 2 {
+		t.Errorf("❌ executed %d times, want twice", executed)
+	}
+
+	ids := map[string]bool{}
+	for _, c := range []Client{a, b} {
+		id := c.(*client).identity
+		if len(id) != 36 || id[14] != '4' || ids[id] {
+			t.Errorf("❌ identity %q, want a new UUID", id)
+		}
+		ids[id] = true
+	}
+
+	// the same id of the same client is
+	request := func(client string) *Response {
+		id := int64(7)
+		return s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "incr", Params: []byte(`1`), Id: Int64ID(id), Client: client})
+	}
+	for _, tt := range []struct {
+		client string
+		dup    bool
+	}{
+		{"a", false},
+		{"b", false},
+		{"", false},
+		{"a", true},
+		{"", true},
+	} {
+		resp := request(tt.client)
+		if dup := resp.Error != nil && resp.Error.Code == ErrAtMostOnce().Code; dup != tt.dup {
+			t.Errorf("❌ client %q: duplicate = %v, want %v", tt.client, dup, tt.dup)
+		}
+	}
+}
+
+func Test_server_AtMostOnce_shed(t *testing.T) {
+	s := NewServer().WithMaxConcurrency(1).WithMaxQueue(0).WithAtMostOnce()
+	release := make(chan struct{})
+	s.MustRegister("hold", func(n int) (int, error) { <-release; return n, nil })
+	s.MustRegister("incr", func(n int) (int, error) { return n + 1, nil })
+
+	request := func(method string, id int64) *Response {
+		return s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: method, Params: []byte(`1`), Id: Int64ID(id)})
+	}
+	held := make(chan *Response)
+	go func() { held <- request("hold", 1) }()
+	for s.Stats()["concurrency.inflight"] != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// shed, then retried with the same id once the server is free: not a duplicate
+	if resp := request("incr", 2); resp.Error == nil || resp.Error.Code != ErrServerBusy().Code {
+		t.Fatalf("❌ not shed: %#v", resp)
+	}
+	close(release)
+	<-held
+	if resp := request("incr", 2); resp.Error != nil || string(resp.Result) != "2" {
+		t.Errorf("❌ retry after shed: %v, want 2", resp.Error)
+	}
+}
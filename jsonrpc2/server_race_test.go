@@ -0,0 +1,109 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Test_server_concurrency stresses the concurrency contract of Server:
+// registering methods while serving requests. Run it with -race.
+func Test_server_concurrency(t *testing.T) {
+	const (
+		registrars = 4
+		servers    = 8
+		methods    = 50 // per registrar
+	)
+
+	s := NewServer().WithAtMostOnce().WithBatchParallelism(2)
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	events := s.Events()
+
+	name := func(r, i int) string { return fmt.Sprintf("m%d.%d", r, i) }
+
+	var wg sync.WaitGroup
+	for r := 0; r < registrars; r++ {
+		r := r
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < methods; i++ {
+				i := i
+				if err := s.Register(name(r, i), func(arg int) (int, error) { return arg + i, nil }); err != nil {
+					t.Error(err)
+				}
+				// a name taken concurrently must fail, never overwrite
+				if err := s.RegisterAll(map[string]any{name(r, i): func(arg int) (int, error) { return -1, nil }}); err == nil {
+					t.Errorf("❌ %s registered twice", name(r, i))
+				}
+			}
+		}()
+	}
+
+	var nextId int64
+	var idMu sync.Mutex
+	newId := func() *ID {
+		idMu.Lock()
+		defer idMu.Unlock()
+		nextId++
+		return Int64ID(nextId)
+	}
+
+	for w := 0; w < servers; w++ {
+		w := w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < methods; i++ {
+				method := name(w%registrars, i)
+				resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: method, Params: []byte(`1`), Id: newId()})
+				// a method is either not registered yet, or the right one
+				if resp.Error != nil && resp.Error.Code != ErrMethodNotFound().Code {
+					t.Errorf("❌ %s: %v", method, resp.Error)
+				}
+				if resp.Error == nil && string(resp.Result) != fmt.Sprint(1+i) {
+					t.Errorf("❌ %s = %s, want %d", method, resp.Result, 1+i)
+				}
+
+				batch := []json.RawMessage{
+					json.RawMessage(fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","params":%d,"id":%s}`, i, *newId())),
+					json.RawMessage(fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,"params":1,"id":%s}`, method, *newId())),
+				}
+				for _, resp := range s.ServeBatch(context.Background(), batch) {
+					if resp.Error != nil && resp.Error.Code != ErrMethodNotFound().Code {
+						t.Errorf("❌ batch: %v", resp.Error)
+					}
+				}
+
+				_ = s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: MethodDiscover, Params: []byte(`null`), Id: newId()})
+				_ = s.Stats()
+			}
+		}()
+	}
+
+	// drain the events meanwhile
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-events:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+
+	var doc DiscoverResult
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: MethodDiscover, Params: []byte(`null`), Id: newId()})
+	_ = json.Unmarshal(resp.Result, &doc)
+	if want := registrars*methods + 1 + 6; len(doc.Methods) != want { // + echo + builtins
+		t.Errorf("❌ %d methods, want %d", len(doc.Methods), want)
+	} else {
+		t.Logf("✅ %d methods registered while serving", len(doc.Methods))
+	}
+}
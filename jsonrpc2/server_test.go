@@ -2,11 +2,13 @@ package jsonrpc2
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -16,11 +18,14 @@ func Test_newMethod(t *testing.T) {
 
 	var (
 		noArg       = func() (*retT, error) { return &retT{}, nil }
-		tooManyArgs = func(a *argT, b int) (*retT, error) { return &retT{}, nil }
+		positional  = func(a *argT, b int) (*retT, error) { return &retT{}, nil }
+		ctxNotFirst = func(a *argT, ctx context.Context) (*retT, error) { return &retT{}, nil }
 		retWrong    = func(a *argT) error { return nil }
 		retNoErr    = func(a *argT) (int, float32) { return 1, 1.0 }
 		expected    = func(a *argT) (*retT, error) { return &retT{}, nil }
 		array       = func(a []int) (*retT, error) { return &retT{}, nil }
+		withContext = func(ctx context.Context, a *argT) (*retT, error) { return &retT{}, nil }
+		ctxNoArg    = func(ctx context.Context) (*retT, error) { return &retT{}, nil }
 	)
 
 	type args struct {
@@ -37,7 +42,12 @@ func Test_newMethod(t *testing.T) {
 		{"int", args{1}, nil, true},
 		{"emptyFunc", args{func() {}}, nil, true},
 		{"noArg", args{noArg}, nil, true},
-		{"tooManyArgs", args{tooManyArgs}, nil, true},
+		{"positional", args{positional}, &method{
+			function: reflect.ValueOf(positional),
+			inTypes:  []reflect.Type{reflect.TypeOf(&argT{}), reflect.TypeOf(0)},
+			outType:  reflect.TypeOf(&retT{}),
+		}, false},
+		{"ctxNotFirst", args{ctxNotFirst}, nil, true},
 		{"retWrong", args{retWrong}, nil, true},
 		{"retNoErr", args{retNoErr}, nil, true},
 		{"expected", args{expected}, &method{
@@ -50,6 +60,12 @@ func Test_newMethod(t *testing.T) {
 			inType:   reflect.TypeOf([]int{}),
 			outType:  reflect.TypeOf(&retT{}),
 		}, false},
+		{"withContext", args{withContext}, &method{
+			function: reflect.ValueOf(withContext),
+			inType:   reflect.TypeOf(&argT{}),
+			outType:  reflect.TypeOf(&retT{}),
+		}, false},
+		{"ctxNoArg", args{ctxNoArg}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -121,6 +137,7 @@ func Test_method_unmarshalParam(t *testing.T) {
 	type fields struct {
 		function reflect.Value
 		inType   reflect.Type
+		inTypes  []reflect.Type
 		outType  reflect.Type
 	}
 	type args struct {
@@ -206,6 +223,7 @@ func Test_method_call(t *testing.T) {
 	type fields struct {
 		function reflect.Value
 		inType   reflect.Type
+		inTypes  []reflect.Type
 		outType  reflect.Type
 	}
 	type args struct {
@@ -244,8 +262,6 @@ func Test_method_call(t *testing.T) {
 }
 
 func Test_method_serveRequest(t *testing.T) {
-	intPtr := func(i int64) *int64 { return &i }
-
 	f := func(a int) (int, error) {
 		return a, nil
 	}
@@ -257,6 +273,7 @@ func Test_method_serveRequest(t *testing.T) {
 	type fields struct {
 		function reflect.Value
 		inType   reflect.Type
+		inTypes  []reflect.Type
 		outType  reflect.Type
 	}
 	type args struct {
@@ -270,16 +287,16 @@ func Test_method_serveRequest(t *testing.T) {
 	}{
 		{"nil",
 			fields(*m), args{req: nil},
-			&Response{JsonRpc: JsonRpc2, Error: ErrInvalidRequest().withReason("nil request")}},
+			&Response{JsonRpc: JsonRpc2, Error: ErrInvalidRequest().WithReason("nil request")}},
 		{"empty",
 			fields(*m), args{req: &Request{}},
-			&Response{JsonRpc: JsonRpc2, Id: nil, Error: ErrInvalidParams().withReason("params should not be nil")}},
+			&Response{JsonRpc: JsonRpc2, Id: nil, Error: ErrInvalidParams().WithReason("params should not be nil")}},
 		{"noParam",
-			fields(*m), args{req: &Request{Id: intPtr(1)}},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(1), Error: ErrInvalidParams().withReason("params should not be nil")}},
+			fields(*m), args{req: &Request{Id: Int64ID(1)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(1), Error: ErrInvalidParams().WithReason("params should not be nil")}},
 		{"good",
-			fields(*m), args{req: &Request{Id: intPtr(1), Params: []byte(`2`)}},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(1), Result: []byte(`2`)}},
+			fields(*m), args{req: &Request{Id: Int64ID(1), Params: []byte(`2`)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(1), Result: []byte(`2`)}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -319,7 +336,7 @@ func Test_server_Register(t *testing.T) {
 	})
 
 	t.Run("badParam", func(t *testing.T) {
-		err := s.Register("add", func(a int, b int) (int, error) { return a + b, nil })
+		err := s.Register("add", func(a int, ctx context.Context) (int, error) { return a, nil })
 		if err == nil {
 			t.Fatal(err)
 		}
@@ -406,10 +423,6 @@ func Test_server_ServeHTTP(t *testing.T) {
 		return &res
 	}
 
-	intPtr := func(i int64) *int64 {
-		return &i
-	}
-
 	type args struct {
 		json string
 	}
@@ -420,19 +433,19 @@ func Test_server_ServeHTTP(t *testing.T) {
 	}{
 		{"good",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 1}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(1), Result: []byte(`{"C":3}`)}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(1), Result: []byte(`{"C":3}`)}},
 		{"err",
 			args{`{"jsonrpc": "2.0", "method": "err", "params": {"A": 1, "B": 2}, "id": 2}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(2), Error: &Error{Code: -1, Message: "error"}}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(2), Error: &Error{Code: -1, Message: "error"}}},
 		{"badMethod",
 			args{`{"jsonrpc": "2.0", "method": "add1", "params": {"A": 1, "B": 2}, "id": 3}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(3), Error: ErrMethodNotFound()}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(3), Error: ErrMethodNotFound()}},
 		{"badParams",
 			args{`{"jsonrpc": "2.0", "method": "add", "params": {"A": "foo"}, "id": 4}`},
-			&Response{JsonRpc: JsonRpc2, Id: intPtr(4), Error: ErrInvalidParams().withReason("json: cannot unmarshal string into Go struct field .A of type int")}},
+			&Response{JsonRpc: JsonRpc2, Id: Int64ID(4), Error: ErrInvalidParams().WithReason("json: cannot unmarshal string into Go struct field .A of type int")}},
 		{"badJson",
 			args{`{"jsonrpc": "2.0", "met`},
-			&Response{JsonRpc: JsonRpc2, Id: nil, Error: ErrParseError().withReason("unexpected EOF")}},
+			&Response{JsonRpc: JsonRpc2, Id: nil, Error: ErrParseError().WithReason("unexpected EOF")}},
 	}
 
 	<-chStart
@@ -450,3 +463,217 @@ func Test_server_ServeHTTP(t *testing.T) {
 	}
 	close(chDoneTest)
 }
+
+func Test_server_RegisterAll(t *testing.T) {
+	s := NewServer()
+	add := func(arg *struct{ A, B int }) (*struct{ C int }, error) {
+		return &struct{ C int }{C: arg.A + arg.B}, nil
+	}
+
+	if err := s.RegisterAll(map[string]any{"add": add, "sub": add}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("taken", func(t *testing.T) {
+		err := s.RegisterAll(map[string]any{"mul": add, "add": add})
+		if err == nil {
+			t.Fatal("expect error")
+		}
+		if err := s.Register("mul", add); err != nil {
+			t.Errorf("mul should not be registered by the failed RegisterAll: %v", err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err := s.RegisterAll(map[string]any{"div": add, "bad": 1})
+		if err == nil {
+			t.Fatal("expect error")
+		}
+		if err := s.Register("div", add); err != nil {
+			t.Errorf("div should not be registered by the failed RegisterAll: %v", err)
+		}
+	})
+
+	t.Run("MustRegister", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expect panic")
+			}
+		}()
+		s.MustRegister("add", add)
+	})
+}
+
+// calculator is a service for Test_server_RegisterService.
+type calculator struct{ base int }
+
+func (c *calculator) Add(arg *struct{ A, B int }) (*struct{ C int }, error) {
+	return &struct{ C int }{C: c.base + arg.A + arg.B}, nil
+}
+
+func (c *calculator) Base(ctx context.Context, arg int) (int, error) { return c.base, nil }
+
+func (c *calculator) Reset() { c.base = 0 } // not a method to serve
+
+func (c *calculator) neg(arg int) (int, error) { return -arg, nil } // unexported
+
+func Test_server_RegisterService(t *testing.T) {
+	s := NewServer()
+	if err := s.RegisterService("calc", &calculator{base: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		params string
+		want   string
+	}{
+		{"calc.Add", `{"A":1,"B":2}`, `{"jsonrpc":"2.0","result":{"C":13},"id":1}`},
+		{"calc.Base", `0`, `{"jsonrpc":"2.0","result":10,"id":1}`},
+		{"calc.Reset", `0`, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`},
+		{"calc.neg", `1`, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`},
+	}
+	for _, tt := range tests {
+		resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: tt.method, Params: json.RawMessage(tt.params), Id: Int64ID(1)})
+		if got, _ := json.Marshal(resp); string(got) != tt.want {
+			t.Errorf("❌ %s: got %s, want %s", tt.method, got, tt.want)
+		}
+	}
+
+	t.Run("taken", func(t *testing.T) {
+		if err := s.RegisterService("calc", &calculator{}); err == nil {
+			t.Error("expect error")
+		}
+	})
+
+	t.Run("nothing to register", func(t *testing.T) {
+		if err := s.RegisterService("nil", nil); err == nil {
+			t.Error("expect error")
+		}
+		if err := s.RegisterService("calc2", calculator{}); err == nil {
+			t.Error("expect error: the methods are of the pointer")
+		}
+	})
+}
+
+func Test_server_omittedParams(t *testing.T) {
+	type point struct{ X, Y int }
+	s := NewServer()
+	s.MustRegister("raw", func(p json.RawMessage) (string, error) { return string(p), nil })
+	s.MustRegister("pointer", func(p *point) (bool, error) { return p == nil, nil })
+	s.MustRegister("typed", Typed(func(p *point) (bool, error) { return p == nil, nil }))
+	s.MustRegister("streaming", Streaming(func(p *point, send Sender[bool]) error { return send.Send(p == nil) }))
+	s.MustRegister("value", func(p point) (bool, error) { return true, nil })
+
+	// the params omitted are null to the methods taking it
+	tests := []struct {
+		method string
+		result string // "" for ErrInvalidParams
+	}{
+		{"raw", `"null"`},
+		{"pointer", `true`},
+		{"typed", `true`},
+		{"streaming", `[true]`},
+		{"value", ``},
+	}
+	for _, tt := range tests {
+		resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: tt.method, Id: Int64ID(1)})
+		switch {
+		case tt.result == "" && (resp.Error == nil || resp.Error.Code != ErrInvalidParams().Code):
+			t.Errorf("❌ %s: %s %v, want ErrInvalidParams", tt.method, resp.Result, resp.Error)
+		case tt.result != "" && (resp.Error != nil || string(resp.Result) != tt.result):
+			t.Errorf("❌ %s: %s %v, want %s", tt.method, resp.Result, resp.Error, tt.result)
+		}
+	}
+}
+
+func Test_server_positionalParams(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("repeat", func(ctx context.Context, n int, str string) (string, error) {
+		return strings.Repeat(str, n), nil
+	})
+
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`[2, "ab"]`, `{"jsonrpc":"2.0","result":"abab","id":1}`},
+		{`[2]`, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":{"reason":"params should be an array of 2"}},"id":1}`},
+		{`{"n": 2, "str": "ab"}`, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":{"reason":"params should be an array of 2"}},"id":1}`},
+		{`[2, 3]`, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":{"reason":"params[1]: json: cannot unmarshal number into Go value of type string"}},"id":1}`},
+	}
+	for _, tt := range tests {
+		resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: "repeat", Params: json.RawMessage(tt.params), Id: Int64ID(1)})
+		if got, _ := json.Marshal(resp); string(got) != tt.want {
+			t.Errorf("❌ %s: got %s, want %s", tt.params, got, tt.want)
+		}
+	}
+
+	// coerced item by item
+	s.WithParamCoercion(true)
+	var got string
+	if err := NewClient(&serverTransport{server: s}).Call("repeat", []any{"3", "x"}, &got); err != nil || got != "xxx" {
+		t.Errorf("❌ coerced: got %q, %v", got, err)
+	}
+
+	resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: MethodDescribe, Params: []byte(`"repeat"`), Id: Int64ID(1)})
+	if want := `{"name":"repeat","params":[{"name":"params","schema":{"type":"array","prefixItems":[{"type":"integer"},{"type":"string"}]}}],"result":{"name":"result","schema":{"type":"string"}}}`; string(resp.Result) != want {
+		t.Errorf("❌ described as %s, want %s", resp.Result, want)
+	}
+	var desc MethodDescriptor
+	_ = json.Unmarshal(resp.Result, &desc)
+	if err := desc.Params[0].Schema.Validate([]byte(`[1, 2]`)); err == nil || err.Error() != "params[1]: want string, got 2" {
+		t.Errorf("❌ validated by position: %v", err)
+	}
+}
+
+func Test_server_Register_concurrent(t *testing.T) {
+	s := NewServer()
+	f := func(a int) (int, error) { return a, nil }
+
+	const n = 16
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			errs <- s.Register("same", f)
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		if <-errs == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent registrations succeeded, want 1", succeeded)
+	}
+}
+
+func Test_server_WithReadinessGate(t *testing.T) {
+	s := NewServer().WithReadinessGate()
+	s.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil })
+
+	id := int64(1)
+	add := &Request{JsonRpc: JsonRpc2, Method: "add", Params: json.RawMessage(`[1,2]`), Id: Int64ID(id)}
+	health := &Request{JsonRpc: JsonRpc2, Method: MethodHealth, Id: Int64ID(id)}
+
+	resp := s.ServeRPC(context.Background(), add)
+	if resp.Error == nil || resp.Error.Code != ErrNotReady().Code {
+		t.Fatalf("❌ want ErrNotReady before SetReady, got %+v", resp)
+	}
+	if !bytes.Contains(resp.Error.Data, []byte(`"retry_after_ms":1000`)) {
+		t.Errorf("❌ want a retry hint in the error data, got %s", resp.Error.Data)
+	}
+	if resp := s.ServeRPC(context.Background(), health); string(resp.Result) != `{"status":"starting"}` {
+		t.Errorf("❌ health before SetReady = %s, %v", resp.Result, resp.Error)
+	}
+
+	s.SetReady()
+	if resp := s.ServeRPC(context.Background(), add); string(resp.Result) != `3` {
+		t.Errorf("❌ add after SetReady = %s, %v", resp.Result, resp.Error)
+	}
+	if resp := s.ServeRPC(context.Background(), health); string(resp.Result) != `{"status":"ok"}` {
+		t.Errorf("❌ health after SetReady = %s, %v", resp.Result, resp.Error)
+	}
+}
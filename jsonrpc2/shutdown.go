@@ -0,0 +1,112 @@
+package jsonrpc2
+
+// 这个文件实现流式传输层的优雅关闭 (graceful shutdown)：
+// 停止接受新连接，停止读取新请求，等已读到的请求都回复之后再关闭连接。
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrTransportClosed is returned by the Serve of a server transport once
+// it's Shutdown.
+var ErrTransportClosed = errors.New("jsonrpc2: transport shut down")
+
+// serving tracks the listeners and the connections of a StreamServerTransport,
+// to shut them down.
+type serving struct {
+	mu        sync.Mutex
+	closing   bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]context.CancelFunc // cancelling the requests of the conn
+	wg        sync.WaitGroup                  // of the conns
+}
+
+// trackListener adds (or removes, if !add) l. It's false if closing:
+// l must not be served.
+func (s *serving) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.listeners, l)
+		return true
+	}
+	if s.closing {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+// trackConn adds conn, served until cancel. It's false if closing:
+// conn must not be served.
+func (s *serving) trackConn(conn net.Conn, cancel context.CancelFunc) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]context.CancelFunc)
+	}
+	s.conns[conn] = cancel
+	s.wg.Add(1)
+	return true
+}
+
+// untrackConn removes conn, once it's served.
+func (s *serving) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+	s.wg.Done()
+}
+
+func (s *serving) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// Shutdown stops Serve and ServeListener gracefully: it closes the
+// listeners, stops reading requests from the connections, waits for the
+// requests already read to be answered, and closes the connections.
+// If ctx is done first, the connections left are closed at once, their
+// requests in flight cancelled, and the error of ctx is returned.
+// Serve and ServeListener return ErrTransportClosed once it's called.
+func (t *StreamServerTransport) Shutdown(ctx context.Context) error {
+	s := &t.serving
+	s.mu.Lock()
+	s.closing = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.SetReadDeadline(time.Now()) // stop reading, see connServing.draining
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn, cancel := range s.conns {
+			cancel()
+			conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
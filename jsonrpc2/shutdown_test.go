@@ -0,0 +1,144 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// slowServer has a method "slow" answering once release is closed,
+// telling started when it's called.
+func slowServer() (s Server, started chan struct{}, release chan struct{}) {
+	started, release = make(chan struct{}, 1), make(chan struct{})
+	s = NewServer()
+	s.MustRegister("slow", func(ctx context.Context, arg int) (int, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return arg, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	})
+	return s, started, release
+}
+
+func TestStreamServerTransport_Shutdown(t *testing.T) {
+	s, started, release := slowServer()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := NewTcpServerTransport("")
+	served := make(chan error, 1)
+	go func() { served <- st.ServeListener(l, s) }()
+
+	c := NewClient(NewTcpClientTransport(l.Addr().String()))
+	defer c.Close()
+	called := make(chan error, 1)
+	go func() {
+		var got int
+		called <- c.Call("slow", 1, &got)
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- st.Shutdown(context.Background()) }()
+	if err := <-served; !errors.Is(err, ErrTransportClosed) {
+		t.Errorf("❌ ServeListener returned %v, want ErrTransportClosed", err)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("❌ Shutdown returned %v before the request in flight was answered", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-called; err != nil {
+		t.Errorf("❌ the request in flight failed: %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("❌ Shutdown: %v", err)
+	}
+	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		t.Errorf("❌ still accepting connections after Shutdown")
+	}
+}
+
+func TestStreamServerTransport_Shutdown_timeout(t *testing.T) {
+	s, started, _ := slowServer()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := NewTcpServerTransport("")
+	go st.ServeListener(l, s)
+
+	c := NewClient(NewTcpClientTransport(l.Addr().String()))
+	defer c.Close()
+	called := make(chan error, 1)
+	go func() {
+		var got int
+		called <- c.Call("slow", 1, &got)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := st.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("❌ Shutdown = %v, want DeadlineExceeded", err)
+	}
+	if err := <-called; err == nil {
+		t.Errorf("❌ want the request in flight cut off")
+	}
+}
+
+func TestHttpServerTransport_Shutdown(t *testing.T) {
+	s, started, release := slowServer()
+
+	// a free port, for Serve to listen on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	st := NewHttpServerTransport(addr)
+	served := make(chan error, 1)
+	go func() { served <- st.Serve(s) }()
+
+	c := NewClient(NewHttpClientTransport("http://" + addr))
+	called := make(chan error, 1)
+	go func() {
+		var got int
+		var err error
+		for i := 0; i < 50; i++ { // while the server starts
+			if err = c.Call("slow", 1, &got); err == nil || errors.As(err, new(*Error)) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		called <- err
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- st.Shutdown(context.Background()) }()
+	if err := <-served; !errors.Is(err, ErrTransportClosed) {
+		t.Errorf("❌ Serve returned %v, want ErrTransportClosed", err)
+	}
+
+	close(release)
+	if err := <-called; err != nil {
+		t.Errorf("❌ the request in flight failed: %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("❌ Shutdown: %v", err)
+	}
+	if err := st.Serve(s); !errors.Is(err, ErrTransportClosed) {
+		t.Errorf("❌ Serve after Shutdown = %v, want ErrTransportClosed", err)
+	}
+}
@@ -0,0 +1,77 @@
+package jsonrpc2
+
+// 这个文件实现基于标准输入输出的传输层，分帧与 StreamServerTransport 相同 (即 LSP 的分帧)。
+// 客户端启动一个子进程，通过它的 stdin/stdout 调用 (见 stdio_client.go)；服务端即是那个子进程，
+// 从自己的 stdin 读请求、向 stdout 写响应。可用于实现语言服务器 (LSP) 或插件进程。
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// StdioServerTransport serves jsonrpc2 over the stdin and stdout of the
+// process, with Content-Length framed messages (as LSP does), e.g. for a
+// language server or a plugin started by a StdioClientTransport.
+//
+// Requests are served concurrently, as on a stream connection.
+type StdioServerTransport struct {
+	// In and Out carry the requests and the responses.
+	// nil means os.Stdin and os.Stdout.
+	//
+	// With Out nil, Serve takes over os.Stdout: os.Stdout is pointed to
+	// os.Stderr, so that prints (including the logs of this package)
+	// don't corrupt the responses.
+	In  io.Reader
+	Out io.Writer
+
+	// RequestTimeout, MaxConnConcurrency and MaxConnQueue work as for
+	// StreamServerTransport.
+	RequestTimeout     time.Duration
+	MaxConnConcurrency int
+	MaxConnQueue       int
+}
+
+func NewStdioServerTransport() *StdioServerTransport {
+	return &StdioServerTransport{}
+}
+
+// Serve the requests read from In until it's closed, e.g. the client
+// exited or closed the stdin of the process.
+func (t *StdioServerTransport) Serve(server Server) error {
+	in, out := t.In, t.Out
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stdout
+		os.Stdout = os.Stderr
+	}
+
+	st := &StreamServerTransport{
+		RequestTimeout:     t.RequestTimeout,
+		MaxConnConcurrency: t.MaxConnConcurrency,
+		MaxConnQueue:       t.MaxConnQueue,
+	}
+	ctx := WithTransportInfo(context.Background(), &TransportInfo{
+		Kind:   "stdio",
+		ConnID: nextConnID(),
+	})
+	st.serveStream(ctx, stdio{in, out}, server)
+	return nil
+}
+
+// stdio is a reader and a writer, as an io.ReadWriteCloser.
+// Closing closes the reader, if it's an io.Closer, to stop reading.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+func (s stdio) Close() error {
+	if c, ok := s.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
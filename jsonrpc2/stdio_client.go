@@ -0,0 +1,95 @@
+//go:build !js
+
+package jsonrpc2
+
+// 这个文件实现 stdio 传输层的客户端：启动子进程并通过它的 stdin/stdout 调用。
+// 浏览器 (GOOS=js) 中不能启动进程，不编译。
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// StdioClientTransport calls a subprocess serving jsonrpc2 over its stdin
+// and stdout (e.g. by a StdioServerTransport), like an LSP client calls
+// its language server. It must be made by NewStdioClientTransport.
+//
+// The subprocess is started on the first call, and restarted on the next
+// call after it exits. As over TCP, concurrent calls are pipelined.
+// Close closes the stdin of the subprocess, asking it to exit, and kills
+// it if it's still running a few seconds later.
+type StdioClientTransport struct {
+	StreamClientTransport
+
+	// Command makes the command starting the subprocess, afresh for every
+	// start: an exec.Cmd can't be reused. Its Stdin and Stdout are taken
+	// by the transport.
+	Command func() *exec.Cmd
+}
+
+// stdioExitTimeout is how long a subprocess is given to exit once its
+// stdin is closed.
+const stdioExitTimeout = 3 * time.Second
+
+// NewStdioClientTransport calls the subprocess started by running the
+// program name with arg, its stderr passed through to os.Stderr.
+func NewStdioClientTransport(name string, arg ...string) *StdioClientTransport {
+	t := &StdioClientTransport{
+		Command: func() *exec.Cmd {
+			cmd := exec.Command(name, arg...)
+			cmd.Stderr = os.Stderr
+			return cmd
+		},
+	}
+	t.Network = "stdio"
+	t.Addr = name
+	t.dial = t.start
+	return t
+}
+
+// start the subprocess, connecting to its stdin and stdout.
+func (t *StdioClientTransport) start(ctx context.Context) (messageConn, error) {
+	cmd := t.Command()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return newFramedConn(&processPipes{stdout, stdin, cmd}), nil
+}
+
+// processPipes is the stdout and stdin of a started cmd,
+// as an io.ReadWriteCloser.
+type processPipes struct {
+	io.ReadCloser  // stdout
+	io.WriteCloser // stdin
+	cmd            *exec.Cmd
+}
+
+// Close the stdin of the process and wait for it to exit,
+// killing it if it doesn't in stdioExitTimeout.
+func (p *processPipes) Close() error {
+	_ = p.WriteCloser.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- p.cmd.Wait() }()
+
+	timer := time.NewTimer(stdioExitTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-exited:
+		return err
+	case <-timer.C:
+		_ = p.cmd.Process.Kill()
+		return <-exited
+	}
+}
@@ -0,0 +1,101 @@
+//go:build !js
+
+package jsonrpc2
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newStdioTestServer() Server {
+	s := NewServer()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+	s.MustRegister("kind", func(ctx context.Context, arg int) (string, error) {
+		info, _ := TransportInfoFromContext(ctx)
+		return info.Kind, nil
+	})
+	return s
+}
+
+// Test_StdioHelperProcess is not a real test: it's the subprocess started
+// by Test_StdioTransport, serving on its stdin and stdout.
+func Test_StdioHelperProcess(t *testing.T) {
+	if os.Getenv("JSONRPC2_STDIO_HELPER") != "1" {
+		t.Skip("only run as a subprocess")
+	}
+	_ = NewStdioServerTransport().Serve(newStdioTestServer())
+	os.Exit(0)
+}
+
+func Test_StdioServerTransport(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		st := &StdioServerTransport{In: inR, Out: outW}
+		_ = st.Serve(newStdioTestServer())
+	}()
+
+	c := newFramedConn(stdio{outR, inW})
+	if err := c.writeMessage([]byte(`{"jsonrpc":"2.0","method":"kind","params":1,"id":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	body, err := c.readMessage()
+	if want := `{"jsonrpc":"2.0","result":"stdio","id":1}`; err != nil || string(body) != want {
+		t.Fatalf("❌ got %s, %v\nwant %s", body, err, want)
+	}
+
+	// the server is done once its input is closed
+	inW.Close()
+	<-done
+	t.Logf("✅ %s", body)
+}
+
+func Test_StdioTransport(t *testing.T) {
+	ct := NewStdioClientTransport(os.Args[0], "-test.run=^Test_StdioHelperProcess$")
+	ct.Command = func() *exec.Cmd {
+		cmd := exec.Command(os.Args[0], "-test.run=^Test_StdioHelperProcess$")
+		cmd.Env = append(os.Environ(), "JSONRPC2_STDIO_HELPER=1")
+		cmd.Stderr = os.Stderr
+		return cmd
+	}
+	defer ct.Close()
+	c := NewClient(ct)
+
+	var kind string
+	if err := c.Call("kind", 1, &kind); err != nil || kind != "stdio" {
+		t.Fatalf("❌ kind = %q, %v", kind, err)
+	}
+
+	// pipelined over the pipes of one process
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			arg := strings.Repeat("x", n*1000)
+			var ret string
+			if err := c.Call("echo", arg, &ret); err != nil || ret != arg {
+				t.Errorf("❌ echo of %d bytes: %d bytes, %v", len(arg), len(ret), err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// the process exits once closed, and is restarted by the next call
+	if err := ct.Close(); err != nil {
+		t.Fatalf("❌ Close: %v", err)
+	}
+	var ret string
+	if err := c.Call("echo", "again", &ret); err != nil || ret != "again" {
+		t.Fatalf("❌ echo after restart = %q, %v", ret, err)
+	}
+	t.Logf("✅ calls over stdio")
+}
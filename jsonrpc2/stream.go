@@ -0,0 +1,1145 @@
+package jsonrpc2
+
+// 这个文件实现基于流 (TCP、Unix socket 等) 的传输层。
+//
+// 一条连接上可以同时有多个请求在途 (pipelining)：服务端并发地处理同一连接上的请求，
+// 响应按完成的先后写回，客户端按 id 把响应分派给对应的调用。
+//
+// 消息的分帧 (framing) 与 LSP 相同，每条消息之前是一个 Content-Length 头：
+//
+//     Content-Length: 42\r\n
+//     \r\n
+//     {"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}
+//
+// 与 HTTP 相比，一条长连接省去了每次调用的连接建立与 HTTP 头的开销，适合低延迟的小请求。
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxFrameSize is the MaxFrameSize of the stream transports not setting it.
+const DefaultMaxFrameSize = 32 << 20
+
+// maxFrameHeader bounds the size of the header of a frame.
+const maxFrameHeader = 4 << 10
+
+// errBadFrame is a frame breaking the framing, after which the stream can't
+// be read any further: the connection must be closed.
+var errBadFrame = errors.New("bad frame")
+
+// readFrame reads the body of a Content-Length framed message from r,
+// of at most maxSize bytes (<= 0 means DefaultMaxFrameSize). A frame too
+// big or with a corrupted header fails with errBadFrame, before its body
+// is allocated.
+func readFrame(r *bufio.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+
+	length := int64(-1)
+	for headerSize := 0; ; {
+		line, err := r.ReadSlice('\n')
+		headerSize += len(line)
+		if err == bufio.ErrBufferFull || headerSize > maxFrameHeader {
+			return nil, fmt.Errorf("%w: header over %d bytes", errBadFrame, maxFrameHeader)
+		}
+		if err != nil {
+			if err == io.EOF && headerSize == 0 {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("bad frame header: %w", err)
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			break // end of the header
+		}
+		name, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			return nil, fmt.Errorf("%w: bad header line %q", errBadFrame, line)
+		}
+		if textproto.CanonicalMIMEHeaderKey(string(bytes.TrimSpace(name))) != "Content-Length" {
+			continue // e.g. Content-Type
+		}
+		length, err = strconv.ParseInt(string(bytes.TrimSpace(value)), 10, 64)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("%w: bad Content-Length: %q", errBadFrame, value)
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("%w: no Content-Length", errBadFrame)
+	}
+	if length > maxSize {
+		return nil, fmt.Errorf("%w: Content-Length %d over the max frame size %d", errBadFrame, length, maxSize)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeFrame writes body as a Content-Length framed message into w.
+func writeFrame(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// serveMessage serves a message (a request or a batch) and returns the
+// encoded response to send back, nil if there is none (notifications).
+// It is indented WithPretty, the same as over HTTP.
+func serveMessage(ctx context.Context, server Server, body []byte) ([]byte, error) {
+	pretty := prettyOf(server) || prettyFromContext(ctx)
+
+	if isBatch(body) {
+		batch, err := unmarshalBatch(body)
+		if err != nil {
+			return marshalFrame(errorResponse(nil, ErrParseError().WithReason(err.Error())), pretty)
+		}
+		responses := server.ServeBatch(ctx, batch)
+		// an empty batch is answered with a single error, not an array
+		if len(batch) == 0 && len(responses) == 1 {
+			return marshalFrame(responses[0], pretty || responses[0].pretty)
+		}
+		if len(responses) == 0 { // all notifications
+			return nil, nil
+		}
+		for _, resp := range responses {
+			pretty = pretty || resp.pretty
+		}
+		return marshalFrame(responses, pretty)
+	}
+
+	var req Request
+	if err := unmarshalRequest(bytes.NewReader(body), &req); err != nil {
+		// valid JSON that is not a Request object is an Invalid Request
+		rpcErr := ErrParseError()
+		if json.Valid(body) {
+			rpcErr = ErrInvalidRequest()
+		}
+		return marshalFrame(errorResponse(nil, rpcErr.WithReason(err.Error())), pretty)
+	}
+	if err := validateRequest(server, &req, body); err != nil {
+		return marshalFrame(errorResponse(req.Id, ErrInvalidRequest().WithReason(err.Error())), pretty)
+	}
+
+	resp := server.ServeRPC(ctx, &req)
+	if resp == nil {
+		if req.IsNotification() {
+			return nil, nil
+		}
+		return nil, errors.New("nil response")
+	}
+	if err := resp.validate(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := resp.marshal(&buf); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// marshalFrame encodes v as the body of a frame, indented if pretty.
+func marshalFrame(v any, pretty bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, v, pretty); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// serveLimited is serveMessage within the concurrency limit of l, if any.
+// A message rejected by l is answered with ErrServerBusy.
+func serveLimited(ctx context.Context, l *limiter, server Server, body []byte) ([]byte, error) {
+	if l == nil {
+		return serveMessage(ctx, server, body)
+	}
+
+	clock := clockOf(server)
+	if _, ok := l.acquire(ctx, clock, nopMetrics{}); !ok {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		retryAfter := l.retryAfter()
+		return rejectMessage(body, func() *Error {
+			return ErrServerBusy().withRetryAfter("too many concurrent requests on the connection", retryAfter)
+		})
+	}
+
+	start := clock.Now()
+	defer func() { l.release(nopMetrics{}, since(clock, start)) }()
+
+	return serveMessage(ctx, server, body)
+}
+
+// rejectMessage answers a message (a request or a batch) with the error
+// made by rpcErr for every request in it, without serving it.
+// Notifications are not answered, nil is returned if there is nothing else.
+func rejectMessage(body []byte, rpcErr func() *Error) ([]byte, error) {
+	var batch []Request
+	if isBatch(body) && json.Unmarshal(body, &batch) == nil && len(batch) > 0 {
+		responses := make([]*Response, 0, len(batch))
+		for _, entry := range batch {
+			if !entry.IsNotification() {
+				responses = append(responses, errorResponse(entry.Id, rpcErr()))
+			}
+		}
+		if len(responses) == 0 {
+			return nil, nil
+		}
+		return json.Marshal(responses)
+	}
+
+	var req Request
+	if err := json.Unmarshal(body, &req); err == nil && req.IsNotification() {
+		return nil, nil
+	}
+	return json.Marshal(errorResponse(req.Id, rpcErr()))
+}
+
+// serveIsolated serves a message like serveLimited, in the goroutine
+// calling it, so that neither a panic (out of the method calls, which
+// recover by themselves) nor a method blocking past timeout (if > 0) can
+// take the connection down: the message is answered with ErrInternalError
+// or ErrRequestTimeout instead, the latter by a timer while the method
+// keeps running, holding its slot of l. It returns once the method does.
+//
+// The answer (nil for notifications) is given to respond once, unless ctx
+// is done before (the connection is gone).
+func serveIsolated(ctx context.Context, timeout time.Duration, l *limiter, server Server, body []byte, respond func(out []byte, err error)) {
+	parent := ctx
+	var answered atomic.Bool
+	answer := func(out []byte, err error) {
+		if parent.Err() == nil && answered.CompareAndSwap(false, true) {
+			respond(out, err)
+		}
+	}
+
+	timedOut := func() {
+		answer(rejectMessage(body, func() *Error {
+			return ErrRequestTimeout().WithReason(fmt.Sprintf("not done in %v", timeout))
+		}))
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		timer := time.AfterFunc(timeout, timedOut)
+		defer timer.Stop()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			loggerOf(server).Log(LevelError, "recovered from serving request", Field{"panic", r})
+			answer(rejectMessage(body, func() *Error {
+				return ErrInternalError().WithReason(fmt.Sprint(r))
+			}))
+		}
+	}()
+	out, err := serveLimited(ctx, l, server, body)
+	if err == context.DeadlineExceeded && parent.Err() == nil {
+		timedOut() // waiting for l
+		return
+	}
+	answer(out, err)
+}
+
+// StreamServerTransport serves jsonrpc2 over a stream-oriented network,
+// e.g. TCP or Unix sockets, with Content-Length framed messages.
+type StreamServerTransport struct {
+	Network    string // "tcp", "unix", ... as for net.Listen
+	ListenAddr string
+
+	// FlushInterval is how long the responses of a connection may be held
+	// to coalesce them into one write (group commit).
+	// With 0, responses are still coalesced while others are being written,
+	// but a lone response is written at once.
+	FlushInterval time.Duration
+
+	// WriteTimeout bounds every write of responses into a connection.
+	// A client not reading its responses for that long is disconnected.
+	// 0 means no timeout.
+	WriteTimeout time.Duration
+
+	// MaxPendingBytes caps the responses of a connection waiting to be
+	// written. A client reading its responses slower than it sends
+	// requests is disconnected once it's exceeded, instead of pinning
+	// more and more server memory. 0 means no cap.
+	MaxPendingBytes int64
+
+	// MaxConnConcurrency limits the requests of a connection executing at
+	// once, so that one greedy connection can't monopolize the server.
+	// 0 means no limit.
+	//
+	// Requests beyond the limit wait in a FIFO queue of up to MaxConnQueue
+	// requests (< 0 means unbounded). When the queue is full as well, the
+	// requests are rejected with ErrServerBusy. The default MaxConnQueue of
+	// 0 rejects at once.
+	MaxConnConcurrency int
+	MaxConnQueue       int
+
+	// RequestTimeout bounds how long a request may take. A request not
+	// done in time is answered with ErrRequestTimeout, and its context is
+	// cancelled; a method ignoring the context keeps running in the
+	// background. 0 means no timeout.
+	RequestTimeout time.Duration
+
+	// MaxFrameSize bounds the size of the messages read (decompressed, if
+	// compressed). A connection sending a bigger one, or breaking the
+	// framing (e.g. a corrupted Content-Length), is answered an
+	// ErrInvalidRequest and closed, before the message is read.
+	// 0 means DefaultMaxFrameSize.
+	MaxFrameSize int64
+
+	// TLSConfig makes Serve serve over TLS, if not nil. It must provide the
+	// certificate, by Certificates or GetCertificate (e.g. a CertReloader).
+	TLSConfig *tls.Config
+
+	// MaxConnections caps the connections served at once. At the cap, no
+	// more connections are accepted until one closes: new ones wait in the
+	// backlog of the listener, not in file descriptors of the server.
+	// 0 means no cap.
+	MaxConnections int
+
+	// AcceptRate limits how many connections are accepted per second,
+	// e.g. to absorb a reconnect storm of clients. 0 means no limit.
+	AcceptRate int
+
+	// IPFilter turns away clients by their IP address: their connections
+	// are closed as soon as they are accepted, before reading anything.
+	// nil means no filtering.
+	IPFilter *IPFilter
+
+	// Compression lists the compression algorithms the connections may be
+	// compressed by, as negotiated by the clients (see MethodCompress):
+	// "deflate", or any registered by RegisterCompression. The client picks
+	// by its preference. nil means no compression.
+	Compression []string
+
+	// Authenticate, if not nil, requires the first message of every
+	// connection (after the MethodCompress one, if any) to be a MethodAuth
+	// call, checked by it. Connections failing it are answered
+	// ErrUnauthorized (or its error) and closed. The identity it returns is
+	// attached to the requests of the connection, see IdentityFromContext.
+	Authenticate Authenticator
+
+	// HandshakeTimeout bounds how long a connection may take to send its
+	// MethodAuth call, if Authenticate is set: one not sending it in time
+	// is closed, not to hold the server unauthenticated.
+	// 0 means DefaultHandshakeTimeout.
+	HandshakeTimeout time.Duration
+
+	// ReusePort makes Serve listen on a TCP address with SO_REUSEPORT,
+	// so that another process, e.g. an upgraded server, may listen on it
+	// at the same time, the connections spread between them. Serve fails
+	// on systems without it. See also Handoff.
+	ReusePort bool
+
+	serving     serving // see Shutdown
+	compression compressionStats
+}
+
+// WithMaxConnections 原址设置连接数上限 (见 MaxConnections)，并返回 StreamServerTransport 以供链式
+func (t *StreamServerTransport) WithMaxConnections(n int) *StreamServerTransport {
+	t.MaxConnections = n
+	return t
+}
+
+// Stats reports the bytes of the messages of the connections compressed,
+// see Compression: "compression.bytes_raw", "compression.bytes_compressed"
+// and "compression.ratio_pct" (the percentage of the raw bytes put on the wire).
+func (t *StreamServerTransport) Stats() map[string]int64 {
+	return t.compression.snapshot(make(map[string]int64))
+}
+
+// NewTcpServerTransport serves on the TCP address listenAddr, e.g. ":5680".
+func NewTcpServerTransport(listenAddr string) *StreamServerTransport {
+	return &StreamServerTransport{Network: "tcp", ListenAddr: listenAddr}
+}
+
+// NewUnixServerTransport serves on the Unix socket at path.
+func NewUnixServerTransport(path string) *StreamServerTransport {
+	return &StreamServerTransport{Network: "unix", ListenAddr: path}
+}
+
+// Serve listens on the address of t and serves every connection accepted.
+// A listener of the address inherited from the parent process (see
+// Handoff) is taken over instead.
+func (t *StreamServerTransport) Serve(server Server) error {
+	l, err := listen(t.Network, t.ListenAddr, t.ReusePort)
+	if err != nil {
+		return err
+	}
+	if t.TLSConfig != nil {
+		l = tls.NewListener(l, t.TLSConfig)
+	}
+	return t.ServeListener(l, server)
+}
+
+// ServeListener serves every connection accepted from l, until l fails.
+// Temporary failures of accept, like running out of file descriptors, are
+// retried after a backoff.
+func (t *StreamServerTransport) ServeListener(l net.Listener, server Server) error {
+	defer l.Close()
+	if !t.serving.trackListener(l, true) {
+		return ErrTransportClosed
+	}
+	defer t.serving.trackListener(l, false)
+
+	a := t.newAcceptor(l, loggerOf(server))
+	for {
+		conn, release, err := a.accept()
+		if err != nil {
+			if t.serving.isClosing() {
+				return ErrTransportClosed
+			}
+			return err
+		}
+		go func() {
+			defer release()
+			t.ServeConn(conn, server)
+		}()
+	}
+}
+
+// ServeConn serves the requests coming from conn until it's closed.
+// The requests are served concurrently, and the context given to the
+// server is cancelled once the connection is gone: the methods still in
+// flight should give up, as their responses can't be delivered anymore.
+func (t *StreamServerTransport) ServeConn(conn net.Conn, server Server) {
+	if !t.IPFilter.allowedAddr(conn.RemoteAddr()) {
+		conn.Close()
+		return
+	}
+
+	info := &TransportInfo{
+		Kind:       conn.LocalAddr().Network(),
+		LocalAddr:  conn.LocalAddr(),
+		RemoteAddr: conn.RemoteAddr(),
+		ConnID:     nextConnID(),
+	}
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err := tc.Handshake(); err != nil {
+			loggerOf(server).Log(LevelWarn, "TLS handshake failed", Field{"remote", conn.RemoteAddr()}, Field{"error", err})
+			conn.Close()
+			return
+		}
+		state := tc.ConnectionState()
+		info.TLS = &state
+	}
+	ctx, cancel := context.WithCancel(WithTransportInfo(context.Background(), info))
+	defer cancel()
+	if !t.serving.trackConn(conn, cancel) {
+		conn.Close()
+		return
+	}
+	defer t.serving.untrackConn(conn)
+	t.serveStream(ctx, conn, server)
+}
+
+// serveStream serves the framed messages read from rwc until it fails,
+// writing the responses back into rwc.
+func (t *StreamServerTransport) serveStream(ctx context.Context, rwc io.ReadWriteCloser, server Server) {
+	gw := newGroupWriter(&deadlineWriter{conn: rwc, timeout: t.WriteTimeout}, t.FlushInterval)
+	gw.maxPending = t.MaxPendingBytes
+
+	r := bufio.NewReader(rwc)
+	conn := connServing{
+		logger:         loggerOf(server),
+		requestTimeout: t.RequestTimeout,
+		maxConcurrency: t.MaxConnConcurrency,
+		maxQueue:       t.MaxConnQueue,
+		authenticate:   t.Authenticate,
+		handshake:      t.HandshakeTimeout,
+		compression:    t.Compression,
+		stats:          &t.compression,
+		maxMessage:     t.MaxFrameSize,
+		draining:       t.serving.isClosing,
+		read:           func() ([]byte, error) { return readFrame(r, t.MaxFrameSize) },
+		write:          gw.writeFrame,
+		drop:           func() { rwc.Close() },
+		close: func() {
+			_ = gw.flush()
+			rwc.Close()
+		},
+	}
+	if d, ok := rwc.(interface{ SetReadDeadline(time.Time) error }); ok {
+		conn.setReadDeadline = d.SetReadDeadline
+	}
+	conn.serve(ctx, server)
+}
+
+// connServing serves the messages of a connection, see serve.
+type connServing struct {
+	requestTimeout time.Duration     // see StreamServerTransport.RequestTimeout
+	maxConcurrency int               // see StreamServerTransport.MaxConnConcurrency
+	maxQueue       int               // see StreamServerTransport.MaxConnQueue
+	authenticate   Authenticator     // see StreamServerTransport.Authenticate, nil: none
+	handshake      time.Duration     // see StreamServerTransport.HandshakeTimeout
+	compression    []string          // see StreamServerTransport.Compression
+	stats          *compressionStats // counts the compressed messages, nil: none
+	maxMessage     int64             // of the messages decompressed, 0: DefaultMaxFrameSize
+	draining       func() bool       // the transport is shutting down: finish the requests read, nil: never
+	logger         Logger
+
+	read        func() ([]byte, error) // the next message
+	write       func([]byte) error     // a message, safe for concurrent use
+	writeBinary func([]byte) error     // a binary message (compressed), nil: by write
+	drop        func()                 // close the connection at once, idempotent
+	close       func()                 // close the connection once done, after drop too
+
+	setReadDeadline func(time.Time) error // of the connection, to time the handshake, nil: none
+}
+
+// serve the messages read until the connection fails, concurrently,
+// writing the responses back. Once it's gone, the requests in flight are
+// drained: cancelled and waited for, so none outlives the connection. The
+// connection is closed once their methods have returned, even the ones
+// answered already (timed out) or ignoring the cancellation.
+func (c connServing) serve(ctx context.Context, server Server) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var connLimiter *limiter
+	if c.maxConcurrency > 0 {
+		connLimiter = newLimiter(c.maxConcurrency, c.maxQueue)
+	}
+
+	var wg sync.WaitGroup // the requests being served, until their methods return
+	defer func() {
+		cancel()
+		wg.Wait()
+		c.close()
+	}()
+
+	// an unauthenticated connection may not hold the server for long
+	if c.authenticate != nil && c.setReadDeadline != nil {
+		timeout := c.handshake
+		if timeout <= 0 {
+			timeout = DefaultHandshakeTimeout
+		}
+		_ = c.setReadDeadline(time.Now().Add(timeout))
+	}
+
+	// the first message may negotiate the compression of the next ones
+	first, err := c.read()
+	if err != nil {
+		c.readFailed(err)
+		return
+	}
+	if req, ok := isCompressRequest(first); ok {
+		compression, err := answerCompress(req, c.compression, c.write)
+		if err != nil {
+			c.logger.Log(LevelWarn, "failed to write response", Field{"error", err})
+			return
+		}
+		if compression != nil {
+			c.compress(compression)
+		}
+		first = nil
+	}
+	// next reads the next message, first if it's not served yet
+	next := func() ([]byte, error) {
+		if body := first; body != nil {
+			first = nil
+			return body, nil
+		}
+		return c.read()
+	}
+
+	if c.authenticate != nil {
+		body, err := next()
+		if err != nil {
+			c.readFailed(err)
+			return
+		}
+		var ok bool
+		if ctx, ok = authenticate(ctx, c.authenticate, body, c.write, c.logger); !ok {
+			return
+		}
+		if c.setReadDeadline != nil {
+			if c.draining != nil && c.draining() {
+				return // its deadline of the shutdown would be cleared
+			}
+			_ = c.setReadDeadline(time.Time{})
+		}
+	}
+
+	// the streaming methods may send their values as they come
+	ctx = withPartialSink(ctx, c.write)
+	// and the methods may call the client back
+	peer := newPeer(c.write)
+	defer peer.close()
+	ctx = withPeer(ctx, peer)
+
+	for {
+		body, err := next()
+		if err != nil {
+			if c.draining != nil && c.draining() && !errors.Is(err, errBadFrame) {
+				wg.Wait() // answer the requests in flight before closing
+				return
+			}
+			c.readFailed(err)
+			return
+		}
+		if peer.deliver(body) {
+			continue // a response to a call of the peer
+		}
+
+		wg.Add(1)
+		goTask(server, func() {
+			defer wg.Done()
+
+			serveIsolated(ctx, c.requestTimeout, connLimiter, server, body, func(out []byte, err error) {
+				if err != nil {
+					c.logger.Log(LevelWarn, "failed to serve request", Field{"error", err})
+					return
+				}
+				if out == nil {
+					return // notifications: nothing to respond
+				}
+
+				if err := c.write(out); err != nil {
+					// a stalled or slow client: drop it, which stops reading
+					// its requests and cancels the ones in flight
+					c.logger.Log(LevelWarn, "failed to write response", Field{"error", err})
+					c.drop()
+				}
+			})
+		})
+	}
+}
+
+// readFailed reports the failure to read a message, err, before the
+// connection is closed. A frame breaking the framing is answered why.
+func (c *connServing) readFailed(err error) {
+	if err == io.EOF {
+		return
+	}
+	c.logger.Log(LevelWarn, "failed to read request", Field{"error", err})
+	if errors.Is(err, errBadFrame) {
+		if out, err := json.Marshal(errorResponse(nil, ErrInvalidRequest().WithReason(err.Error()))); err == nil {
+			_ = c.write(out)
+		}
+	}
+}
+
+// compress the messages of the connection from now on by compression.
+func (c *connServing) compress(compression *Compression) {
+	stats := c.stats
+	if stats == nil {
+		stats = new(compressionStats)
+	}
+	z := compressedIO{c: compression, stats: stats, limit: c.maxMessage}
+	write := c.write
+	if c.writeBinary != nil {
+		write = c.writeBinary
+	}
+	c.read = z.read(c.read)
+	c.write = z.write(write)
+}
+
+// StreamClientTransport sends jsonrpc2 requests over a stream-oriented
+// connection, e.g. TCP or Unix sockets, with Content-Length framed messages,
+// or WebSocket messages (see NewWebSocketClientTransport).
+//
+// All the calls share one connection, dialed on the first call and
+// redialed on the next call after it breaks. Concurrent calls are
+// pipelined: they don't wait for each other's responses.
+type StreamClientTransport struct {
+	Network string // "tcp", "unix", ... as for net.Dial
+	Addr    string
+
+	// Dialer makes the connections, e.g. through a tunnel (see
+	// NewTunnelClientTransport). nil means a net.Dialer: dial directly.
+	Dialer Dialer
+
+	// AuthParams, if not nil, are the params of the MethodAuth call sent
+	// first on every connection, e.g. a token, for servers requiring it
+	// (see StreamServerTransport.Authenticate). A connection failing it is
+	// closed, and the call dialing it fails with the error of the server.
+	AuthParams any
+
+	// Compression lists the compression algorithms offered to the server
+	// on every connection, the preferred first, e.g. []string{"deflate"}:
+	// the messages are compressed by the first one the server allows, if
+	// any (see MethodCompress). nil means no compression, nor negotiation.
+	Compression []string
+
+	// MaxFrameSize bounds the size of the responses read, as for
+	// StreamServerTransport: a connection breaking it is closed, failing
+	// its calls. 0 means DefaultMaxFrameSize. It's not for WebSocket
+	// connections, bounded by their WebSocketDialer.MaxMessageSize.
+	MaxFrameSize int64
+
+	// OrphanTimeout fails calls left without a response that long with
+	// ErrOrphaned, e.g. requests a buggy server dropped, even if their ctx
+	// has no deadline. 0 means calls wait as long as their ctx.
+	OrphanTimeout time.Duration
+	Clock         Clock // times OrphanTimeout, nil means SystemClock
+
+	// Logger logs the failures of the connections, e.g. responses for
+	// unknown ids. nil means that of the Client (see Client.WithLogger),
+	// if any, else a StdLogger.
+	Logger Logger
+
+	// Callbacks serves the calls of the server on the connections (see
+	// Peer), e.g. the events of a subscription it pushes. nil means none:
+	// they're answered ErrMethodNotFound.
+	Callbacks Server
+
+	// dial opens the connections instead of Dialer, if not nil,
+	// e.g. WebSocket ones (see NewWebSocketClientTransport).
+	dial func(ctx context.Context) (messageConn, error)
+
+	mu          sync.Mutex
+	conn        *streamConn
+	stats       clientStats
+	compression compressionStats
+	events      eventHandlers // see HandleEvents
+}
+
+// useLogger makes the Logger of t logger, unless it's set.
+func (t *StreamClientTransport) useLogger(logger Logger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Logger == nil {
+		t.Logger = logger
+	}
+}
+
+// NewTcpClientTransport connects to the TCP address addr, e.g. "localhost:5680".
+func NewTcpClientTransport(addr string) *StreamClientTransport {
+	return &StreamClientTransport{Network: "tcp", Addr: addr}
+}
+
+// NewUnixClientTransport connects to the Unix socket at path.
+func NewUnixClientTransport(path string) *StreamClientTransport {
+	return &StreamClientTransport{Network: "unix", Addr: path}
+}
+
+func (t *StreamClientTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
+	if req.IsNotification() {
+		return nil, errors.New("id should not be nil, use Notify to send a notification")
+	}
+
+	reqJson, err := req.toJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.roundTrip(ctx, *req.Id, reqJson)
+}
+
+// Notify sends the notification req, without waiting for anything.
+func (t *StreamClientTransport) Notify(ctx context.Context, req *Request) error {
+	reqJson, err := req.toJSON()
+	if err != nil {
+		return err
+	}
+
+	conn, err := t.connect(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.send(reqJson)
+}
+
+// Stats reports the diagnostics of the responses matched to the calls:
+//   - "calls.pending": calls waiting for their responses;
+//   - "calls.orphaned": calls failed by OrphanTimeout;
+//   - "responses.late": responses to calls given up already (ctx done or orphaned);
+//   - "responses.unknown": responses to ids never sent, a buggy server;
+//   - "compression.bytes_raw", "compression.bytes_compressed" and
+//     "compression.ratio_pct": of the messages compressed, if Compression is set.
+func (t *StreamClientTransport) Stats() map[string]int64 {
+	t.mu.Lock()
+	conn := t.conn
+	t.mu.Unlock()
+
+	stats := t.stats.snapshot()
+	if len(t.Compression) > 0 {
+		t.compression.snapshot(stats)
+	}
+	if conn != nil {
+		conn.mu.Lock()
+		stats["calls.pending"] = int64(len(conn.pending))
+		conn.mu.Unlock()
+	}
+	return stats
+}
+
+// Close the connection, if any. The next call redials.
+func (t *StreamClientTransport) Close() error {
+	t.mu.Lock()
+	conn := t.conn
+	t.conn = nil
+	t.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return conn.close(errors.New("transport closed"))
+}
+
+// connect returns the current connection, dialing a new one if there is
+// none or it's broken.
+func (t *StreamClientTransport) connect(ctx context.Context) (_ *streamConn, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn != nil && !t.conn.isBroken() {
+		return t.conn, nil
+	}
+
+	var conn messageConn
+	if t.dial != nil {
+		conn, err = t.dial(ctx)
+	} else {
+		var c net.Conn
+		if c, err = dialContext(ctx, t.Dialer, t.Network, t.Addr); err == nil {
+			fc := newFramedConn(c)
+			fc.maxFrame = t.MaxFrameSize
+			conn = fc
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Compression) > 0 {
+		compressed, err := negotiateCompression(ctx, conn, t.Compression, &t.compression, t.MaxFrameSize)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = compressed
+	}
+	c := newStreamConn(conn, loggerOr(t.Logger))
+	c.orphanTimeout = t.OrphanTimeout
+	c.clock = clockOrSystem(t.Clock)
+	c.stats = &t.stats
+	c.callbacks = t.Callbacks
+	c.events = &t.events
+	go c.readLoop()
+	if t.AuthParams != nil {
+		if err := c.authenticate(ctx, t.AuthParams); err != nil {
+			_ = c.close(err)
+			return nil, err
+		}
+	}
+	if topics := t.events.topics(); len(topics) > 0 {
+		c.resubscribe(ctx, topics)
+	}
+	t.conn = c
+	return t.conn, nil
+}
+
+// ErrOrphaned fails a call left without a response for the OrphanTimeout
+// of its StreamClientTransport.
+var ErrOrphaned = errors.New("jsonrpc2: no response in time, call orphaned")
+
+// clientStats counts what happens to the responses of a client transport.
+type clientStats struct {
+	orphaned atomic.Int64
+	late     atomic.Int64
+	unknown  atomic.Int64
+}
+
+func (s *clientStats) snapshot() map[string]int64 {
+	return map[string]int64{
+		"calls.pending":     0,
+		"calls.orphaned":    s.orphaned.Load(),
+		"responses.late":    s.late.Load(),
+		"responses.unknown": s.unknown.Load(),
+	}
+}
+
+// maxForgotten bounds how many ids of calls given up a streamConn remembers,
+// to tell late responses from unknown ones.
+const maxForgotten = 1024
+
+// messageConn is a connection carrying whole messages: Content-Length
+// frames over a byte stream (framedConn), or WebSocket messages (wsConn).
+type messageConn interface {
+	readMessage() ([]byte, error)
+	writeMessage(body []byte) error // safe for concurrent use
+	Close() error
+}
+
+// framedConn is a messageConn of Content-Length framed messages over rwc.
+type framedConn struct {
+	rwc      io.ReadWriteCloser
+	r        *bufio.Reader
+	maxFrame int64 // 0: DefaultMaxFrameSize
+	writeMu  sync.Mutex
+}
+
+func newFramedConn(rwc io.ReadWriteCloser) *framedConn {
+	return &framedConn{rwc: rwc, r: bufio.NewReader(rwc)}
+}
+
+func (c *framedConn) readMessage() ([]byte, error) {
+	return readFrame(c.r, c.maxFrame)
+}
+
+func (c *framedConn) writeMessage(body []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeFrame(c.rwc, body)
+}
+
+func (c *framedConn) Close() error {
+	return c.rwc.Close()
+}
+
+// streamConn is a client connection, dispatching the responses read
+// to the pending calls by id.
+type streamConn struct {
+	conn messageConn
+
+	orphanTimeout time.Duration // 0: none
+	clock         Clock
+	stats         *clientStats
+	logger        Logger
+
+	callbacks       Server          // serves the calls of the server, nil: none
+	callbacksCtx    context.Context // of the callbacks, cancelled once closed
+	cancelCallbacks context.CancelFunc
+	events          *eventHandlers // of the events pushed by the server, nil: none
+
+	mu        sync.Mutex
+	pending   map[ID]chan *Response
+	partials  map[ID]*partialCall // of the pending calls streaming, see roundTripStream
+	forgotten []ID                // ids of the calls given up, the oldest first
+	err       error               // why the connection is broken, nil if it's not
+}
+
+// newStreamConn wraps conn. The caller starts its readLoop once it's configured.
+func newStreamConn(conn messageConn, logger Logger) *streamConn {
+	c := &streamConn{
+		conn:    conn,
+		logger:  logger,
+		clock:   SystemClock,
+		stats:   new(clientStats),
+		pending: make(map[ID]chan *Response),
+	}
+	c.callbacksCtx, c.cancelCallbacks = context.WithCancel(context.Background())
+	return c
+}
+
+func (c *streamConn) roundTrip(ctx context.Context, id ID, reqJson []byte) (*Response, error) {
+	ch := make(chan *Response, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	if _, dup := c.pending[id]; dup {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("request id %s is already in flight", id)
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.conn.writeMessage(reqJson); err != nil {
+		c.forget(id, false)
+		_ = c.close(err)
+		return nil, err
+	}
+
+	var orphaned <-chan time.Time
+	if c.orphanTimeout > 0 {
+		timer := c.clock.NewTimer(c.orphanTimeout)
+		defer timer.Stop()
+		orphaned = timer.C()
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, c.brokenErr()
+		}
+		return resp, nil
+	case <-ctx.Done():
+		c.forget(id, true)
+		return nil, ctx.Err()
+	case <-orphaned:
+		c.stats.orphaned.Add(1)
+		c.forget(id, true)
+		return nil, fmt.Errorf("%w: id %s", ErrOrphaned, id)
+	}
+}
+
+// authId is the id of the MethodAuth call of a connection.
+// The ids of the Client calls start from 1.
+const authId = 0
+
+// authenticate the connection by a MethodAuth call with params.
+func (c *streamConn) authenticate(ctx context.Context, params any) error {
+	id := Int64ID(authId)
+	paramsJson, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	reqJson, err := Request{JsonRpc: JsonRpc2, Method: MethodAuth, Params: paramsJson, Id: id}.toJSON()
+	if err != nil {
+		return err
+	}
+	resp, err := c.roundTrip(ctx, *id, reqJson)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
+
+// send a message expecting no response.
+func (c *streamConn) send(reqJson []byte) error {
+	if err := c.brokenErr(); err != nil {
+		return err
+	}
+
+	err := c.conn.writeMessage(reqJson)
+	if err != nil {
+		_ = c.close(err)
+	}
+	return err
+}
+
+// forget the pending call id. If it was sent, its response may still come:
+// remember it to tell a late response from an unknown one.
+func (c *streamConn) forget(id ID, sent bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.pending[id]; !ok {
+		return // answered meanwhile
+	}
+	delete(c.pending, id)
+	if sent {
+		if len(c.forgotten) >= maxForgotten {
+			c.forgotten = c.forgotten[1:]
+		}
+		c.forgotten = append(c.forgotten, id)
+	}
+}
+
+// late tells whether id is of a call given up, forgetting it for good.
+// c.mu must be held.
+func (c *streamConn) late(id ID) bool {
+	for i, forgotten := range c.forgotten {
+		if forgotten == id {
+			c.forgotten = append(c.forgotten[:i], c.forgotten[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// readLoop reads the responses and hands them to the pending calls,
+// serving the calls of the server by the callbacks.
+func (c *streamConn) readLoop() {
+	for {
+		body, err := c.conn.readMessage()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			_ = c.close(err)
+			return
+		}
+
+		if p, ok := isPartial(body); ok {
+			c.deliverPartial(p)
+			continue
+		}
+		if e, ok := isEvent(body); ok && c.events != nil {
+			c.events.deliver(e)
+			continue
+		}
+		if isRequest(body) {
+			go c.serveCallback(body)
+			continue
+		}
+
+		var resp Response
+		if err := unmarshalResponse(bytes.NewReader(body), &resp); err != nil {
+			c.logger.Log(LevelWarn, "failed to read response", Field{"error", err})
+			continue
+		}
+		if resp.Id == nil {
+			// an error about a request the server couldn't even read:
+			// can't tell whose it is.
+			c.logger.Log(LevelWarn, "dropped response without id", Field{"response", string(body)})
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[*resp.Id]
+		delete(c.pending, *resp.Id)
+		late := !ok && c.late(*resp.Id)
+		c.mu.Unlock()
+
+		switch {
+		case ok:
+			ch <- &resp
+		case late:
+			c.stats.late.Add(1)
+		default:
+			c.stats.unknown.Add(1)
+			c.logger.Log(LevelWarn, "received response for unknown id", Field{"id", formatId(resp.Id)})
+		}
+	}
+}
+
+// close the connection for err, failing all the pending calls.
+func (c *streamConn) close(err error) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil
+	}
+	c.err = err
+	c.cancelCallbacks()
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+
+	return c.conn.Close()
+}
+
+func (c *streamConn) isBroken() bool {
+	return c.brokenErr() != nil
+}
+
+func (c *streamConn) brokenErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
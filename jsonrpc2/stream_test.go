@@ -0,0 +1,739 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_frame(t *testing.T) {
+	var sb strings.Builder
+	if err := writeFrame(&sb, []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sb.String(), "Content-Length: 7\r\n\r\n{\"a\":1}"; got != want {
+		t.Errorf("writeFrame = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"ok", "Content-Length: 7\r\n\r\n{\"a\":1}", `{"a":1}`, false},
+		{"extraHeader", "Content-Type: application/json\r\nContent-Length: 2\r\n\r\n{}", `{}`, false},
+		{"noLength", "Foo: bar\r\n\r\n{}", ``, true},
+		{"short", "Content-Length: 10\r\n\r\n{}", ``, true},
+		{"tooBig", "Content-Length: 99999999999\r\n\r\n{}", ``, true},
+		{"overMax", "Content-Length: 11\r\n\r\n{\"a\":12345}", ``, true},
+		{"negative", "Content-Length: -1\r\n\r\n{}", ``, true},
+		{"corrupted", "Content-Length: 7x\r\n\r\n{}", ``, true},
+		{"notAHeader", "\x00\x01garbage\r\n\r\n{}", ``, true},
+		{"endlessHeader", "Content-Length: 2\r\nX: " + strings.Repeat("x", 8<<10), ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFrame(bufio.NewReader(strings.NewReader(tt.in)), 10)
+			if (err != nil) != tt.wantErr || string(got) != tt.want {
+				t.Errorf("❌ readFrame = %q, %v; want %q, wantErr %v", got, err, tt.want, tt.wantErr)
+			} else {
+				t.Logf("✅ readFrame = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func Test_StreamServerTransport_MaxFrameSize(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp", MaxFrameSize: 100}).ServeListener(l, s)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// a prefix of gigabytes: refused before the body is read, or allocated
+	if _, err := fmt.Fprint(conn, "Content-Length: 4000000000\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	body, err := readFrame(r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Error == nil || resp.Error.Code != ErrInvalidRequest().Code {
+		t.Errorf("❌ response to a frame too big = %s, want ErrInvalidRequest", body)
+	}
+	if _, err := readFrame(r, 0); err != io.EOF {
+		t.Errorf("❌ after a frame too big: %v, want the connection closed", err)
+	}
+
+	// and the client side: a response over its MaxFrameSize breaks the connection
+	ct := NewTcpClientTransport(l.Addr().String())
+	ct.MaxFrameSize = 50
+	defer ct.Close()
+	var got string
+	if err := NewClient(ct).Call("echo", strings.Repeat("x", 60), &got); err == nil {
+		t.Errorf("❌ call with a response over MaxFrameSize = %q, want error", got)
+	}
+}
+
+func newStreamTestEchoServer(t *testing.T) Server {
+	s := NewServer()
+	s.MustRegister("echo", func(ctx context.Context, arg int) (int, error) {
+		if info, ok := TransportInfoFromContext(ctx); !ok || info.ConnID == 0 {
+			t.Errorf("no TransportInfo: %v", info)
+		}
+		time.Sleep(time.Duration(arg%5) * time.Millisecond) // responses come back out of order
+		return arg, nil
+	})
+	return s
+}
+
+func Test_StreamTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		addr    string
+	}{
+		{"tcp", "tcp", "127.0.0.1:0"},
+		{"unix", "unix", filepath.Join(t.TempDir(), "jsonrpc2.sock")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen(tt.network, tt.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			st := &StreamServerTransport{Network: tt.network}
+			go st.ServeListener(l, newStreamTestEchoServer(t))
+			defer l.Close()
+
+			ct := &StreamClientTransport{Network: tt.network, Addr: l.Addr().String()}
+			defer ct.Close()
+			c := NewClient(ct)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					var ret int
+					if err := c.Call("echo", i, &ret); err != nil || ret != i {
+						t.Errorf("❌ echo(%d) = %d, %v", i, ret, err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			// an unknown method is an error response, not a broken connection
+			var ret int
+			if err := c.Call("nope", 1, &ret); err == nil {
+				t.Error("❌ want Method not found")
+			}
+
+			// broken connections are redialed
+			ct.Close()
+			if err := c.Call("echo", 42, &ret); err != nil || ret != 42 {
+				t.Errorf("❌ echo after redial = %d, %v", ret, err)
+			}
+		})
+	}
+}
+
+func Test_StreamClientTransport_serverGone(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// a server that reads the request and hangs up
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = readFrame(bufio.NewReader(conn), 0)
+		conn.Close()
+	}()
+
+	c := NewClient(NewTcpClientTransport(l.Addr().String()))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var ret int
+	if err := c.CallContext(ctx, "echo", 1, &ret); err == nil || ctx.Err() != nil {
+		t.Errorf("❌ pending call should fail when the connection breaks, got %v (ctx: %v)", err, ctx.Err())
+	}
+}
+
+func Test_StreamClientTransport_pipelining(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// lock is held by the first call until the unlock call:
+	// in lockstep, the unlock would wait for the lock response forever.
+	s := NewServer()
+	lockServed, unlocked := make(chan struct{}), make(chan struct{})
+	s.MustRegister("lock", func(arg int) (int, error) { close(lockServed); <-unlocked; return arg, nil })
+	s.MustRegister("unlock", func(arg int) (int, error) { close(unlocked); return arg, nil })
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+
+	ct := NewTcpClientTransport(l.Addr().String())
+	defer ct.Close()
+	c := NewClient(ct)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	locked := make(chan error)
+	go func() { locked <- c.CallContext(ctx, "lock", 1, nil) }()
+	select { // lock is outstanding first
+	case <-lockServed:
+	case err := <-locked:
+		t.Fatalf("❌ lock returned before unlock: %v", err)
+	}
+
+	if err := c.CallContext(ctx, "unlock", 2, nil); err != nil {
+		t.Fatalf("❌ unlock while lock is outstanding: %v", err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatalf("❌ lock: %v", err)
+	}
+	t.Logf("✅ the calls were pipelined over one connection")
+}
+
+// serveBuggy serves l like a buggy server: "hold" is answered only after
+// the next "release" (out of order), "drop" only along the next "late" (after
+// its caller has given up), and "echo" is preceded by a response to an id never sent.
+func serveBuggy(t *testing.T, l net.Listener) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	respond := func(id int64) {
+		_ = writeFrame(conn, []byte(fmt.Sprintf(`{"jsonrpc":"2.0","result":%d,"id":%d}`, id, id)))
+	}
+
+	var held, dropped int64
+	r := bufio.NewReader(conn)
+	for {
+		body, err := readFrame(r, 0)
+		if err != nil {
+			return
+		}
+		var req Request
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("bad request %s: %v", body, err)
+			return
+		}
+		switch id, _ := req.Id.Int64(); req.Method {
+		case "hold":
+			held = id
+		case "release":
+			respond(id)
+			respond(held)
+		case "drop":
+			dropped = id
+		case "late":
+			respond(dropped)
+			respond(id)
+		case "echo":
+			respond(id + 1000)
+			respond(id)
+		}
+	}
+}
+
+func Test_StreamClientTransport_orphans(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go serveBuggy(t, l)
+
+	ct := NewTcpClientTransport(l.Addr().String())
+	ct.OrphanTimeout = 50 * time.Millisecond
+	defer ct.Close()
+	c := NewClient(ct)
+
+	// out of order
+	held := make(chan error)
+	go func() { var ret int64; held <- c.Call("hold", 1, &ret) }()
+	time.Sleep(10 * time.Millisecond) // hold is sent first
+	if err := c.Call("release", 2, nil); err != nil {
+		t.Fatalf("❌ release: %v", err)
+	}
+	if err := <-held; err != nil {
+		t.Fatalf("❌ hold: %v", err)
+	}
+	t.Logf("✅ responses out of order matched their calls")
+
+	// orphan, then its late response
+	if err := c.Call("drop", 3, nil); !errors.Is(err, ErrOrphaned) {
+		t.Fatalf("❌ drop: err = %v, want ErrOrphaned", err)
+	}
+	if err := c.Call("late", 4, nil); err != nil {
+		t.Fatalf("❌ late: %v", err)
+	}
+
+	// unknown id
+	var ret int64
+	if err := c.Call("echo", 5, &ret); err != nil {
+		t.Fatalf("❌ echo: %v", err)
+	}
+
+	want := map[string]int64{
+		"calls.pending":     0,
+		"calls.orphaned":    1,
+		"responses.late":    1,
+		"responses.unknown": 1,
+	}
+	if got := ct.Stats(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("❌ Stats() = %v, want %v", got, want)
+	}
+	t.Logf("✅ Stats() = %v", ct.Stats())
+}
+
+// countingConn counts the writes into a net.Conn.
+type countingConn struct {
+	net.Conn
+	writes atomic.Int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	c.writes.Add(1)
+	return c.Conn.Write(p)
+}
+
+func Test_StreamServerTransport_groupCommit(t *testing.T) {
+	const calls = 100
+
+	tests := []struct {
+		name          string
+		flushInterval time.Duration
+	}{
+		{"noInterval", 0},
+		{"interval", 2 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			st := &StreamServerTransport{Network: "tcp", FlushInterval: tt.flushInterval}
+			s := NewServer()
+			s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+
+			conns := make(chan *countingConn, 1)
+			go func() {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				cc := &countingConn{Conn: conn}
+				conns <- cc
+				st.ServeConn(cc, s)
+			}()
+
+			c := NewClient(NewTcpClientTransport(l.Addr().String()))
+			var wg sync.WaitGroup
+			for i := 0; i < calls; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					var ret int
+					if err := c.Call("echo", i, &ret); err != nil || ret != i {
+						t.Errorf("❌ echo(%d) = %d, %v", i, ret, err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			writes := (<-conns).writes.Load()
+			if tt.flushInterval > 0 && writes >= calls {
+				t.Errorf("❌ %d writes for %d responses, want them coalesced", writes, calls)
+			} else {
+				t.Logf("✅ %d writes for %d responses", writes, calls)
+			}
+		})
+	}
+}
+
+func Test_StreamServerTransport_slowClient(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("big", func(n int) (string, error) { return strings.Repeat("x", n), nil })
+
+	request := func(id int) []byte {
+		var sb strings.Builder
+		_ = writeFrame(&sb, []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"big","params":1024,"id":%d}`, id)))
+		return []byte(sb.String())
+	}
+
+	tests := []struct {
+		name string
+		st   *StreamServerTransport
+	}{
+		{"writeTimeout", &StreamServerTransport{WriteTimeout: 20 * time.Millisecond}},
+		{"maxPendingBytes", &StreamServerTransport{MaxPendingBytes: 4 << 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+
+			done := make(chan struct{})
+			go func() {
+				tt.st.ServeConn(server, s)
+				close(done)
+			}()
+
+			// send requests, never read the responses
+			go func() {
+				for i := 1; ; i++ {
+					if _, err := client.Write(request(i)); err != nil {
+						return
+					}
+				}
+			}()
+
+			select {
+			case <-done:
+				t.Logf("✅ the stalled client is disconnected")
+			case <-time.After(5 * time.Second):
+				t.Fatal("❌ the stalled client pins the connection")
+			}
+		})
+	}
+}
+
+func Test_StreamServerTransport_MaxConnConcurrency(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 10)
+
+	s := NewServer()
+	s.MustRegister("block", func(arg int) (int, error) {
+		started <- struct{}{}
+		<-block
+		return arg, nil
+	})
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	st := &StreamServerTransport{Network: "tcp", MaxConnConcurrency: 1, MaxConnQueue: 1}
+	go st.ServeListener(l, s)
+
+	greedy := NewClient(NewTcpClientTransport(l.Addr().String()))
+
+	// 1 executing + 1 queued
+	var wg sync.WaitGroup
+	for i := 1; i <= 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var ret int
+			if err := greedy.Call("block", i, &ret); err != nil {
+				t.Errorf("❌ block(%d): %v", i, err)
+			}
+		}(i)
+	}
+	<-started
+	time.Sleep(20 * time.Millisecond) // let the 2nd one queue
+
+	// the 3rd one overflows
+	var ret int
+	err = greedy.Call("block", 3, &ret)
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != ErrServerBusy().Code {
+		t.Errorf("❌ want ErrServerBusy, got %v", err)
+	} else {
+		t.Logf("✅ overflow: %v", err)
+	}
+
+	// other connections are not affected
+	other := NewClient(NewTcpClientTransport(l.Addr().String()))
+	if err := other.Call("echo", 4, &ret); err != nil || ret != 4 {
+		t.Errorf("❌ other connection: %d, %v", ret, err)
+	}
+
+	close(block)
+	wg.Wait()
+}
+
+func Test_StreamServerTransport_drain(t *testing.T) {
+	started := make(chan struct{})
+	drained := make(chan error, 1)
+
+	s := NewServer().WithAtMostOnce().WithMaxConcurrency(1).WithMaxQueue(1)
+	s.MustRegister("wait", func(ctx context.Context, arg int) (int, error) {
+		if arg == 0 { // holds the slot until the connection is gone
+			started <- struct{}{}
+			<-ctx.Done()
+			drained <- ctx.Err()
+		}
+		return arg, nil
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+
+	// one request executing, one waiting for the slot, then the connection is gone
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = writeFrame(conn, []byte(`{"jsonrpc":"2.0","method":"wait","params":0,"id":1}`))
+	<-started
+	_ = writeFrame(conn, []byte(`{"jsonrpc":"2.0","method":"wait","params":2,"id":2}`))
+	time.Sleep(20 * time.Millisecond) // let it queue
+	conn.Close()
+
+	select {
+	case err := <-drained:
+		t.Logf("✅ the handler in flight was cancelled: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("❌ the handler in flight outlived its connection")
+	}
+
+	// the queued request never ran: it may be retried
+	c := NewClient(NewTcpClientTransport(l.Addr().String()))
+	c.(*client).nextId.Store(1) // the next call has id 2
+	var ret int
+	if err := c.Call("wait", 2, &ret); err != nil || ret != 2 {
+		t.Fatalf("❌ retry of the drained request = %d, %v", ret, err)
+	}
+	if got := s.Stats()["dedupe.entries"]; got != 2 {
+		t.Errorf("❌ dedupe.entries = %d, want 2", got)
+	}
+	t.Logf("✅ the drained request was retried")
+}
+
+func Test_StreamServerTransport_MaxConnections(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	st := (&StreamServerTransport{Network: "tcp"}).WithMaxConnections(1)
+	go st.ServeListener(l, newStreamTestEchoServer(t))
+
+	first := NewTcpClientTransport(l.Addr().String())
+	var ret int
+	if err := NewClient(first).Call("echo", 1, &ret); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second connection waits in the backlog
+	second := NewTcpClientTransport(l.Addr().String())
+	defer second.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	c := NewClient(second)
+	if err := c.CallContext(ctx, "echo", 2, &ret); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("❌ over the cap: err = %v, want DeadlineExceeded", err)
+	}
+	t.Logf("✅ over the cap, not served")
+
+	// until the first one closes
+	first.Close()
+	if err := c.Call("echo", 3, &ret); err != nil || ret != 3 {
+		t.Fatalf("❌ after the first one closed: %d, %v", ret, err)
+	}
+	t.Logf("✅ served once a slot is free")
+}
+
+// flakyListener fails its first accepts with err.
+type flakyListener struct {
+	net.Listener
+	fails int
+	err   error
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.fails > 0 {
+		l.fails--
+		return nil, l.err
+	}
+	return l.Listener.Accept()
+}
+
+func Test_StreamServerTransport_acceptErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantServe bool // keeps serving after the error
+	}{
+		{"EMFILE", &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}, true},
+		{"closed", net.ErrClosed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			st := &StreamServerTransport{Network: "tcp", AcceptRate: 100}
+			served := make(chan error, 1)
+			go func() { served <- st.ServeListener(&flakyListener{l, 2, tt.err}, newStreamTestEchoServer(t)) }()
+
+			if !tt.wantServe {
+				if err := <-served; !errors.Is(err, tt.err) {
+					t.Fatalf("❌ ServeListener() = %v, want %v", err, tt.err)
+				}
+				t.Logf("✅ stopped on %v", tt.err)
+				return
+			}
+
+			ct := NewTcpClientTransport(l.Addr().String())
+			defer ct.Close()
+			var ret int
+			if err := NewClient(ct).Call("echo", 1, &ret); err != nil || ret != 1 {
+				t.Fatalf("❌ after %v: %d, %v", tt.err, ret, err)
+			}
+			t.Logf("✅ retried after %v", tt.err)
+		})
+	}
+}
+
+func Test_StreamServerTransport_drain_ignoringCtx(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := NewServer()
+	s.MustRegister("stuck", func(ctx context.Context, arg int) (int, error) {
+		close(started)
+		<-release // ignoring ctx
+		return arg, nil
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = writeFrame(conn, []byte(`{"jsonrpc":"2.0","method":"stuck","params":1,"id":1}`))
+	<-started
+	_ = conn.(*net.TCPConn).CloseWrite() // gone, as far as the server reads
+
+	// not closed while the method runs
+	_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("❌ read %v while the method runs, want a timeout", err)
+	}
+
+	// closed once it returns
+	close(release)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("❌ read %v once the method returned, want EOF", err)
+	}
+}
+
+// panickyServer panics out of ServeRPC for the method "panic".
+type panickyServer struct {
+	Server
+}
+
+func (s panickyServer) ServeRPC(ctx context.Context, req *Request) *Response {
+	if req.Method == "panic" {
+		panic("boom")
+	}
+	return s.Server.ServeRPC(ctx, req)
+}
+
+func Test_StreamServerTransport_isolation(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	s := NewServer()
+	s.MustRegister("block", func(arg int) (int, error) {
+		<-block // ignoring any ctx, like Lock
+		return arg, nil
+	})
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	st := &StreamServerTransport{Network: "tcp", RequestTimeout: 50 * time.Millisecond}
+	go st.ServeListener(l, panickyServer{s})
+
+	c := NewClient(NewTcpClientTransport(l.Addr().String()))
+
+	tests := []struct {
+		name     string
+		method   string
+		wantCode int
+	}{
+		{"timeout", "block", ErrRequestTimeout().Code},
+		{"panic", "panic", ErrInternalError().Code},
+		{"stillServing", "echo", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ret int
+			err := c.Call(tt.method, 1, &ret)
+			if tt.wantCode == 0 {
+				if err != nil || ret != 1 {
+					t.Errorf("❌ %s = %d, %v", tt.method, ret, err)
+				}
+				return
+			}
+			if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != tt.wantCode {
+				t.Errorf("❌ %s: want error %d, got %v", tt.method, tt.wantCode, err)
+			} else {
+				t.Logf("✅ %s: %v", tt.method, err)
+			}
+		})
+	}
+}
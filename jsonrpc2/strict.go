@@ -0,0 +1,138 @@
+package jsonrpc2
+
+// 这个文件实现严格的规范模式 (WithStrictSpec)：默认的服务端是宽松的，接受规范之外的请求
+// (未知的成员、null 的 id、标量的 params 等)；严格模式下这些请求都以 Invalid Request 拒绝，
+// 保留名字不能再被注册，HTTP 上的错误响应也带上相应的状态码。
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+func (s *server) WithStrictSpec() Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strict = true
+	return s
+}
+
+// strictOf tells whether the Server s is WithStrictSpec.
+func strictOf(s Server) bool {
+	switch srv := s.(type) {
+	case *server:
+		srv.mu.RLock()
+		defer srv.mu.RUnlock()
+		return srv.strict
+	case *ProxyServer:
+		return strictOf(srv.Server)
+	default:
+		return false
+	}
+}
+
+// requestMembers are the members a request may have in the strict mode:
+// those of the spec, and the extension members of Request.
+var requestMembers = map[string]bool{
+	"jsonrpc": true, "method": true, "params": true, "id": true,
+	"client": true, "meta": true,
+}
+
+// checkStrict checks the request raw (valid as a Request already) against
+// the spec to the letter: no unknown members, no null id, and params, if
+// any, an array or an object.
+func checkStrict(raw []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return err
+	}
+	var unknown []string
+	for name := range members {
+		if !requestMembers[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown members %q", unknown)
+	}
+	if id, ok := members["id"]; ok && string(id) == "null" {
+		return errors.New("id should not be null")
+	}
+	if params, ok := members["params"]; ok {
+		if first := firstByte(params); first != '[' && first != '{' {
+			return errors.New("params should be an array or an object")
+		}
+	}
+	return nil
+}
+
+func firstByte(raw json.RawMessage) byte {
+	for _, b := range raw {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return b
+		}
+	}
+	return 0
+}
+
+// validateRequest validates req, parsed out of raw, for server: strictly
+// if it's WithStrictSpec.
+func validateRequest(server Server, req *Request, raw []byte) error {
+	if err := req.validate(); err != nil {
+		return err
+	}
+	if strictOf(server) {
+		return checkStrict(raw)
+	}
+	return nil
+}
+
+// httpStatusOf is the HTTP status of an error response of the code, in
+// the strict mode.
+func httpStatusOf(code int) int {
+	switch {
+	case code == ErrParseError().Code, code == ErrInvalidRequest().Code, code == ErrInvalidParams().Code:
+		return http.StatusBadRequest
+	case code == ErrMethodNotFound().Code:
+		return http.StatusNotFound
+	case code == ErrUnauthorized().Code:
+		return http.StatusUnauthorized
+	case code == ErrQuotaExceeded().Code:
+		return http.StatusTooManyRequests
+	case code == ErrServerBusy().Code, code == ErrNotReady().Code:
+		return http.StatusServiceUnavailable
+	case code == ErrInternalError().Code, -32099 <= code && code <= -32000:
+		return http.StatusInternalServerError
+	default: // of the application
+		return http.StatusOK
+	}
+}
+
+// statusResponseWriter writes its status before the body.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (w *statusResponseWriter) Write(p []byte) (int, error) {
+	if !w.wrote {
+		w.wrote = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// responseWriter is w writing resp, with the HTTP status of its error in
+// the strict mode.
+func (t *HttpServerTransport) responseWriter(w http.ResponseWriter, resp *Response) http.ResponseWriter {
+	if resp == nil || resp.Error == nil || !strictOf(t.server) {
+		return w
+	}
+	return &statusResponseWriter{ResponseWriter: w, status: httpStatusOf(resp.Error.Code)}
+}
@@ -0,0 +1,127 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newStrictTestServer(strict bool) Server {
+	s := NewServer()
+	if strict {
+		s.WithStrictSpec()
+	}
+	s.MustRegister("add", func(arg []int) (int, error) { return arg[0] + arg[1], nil })
+	s.MustRegister("fail", func(arg []int) (int, error) { return 0, errors.New("failed") })
+	return s
+}
+
+func Test_server_WithStrictSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		strict int // the error code in the strict mode, 0: served
+	}{
+		{"spec", `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}`, 0},
+		{"extensions", `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1, "client": "c", "meta": {"A": "b"}}`, 0},
+		{"unknownMember", `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1, "foo": 1}`, ErrInvalidRequest().Code},
+		{"nullId", `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": null}`, ErrInvalidRequest().Code},
+		{"scalarParams", `{"jsonrpc": "2.0", "method": "add", "params": 1, "id": 1}`, ErrInvalidRequest().Code},
+	}
+	strict, lenient := newStrictTestServer(true), newStrictTestServer(false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := serveMessage(context.Background(), strict, []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var resp Response
+			if len(out) > 0 {
+				if err := json.Unmarshal(out, &resp); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.strict == 0 && resp.Error != nil || tt.strict != 0 && (resp.Error == nil || resp.Error.Code != tt.strict) {
+				t.Errorf("❌ strict: %s, want the error %d", out, tt.strict)
+			}
+
+			// the batches as well
+			out, _ = serveMessage(context.Background(), strict, []byte("["+tt.body+"]"))
+			if tt.strict != 0 && !bytes.Contains(out, []byte(`"code":-32600`)) {
+				t.Errorf("❌ strict batch: %s, want the error %d", out, tt.strict)
+			}
+
+			out, _ = serveMessage(context.Background(), lenient, []byte(tt.body))
+			if bytes.Contains(out, []byte(`"code":-32600`)) {
+				t.Errorf("❌ lenient: %s, want served", out)
+			}
+		})
+	}
+
+	if err := NewServer().WithReservedNames(true).WithStrictSpec().Register(MethodHealth, func(arg int) (int, error) { return arg, nil }); err == nil {
+		t.Error("❌ registered a reserved name in the strict mode")
+	}
+}
+
+func Test_HttpServerTransport_strictStatus(t *testing.T) {
+	for _, strict := range []bool{true, false} {
+		st := NewHttpServerTransport("")
+		st.Use(newStrictTestServer(strict))
+		ts := httptest.NewServer(st)
+
+		tests := []struct {
+			body       string
+			wantStatus int // in the strict mode
+		}{
+			{`{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}`, http.StatusOK},
+			{`{"jsonrpc": "2.0", "method": "fail", "params": [1, 2], "id": 1}`, http.StatusOK},
+			{`{"jsonrpc": "2.0", "method": "nothing", "params": [1, 2], "id": 1}`, http.StatusNotFound},
+			{`{"jsonrpc": "2.0", "method": "add", "params": 1, "id": 1}`, http.StatusBadRequest},
+			{`{"jsonrpc": "2.0", "method": "add", "params": [1, 2]`, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			resp, err := http.Post(ts.URL, "application/json", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			want := tt.wantStatus
+			if !strict {
+				want = http.StatusOK
+			}
+			if resp.StatusCode != want {
+				t.Errorf("❌ strict=%v: %s: status %d, want %d", strict, tt.body, resp.StatusCode, want)
+			}
+		}
+		ts.Close()
+	}
+}
+
+func Test_httpStatusOf(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		want int
+	}{
+		{ErrParseError(), http.StatusBadRequest},
+		{ErrInvalidRequest(), http.StatusBadRequest},
+		{ErrInvalidParams(), http.StatusBadRequest},
+		{ErrMethodNotFound(), http.StatusNotFound},
+		{ErrInternalError(), http.StatusInternalServerError},
+		{ErrServerError(), http.StatusInternalServerError},
+		{ErrServerBusy(), http.StatusServiceUnavailable},
+		{ErrRequestTimeout(), http.StatusInternalServerError},
+		{ErrUnauthorized(), http.StatusUnauthorized},
+		{ErrQuotaExceeded(), http.StatusTooManyRequests},
+		{ErrNotReady(), http.StatusServiceUnavailable},
+		{&Error{Code: 42, Message: "of the application"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := httpStatusOf(tt.err.Code); got != tt.want {
+			t.Errorf("❌ httpStatusOf(%d %s) = %d, want %d", tt.err.Code, tt.err.Message, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package jsonrpc2
+
+// 这个文件在 Topic (pubsub.go) 之上实现跨连接的发布订阅：服务端以 Server.Topic 取得主题并
+// Publish，客户端在面向连接的传输上以 Client.Subscribe 订阅，消息以 MethodEvent 通知推送
+// (见 Peer)，无需另外的消息中间件。
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+const (
+	// MethodSubscribe is the built-in method subscribing the connection of
+	// the request to a topic of the server (see Server.Topic), sent by
+	// Client.Subscribe. The params is TopicParams. The messages published
+	// are pushed to the client by MethodEvent notifications, until it
+	// unsubscribes by MethodUnsubscribe or the connection is closed.
+	// Subscribing twice is a no-op.
+	MethodSubscribe = "rpc.subscribe"
+
+	// MethodUnsubscribe is the built-in method ending a subscription of
+	// MethodSubscribe. The params is TopicParams, the result tells whether
+	// the connection was subscribed.
+	MethodUnsubscribe = "rpc.unsubscribe"
+
+	// MethodEvent is the notification pushing a message of a topic to a
+	// subscriber, with EventParams.
+	MethodEvent = "rpc.event"
+)
+
+// subscriberBuffer is how many messages are buffered for a subscriber
+// slower than the publisher, the oldest dropped beyond (see DropOldest).
+const subscriberBuffer = 64
+
+// TopicParams is the params of MethodSubscribe and MethodUnsubscribe.
+type TopicParams struct {
+	Topic string `json:"topic"`
+}
+
+// EventParams is the params of MethodEvent.
+type EventParams struct {
+	Topic   string          `json:"topic"`
+	Message json.RawMessage `json:"message"`
+}
+
+// topics are the Topics of a server and the subscriptions of the
+// connections to them.
+type topics struct {
+	mu     sync.Mutex
+	byName map[string]*Topic
+	subs   map[topicSub]*Subscription
+}
+
+// topicSub is a subscription of a connection to a topic.
+type topicSub struct {
+	peer  *Peer
+	topic string
+}
+
+// Topic returns the topic name of the server, created on first use.
+func (s *server) Topic(name string) *Topic {
+	s.topics.mu.Lock()
+	defer s.topics.mu.Unlock()
+
+	if t, ok := s.topics.byName[name]; ok {
+		return t
+	}
+	if s.topics.byName == nil {
+		s.topics.byName = make(map[string]*Topic)
+	}
+	t := NewTopic(name)
+	s.topics.byName[name] = t
+	return t
+}
+
+// subscribe is the MethodSubscribe method.
+func (s *server) subscribe(ctx context.Context, params *TopicParams) (bool, error) {
+	peer, ok := PeerFromContext(ctx)
+	if !ok {
+		return false, ErrInvalidRequest().WithReason(MethodSubscribe + " over a connection only")
+	}
+
+	s.topics.mu.Lock()
+	defer s.topics.mu.Unlock()
+
+	topic, ok := s.topics.byName[params.Topic]
+	if !ok {
+		return false, ErrInvalidParams().WithReason("no topic " + params.Topic)
+	}
+	key := topicSub{peer, params.Topic}
+	if _, ok := s.topics.subs[key]; ok {
+		return true, nil
+	}
+	if s.topics.subs == nil {
+		s.topics.subs = make(map[topicSub]*Subscription)
+	}
+	sub := topic.Subscribe(subscriberBuffer, DropOldest)
+	s.topics.subs[key] = sub
+	go s.push(key, sub)
+	return true, nil
+}
+
+// unsubscribe is the MethodUnsubscribe method.
+func (s *server) unsubscribe(ctx context.Context, params *TopicParams) (bool, error) {
+	peer, ok := PeerFromContext(ctx)
+	if !ok {
+		return false, nil
+	}
+
+	key := topicSub{peer, params.Topic}
+	s.topics.mu.Lock()
+	sub, ok := s.topics.subs[key]
+	delete(s.topics.subs, key)
+	s.topics.mu.Unlock()
+
+	if ok {
+		sub.Close()
+	}
+	return ok, nil
+}
+
+// push the messages of sub to its connection, until either is closed.
+func (s *server) push(key topicSub, sub *Subscription) {
+	defer func() {
+		s.topics.mu.Lock()
+		if s.topics.subs[key] == sub {
+			delete(s.topics.subs, key)
+		}
+		s.topics.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-sub.C():
+			if !ok {
+				return // unsubscribed
+			}
+			event := &EventParams{Topic: key.topic, Message: msg}
+			if err := key.peer.Notify(context.Background(), MethodEvent, event); err != nil {
+				sub.Close()
+				return
+			}
+		case <-key.peer.Done():
+			sub.Close()
+			return
+		}
+	}
+}
+
+// isEvent tells whether the message body read by a client is a MethodEvent
+// notification, returning its params.
+func isEvent(body []byte) (*EventParams, bool) {
+	if !bytes.Contains(body, []byte(MethodEvent)) {
+		return nil, false // the responses, mostly
+	}
+	var msg struct {
+		Method string      `json:"method"`
+		Id     *ID         `json:"id"`
+		Params EventParams `json:"params"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Method != MethodEvent || msg.Id != nil {
+		return nil, false
+	}
+	return &msg.Params, true
+}
+
+// ErrSubscribeUnsupported is returned by Client.Subscribe over transports
+// not implementing EventClientTransport.
+var ErrSubscribeUnsupported = errors.New("jsonrpc2: the transport can't receive events")
+
+// EventClientTransport is a ClientTransport receiving the messages of
+// topics pushed by the server (see MethodEvent), for Client.Subscribe.
+type EventClientTransport interface {
+	ClientTransport
+
+	// HandleEvents calls handler with the messages of topic pushed, in
+	// order, until remove is called, which tells whether handler was the
+	// last one of topic. The connections dialed subscribe to the topics
+	// handled (by MethodSubscribe) as they're established.
+	HandleEvents(topic string, handler func(message json.RawMessage)) (remove func() (last bool))
+}
+
+// Subscribe subscribes to topic, see Client.Subscribe.
+func (c *client) Subscribe(topic string, handler func(message json.RawMessage)) (unsubscribe func() error, err error) {
+	et, ok := c.transport.(EventClientTransport)
+	if !ok {
+		return nil, ErrSubscribeUnsupported
+	}
+
+	// handled first, not to miss the messages pushed right after subscribing
+	remove := et.HandleEvents(topic, handler)
+	if err := c.Call(MethodSubscribe, &TopicParams{Topic: topic}, nil); err != nil {
+		remove()
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() error {
+		var err error
+		once.Do(func() {
+			if remove() {
+				err = c.Call(MethodUnsubscribe, &TopicParams{Topic: topic}, nil)
+			}
+		})
+		return err
+	}, nil
+}
+
+// eventHandlers are the handlers of the topics subscribed over a
+// StreamClientTransport, see HandleEvents.
+type eventHandlers struct {
+	mu      sync.Mutex
+	byTopic map[string][]*eventHandler
+}
+
+type eventHandler struct {
+	handle func(message json.RawMessage)
+}
+
+// add handler to topic until remove is called.
+func (h *eventHandlers) add(topic string, handler func(message json.RawMessage)) (remove func() (last bool)) {
+	eh := &eventHandler{handle: handler}
+
+	h.mu.Lock()
+	if h.byTopic == nil {
+		h.byTopic = make(map[string][]*eventHandler)
+	}
+	h.byTopic[topic] = append(h.byTopic[topic], eh)
+	h.mu.Unlock()
+
+	return func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		handlers := h.byTopic[topic]
+		for i, other := range handlers {
+			if other == eh {
+				handlers = append(handlers[:i:i], handlers[i+1:]...)
+				break
+			}
+		}
+		if len(handlers) == 0 {
+			delete(h.byTopic, topic)
+			return true
+		}
+		h.byTopic[topic] = handlers
+		return false
+	}
+}
+
+// topics returns the names of the topics handled.
+func (h *eventHandlers) topics() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topics := make([]string, 0, len(h.byTopic))
+	for topic := range h.byTopic {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// deliver the message of the event to the handlers of its topic.
+func (h *eventHandlers) deliver(event *EventParams) {
+	h.mu.Lock()
+	handlers := h.byTopic[event.Topic]
+	h.mu.Unlock()
+
+	for _, eh := range handlers {
+		eh.handle(event.Message)
+	}
+}
+
+// HandleEvents calls handler with the messages of topic pushed by the
+// server, see EventClientTransport. The handlers are called by the
+// reading of the connection: they must return quickly (e.g. hand the
+// message over to a channel), for the responses wait meanwhile.
+func (t *StreamClientTransport) HandleEvents(topic string, handler func(message json.RawMessage)) (remove func() (last bool)) {
+	return t.events.add(topic, handler)
+}
+
+// resubscribe the new connection to the topics handled, by MethodSubscribe
+// calls of string ids, not to collide with those of the Client calls.
+func (c *streamConn) resubscribe(ctx context.Context, topics []string) {
+	for _, topic := range topics {
+		id := StringID(MethodSubscribe + " " + topic)
+		params, err := json.Marshal(&TopicParams{Topic: topic})
+		if err != nil {
+			continue
+		}
+		reqJson, err := Request{JsonRpc: JsonRpc2, Method: MethodSubscribe, Params: params, Id: id}.toJSON()
+		if err != nil {
+			continue
+		}
+		resp, err := c.roundTrip(ctx, *id, reqJson)
+		if err == nil && resp.Error != nil {
+			err = resp.Error
+		}
+		if err != nil {
+			c.logger.Log(LevelWarn, "failed to subscribe again", Field{"topic", topic}, Field{"error", err})
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// waitSubscribers waits for topic to have n subscribers.
+func waitSubscribers(t *testing.T, topic *Topic, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); topic.Subscribers() != n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("❌ %d subscribers of %s, want %d", topic.Subscribers(), topic.Name(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_Client_Subscribe(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("ping", func(arg int) (int, error) { return arg, nil })
+	prices := s.Topic("prices")
+	if s.Topic("prices") != prices {
+		t.Error("❌ Topic made twice")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+	ct := NewTcpClientTransport(l.Addr().String())
+	defer ct.Close()
+	c := NewClient(ct)
+
+	messages := make(chan string, 10)
+	unsubscribe, err := c.Subscribe("prices", func(message json.RawMessage) {
+		messages <- string(message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prices.Subscribers() != 1 {
+		t.Fatalf("❌ %d subscribers, want 1", prices.Subscribers())
+	}
+
+	receive := func(want string) {
+		t.Helper()
+		select {
+		case got := <-messages:
+			if got != want {
+				t.Errorf("❌ got %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("❌ no message, want %s", want)
+		}
+	}
+	_ = prices.Publish(1)
+	_ = prices.Publish(2)
+	receive("1")
+	receive("2")
+
+	// the connection redialed subscribes again
+	_ = ct.Close()
+	if err := c.Call("ping", 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	waitSubscribers(t, prices, 1)
+	_ = prices.Publish(3)
+	receive("3")
+
+	if err := unsubscribe(); err != nil {
+		t.Errorf("❌ unsubscribe: %v", err)
+	}
+	waitSubscribers(t, prices, 0)
+
+	var rpcErr *Error
+	if _, err := c.Subscribe("nothing", func(json.RawMessage) {}); !errors.As(err, &rpcErr) || rpcErr.Code != ErrInvalidParams().Code {
+		t.Errorf("❌ subscribed to no topic: %v", err)
+	}
+	if _, err := NewClient(NewHttpClientTransport("http://localhost")).Subscribe("prices", func(json.RawMessage) {}); err != ErrSubscribeUnsupported {
+		t.Errorf("❌ subscribed over HTTP: %v", err)
+	}
+}
+
+func Test_server_subscribe_notOverConnection(t *testing.T) {
+	s := NewServer()
+	s.Topic("prices")
+	c := NewClient(&serverTransport{server: s})
+	var rpcErr *Error
+	if err := c.Call(MethodSubscribe, &TopicParams{Topic: "prices"}, nil); !errors.As(err, &rpcErr) || rpcErr.Code != ErrInvalidRequest().Code {
+		t.Errorf("❌ subscribed without a connection: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package jsonrpc2
+
+// 这个文件实现多租户 (multi-tenant) 分区：一个共享的 RPC 端点为多个相互隔离的客户服务。
+//
+// 请求的 tenant key 由传输层 (或中间件) 放进 ctx (见 WithTenant)，
+// Server 据此按 tenant 划分 at-most-once 去重、并发限制 (WithTenantMaxConcurrency)
+// 与 metrics 的名字。
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx serving requests for tenant.
+// This is for ServerTransport implementations and middlewares, which take
+// the tenant key from request metadata, e.g. HttpServerTransport.TenantHeader.
+//
+// A Server serving the request then scopes by tenant:
+//   - at-most-once dedupe: the same id of different tenants are different requests;
+//   - concurrency limits, see WithTenantMaxConcurrency;
+//   - metrics: counters and timings are recorded both as they are and
+//     labeled as "tenant.<tenant>.<name>"; gauges of the tenant only labeled.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant attached by WithTenant.
+// An empty tenant is no tenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant, tenant != ""
+}
+
+// tenantDedupeKey scopes the at-most-once dedupe key of an id to the tenant.
+// The length prefix keeps it unambiguous whatever the tenant contains.
+func tenantDedupeKey(tenant, key string) string {
+	return "t" + strconv.Itoa(len(tenant)) + ":" + tenant + "/" + key
+}
+
+// tenantMetrics labels the measurements of a tenant.
+type tenantMetrics struct {
+	Metrics
+	tenant string
+}
+
+func (m tenantMetrics) label(name string) string {
+	return "tenant." + m.tenant + "." + name
+}
+
+func (m tenantMetrics) Add(name string, delta int64) {
+	m.Metrics.Add(name, delta)
+	m.Metrics.Add(m.label(name), delta)
+}
+
+// Set only the labeled gauge: the tenant's value is not the server's one.
+func (m tenantMetrics) Set(name string, value int64) {
+	m.Metrics.Set(m.label(name), value)
+}
+
+func (m tenantMetrics) Observe(name string, d time.Duration) {
+	m.Metrics.Observe(name, d)
+	m.Metrics.Observe(m.label(name), d)
+}
+
+// tenantLimiters holds a limiter per tenant, made on first use.
+type tenantLimiters struct {
+	mu       sync.Mutex
+	limit    int // <= 0: no limit
+	limiters map[string]*limiter
+}
+
+// get the limiter of tenant, or nil if there is no limit.
+func (t *tenantLimiters) get(tenant string, maxQueue int) *limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.limit <= 0 {
+		return nil
+	}
+	l, ok := t.limiters[tenant]
+	if !ok {
+		if t.limiters == nil {
+			t.limiters = make(map[string]*limiter)
+		}
+		l = newLimiter(t.limit, maxQueue)
+		t.limiters[tenant] = l
+	}
+	return l
+}
+
+// setLimit sets the limit of the tenants, dropping the limiters of the old limit.
+func (t *tenantLimiters) setLimit(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limit = n
+	t.limiters = nil
+}
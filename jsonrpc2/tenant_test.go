@@ -0,0 +1,133 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_tenant_AtMostOnce(t *testing.T) {
+	s := NewServer().WithAtMostOnce()
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+
+	st := NewHttpServerTransport("")
+	st.TenantHeader = "X-Tenant"
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		tenant   string
+		wantCode int // 0 for a result
+	}{
+		{"first of a", "a", 0},
+		{"same id of b", "b", 0},
+		{"same id of no tenant", "", 0},
+		{"dup of a", "a", ErrAtMostOnce().Code},
+		{"dup of no tenant", "", ErrAtMostOnce().Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL,
+				bytes.NewBufferString(`{"jsonrpc": "2.0", "method": "echo", "params": 1, "id": 1}`))
+			if tt.tenant != "" {
+				req.Header.Set("X-Tenant", tt.tenant)
+			}
+			httpResp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer httpResp.Body.Close()
+
+			var resp Response
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.wantCode {
+				t.Errorf("❌ error code = %d, want %d: %+v", code, tt.wantCode, resp.Error)
+			} else {
+				t.Logf("✅ error code = %d", code)
+			}
+		})
+	}
+
+	if got := s.Stats()["tenant.a.requests"]; got != 2 {
+		t.Errorf("❌ tenant.a.requests = %d, want 2", got)
+	}
+}
+
+func Test_server_WithTenantMaxConcurrency(t *testing.T) {
+	s := NewServer().WithTenantMaxConcurrency(1).WithMaxQueue(0)
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	s.MustRegister("block", func(arg int) (int, error) {
+		if arg == 1 {
+			close(started)
+			<-block
+		}
+		return arg, nil
+	})
+
+	call := func(tenant string, arg int64) *Response {
+		ctx := context.Background()
+		if tenant != "" {
+			ctx = WithTenant(ctx, tenant)
+		}
+		params, _ := json.Marshal(arg)
+		return s.ServeRPC(ctx, &Request{JsonRpc: JsonRpc2, Method: "block", Params: params, Id: Int64ID(arg)})
+	}
+
+	done := make(chan *Response)
+	go func() { done <- call("a", 1) }()
+	<-started
+
+	tests := []struct {
+		name     string
+		tenant   string
+		wantCode int
+	}{
+		{"busy tenant is shed", "a", ErrServerBusy().Code},
+		{"other tenant is served", "b", 0},
+		{"no tenant is served", "", 0},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := call(tt.tenant, int64(i+2))
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.wantCode {
+				t.Errorf("❌ error code = %d, want %d: %+v", code, tt.wantCode, resp.Error)
+			} else {
+				t.Logf("✅ error code = %d", code)
+			}
+		})
+	}
+
+	close(block)
+	if resp := <-done; resp.Error != nil {
+		t.Errorf("❌ blocked call: %+v", resp.Error)
+	}
+
+	stats := s.Stats()
+	for name, want := range map[string]int64{
+		"tenant.a.requests.shed":        1,
+		"requests.shed":                 1,
+		"tenant.a.concurrency.inflight": 0,
+		"tenant.b.requests":             1,
+	} {
+		if got := stats[name]; got != want {
+			t.Errorf("❌ %s = %d, want %d", name, got, want)
+		}
+	}
+}
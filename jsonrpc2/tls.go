@@ -0,0 +1,104 @@
+package jsonrpc2
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultCertCheckInterval is how often a CertReloader checks its files by default.
+const DefaultCertCheckInterval = 10 * time.Second
+
+// CertReloader serves a certificate from cert/key files, reloading it
+// when the files change, so certificates rotate without restarting the server.
+//
+// Plug it into the TLSConfig of a server transport:
+//
+//	r, err := jsonrpc2.NewCertReloader("server.crt", "server.key")
+//	st := jsonrpc2.NewHttpServerTransport(":443")
+//	st.TLSConfig = r.TLSConfig()
+//
+// The files are checked (by their modification times) on handshakes, at
+// most once per CheckInterval. If the new files fail to load, e.g. caught
+// halfway through being written, the last good certificate is kept, and
+// loading is retried at the next check.
+type CertReloader struct {
+	CertFile, KeyFile string
+	CheckInterval     time.Duration // 0 means DefaultCertCheckInterval
+	Clock             Clock         // nil means SystemClock
+	Logger            Logger        // logs the failed reloads, nil means a StdLogger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+	checked time.Time
+}
+
+// NewCertReloader loads the certificate from certFile and keyFile.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{CertFile: certFile, KeyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is for tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	interval := r.CheckInterval
+	if interval <= 0 {
+		interval = DefaultCertCheckInterval
+	}
+	if since(clockOrSystem(r.Clock), r.checked) >= interval {
+		if err := r.reloadLocked(); err != nil {
+			loggerOr(r.Logger).Log(LevelWarn, "failed to reload certificate, keeping the last one", Field{"error", err})
+		}
+	}
+
+	if r.cert == nil {
+		return nil, fmt.Errorf("no certificate loaded from %s", r.CertFile)
+	}
+	return r.cert, nil
+}
+
+// TLSConfig returns a tls.Config serving the certificate of r.
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: r.GetCertificate}
+}
+
+func (r *CertReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reloadLocked()
+}
+
+// reloadLocked reloads the certificate if the files changed. r.mu must be held.
+func (r *CertReloader) reloadLocked() error {
+	r.checked = clockOrSystem(r.Clock).Now()
+
+	certInfo, err := os.Stat(r.CertFile)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(r.KeyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && certInfo.ModTime().Equal(r.certMod) && keyInfo.ModTime().Equal(r.keyMod) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.certMod, r.keyMod = certInfo.ModTime(), keyInfo.ModTime()
+	return nil
+}
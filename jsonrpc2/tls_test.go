@@ -0,0 +1,185 @@
+package jsonrpc2
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 with serial
+// into certFile and keyFile, and returns the certificate.
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "jsonrpc2 test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := os.WriteFile(certFile, certPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func Test_CertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+
+	first := writeTestCert(t, certFile, keyFile, 1)
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewFakeClock(time.Now())
+	r.Clock = clock
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	st := &StreamServerTransport{Network: "tcp"}
+	s := NewServer()
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	go st.ServeListener(tls.NewListener(l, r.TLSConfig()), s)
+
+	// servedSerial handshakes with the server, trusting ca, and returns the serial of its certificate.
+	servedSerial := func(ca *x509.Certificate) (int64, error) {
+		pool := x509.NewCertPool()
+		pool.AddCert(ca)
+		conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{RootCAs: pool})
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+	}
+
+	if serial, err := servedSerial(first); err != nil || serial != 1 {
+		t.Fatalf("❌ serial = %d, %v; want 1", serial, err)
+	}
+
+	// rotate
+	second := writeTestCert(t, certFile, keyFile, 2)
+	future := time.Now().Add(time.Minute) // make sure the mtimes change
+	_ = os.Chtimes(certFile, future, future)
+	_ = os.Chtimes(keyFile, future, future)
+
+	if serial, err := servedSerial(first); err != nil || serial != 1 {
+		t.Fatalf("❌ serial before the next check = %d, %v; want 1", serial, err)
+	}
+
+	clock.Advance(DefaultCertCheckInterval)
+	if serial, err := servedSerial(second); err != nil || serial != 2 {
+		t.Fatalf("❌ serial after rotation = %d, %v; want 2", serial, err)
+	}
+	t.Logf("✅ rotated without restarting")
+
+	// a broken rotation keeps the last good certificate
+	if err := os.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := future.Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+
+	clock.Advance(DefaultCertCheckInterval)
+	if serial, err := servedSerial(second); err != nil || serial != 2 {
+		t.Fatalf("❌ serial after broken rotation = %d, %v; want 2", serial, err)
+	}
+	t.Logf("✅ kept the last good certificate")
+}
+
+func Test_HttpServerTransport_ServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	clientCert, clientKey := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	serverCA := writeTestCert(t, serverCert, serverKey, 1)
+	clientCA := writeTestCert(t, clientCert, clientKey, 2)
+
+	// a free port, for ServeTLS to listen on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA)
+	st := NewHttpServerTransport(addr)
+	st.TLSConfig = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	s := NewServer()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+	go st.ServeTLS(s, serverCert, serverKey)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverCA)
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// call over the transport, retrying while the server starts
+	call := func(transport *HttpClientTransport) (got string, err error) {
+		c := NewClient(transport)
+		for i := 0; i < 50; i++ {
+			if err = c.Call("echo", "hi", &got); err == nil || !errors.Is(err, syscall.ECONNREFUSED) {
+				return got, err
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return got, err
+	}
+
+	mutual := NewHttpClientTransport("https://" + addr).
+		WithTLSConfig(&tls.Config{RootCAs: rootCAs, Certificates: []tls.Certificate{cert}})
+	if got, err := call(mutual); err != nil || got != "hi" {
+		t.Fatalf("❌ echo over mutual TLS = %q, %v", got, err)
+	}
+
+	anonymous := NewHttpClientTransport("https://" + addr).WithTLSConfig(&tls.Config{RootCAs: rootCAs})
+	if _, err := call(anonymous); err == nil {
+		t.Errorf("❌ want a client without certificate refused")
+	}
+}
@@ -15,20 +15,117 @@ package jsonrpc2
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type ServerTransport interface {
 	Serve(server Server) error
 }
 
+// TransportInfo tells where a request came from.
+//
+// Every ServerTransport attaches one to the context given to Server.ServeRPC
+// (see WithTransportInfo), so methods and events can tell
+// HTTP, TCP, WebSocket, ... requests apart in the same way.
+type TransportInfo struct {
+	Kind       string               // e.g. "http"
+	LocalAddr  net.Addr             // may be nil if unknown
+	RemoteAddr net.Addr             // may be nil if unknown
+	TLS        *tls.ConnectionState // nil if not over TLS
+	ConnID     uint64               // identifies the connection within the process, 0 if unknown
+}
+
+type transportInfoKey struct{}
+
+// WithTransportInfo returns a copy of ctx carrying info.
+// This is for ServerTransport implementations.
+func WithTransportInfo(ctx context.Context, info *TransportInfo) context.Context {
+	return context.WithValue(ctx, transportInfoKey{}, info)
+}
+
+// TransportInfoFromContext returns the TransportInfo attached by the transport.
+func TransportInfoFromContext(ctx context.Context) (*TransportInfo, bool) {
+	info, ok := ctx.Value(transportInfoKey{}).(*TransportInfo)
+	return info, ok
+}
+
+// lastConnID is the last ConnID given to a connection.
+var lastConnID atomic.Uint64
+
+// nextConnID returns a ConnID unique within the process.
+func nextConnID() uint64 {
+	return lastConnID.Add(1)
+}
+
+// addr is a net.Addr known only by its string, e.g. http.Request.RemoteAddr.
+type addr struct {
+	network, address string
+}
+
+func (a addr) Network() string { return a.network }
+func (a addr) String() string  { return a.address }
+
 // HttpServerTransport serve jsonrpc2 over http.
 // It's both a http.Handler and a ServerTransport.
 type HttpServerTransport struct {
 	ListenAddr string
-	server     Server
+
+	// WriteTimeout is the http.Server.WriteTimeout used by Serve:
+	// a client not reading its response for that long is disconnected.
+	// 0 means no timeout.
+	WriteTimeout time.Duration
+
+	// AllowOrigins lets web pages of these origins call the server from
+	// browsers (CORS), e.g. "https://example.com", or "*" for any page.
+	// Preflight (OPTIONS) requests are answered as well, but pages can
+	// avoid them by POSTing with Content-Type: text/plain, which the
+	// server accepts like application/json.
+	AllowOrigins []string
+
+	// JSONP enables GET requests for legacy pages that load the response
+	// as a <script>, see serveBrowser.
+	JSONP bool
+
+	// TLSConfig makes Serve serve HTTPS, if not nil. It must provide the
+	// certificate, by Certificates or GetCertificate (e.g. a CertReloader).
+	TLSConfig *tls.Config
+
+	// ReusePort works as for StreamServerTransport.
+	ReusePort bool
+
+	// TenantHeader names the header carrying the tenant key of requests,
+	// e.g. "X-Tenant", see WithTenant. Empty (the default) means no tenants.
+	// The header must be set by something trusted, like an auth proxy.
+	TenantHeader string
+
+	// IPFilter turns away clients by their IP address (RemoteAddr, proxies
+	// are not seen through) with 403 Forbidden, before reading their
+	// requests. nil means no filtering.
+	IPFilter *IPFilter
+
+	// AllowGob accepts gob encoded requests (Content-Type GobContentType),
+	// from Go clients by GobHttpClientTransport. They are answered in gob.
+	// gob is not meant for untrusted input: allow it for trusted clients only.
+	AllowGob bool
+
+	server Server
+
+	mu     sync.Mutex
+	hs     *http.Server // serving, see Shutdown
+	closed bool         // by Shutdown
 }
 
 func NewHttpServerTransport(listenAddr string) *HttpServerTransport {
@@ -44,38 +141,176 @@ func (t *HttpServerTransport) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		panic("must call Use to set server before ServeHTTP")
 	}
 
+	if !t.IPFilter.allowedRequest(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if t.AllowGob && isGob(r) {
+		t.serveGob(w, r)
+		return
+	}
+
+	if t.serveBrowser(w, r) {
+		return
+	}
+
+	// the body is not referred to once served (see scratch_on.go)
+	body := getScratch()
+	defer putScratch(body)
+	if _, err := body.ReadFrom(r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t.serveBody(w, r, body.Bytes())
+}
+
+// serveBody serves the request (or batch) body of r.
+func (t *HttpServerTransport) serveBody(w http.ResponseWriter, r *http.Request, body []byte) {
+	if isBatch(body) {
+		t.serveBatch(w, r, body)
+		return
+	}
+
 	var req Request
 
 	// parse rpc request
-	if err := unmarshalRequest(r.Body, &req); err != nil {
-		err := writeJsonResponse(w,
-			errorResponse(nil, ErrParseError().withReason(err.Error())))
-		if err != nil {
-			fmt.Println("Failed to write response: ", err)
+	if err := unmarshalRequest(bytes.NewReader(body), &req); err != nil {
+		// valid JSON that is not a Request object is an Invalid Request
+		rpcErr := ErrParseError()
+		if json.Valid(body) {
+			rpcErr = ErrInvalidRequest()
+		}
+		resp := httpErrorResponse(r, nil, rpcErr.WithReason(err.Error()))
+		if err := writeJsonResponse(t.responseWriter(w, resp), resp); err != nil {
+			loggerOf(t.server).Log(LevelWarn, "failed to write response", Field{"error", err})
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		}
 		return
 	}
 
-	if err := req.validate(); err != nil {
-		err := writeJsonResponse(w,
-			errorResponse(req.Id, ErrInvalidRequest().withReason(err.Error())))
+	if err := validateRequest(t.server, &req, body); err != nil {
+		resp := httpErrorResponse(r, req.Id, ErrInvalidRequest().WithReason(err.Error()))
+		err := writeJsonResponse(t.responseWriter(w, resp), resp)
 		if err != nil {
-			fmt.Println("Failed to write response: ", err)
+			loggerOf(t.server).Log(LevelWarn, "failed to write response", Field{"error", err})
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		}
 		return
 	}
 
-	resp := t.server.ServeRPC(&req)
+	sink := &httpResultSink{w: w}
+	resp := t.server.ServeRPC(withResultSink(t.context(r), sink), &req)
+
+	// a streaming method has written the response itself
+	if sink.started {
+		if sink.broken {
+			panic(http.ErrAbortHandler)
+		}
+		return
+	}
+
+	// a notification is answered with nothing
+	if resp == nil && req.IsNotification() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 
 	// write response
-	if err := writeJsonResponse(w, resp); err != nil {
-		fmt.Println("Failed to write response: ", err)
+	if err := writeJsonResponse(t.responseWriter(w, resp), resp); err != nil {
+		loggerOf(t.server).Log(LevelWarn, "failed to write response", Field{"error", err})
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// serveBatch serves a batch request body.
+func (t *HttpServerTransport) serveBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	batch, err := unmarshalBatch(body)
+	if err != nil {
+		resp := httpErrorResponse(r, nil, ErrParseError().WithReason(err.Error()))
+		if err := writeJsonResponse(t.responseWriter(w, resp), resp); err != nil {
+			loggerOf(t.server).Log(LevelWarn, "failed to write response", Field{"error", err})
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	responses := t.server.ServeBatch(t.context(r), batch)
+
+	// an empty batch is answered with a single error, not an array
+	if len(batch) == 0 && len(responses) == 1 {
+		if err := writeJsonResponse(t.responseWriter(w, responses[0]), responses[0]); err != nil {
+			loggerOf(t.server).Log(LevelWarn, "failed to write response", Field{"error", err})
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// a batch of notifications only is answered with nothing
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := writeJsonBatch(w, responses); err != nil {
+		loggerOf(t.server).Log(LevelWarn, "failed to write response", Field{"error", err})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type httpConnIDKey struct{}
+
+// context returns the context to serve r, carrying its TransportInfo.
+func (t *HttpServerTransport) context(r *http.Request) context.Context {
+	ctx := r.Context()
+
+	info := &TransportInfo{
+		Kind:       "http",
+		RemoteAddr: addr{"tcp", r.RemoteAddr},
+		TLS:        r.TLS,
+	}
+	if local, ok := ctx.Value(http.LocalAddrContextKey).(net.Addr); ok {
+		info.LocalAddr = local
+	}
+	if id, ok := ctx.Value(httpConnIDKey{}).(uint64); ok {
+		info.ConnID = id
+	}
+	if prettyRequested(r) {
+		ctx = withPretty(ctx)
+	}
+	if t.TenantHeader != "" {
+		if tenant := r.Header.Get(t.TenantHeader); tenant != "" {
+			ctx = WithTenant(ctx, tenant)
+		}
+	}
+	return WithTransportInfo(ctx, info)
+}
+
+// httpErrorResponse is errorResponse for r, pretty if r asks for it.
+func httpErrorResponse(r *http.Request, id *ID, err *Error) *Response {
+	resp := errorResponse(id, err)
+	resp.pretty = prettyRequested(r)
+	return resp
+}
+
+// writeJsonBatch helps to respond with a JSON array of responses to the client.
+// It's indented if any of the responses is pretty.
+func writeJsonBatch(w http.ResponseWriter, responses []*Response) error {
+	w.Header().Set("Content-Type", "application/json")
+	pretty := false
+	for _, response := range responses {
+		if response == nil {
+			return errors.New("nil response")
+		}
+		if err := response.validate(); err != nil {
+			return err
+		}
+		pretty = pretty || response.pretty
+	}
+	return encodeJSON(w, responses, pretty)
+}
+
 // writeJsonResponse helps to respond with JSON content to the client.
 func writeJsonResponse(w http.ResponseWriter, response *Response) error {
 	w.Header().Set("Content-Type", "application/json")
@@ -85,6 +320,15 @@ func writeJsonResponse(w http.ResponseWriter, response *Response) error {
 	if err := response.validate(); err != nil {
 		return err
 	}
+	for _, warning := range response.warnings {
+		w.Header().Add("Warning", "299 - "+strconv.Quote(warning))
+	}
+	if response.Meta != nil {
+		writeMetaHeaders(w.Header(), response.Meta)
+		withoutMeta := *response
+		withoutMeta.Meta = nil
+		response = &withoutMeta
+	}
 	return response.marshal(w)
 }
 
@@ -95,41 +339,325 @@ func (t *HttpServerTransport) Use(server Server) {
 
 // Serve = Use + ServeHTTP
 func (t *HttpServerTransport) Serve(server Server) error {
+	return t.serve(server, t.TLSConfig)
+}
+
+// ServeTLS is Serve over HTTPS, with the certificate of certFile and
+// keyFile, reloaded when they change (see CertReloader).
+//
+// The other settings of TLSConfig, if any, are kept: e.g. to authenticate
+// the clients by their certificates (mutual TLS), set its ClientAuth to
+// tls.RequireAndVerifyClientCert and its ClientCAs to their CAs.
+func (t *HttpServerTransport) ServeTLS(server Server, certFile, keyFile string) error {
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{}
+	if t.TLSConfig != nil {
+		config = t.TLSConfig.Clone()
+	}
+	config.Certificates = nil
+	config.GetCertificate = r.GetCertificate
+	return t.serve(server, config)
+}
+
+// serve server on the ListenAddr, over TLS if tlsConfig is not nil.
+func (t *HttpServerTransport) serve(server Server, tlsConfig *tls.Config) error {
 	t.Use(server)
-	return http.ListenAndServe(t.ListenAddr, t)
+	hs := &http.Server{
+		Addr:         t.ListenAddr,
+		Handler:      t,
+		WriteTimeout: t.WriteTimeout,
+		TLSConfig:    tlsConfig,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, httpConnIDKey{}, nextConnID())
+		},
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ErrTransportClosed
+	}
+	t.hs = hs
+	t.mu.Unlock()
+
+	l, err := listen("tcp", httpListenAddr(t.ListenAddr, tlsConfig), t.ReusePort)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		err = hs.ServeTLS(l, "", "")
+	} else {
+		err = hs.Serve(l)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return ErrTransportClosed
+	}
+	return err
+}
+
+// httpListenAddr is the address listened on for addr, defaulting to the
+// port of the scheme, as http.Server.ListenAndServe does.
+func httpListenAddr(addr string, tlsConfig *tls.Config) string {
+	switch {
+	case addr != "":
+		return addr
+	case tlsConfig != nil:
+		return ":https"
+	default:
+		return ":http"
+	}
+}
+
+// Shutdown stops Serve gracefully: it stops listening, waits for the
+// requests in flight to be answered, and closes the connections. If ctx
+// is done first, its error is returned, and the requests still in flight
+// are left to finish in the background.
+// Serve returns ErrTransportClosed once it's called.
+func (t *HttpServerTransport) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.closed = true
+	hs := t.hs
+	t.mu.Unlock()
+
+	if hs == nil {
+		return nil
+	}
+	return hs.Shutdown(ctx)
 }
 
 type ClientTransport interface {
-	SendAndReceive(req *Request) (*Response, error)
+	// SendAndReceive sends req and waits for its response.
+	// It should give up (and return an error) once ctx is done.
+	SendAndReceive(ctx context.Context, req *Request) (*Response, error)
 }
 
+// HttpClientTransport posts the requests to a server over HTTP. It's the
+// transport of the clients built for browsers (GOOS=js GOARCH=wasm), which
+// send them by the fetch API, see httpclient_js.go.
 type HttpClientTransport struct {
 	Addr string
+
+	// TLSConfig configures https:// connections, e.g. to trust a private
+	// CA or to present a client certificate. nil means the defaults.
+	TLSConfig *tls.Config
+
+	// HTTPClient posts the requests, e.g. with a Timeout. nil means a client
+	// of the transport's own, keeping up to DefaultMaxIdleConnsPerHost idle
+	// connections to the server for reuse, where http.DefaultClient keeps 2,
+	// and busy callers would keep opening and closing connections beyond them.
+	// TLSConfig applies to the transport's own client only.
+	HTTPClient *http.Client
+
+	own ownHTTPClient
 }
 
+// DefaultMaxIdleConnsPerHost is how many idle connections to the server
+// an HttpClientTransport keeps for reuse, with its own http.Client.
+const DefaultMaxIdleConnsPerHost = 100
+
 func NewHttpClientTransport(addr string) *HttpClientTransport {
 	return &HttpClientTransport{Addr: addr}
 }
 
-func (t *HttpClientTransport) SendAndReceive(req *Request) (*Response, error) {
+// WithHTTPClient 原址设置发送请求的 http.Client (须在调用之前)，并返回 HttpClientTransport 以供链式
+func (t *HttpClientTransport) WithHTTPClient(c *http.Client) *HttpClientTransport {
+	t.HTTPClient = c
+	return t
+}
+
+// Close closes the idle connections of the transport.
+// The transport may still be used, opening new connections.
+func (t *HttpClientTransport) Close() error {
+	t.httpClient().CloseIdleConnections()
+	return nil
+}
+
+// WithTLSConfig 原址设置 https 连接的 TLSConfig (须在调用之前)，并返回 HttpClientTransport 以供链式
+//
+// e.g. mutual TLS, trusting the CA of the server and presenting a client certificate:
+//
+//	t := jsonrpc2.NewHttpClientTransport("https://rpc.example.com").
+//		WithTLSConfig(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}})
+func (t *HttpClientTransport) WithTLSConfig(c *tls.Config) *HttpClientTransport {
+	t.TLSConfig = c
+	return t
+}
+
+func (t *HttpClientTransport) SendAndReceive(ctx context.Context, req *Request) (*Response, error) {
 	// request -> json
 	reqJson, err := req.toJSON()
 	if err != nil {
 		return nil, err
 	}
 
-	// send request
-	resp, err := http.Post(t.Addr, "application/json", bytes.NewReader(reqJson))
+	body, header, err := t.post(ctx, reqJson)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	// parse response json
 	var rpcResp Response
-	if err := unmarshalResponse(resp.Body, &rpcResp); err != nil {
+	if err := unmarshalResponse(bytes.NewReader(body), &rpcResp); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: %v", ErrTruncatedResponse, err)
+		}
 		return nil, err
 	}
+	if meta := readMetaHeaders(header); meta != nil {
+		rpcResp.Meta = meta
+	}
 
 	return &rpcResp, nil
 }
+
+// Notify sends the notification req. The server responds nothing but
+// for a request it can't take, e.g. invalid, whose error is returned.
+func (t *HttpClientTransport) Notify(ctx context.Context, req *Request) error {
+	reqJson, err := req.toJSON()
+	if err != nil {
+		return err
+	}
+
+	body, _, err := t.post(ctx, reqJson)
+	if err != nil || len(bytes.TrimSpace(body)) == 0 {
+		return err
+	}
+
+	var rpcResp Response
+	if err := json.Unmarshal(body, &rpcResp); err != nil {
+		return err
+	}
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+	return nil
+}
+
+// SendAndReceiveBatch sends reqs as a batch in one HTTP request.
+func (t *HttpClientTransport) SendAndReceiveBatch(ctx context.Context, reqs []*Request) ([]*Response, error) {
+	reqJson, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _, err := t.post(ctx, reqJson)
+	if err != nil {
+		return nil, err
+	}
+
+	// the server answers a batch it can't take (e.g. unparsable) with a single error
+	if !isBatch(body) {
+		var rpcResp Response
+		if err := json.Unmarshal(body, &rpcResp); err != nil {
+			return nil, err
+		}
+		if rpcResp.Error != nil {
+			return nil, rpcResp.Error
+		}
+		return nil, errors.New("single response to a batch")
+	}
+
+	var responses []*Response
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&responses); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: %v", ErrTruncatedResponse, err)
+		}
+		return nil, err
+	}
+	return responses, nil
+}
+
+// post the JSON body to the server, and returns the body and the header of the response.
+func (t *HttpClientTransport) post(ctx context.Context, reqJson []byte) ([]byte, http.Header, error) {
+	// send request
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Addr, bytes.NewReader(reqJson))
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if !browserFetch {
+		httpReq.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	resp, err := t.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := readResponseBody(resp)
+	return body, resp.Header, err
+}
+
+// httpClient is the http.Client posting the requests: the HTTPClient,
+// else the transport's own.
+func (t *HttpClientTransport) httpClient() *http.Client {
+	return t.own.get(t.HTTPClient, t.TLSConfig)
+}
+
+// ownHTTPClient is the own http.Client of an HTTP client transport, made
+// once it's needed.
+type ownHTTPClient struct {
+	once   sync.Once
+	client *http.Client
+}
+
+// get returns custom, if not nil, or the own client, with tlsConfig.
+func (c *ownHTTPClient) get(custom *http.Client, tlsConfig *tls.Config) *http.Client {
+	if custom != nil {
+		return custom
+	}
+	c.once.Do(func() {
+		c.client = &http.Client{Transport: newHTTPTransport(tlsConfig)}
+	})
+	return c.client
+}
+
+// ErrTruncatedResponse tells that the body of a response ended too early,
+// e.g. the connection broke while it was sent.
+var ErrTruncatedResponse = errors.New("jsonrpc2: truncated response body")
+
+// readResponseBody reads the whole body of resp, checking it against its
+// Content-Length and decompressing it by its Content-Encoding.
+//
+// The client asks for gzip by itself (Accept-Encoding), so http.Client leaves
+// the body as it's sent, and its Content-Length can be checked. But in
+// browsers, which decompress it already (see browserFetch).
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(resp.Body)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: got %d of %d bytes", ErrTruncatedResponse, len(body), resp.ContentLength)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if browserFetch {
+		return body, nil
+	}
+	if resp.ContentLength >= 0 && int64(len(body)) != resp.ContentLength {
+		return nil, fmt.Errorf("%w: got %d of %d bytes", ErrTruncatedResponse, len(body), resp.ContentLength)
+	}
+
+	switch encoding := strings.ToLower(resp.Header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("jsonrpc2: bad gzip response body: %w", err)
+		}
+		body, err = io.ReadAll(zr)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: %v", ErrTruncatedResponse, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jsonrpc2: bad gzip response body: %w", err)
+		}
+		return body, nil
+	default:
+		return nil, fmt.Errorf("jsonrpc2: unsupported response Content-Encoding %q", encoding)
+	}
+}
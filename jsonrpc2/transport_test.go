@@ -1,3 +1,208 @@
 package jsonrpc2
 
 // done by server_test.go and client_test.go
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpServerTransport_TransportInfo(t *testing.T) {
+	s := NewServer()
+	events := s.Events()
+
+	infos := make(chan *TransportInfo, 1)
+	err := s.Register("whoami", func(ctx context.Context, arg int) (string, error) {
+		info, ok := TransportInfoFromContext(ctx)
+		if !ok {
+			return "", errors.New("no TransportInfo")
+		}
+		infos <- info
+		return info.RemoteAddr.String(), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	var remote string
+	cli := NewClient(NewHttpClientTransport(ts.URL))
+	if err := cli.Call("whoami", 1, &remote); err != nil {
+		t.Fatal(err)
+	}
+
+	info := <-infos
+	if info.Kind != "http" || info.RemoteAddr == nil || info.LocalAddr == nil || info.TLS != nil {
+		t.Errorf("bad TransportInfo: %+v", info)
+	}
+	if info.LocalAddr.String() != ts.Listener.Addr().String() {
+		t.Errorf("LocalAddr = %v, want %v", info.LocalAddr, ts.Listener.Addr())
+	}
+	if remote != info.RemoteAddr.String() {
+		t.Errorf("method got RemoteAddr %v, want %v", remote, info.RemoteAddr)
+	}
+
+	for e := range events {
+		if e.Kind == EventRequestStarted {
+			if e.Transport != info {
+				t.Errorf("event TransportInfo = %+v, want %+v", e.Transport, info)
+			}
+			break
+		}
+	}
+}
+
+func TestHttpClientTransport_responseBody(t *testing.T) {
+	const body = `{"jsonrpc":"2.0","result":42,"id":1}`
+
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte(s))
+		_ = zw.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		wantTruncated bool
+		wantErr       bool
+	}{
+		{"plain", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}, false, false},
+		{"gzip", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept-Encoding") != "gzip" {
+				t.Errorf("Accept-Encoding = %q, want gzip", r.Header.Get("Accept-Encoding"))
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(gzipped(body))
+		}, false, false},
+		{"short Content-Length", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)+10))
+			_, _ = w.Write([]byte(body))
+		}, true, true},
+		{"truncated gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			z := gzipped(body)
+			_, _ = w.Write(z[:len(z)-10])
+		}, true, true},
+		{"truncated JSON", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body[:20]))
+		}, true, true},
+		{"unknown encoding", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = w.Write([]byte(body))
+		}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(tt.handler)
+			defer ts.Close()
+
+			id := int64(1)
+			resp, err := NewHttpClientTransport(ts.URL).SendAndReceive(context.Background(),
+				&Request{JsonRpc: JsonRpc2, Method: "answer", Params: []byte(`null`), Id: Int64ID(id)})
+			if (err != nil) != tt.wantErr || errors.Is(err, ErrTruncatedResponse) != tt.wantTruncated {
+				t.Fatalf("❌ err = %v, want error: %v, truncated: %v", err, tt.wantErr, tt.wantTruncated)
+			}
+			if err == nil && string(resp.Result) != "42" {
+				t.Fatalf("❌ result = %s, want 42", resp.Result)
+			}
+			t.Logf("✅ resp = %+v, err = %v", resp, err)
+		})
+	}
+}
+
+func TestHttpClientTransport_Close(t *testing.T) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewUnstartedServer(st)
+	closed := make(chan struct{}, 1)
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	c := NewClient(NewHttpClientTransport(ts.URL))
+	var got int
+	if err := c.Call("echo", 1, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("❌ Close: %v", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("❌ the idle connection is not closed")
+	}
+
+	// still usable, by a new connection
+	if err := c.Call("echo", 2, &got); err != nil || got != 2 {
+		t.Errorf("❌ call after Close = %d, %v", got, err)
+	}
+}
+
+// BenchmarkHttpClientTransport calls from many goroutines at once, by the
+// transport's own http.Client, reusing the connections, and by
+// http.DefaultClient, which keeps 2 idle ones and opens new ones for the rest.
+func BenchmarkHttpClientTransport(b *testing.B) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	st := NewHttpServerTransport("")
+	st.Use(s)
+	ts := httptest.NewUnstartedServer(st)
+	var conns atomic.Int64
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	for _, bc := range []struct {
+		name   string
+		client *http.Client
+	}{
+		{"pooled", nil},
+		{"DefaultClient", http.DefaultClient},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c := NewClient(NewHttpClientTransport(ts.URL).WithHTTPClient(bc.client))
+			defer c.Close()
+			conns.Store(0)
+			b.SetParallelism(16)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					var got int
+					if err := c.Call("echo", 1, &got); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package jsonrpc2
+
+// 这个文件实现通过隧道 (SSH、SOCKS5 代理) 连接服务端的客户端传输层，
+// 用于访问位于私有网络中的服务端。消息的分帧与 StreamClientTransport 相同。
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Dialer makes connections, e.g. a *net.Dialer, or a tunnel.
+//
+// An *ssh.Client of golang.org/x/crypto/ssh is a Dialer: it dials from
+// the SSH server, like ssh -L:
+//
+//	sshClient, err := ssh.Dial("tcp", "bastion:22", sshConfig)
+//	t := jsonrpc2.NewTunnelClientTransport(sshClient, "tcp", "10.0.0.5:5680")
+type Dialer interface {
+	Dial(network, addr string) (net.Conn, error)
+}
+
+// contextDialer is a Dialer able to give up dialing when ctx is done.
+type contextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// dialContext dials with d, or directly if d is nil.
+// ctx bounds dialing only if d supports it.
+func dialContext(ctx context.Context, d Dialer, network, addr string) (net.Conn, error) {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	if cd, ok := d.(contextDialer); ok {
+		return cd.DialContext(ctx, network, addr)
+	}
+	return d.Dial(network, addr)
+}
+
+// NewTunnelClientTransport connects to addr through the tunnel d,
+// e.g. an *ssh.Client, or a SOCKS5Dialer.
+func NewTunnelClientTransport(d Dialer, network, addr string) *StreamClientTransport {
+	return &StreamClientTransport{Network: network, Addr: addr, Dialer: d}
+}
+
+// SOCKS5Dialer dials TCP connections through a SOCKS5 proxy (RFC 1928),
+// with optional username/password authentication (RFC 1929).
+//
+// The target address is resolved by the proxy, so names of the private
+// network work.
+type SOCKS5Dialer struct {
+	ProxyAddr string // e.g. "localhost:1080"
+
+	Username, Password string // no authentication if Username is empty
+
+	// Forward dials the proxy, nil means directly.
+	// (e.g. an *ssh.Client: a SOCKS5 proxy behind an SSH tunnel)
+	Forward Dialer
+}
+
+func (d *SOCKS5Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *SOCKS5Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("socks5: network %q not supported", network)
+	}
+
+	conn, err := dialContext(ctx, d.Forward, "tcp", d.ProxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// the handshake respects ctx as well
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if err := d.connect(conn, addr); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+const (
+	socks5Version        = 5
+	socks5AuthNone       = 0
+	socks5AuthPassword   = 2
+	socks5AuthNoAccept   = 0xff
+	socks5CmdConnect     = 1
+	socks5AddrIPv4       = 1
+	socks5AddrDomain     = 3
+	socks5AddrIPv6       = 4
+	socks5PasswordOK     = 0
+	socks5ReplySucceeded = 0
+)
+
+// connect asks the proxy on conn to connect to addr.
+func (d *SOCKS5Dialer) connect(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("socks5: bad port %q", portStr)
+	}
+
+	// greeting: the auth methods we support
+	method := byte(socks5AuthNone)
+	if d.Username != "" {
+		method = socks5AuthPassword
+	}
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("socks5: bad version %d", reply[0])
+	}
+	if reply[1] == socks5AuthNoAccept || reply[1] != method {
+		return errors.New("socks5: no acceptable authentication method")
+	}
+
+	if method == socks5AuthPassword {
+		if len(d.Username) > 255 || len(d.Password) > 255 {
+			return errors.New("socks5: username or password too long")
+		}
+		req := []byte{1, byte(len(d.Username))}
+		req = append(req, d.Username...)
+		req = append(req, byte(len(d.Password)))
+		req = append(req, d.Password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply[:]); err != nil {
+			return err
+		}
+		if reply[1] != socks5PasswordOK {
+			return errors.New("socks5: authentication failed")
+		}
+	}
+
+	// connect
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socks5AddrIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socks5AddrIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New("socks5: host name too long")
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// reply: VER REP RSV ATYP BND.ADDR BND.PORT
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+	if head[1] != socks5ReplySucceeded {
+		return fmt.Errorf("socks5: connect to %s failed: reply %d", addr, head[1])
+	}
+	var skip int
+	switch head[3] {
+	case socks5AddrIPv4:
+		skip = net.IPv4len
+	case socks5AddrIPv6:
+		skip = net.IPv6len
+	case socks5AddrDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("socks5: bad address type %d", head[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
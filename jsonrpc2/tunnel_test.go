@@ -0,0 +1,160 @@
+package jsonrpc2
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// serveTestSOCKS5 runs a minimal SOCKS5 proxy (CONNECT only) on l.
+// If user is not empty, it requires username/password authentication.
+func serveTestSOCKS5(l net.Listener, user, pass string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+
+			var head [2]byte
+			if _, err := io.ReadFull(conn, head[:]); err != nil {
+				return
+			}
+			methods := make([]byte, head[1])
+			if _, err := io.ReadFull(conn, methods); err != nil {
+				return
+			}
+			want := byte(socks5AuthNone)
+			if user != "" {
+				want = socks5AuthPassword
+			}
+			if methods[0] != want {
+				conn.Write([]byte{socks5Version, socks5AuthNoAccept})
+				return
+			}
+			conn.Write([]byte{socks5Version, want})
+
+			if user != "" {
+				var b [2]byte
+				io.ReadFull(conn, b[:])
+				u := make([]byte, b[1])
+				io.ReadFull(conn, u)
+				io.ReadFull(conn, b[:1])
+				p := make([]byte, b[0])
+				io.ReadFull(conn, p)
+				if string(u) != user || string(p) != pass {
+					conn.Write([]byte{1, 1})
+					return
+				}
+				conn.Write([]byte{1, socks5PasswordOK})
+			}
+
+			var req [4]byte
+			if _, err := io.ReadFull(conn, req[:]); err != nil {
+				return
+			}
+			var host string
+			switch req[3] {
+			case socks5AddrIPv4:
+				ip := make([]byte, 4)
+				io.ReadFull(conn, ip)
+				host = net.IP(ip).String()
+			case socks5AddrDomain:
+				var n [1]byte
+				io.ReadFull(conn, n[:])
+				name := make([]byte, n[0])
+				io.ReadFull(conn, name)
+				host = string(name)
+			}
+			var port [2]byte
+			io.ReadFull(conn, port[:])
+
+			target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))))
+			if err != nil {
+				conn.Write([]byte{socks5Version, 5, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+				return
+			}
+			defer target.Close()
+			conn.Write([]byte{socks5Version, socks5ReplySucceeded, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+
+			go io.Copy(target, conn)
+			io.Copy(conn, target)
+		}()
+	}
+}
+
+// countingDialer stands for a tunnel, e.g. an *ssh.Client.
+type countingDialer struct {
+	dials atomic.Int64
+}
+
+func (d *countingDialer) Dial(network, addr string) (net.Conn, error) {
+	d.dials.Add(1)
+	return net.Dial(network, addr)
+}
+
+func Test_TunnelClientTransport(t *testing.T) {
+	// the server on the "private network"
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s := NewServer()
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	go NewTcpServerTransport("").ServeListener(l, s)
+
+	// the proxies
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	go serveTestSOCKS5(proxy, "", "")
+
+	authProxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer authProxy.Close()
+	go serveTestSOCKS5(authProxy, "alice", "s3cr3t")
+
+	tunnel := &countingDialer{}
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	tests := []struct {
+		name    string
+		dialer  Dialer
+		addr    string
+		wantErr bool
+	}{
+		{"ssh-like", tunnel, l.Addr().String(), false},
+		{"socks5", &SOCKS5Dialer{ProxyAddr: proxy.Addr().String()}, l.Addr().String(), false},
+		{"socks5Domain", &SOCKS5Dialer{ProxyAddr: proxy.Addr().String()}, net.JoinHostPort("localhost", port), false},
+		{"socks5Auth", &SOCKS5Dialer{ProxyAddr: authProxy.Addr().String(), Username: "alice", Password: "s3cr3t"}, l.Addr().String(), false},
+		{"socks5BadAuth", &SOCKS5Dialer{ProxyAddr: authProxy.Addr().String(), Username: "alice", Password: "nope"}, l.Addr().String(), true},
+		{"socks5OverTunnel", &SOCKS5Dialer{ProxyAddr: proxy.Addr().String(), Forward: tunnel}, l.Addr().String(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ct := NewTunnelClientTransport(tt.dialer, "tcp", tt.addr)
+			defer ct.Close()
+
+			var ret int
+			err := NewClient(ct).Call("echo", 42, &ret)
+			if (err != nil) != tt.wantErr || (err == nil && ret != 42) {
+				t.Errorf("❌ echo = %d, %v; wantErr %v", ret, err, tt.wantErr)
+			} else {
+				t.Logf("✅ echo = %d, %v", ret, err)
+			}
+		})
+	}
+
+	if tunnel.dials.Load() != 2 {
+		t.Errorf("❌ tunnel dialed %d times, want 2", tunnel.dials.Load())
+	}
+}
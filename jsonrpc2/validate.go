@@ -0,0 +1,56 @@
+package jsonrpc2
+
+// 这个文件实现参数的校验钩子：参数的类型实现 Validator 的，服务端在解码后调用其 Validate，
+// 失败则以 Invalid Params 响应 (原因即其错误)，不再调用方法，省去每个方法开头的样板校验。
+
+import "reflect"
+
+// Validator is implemented by the params validating themselves, e.g.
+//
+//	func (a *TransferArg) Validate() error {
+//		if a.Amount <= 0 {
+//			return errors.New("amount should be positive")
+//		}
+//		return nil
+//	}
+//
+// The server calls Validate once the params are unmarshaled (each of the
+// positional ones, see Register), by a pointer receiver or not, and fails
+// the call with ErrInvalidParams, the error as its reason, instead of
+// calling the method. nil pointers (the params null) are not validated.
+// RawFunc methods get their params as they are, unvalidated.
+type Validator interface {
+	Validate() error
+}
+
+// validateArg is validateParams for the arg of a Func or a StreamFunc,
+// reflecting only on the pointers to check them for nil.
+func validateArg[T any](arg *T) error {
+	if val, ok := any(arg).(Validator); ok {
+		return val.Validate()
+	}
+	if _, ok := any(*arg).(Validator); !ok {
+		return nil
+	}
+	return validateParams(reflect.ValueOf(arg).Elem())
+}
+
+// validateParams validates the params v unmarshaled, if it's a Validator.
+func validateParams(v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+	} else {
+		if !v.CanAddr() {
+			addressable := reflect.New(v.Type()).Elem()
+			addressable.Set(v)
+			v = addressable
+		}
+		v = v.Addr()
+	}
+	if val, ok := v.Interface().(Validator); ok {
+		return val.Validate()
+	}
+	return nil
+}
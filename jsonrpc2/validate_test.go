@@ -0,0 +1,74 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type transferArg struct {
+	From   string `json:"from"`
+	Amount int    `json:"amount"`
+}
+
+func (a *transferArg) Validate() error {
+	if a.Amount <= 0 {
+		return errors.New("amount should be positive")
+	}
+	return nil
+}
+
+// positiveInt validates by a value receiver.
+type positiveInt int
+
+func (n positiveInt) Validate() error {
+	if n <= 0 {
+		return errors.New("should be positive")
+	}
+	return nil
+}
+
+func Test_server_validateParams(t *testing.T) {
+	s := NewServer()
+	called := 0
+	s.MustRegister("reflect", func(arg *transferArg) (int, error) { called++; return 0, nil })
+	s.MustRegister("byValue", func(arg transferArg) (int, error) { called++; return 0, nil })
+	s.MustRegister("typed", Typed(func(arg *transferArg) (int, error) { called++; return 0, nil }))
+	s.MustRegister("positional", func(from string, n positiveInt) (int, error) { called++; return 0, nil })
+	s.MustRegister("valueReceiver", Typed(func(n *positiveInt) (int, error) { called++; return 0, nil }))
+	s.MustRegister("streaming", Streaming(func(arg transferArg, send Sender[int]) error { called++; return nil }))
+
+	tests := []struct {
+		method string
+		params string
+		reason string // "" if valid
+	}{
+		{"reflect", `{"from": "a", "amount": 1}`, ""},
+		{"reflect", `{"from": "a", "amount": 0}`, "amount should be positive"},
+		{"reflect", `null`, ""},
+		{"byValue", `{"amount": -1}`, "amount should be positive"},
+		{"typed", `{"amount": 1}`, ""},
+		{"typed", `{"amount": 0}`, "amount should be positive"},
+		{"positional", `["a", 1]`, ""},
+		{"positional", `["a", 0]`, "params[1]: should be positive"},
+		{"valueReceiver", `2`, ""},
+		{"valueReceiver", `-2`, "should be positive"},
+		{"valueReceiver", `null`, ""},
+		{"streaming", `{"amount": 0}`, "amount should be positive"},
+	}
+	for _, tt := range tests {
+		called = 0
+		resp := s.ServeRPC(context.Background(), &Request{JsonRpc: JsonRpc2, Method: tt.method, Params: json.RawMessage(tt.params), Id: Int64ID(1)})
+		switch {
+		case tt.reason == "" && (resp.Error != nil || called != 1):
+			t.Errorf("❌ %s(%s): %v, called %d times", tt.method, tt.params, resp.Error, called)
+		case tt.reason != "" && (resp.Error == nil || resp.Error.Code != ErrInvalidParams().Code || called != 0):
+			t.Errorf("❌ %s(%s): %v, called %d times, want invalid params", tt.method, tt.params, resp.Error, called)
+		case tt.reason != "":
+			if reason, _ := resp.Error.Reason(); reason != tt.reason {
+				t.Errorf("❌ %s(%s): reason %q, want %q", tt.method, tt.params, reason, tt.reason)
+			}
+		}
+	}
+}
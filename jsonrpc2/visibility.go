@@ -0,0 +1,43 @@
+package jsonrpc2
+
+import (
+	"context"
+	"strings"
+)
+
+// MethodFilter tells whether the method name is visible to the caller of ctx,
+// e.g. by its tenant (see TenantFromContext).
+//
+// Invisible methods are answered with Method Not Found, exactly like methods
+// that don't exist, and left out of rpc.discover and rpc.describe:
+// unauthorized callers can't even tell they exist.
+// A MethodFilter must be safe for concurrent use.
+type MethodFilter func(ctx context.Context, name string) bool
+
+// RestrictPrefix makes a MethodFilter showing the methods starting with
+// prefix only to the given tenants. Other methods are visible to all.
+//
+// e.g.
+//
+//	s.WithMethodFilter(RestrictPrefix("admin.", "ops"))
+func RestrictPrefix(prefix string, tenants ...string) MethodFilter {
+	allowed := make(map[string]bool, len(tenants))
+	for _, tenant := range tenants {
+		allowed[tenant] = true
+	}
+	return func(ctx context.Context, name string) bool {
+		if !strings.HasPrefix(name, prefix) {
+			return true
+		}
+		tenant, ok := TenantFromContext(ctx)
+		return ok && allowed[tenant]
+	}
+}
+
+// visible reports whether the method name is visible to the caller of ctx.
+func (s *server) visible(ctx context.Context, name string) bool {
+	s.mu.RLock()
+	filter := s.methodFilter
+	s.mu.RUnlock()
+	return filter == nil || filter(ctx, name)
+}
@@ -0,0 +1,63 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func Test_WithMethodFilter(t *testing.T) {
+	s := NewServer().WithMethodFilter(RestrictPrefix("admin.", "ops"))
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+	s.MustRegister("admin.reset", func(arg int) (int, error) { return 0, nil })
+
+	serve := func(tenant, method string, params string) *Response {
+		ctx := context.Background()
+		if tenant != "" {
+			ctx = WithTenant(ctx, tenant)
+		}
+		return s.ServeRPC(ctx, &Request{JsonRpc: JsonRpc2, Method: method, Params: json.RawMessage(params), Id: Int64ID(1)})
+	}
+
+	tests := []struct {
+		name     string
+		tenant   string
+		method   string
+		params   string
+		wantCode int // 0 for a result
+	}{
+		{"public", "", "echo", `1`, 0},
+		{"admin of ops", "ops", "admin.reset", `1`, 0},
+		{"admin of other", "acme", "admin.reset", `1`, ErrMethodNotFound().Code},
+		{"admin of no tenant", "", "admin.reset", `1`, ErrMethodNotFound().Code},
+		{"describe admin of other", "acme", MethodDescribe, `"admin.reset"`, -1},
+		{"describe admin of ops", "ops", MethodDescribe, `"admin.reset"`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(tt.tenant, tt.method, tt.params)
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.wantCode {
+				t.Errorf("❌ error code = %d, want %d: %+v", code, tt.wantCode, resp.Error)
+			} else {
+				t.Logf("✅ error code = %d", code)
+			}
+		})
+	}
+
+	// rpc.discover lists the visible methods only
+	for tenant, want := range map[string]bool{"ops": true, "acme": false} {
+		var doc DiscoverResult
+		_ = json.Unmarshal(serve(tenant, MethodDiscover, `null`).Result, &doc)
+		listed := false
+		for _, m := range doc.Methods {
+			listed = listed || m.Name == "admin.reset"
+		}
+		if listed != want {
+			t.Errorf("❌ admin.reset listed to %s: %v, want %v", tenant, listed, want)
+		}
+	}
+}
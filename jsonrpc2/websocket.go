@@ -0,0 +1,459 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebSocketSubprotocol is the Sec-WebSocket-Protocol spoken by the WebSocket transport.
+const WebSocketSubprotocol = "jsonrpc2"
+
+// WebSocketPolicy decides which WebSocket handshakes a server accepts.
+//
+// It only looks at the HTTP upgrade request, before any upgrading,
+// so browsers on foreign pages or speaking other protocols are turned away early.
+// The zero value accepts same-origin (or Origin-less) handshakes, and negotiates
+// WebSocketSubprotocol if the client offers it.
+type WebSocketPolicy struct {
+	// Subprotocols the server speaks, in order of preference.
+	// Empty means []string{WebSocketSubprotocol}.
+	Subprotocols []string
+
+	// RequireSubprotocol rejects handshakes offering none of Subprotocols.
+	// Otherwise such handshakes are accepted without a subprotocol.
+	RequireSubprotocol bool
+
+	// AllowedOrigins lists the accepted Origin headers, e.g. "https://example.com".
+	// "*" accepts any origin. Empty means same-origin only: the host of Origin
+	// must equal the Host of the request.
+	//
+	// Requests without an Origin header don't come from browsers and are accepted.
+	AllowedOrigins []string
+
+	// CheckOrigin, if set, replaces the AllowedOrigins check.
+	CheckOrigin func(r *http.Request) bool
+}
+
+var (
+	errWebSocketOrigin      = errors.New("websocket: origin not allowed")
+	errWebSocketSubprotocol = errors.New("websocket: no acceptable subprotocol")
+)
+
+// Check validates the handshake request r against the policy, returning the
+// negotiated subprotocol ("" if none) to send back in Sec-WebSocket-Protocol.
+// On error, the handshake should be refused with http.StatusForbidden.
+func (p *WebSocketPolicy) Check(r *http.Request) (subprotocol string, err error) {
+	if !p.checkOrigin(r) {
+		return "", errWebSocketOrigin
+	}
+
+	subprotocol = p.negotiate(r)
+	if subprotocol == "" && p.RequireSubprotocol {
+		return "", errWebSocketSubprotocol
+	}
+	return subprotocol, nil
+}
+
+func (p *WebSocketPolicy) checkOrigin(r *http.Request) bool {
+	if p.CheckOrigin != nil {
+		return p.CheckOrigin(r)
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(p.AllowedOrigins) == 0 { // same-origin
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate picks the first of the server's subprotocols offered by the client.
+func (p *WebSocketPolicy) negotiate(r *http.Request) string {
+	supported := p.Subprotocols
+	if len(supported) == 0 {
+		supported = []string{WebSocketSubprotocol}
+	}
+
+	var offered []string
+	for _, h := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, proto := range strings.Split(h, ",") {
+			if proto = strings.TrimSpace(proto); proto != "" {
+				offered = append(offered, proto)
+			}
+		}
+	}
+
+	for _, s := range supported {
+		for _, o := range offered {
+			if s == o {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// WebSocketServerTransport serves jsonrpc2 over WebSocket connections: a
+// client keeps one connection for all its calls, each message a request
+// (or a batch), answered in a message by id, concurrently.
+//
+// It's a http.Handler, to be mounted at some path of a http.Server, or
+// served on its own by Serve.
+type WebSocketServerTransport struct {
+	ListenAddr string
+
+	// Policy decides which handshakes are accepted, see WebSocketPolicy.
+	Policy WebSocketPolicy
+
+	// MaxMessageSize bounds the size of the messages read: a connection
+	// sending a bigger one is closed. 0 means DefaultWebSocketMaxMessage.
+	MaxMessageSize int64
+
+	// WriteTimeout bounds every write of a message: a client not reading
+	// its responses for that long is disconnected. 0 means no timeout.
+	WriteTimeout time.Duration
+
+	// RequestTimeout, MaxConnConcurrency and MaxConnQueue work as for
+	// StreamServerTransport, for each connection.
+	RequestTimeout     time.Duration
+	MaxConnConcurrency int
+	MaxConnQueue       int
+
+	// IPFilter turns away clients by their IP address, before the handshake.
+	// nil means no filtering.
+	IPFilter *IPFilter
+
+	// Authenticate works as for StreamServerTransport, for each connection.
+	Authenticate Authenticator
+
+	// HandshakeTimeout works as for StreamServerTransport.
+	HandshakeTimeout time.Duration
+
+	// Compression works as for StreamServerTransport, the messages
+	// compressed being binary ones.
+	Compression []string
+
+	// TLSConfig makes Serve serve over TLS (wss://), if not nil.
+	TLSConfig *tls.Config
+
+	// ReusePort works as for StreamServerTransport.
+	ReusePort bool
+
+	server      Server
+	compression compressionStats
+}
+
+func NewWebSocketServerTransport(listenAddr string) *WebSocketServerTransport {
+	return &WebSocketServerTransport{ListenAddr: listenAddr}
+}
+
+// Stats reports the bytes of the messages compressed, as for StreamServerTransport.
+func (t *WebSocketServerTransport) Stats() map[string]int64 {
+	return t.compression.snapshot(make(map[string]int64))
+}
+
+// Use server to serve rpc requests.
+func (t *WebSocketServerTransport) Use(server Server) {
+	t.server = server
+}
+
+// Serve = Use + ServeHTTP, at any path of ListenAddr.
+func (t *WebSocketServerTransport) Serve(server Server) error {
+	t.Use(server)
+	hs := &http.Server{
+		Addr:      t.ListenAddr,
+		Handler:   t,
+		TLSConfig: t.TLSConfig,
+	}
+	l, err := listen("tcp", httpListenAddr(t.ListenAddr, t.TLSConfig), t.ReusePort)
+	if err != nil {
+		return err
+	}
+	if t.TLSConfig != nil {
+		return hs.ServeTLS(l, "", "")
+	}
+	return hs.Serve(l)
+}
+
+// ServeHTTP upgrades the request r to a WebSocket connection, and serves
+// it until it's closed. Must be called after Use to set the server else it will panic.
+func (t *WebSocketServerTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if t.server == nil {
+		panic("must call Use to set server before ServeHTTP")
+	}
+
+	if !t.IPFilter.allowedRequest(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "websocket handshake expected", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	subprotocol, err := t.Policy.Check(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported by the http server", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		loggerOf(t.server).Log(LevelWarn, "failed to hijack websocket connection", Field{"error", err})
+		return
+	}
+	_ = conn.SetDeadline(time.Time{}) // clear the deadlines of the http.Server
+
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n"
+	if subprotocol != "" {
+		handshake += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	if _, err := conn.Write([]byte(handshake + "\r\n")); err != nil {
+		conn.Close()
+		return
+	}
+
+	ws := &wsConn{
+		conn:         conn,
+		r:            rw.Reader,
+		maxMessage:   t.MaxMessageSize,
+		writeTimeout: t.WriteTimeout,
+	}
+	closeWs := func() { _ = ws.Close() }
+	served := connServing{
+		logger:         loggerOf(t.server),
+		requestTimeout: t.RequestTimeout,
+		maxConcurrency: t.MaxConnConcurrency,
+		maxQueue:       t.MaxConnQueue,
+		authenticate:   t.Authenticate,
+		handshake:      t.HandshakeTimeout,
+		compression:    t.Compression,
+		stats:          &t.compression,
+		maxMessage:     t.MaxMessageSize,
+		read:           ws.readMessage,
+		write:          ws.writeMessage,
+		writeBinary:    ws.writeBinaryMessage,
+		drop:           closeWs,
+		close:          closeWs,
+
+		setReadDeadline: conn.SetReadDeadline,
+	}
+	served.serve(t.context(r), t.server)
+}
+
+// context returns the context to serve the connection upgraded from r,
+// carrying its TransportInfo. It's not r.Context(): it outlives r.
+func (t *WebSocketServerTransport) context(r *http.Request) context.Context {
+	info := &TransportInfo{
+		Kind:       "websocket",
+		RemoteAddr: addr{"tcp", r.RemoteAddr},
+		TLS:        r.TLS,
+		ConnID:     nextConnID(),
+	}
+	if local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		info.LocalAddr = local
+	}
+	return WithTransportInfo(context.Background(), info)
+}
+
+// headerHasToken reports whether the comma separated list of the header
+// name of h has token, case-insensitively, e.g. "Connection: keep-alive, Upgrade".
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WebSocketDialer opens the WebSocket connections of a client transport,
+// see NewWebSocketClientTransport. The zero value is ready to use.
+type WebSocketDialer struct {
+	// Subprotocols offered to the server. Empty means []string{WebSocketSubprotocol}.
+	Subprotocols []string
+
+	// Header is added to the handshake requests, e.g. Origin or Authorization.
+	Header http.Header
+
+	// TLSConfig configures wss:// connections. nil means the defaults.
+	TLSConfig *tls.Config
+
+	// Dialer makes the underlying connections. nil means a net.Dialer.
+	Dialer Dialer
+
+	// MaxMessageSize bounds the size of the messages read.
+	// 0 means DefaultWebSocketMaxMessage.
+	MaxMessageSize int64
+}
+
+// NewWebSocketClientTransport connects to the WebSocket server at wsURL, like
+// "ws://localhost:5681/rpc" or "wss://...", dialing by d (nil: the defaults).
+//
+// As for TCP, all the calls share one connection, redialed once broken,
+// and concurrent calls are pipelined.
+func NewWebSocketClientTransport(wsURL string, d *WebSocketDialer) *StreamClientTransport {
+	if d == nil {
+		d = &WebSocketDialer{}
+	}
+	return &StreamClientTransport{
+		Network: "websocket",
+		Addr:    wsURL,
+		dial: func(ctx context.Context) (messageConn, error) {
+			return d.dial(ctx, wsURL)
+		},
+	}
+}
+
+// dial rawURL and handshake.
+func (d *WebSocketDialer) dial(ctx context.Context, rawURL string) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var secure bool
+	switch u.Scheme {
+	case "ws", "http":
+	case "wss", "https":
+		secure = true
+	default:
+		return nil, fmt.Errorf("websocket: bad url scheme %q", u.Scheme)
+	}
+	hostport := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		hostport = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	conn, err := dialContext(ctx, d.Dialer, "tcp", hostport)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if secure {
+		cfg := &tls.Config{}
+		if d.TLSConfig != nil {
+			cfg = d.TLSConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		conn = tls.Client(conn, cfg)
+	}
+
+	ws, err := d.handshake(conn, u)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ws, nil
+}
+
+// handshake upgrades conn to the WebSocket at u.
+func (d *WebSocketDialer) handshake(conn net.Conn, u *url.URL) (*wsConn, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	subprotocols := d.Subprotocols
+	if len(subprotocols) == 0 {
+		subprotocols = []string{WebSocketSubprotocol}
+	}
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Host:       u.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     d.Header.Clone(),
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", strings.Join(subprotocols, ", "))
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("websocket: handshake refused: %s", resp.Status)
+	}
+	if !headerHasToken(resp.Header, "Upgrade", "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		return nil, fmt.Errorf("%w: bad handshake response", errWebSocketProtocol)
+	}
+	if p := resp.Header.Get("Sec-WebSocket-Protocol"); p != "" {
+		offered := false
+		for _, s := range subprotocols {
+			offered = offered || s == p
+		}
+		if !offered {
+			return nil, fmt.Errorf("%w: subprotocol %q not offered", errWebSocketProtocol, p)
+		}
+	}
+
+	return &wsConn{conn: conn, r: r, client: true, maxMessage: d.MaxMessageSize}, nil
+}
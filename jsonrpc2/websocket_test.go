@@ -0,0 +1,205 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWebSocketPolicy_Check(t *testing.T) {
+	handshake := func(origin string, protocols ...string) *http.Request {
+		r := httptest.NewRequest("GET", "http://rpc.example.com/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		for _, p := range protocols {
+			r.Header.Add("Sec-WebSocket-Protocol", p)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		policy  WebSocketPolicy
+		req     *http.Request
+		wantSub string
+		wantErr error
+	}{
+		{"noOrigin", WebSocketPolicy{}, handshake(""), "", nil},
+		{"sameOrigin", WebSocketPolicy{}, handshake("https://rpc.example.com", "jsonrpc2"), "jsonrpc2", nil},
+		{"crossOrigin", WebSocketPolicy{}, handshake("https://evil.example.com"), "", errWebSocketOrigin},
+		{"allowedOrigin",
+			WebSocketPolicy{AllowedOrigins: []string{"https://app.example.com"}},
+			handshake("https://app.example.com"), "", nil},
+		{"notAllowedOrigin",
+			WebSocketPolicy{AllowedOrigins: []string{"https://app.example.com"}},
+			handshake("https://rpc.example.com"), "", errWebSocketOrigin},
+		{"anyOrigin", WebSocketPolicy{AllowedOrigins: []string{"*"}}, handshake("https://evil.example.com"), "", nil},
+		{"checkOrigin",
+			WebSocketPolicy{CheckOrigin: func(r *http.Request) bool { return false }},
+			handshake(""), "", errWebSocketOrigin},
+		{"offeredList", WebSocketPolicy{}, handshake("", "foo, jsonrpc2"), "jsonrpc2", nil},
+		{"preference",
+			WebSocketPolicy{Subprotocols: []string{"jsonrpc2.v2", "jsonrpc2"}},
+			handshake("", "jsonrpc2", "jsonrpc2.v2"), "jsonrpc2.v2", nil},
+		{"noneOffered", WebSocketPolicy{}, handshake("", "foo"), "", nil},
+		{"required", WebSocketPolicy{RequireSubprotocol: true}, handshake("", "foo"), "", errWebSocketSubprotocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := tt.policy.Check(tt.req)
+			if err != tt.wantErr || sub != tt.wantSub {
+				t.Errorf("Check() = (%q, %v), want (%q, %v)", sub, err, tt.wantSub, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newWebSocketTestServer(t *testing.T) (*WebSocketServerTransport, *httptest.Server) {
+	s := NewServer()
+	s.MustRegister("echo", func(arg string) (string, error) { return arg, nil })
+	s.MustRegister("kind", func(ctx context.Context, arg int) (string, error) {
+		info, _ := TransportInfoFromContext(ctx)
+		return info.Kind, nil
+	})
+
+	st := NewWebSocketServerTransport("")
+	st.Use(s)
+	ts := httptest.NewServer(st)
+	t.Cleanup(ts.Close)
+	return st, ts
+}
+
+func Test_WebSocketTransport(t *testing.T) {
+	_, ts := newWebSocketTestServer(t)
+	ct := NewWebSocketClientTransport("ws"+strings.TrimPrefix(ts.URL, "http")+"/rpc", nil)
+	defer ct.Close()
+	c := NewClient(ct)
+
+	var kind string
+	if err := c.Call("kind", 1, &kind); err != nil || kind != "websocket" {
+		t.Fatalf("❌ kind = %q, %v", kind, err)
+	}
+
+	// pipelined over one connection, messages of all the length encodings
+	var wg sync.WaitGroup
+	for _, n := range []int{0, 125, 126, 65535, 65536, 200000} {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				arg := strings.Repeat("x", n)
+				var ret string
+				if err := c.Call("echo", arg, &ret); err != nil || ret != arg {
+					t.Errorf("❌ echo of %d bytes: %d bytes, %v", n, len(ret), err)
+				}
+			}(n)
+		}
+	}
+	wg.Wait()
+
+	if err := c.Notify("echo", "ignored"); err != nil {
+		t.Fatalf("❌ Notify: %v", err)
+	}
+
+	// broken connections are redialed
+	ct.Close()
+	var ret string
+	if err := c.Call("echo", "again", &ret); err != nil || ret != "again" {
+		t.Fatalf("❌ echo after redial = %q, %v", ret, err)
+	}
+	t.Logf("✅ calls over websocket")
+}
+
+func Test_WebSocketServerTransport_handshake(t *testing.T) {
+	st, ts := newWebSocketTestServer(t)
+	st.Policy = WebSocketPolicy{AllowedOrigins: []string{"https://good.example.com"}}
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	tests := []struct {
+		name    string
+		dialer  *WebSocketDialer
+		wantErr bool
+	}{
+		{"allowedOrigin", &WebSocketDialer{Header: http.Header{"Origin": {"https://good.example.com"}}}, false},
+		{"deniedOrigin", &WebSocketDialer{Header: http.Header{"Origin": {"https://evil.example.com"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ct := NewWebSocketClientTransport(wsURL, tt.dialer)
+			defer ct.Close()
+			var ret string
+			err := NewClient(ct).Call("echo", "hi", &ret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("❌ err = %v, wantErr %v", err, tt.wantErr)
+			}
+			t.Logf("✅ err = %v", err)
+		})
+	}
+
+	t.Run("notUpgrade", func(t *testing.T) {
+		resp, err := http.Post(ts.URL, "application/json", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUpgradeRequired {
+			t.Fatalf("❌ status = %d, want %d", resp.StatusCode, http.StatusUpgradeRequired)
+		}
+	})
+}
+
+func Test_wsConn(t *testing.T) {
+	// frame builds a client frame of op with payload, masked by key.
+	frame := func(fin bool, op byte, payload string) []byte {
+		key := [4]byte{1, 2, 3, 4}
+		b := []byte{op, 0x80 | byte(len(payload))}
+		if fin {
+			b[0] |= 0x80
+		}
+		b = append(b, key[:]...)
+		for i := range payload {
+			b = append(b, payload[i]^key[i%4])
+		}
+		return b
+	}
+	join := func(frames ...[]byte) []byte { return bytes.Join(frames, nil) }
+
+	tests := []struct {
+		name    string
+		input   []byte
+		want    string
+		wantErr error
+	}{
+		{"text", frame(true, wsText, "hello"), "hello", nil},
+		{"fragmented", join(frame(false, wsText, "hel"), frame(true, wsContinuation, "lo")), "hello", nil},
+		{"pingWithin", join(frame(false, wsText, "hel"), frame(true, wsPing, "p"), frame(true, wsContinuation, "lo")), "hello", nil},
+		{"close", frame(true, wsClose, "\x03\xe8"), "", io.EOF},
+		{"tooBig", frame(true, wsText, strings.Repeat("x", 100)), "", errWebSocketTooBig},
+		{"unmasked", []byte{0x81, 0x01, 'x'}, "", errWebSocketProtocol},
+		{"orphanContinuation", frame(true, wsContinuation, "x"), "", errWebSocketProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			go func() { _, _ = client.Write(tt.input) }()
+			go func() { _, _ = io.Copy(io.Discard, client) }() // pongs and closes
+
+			c := &wsConn{conn: server, r: bufio.NewReader(server), maxMessage: 64}
+			got, err := c.readMessage()
+			if !errors.Is(err, tt.wantErr) || string(got) != tt.want {
+				t.Fatalf("❌ readMessage() = %q, %v; want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+			t.Logf("✅ readMessage() = %q, %v", got, err)
+		})
+	}
+}
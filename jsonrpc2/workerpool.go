@@ -0,0 +1,196 @@
+package jsonrpc2
+
+// 这个文件实现共享的工作池 (WithWorkerPool)：批量请求的各项与连接上读到的请求交给常驻的
+// worker 执行，而不是每个请求起一个 goroutine，以在数万个并发的小请求下减少调度的开销。
+// 每个 worker 有自己的队列，空闲的 worker 从别的队列里窃取任务；任务只在预留到空闲的 worker
+// 时入队，否则另起 goroutine 执行：不会排在阻塞的方法 (如等锁的 lock.Lock) 之后，也就不会死锁。
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// workerPool runs tasks by long-lived workers, see WithWorkerPool.
+//
+// A task is queued only if a worker free is reserved for it, so that
+// every task queued is taken at once by a worker not running any; else
+// it runs in a goroutine of its own (an overflow).
+type workerPool struct {
+	queues []*workQueue  // of the workers, by index
+	next   atomic.Uint32 // the queue of the next task, round robin
+	wake   chan struct{} // the workers waiting for tasks, a token each
+
+	idle     atomic.Int64 // workers free, not reserved for a task queued
+	reserved atomic.Int64 // tasks reserved a worker, not taken yet
+	overflow atomic.Int64 // tasks no worker was free for
+	closed   atomic.Bool
+}
+
+// workQueue is the queue of a worker: it takes the last task queued,
+// while the others steal the first ones.
+type workQueue struct {
+	mu    sync.Mutex
+	tasks []func()
+}
+
+func newWorkerPool(workers int) *workerPool {
+	p := &workerPool{
+		queues: make([]*workQueue, workers),
+		wake:   make(chan struct{}, workers),
+	}
+	p.idle.Store(int64(workers))
+	for i := range p.queues {
+		p.queues[i] = &workQueue{}
+	}
+	for i := range p.queues {
+		go p.work(i)
+	}
+	return p
+}
+
+// goTask runs task by a worker free, or in a goroutine of its own if none
+// is, or p is nil.
+func (p *workerPool) goTask(task func()) {
+	if p == nil {
+		go task()
+		return
+	}
+	if !p.reserve() {
+		p.overflow.Add(1)
+		go task()
+		return
+	}
+
+	q := p.queues[p.next.Add(1)%uint32(len(p.queues))]
+	q.mu.Lock()
+	q.tasks = append(q.tasks, task)
+	q.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default: // enough workers are being woken up
+	}
+}
+
+// reserve a worker free for a task, false if none is.
+func (p *workerPool) reserve() bool {
+	p.reserved.Add(1) // before checking closed, see work
+	if !p.closed.Load() {
+		for idle := p.idle.Load(); idle > 0; idle = p.idle.Load() {
+			if p.idle.CompareAndSwap(idle, idle-1) {
+				return true
+			}
+		}
+	}
+	p.reserved.Add(-1)
+	return false
+}
+
+// work runs the tasks of the worker i, or stolen from the others, until
+// p is closed and no task is left.
+func (p *workerPool) work(i int) {
+	for {
+		task := p.take(i)
+		if task == nil {
+			if p.closed.Load() {
+				if p.reserved.Load() == 0 {
+					return
+				}
+				runtime.Gosched() // a task reserved before closing, not queued yet
+				continue
+			}
+			<-p.wake
+			continue
+		}
+		p.reserved.Add(-1)
+		task()
+		p.idle.Add(1)
+	}
+}
+
+// take a task of the queue i, else steal one from the others.
+func (p *workerPool) take(i int) func() {
+	q := p.queues[i]
+	q.mu.Lock()
+	if n := len(q.tasks); n > 0 {
+		task := q.tasks[n-1]
+		q.tasks[n-1] = nil
+		q.tasks = q.tasks[:n-1]
+		q.mu.Unlock()
+		return task
+	}
+	q.mu.Unlock()
+
+	for j := 1; j < len(p.queues); j++ {
+		victim := p.queues[(i+j)%len(p.queues)]
+		victim.mu.Lock()
+		if len(victim.tasks) > 0 {
+			task := victim.tasks[0]
+			victim.tasks[0] = nil
+			victim.tasks = victim.tasks[1:]
+			victim.mu.Unlock()
+			return task
+		}
+		victim.mu.Unlock()
+	}
+	return nil
+}
+
+// close stops the workers once the tasks queued are done. The tasks
+// given later run in goroutines of their own.
+func (p *workerPool) close() {
+	if p == nil || !p.closed.CompareAndSwap(false, true) {
+		return
+	}
+	for range p.queues { // wake them all up, to quit
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// stats reports the workers of p, and the overflow.
+func (p *workerPool) stats() map[string]int64 {
+	return map[string]int64{
+		"workers.total":    int64(len(p.queues)),
+		"workers.idle":     p.idle.Load(),
+		"workers.overflow": p.overflow.Load(),
+	}
+}
+
+// WithWorkerPool 原址设置工作池，并返回 Server 以供链式
+func (s *server) WithWorkerPool(workers int) Server {
+	s.pool.close()
+	s.pool = nil
+	if workers > 0 {
+		s.pool = newWorkerPool(workers)
+	}
+	return s
+}
+
+// taskRunner is implemented by the Servers running tasks by a worker pool.
+type taskRunner interface {
+	goTask(task func())
+}
+
+// goTask runs task by the worker pool of s, if any.
+func (s *server) goTask(task func()) {
+	s.pool.goTask(task)
+}
+
+// goTask runs task by the worker pool of the Server proxied, if any.
+func (p *ProxyServer) goTask(task func()) {
+	goTask(p.Server, task)
+}
+
+// goTask runs task by the worker pool of the Server s, if any, else in a
+// goroutine of its own.
+func goTask(s Server, task func()) {
+	if r, ok := s.(taskRunner); ok {
+		r.goTask(task)
+		return
+	}
+	go task()
+}
@@ -0,0 +1,139 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_workerPool(t *testing.T) {
+	p := newWorkerPool(2)
+	defer p.close()
+
+	// waitIdle waits for the workers to be done with their tasks
+	waitIdle := func() {
+		for p.stats()["workers.idle"] != 2 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// the tasks one by one are run by the workers
+	for i := 0; i < 100; i++ {
+		done := make(chan struct{})
+		p.goTask(func() { close(done) })
+		<-done
+		waitIdle()
+	}
+	if got := p.stats(); got["workers.overflow"] != 0 {
+		t.Errorf("❌ tasks one by one overflowed: %v", got)
+	}
+
+	// the workers blocked, another task runs all the same
+	block := make(chan struct{})
+	var blocked sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		blocked.Add(1)
+		p.goTask(func() { blocked.Done(); <-block })
+	}
+	blocked.Wait()
+	done := make(chan struct{})
+	p.goTask(func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("❌ the task waits for the workers blocked")
+	}
+	if got := p.stats(); got["workers.idle"] != 0 || got["workers.overflow"] != 1 {
+		t.Errorf("❌ stats with the workers blocked: %v", got)
+	}
+	close(block)
+
+	// closed, the tasks run all the same
+	p.close()
+	done = make(chan struct{})
+	p.goTask(func() { close(done) })
+	<-done
+}
+
+func Test_server_WithWorkerPool(t *testing.T) {
+	s := NewServer().WithWorkerPool(1).WithBatchParallelism(4)
+	defer s.WithWorkerPool(0)
+
+	// lock waits for unlock, served by the same worker pool over one connection
+	unlocked := make(chan struct{})
+	s.MustRegister("lock", func(arg int) (int, error) { <-unlocked; return arg, nil })
+	s.MustRegister("unlock", func(arg int) (int, error) { close(unlocked); return arg, nil })
+	s.MustRegister("echo", func(arg int) (int, error) { return arg, nil })
+
+	if got := s.Stats()["workers.total"]; got != 1 {
+		t.Errorf("❌ workers.total = %d, want 1", got)
+	}
+
+	batch := make([]json.RawMessage, 10)
+	for i := range batch {
+		batch[i] = json.RawMessage(`{"jsonrpc":"2.0","method":"echo","params":` + strconv.Itoa(i) + `,"id":` + strconv.Itoa(i) + `}`)
+	}
+	responses := s.ServeBatch(context.Background(), batch)
+	for i, resp := range responses {
+		if resp.Error != nil || string(resp.Result) != strconv.Itoa(i) {
+			t.Errorf("❌ response %d: %#v", i, resp)
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+	ct := NewTcpClientTransport(l.Addr().String())
+	defer ct.Close()
+	c := NewClient(ct)
+
+	for s.Stats()["workers.idle"] != 1 { // done with the batch
+		time.Sleep(time.Millisecond)
+	}
+	overflow := s.Stats()["workers.overflow"]
+	locked := make(chan error, 1)
+	go func() { locked <- c.Call("lock", 1, nil) }()
+	time.Sleep(10 * time.Millisecond) // lock first
+	if err := c.Call("unlock", 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("❌ deadlocked")
+	}
+	// a worker a request: lock took the one, unlock overflowed
+	if got := s.Stats()["workers.overflow"] - overflow; got != 1 {
+		t.Errorf("❌ %d requests overflowed, want 1", got)
+	}
+}
+
+func Benchmark_server_ServeBatch_workerPool(b *testing.B) {
+	batch := make([]json.RawMessage, 64)
+	for i := range batch {
+		batch[i] = json.RawMessage(`{"jsonrpc":"2.0","method":"echo","params":` + strconv.Itoa(i) + `,"id":` + strconv.Itoa(i) + `}`)
+	}
+	for _, workers := range []int{0, 8} {
+		b.Run("workers="+strconv.Itoa(workers), func(b *testing.B) {
+			s := NewServer().WithWorkerPool(workers).WithBatchParallelism(8)
+			defer s.WithWorkerPool(0)
+			s.MustRegister("echo", Typed(func(arg int) (int, error) { return arg, nil }))
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s.ServeBatch(context.Background(), batch)
+				}
+			})
+		})
+	}
+}
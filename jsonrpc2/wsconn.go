@@ -0,0 +1,233 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// 这个文件实现 WebSocket (RFC 6455) 的分帧，够 JSON-RPC 用即可：
+// 每个 JSON-RPC 消息是一个 text message，不支持扩展 (如 permessage-deflate)。
+
+// wsGUID is the magic of RFC 6455 making Sec-WebSocket-Accept.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsAccept is the Sec-WebSocket-Accept answering the Sec-WebSocket-Key key.
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// opcodes of WebSocket frames
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// DefaultWebSocketMaxMessage bounds the size of the messages read from a
+// WebSocket connection, unless configured otherwise (MaxMessageSize).
+const DefaultWebSocketMaxMessage = 32 << 20
+
+// wsCloseTimeout bounds how long closing a connection waits to send the close frame.
+const wsCloseTimeout = time.Second
+
+var (
+	errWebSocketProtocol = errors.New("websocket: protocol error")
+	errWebSocketTooBig   = errors.New("websocket: message too big")
+)
+
+// wsConn is a messageConn of WebSocket messages, after the handshake.
+type wsConn struct {
+	conn         net.Conn
+	r            *bufio.Reader // of conn, may hold bytes read with the handshake
+	client       bool          // masks the frames written, and expects unmasked frames
+	maxMessage   int64         // 0: DefaultWebSocketMaxMessage
+	writeTimeout time.Duration // of each write, 0: none
+
+	writeMu sync.Mutex
+}
+
+// readMessage reads the next data message, answering the control frames
+// met meanwhile. A close frame ends the connection with io.EOF.
+func (c *wsConn) readMessage() ([]byte, error) {
+	maxMessage := c.maxMessage
+	if maxMessage <= 0 {
+		maxMessage = DefaultWebSocketMaxMessage
+	}
+
+	var msg []byte
+	started := false // a fragmented message is being read
+	for {
+		fin, op, payload, err := c.readFrame(maxMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			if len(payload) > 2 {
+				payload = payload[:2] // echo the status code only
+			}
+			_ = c.writeFrame(wsClose, payload)
+			return nil, io.EOF
+		case wsText, wsBinary:
+			if started {
+				return nil, fmt.Errorf("%w: message within a fragmented one", errWebSocketProtocol)
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				return nil, fmt.Errorf("%w: continuation of nothing", errWebSocketProtocol)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown opcode %#x", errWebSocketProtocol, op)
+		}
+
+		if int64(len(msg))+int64(len(payload)) > maxMessage {
+			return nil, errWebSocketTooBig
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads a frame, unmasking its payload.
+func (c *wsConn) readFrame(maxPayload int64) (fin bool, op byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.r, h[:]); err != nil {
+		return
+	}
+	fin, op = h[0]&0x80 != 0, h[0]&0x0F
+	if h[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: reserved bits set", errWebSocketProtocol)
+	}
+	// clients mask their frames, servers don't
+	if masked := h[1]&0x80 != 0; masked == c.client {
+		return false, 0, nil, fmt.Errorf("%w: bad masking", errWebSocketProtocol)
+	}
+
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.r, b[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.r, b[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if op >= wsClose && (n > 125 || !fin) {
+		return false, 0, nil, fmt.Errorf("%w: bad control frame", errWebSocketProtocol)
+	}
+	if n > uint64(maxPayload) {
+		return false, 0, nil, errWebSocketTooBig
+	}
+
+	var key [4]byte
+	if !c.client {
+		if _, err = io.ReadFull(c.r, key[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	if !c.client {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// writeMessage writes body as a text message, in a single frame.
+func (c *wsConn) writeMessage(body []byte) error {
+	return c.writeFrame(wsText, body)
+}
+
+// writeBinaryMessage writes body as a binary message, in a single frame.
+func (c *wsConn) writeBinaryMessage(body []byte) error {
+	return c.writeFrame(wsBinary, body)
+}
+
+// writeFrame writes a final frame of op, masked if c is a client.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|op)
+
+	var mask byte
+	if c.client {
+		mask = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, mask|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, mask|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, mask|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+
+	if c.client {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		buf = append(buf, key[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		for i := range buf[start:] {
+			buf[start+i] ^= key[i%4]
+		}
+	} else {
+		buf = append(buf, payload...)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.writeTimeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// Close sends a close frame (normal closure), if it can without waiting
+// long, and closes the connection.
+func (c *wsConn) Close() error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+	_ = c.writeFrame(wsClose, []byte{0x03, 0xE8}) // 1000
+	return c.conn.Close()
+}
@@ -3,7 +3,7 @@
 // 协程通过 Lock RPC 调用，获取互斥锁，进入临界区，对共享的 critical 变量进行自增操作。
 // 完成临界操作后，通过 Unlock RPC 调用释放锁，退出临界区。
 //
-// 如果一切正确，那么最终 critical 变量的值应该等于 N。例如 N = 1000 时：
+// 如果一切正确，那么最终 critical 变量的值应该等于 N (乘以 -rounds 的轮数)。例如 N = 1000 时：
 //
 //	✅ critical = 1000, expected = 1000
 //
@@ -11,54 +11,102 @@
 //
 //	❌ critical = 992, expected = 1000
 //
-// 注释掉 tryLock 中的两行 RPC 调用代码（mutex.Call），再次运行程序，即可看到这种错误情况。
+// 注释掉 tryLock 中的 RPC 调用（mutex.Lock 与 mutex.Unlock 两处），再次运行程序，即可看到这种错误情况。
+//
+// 程序最后打印总耗时，可作为简单的基准测试。-transport=tcp 时所有协程共用一条 TCP 连接，
+// 调用以流水线 (pipelining) 的方式发送，不必等待彼此的响应：
+//
+//	go run ./lock/client -transport=tcp
+//
+// TCP 连接上的客户端还为服务端提供回调 (见 jsonrpc2.StreamClientTransport.Callbacks)：
+// 锁被服务端撤销 (lock/server 收到 SIGUSR1) 时，打印服务端发来的 lock.Revoked 通知。
+//
+// HTTP 客户端复用连接 (见 jsonrpc2.DefaultMaxIdleConnsPerHost)。持续的调用 (-rounds) 下，
+// 与每个主机只保留 2 个空闲连接的 http.DefaultClient (-pool=false) 相比，
+// 省去了反复建立与关闭连接的开销：
+//
+//	go run ./lock/client -n 100 -rounds 20
+//	go run ./lock/client -n 100 -rounds 20 -pool=false
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"simpleRpc/jsonrpc2"
 	"simpleRpc/lock"
 	"sync"
+	"time"
 )
 
 // N is the number of concurrent goroutines.
 // You can change it by passing -n=10 to the program.
 var N = flag.Int("n", 1000, "number of goroutines")
+var transport = flag.String("transport", "http", "http or tcp")
+var rounds = flag.Int("rounds", 1, "times every goroutine locks and unlocks")
+var pool = flag.Bool("pool", true, "reuse the http connections, else post by http.DefaultClient")
 var critical = 0
 
-func tryLock(mutex jsonrpc2.Client) {
-	must(mutex.Call(lock.MethodLock, &lock.LockRequest{}, &lock.LockResponse{}))
+func tryLock(mutex lock.Service) {
+	_, err := mutex.Lock(context.Background(), &lock.LockRequest{})
+	must(err)
 
 	// critical section
 	critical += 1
 
-	must(mutex.Call(lock.MethodUnlock, &lock.UnlockRequest{}, &lock.UnlockResponse{}))
+	_, err = mutex.Unlock(context.Background(), &lock.UnlockRequest{})
+	must(err)
 }
 
 func main() {
 	flag.Parse()
 
-	mutexRpcClient := jsonrpc2.NewClient(
-		jsonrpc2.NewHttpClientTransport("http://localhost" + lock.ServerAddr))
+	var t jsonrpc2.ClientTransport
+	switch *transport {
+	case "http":
+		ht := jsonrpc2.NewHttpClientTransport("http://localhost" + lock.ServerAddr)
+		if !*pool {
+			ht.WithHTTPClient(http.DefaultClient)
+		}
+		t = ht
+	case "tcp":
+		tt := jsonrpc2.NewTcpClientTransport("localhost" + lock.TcpAddr)
+		tt.Callbacks = jsonrpc2.NewServer()
+		tt.Callbacks.MustRegister(lock.MethodRevoked, func(notice *lock.RevokedNotice) (*struct{}, error) {
+			fmt.Printf("⚠️ %d permits revoked by the server\n", notice.Permits)
+			return nil, nil
+		})
+		t = tt
+	default:
+		panic("unknown transport: " + *transport)
+	}
+	c := jsonrpc2.NewClient(t)
+	defer c.Close()
+	mutexRpcClient := jsonrpc2.NewDescClient(c, lock.ServiceDesc)
+
+	start := time.Now()
 
 	wg := sync.WaitGroup{}
 
 	for i := 0; i < *N; i++ {
+		wg.Add(1)
 		go func() {
-			wg.Add(1)
 			defer wg.Done()
-			tryLock(mutexRpcClient)
+			for r := 0; r < *rounds; r++ {
+				tryLock(mutexRpcClient)
+			}
 		}()
 	}
 
 	wg.Wait()
 
 	correct := "❌"
-	if critical == *N {
+	expected := *N * *rounds
+	if critical == expected {
 		correct = "✅"
 	}
-	fmt.Printf("%s critical = %d, expected = %d", correct, critical, *N)
+	fmt.Printf("%s critical = %d, expected = %d (%s, %v)", correct, critical, expected, *transport, time.Since(start))
 }
 
 func must(err error) {
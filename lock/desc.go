@@ -0,0 +1,58 @@
+package lock
+
+import (
+	"context"
+
+	"simpleRpc/jsonrpc2"
+)
+
+// ServiceDesc describes the lock service for both its server
+// (jsonrpc2.RegisterDesc) and its clients (jsonrpc2.NewDescClient),
+// so that the method names are written here only.
+var ServiceDesc = &jsonrpc2.ServiceDesc[Service]{
+	Name:      "lock",
+	Methods:   []jsonrpc2.Method[Service]{lockMethod, unlockMethod},
+	NewClient: func(c *jsonrpc2.DescClient[Service]) Service { return descClient{c} },
+}
+
+var (
+	lockMethod = &jsonrpc2.MethodDesc[Service, *LockRequest, *LockResponse]{Name: "Lock", Func: Service.Lock,
+		Options: []jsonrpc2.MethodOption{jsonrpc2.WithDoc("Lock takes a permit of the lock, waiting for one to be released.")}}
+	unlockMethod = &jsonrpc2.MethodDesc[Service, *UnlockRequest, *UnlockResponse]{Name: "Unlock", Func: Service.Unlock,
+		Options: []jsonrpc2.MethodOption{
+			jsonrpc2.WithDoc("Unlock releases a permit of the lock taken by Lock."),
+			jsonrpc2.Unlimited(), // not to wait for a slot held by the Lock calls waiting for it
+		}}
+)
+
+// The names of Lock and Unlock before they were named after ServiceDesc,
+// still served for the old clients.
+const (
+	legacyMethodLock   = "lock"
+	legacyMethodUnlock = "unlock"
+)
+
+// registerService registers ls by ServiceDesc into s, with its methods of
+// the legacy names as deprecated aliases.
+func registerService(s jsonrpc2.Server, ls *LockServer) error {
+	if err := jsonrpc2.RegisterDesc[Service](s, ServiceDesc, ls); err != nil {
+		return err
+	}
+	if err := s.Register(legacyMethodLock, ls.Lock, jsonrpc2.Deprecated(MethodLock)); err != nil {
+		return err
+	}
+	return s.Register(legacyMethodUnlock, ls.Unlock, jsonrpc2.Deprecated(MethodUnlock), jsonrpc2.Unlimited())
+}
+
+// descClient is the Service of ServiceDesc.NewClient.
+type descClient struct {
+	c *jsonrpc2.DescClient[Service]
+}
+
+func (s descClient) Lock(ctx context.Context, req *LockRequest) (*LockResponse, error) {
+	return lockMethod.Call(ctx, s.c, req)
+}
+
+func (s descClient) Unlock(ctx context.Context, req *UnlockRequest) (*UnlockResponse, error) {
+	return unlockMethod.Call(ctx, s.c, req)
+}
@@ -0,0 +1,171 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"simpleRpc/jsonrpc2"
+)
+
+// LockServer implements the Service: Lock takes one of its permits,
+// waiting for one to be released by Unlock. With 1 permit, it's a mutex.
+type LockServer struct {
+	permits chan struct{}
+
+	stateFile string // persists the permits held, "" means not
+	saveMu    sync.Mutex
+
+	mu      sync.Mutex
+	holders map[*jsonrpc2.Peer]int // permits held by the clients over connections, see Revoke
+}
+
+var _ Service = (*LockServer)(nil)
+
+// NewLockServer makes a LockServer of n permits, none held.
+func NewLockServer(n int) *LockServer {
+	return &LockServer{permits: make(chan struct{}, n), holders: make(map[*jsonrpc2.Peer]int)}
+}
+
+// lockState is the content of the state file of a LockServer.
+type lockState struct {
+	Held int `json:"held"`
+}
+
+// LoadLockServer makes a LockServer of n permits, persisting the permits
+// held into stateFile, so that locks held survive restarts: the permits
+// held when it was last saved are taken back, if the file exists.
+func LoadLockServer(n int, stateFile string) (*LockServer, error) {
+	s := NewLockServer(n)
+	if err := s.restore(stateFile); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// restore the permits held saved in stateFile, if it exists, and persist
+// them into it from now on. s must hold none yet.
+func (s *LockServer) restore(stateFile string) error {
+	data, err := os.ReadFile(stateFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		var state lockState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("bad lock state %s: %w", stateFile, err)
+		}
+		if state.Held < 0 || state.Held > cap(s.permits) {
+			return fmt.Errorf("bad lock state %s: %d permits held of %d", stateFile, state.Held, cap(s.permits))
+		}
+		for i := 0; i < state.Held; i++ {
+			s.permits <- struct{}{}
+		}
+	}
+
+	s.saveMu.Lock()
+	s.stateFile = stateFile
+	s.saveMu.Unlock()
+	return nil
+}
+
+func (s *LockServer) Lock(ctx context.Context, req *LockRequest) (*LockResponse, error) {
+	select {
+	case s.permits <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if peer, ok := jsonrpc2.PeerFromContext(ctx); ok {
+		s.mu.Lock()
+		s.holders[peer]++
+		s.mu.Unlock()
+	}
+	s.save()
+	return &LockResponse{}, nil
+}
+
+func (s *LockServer) Unlock(ctx context.Context, req *UnlockRequest) (*UnlockResponse, error) {
+	if peer, ok := jsonrpc2.PeerFromContext(ctx); ok {
+		s.mu.Lock()
+		if s.holders[peer] > 1 {
+			s.holders[peer]--
+		} else {
+			delete(s.holders, peer)
+		}
+		s.mu.Unlock()
+	}
+	select {
+	case <-s.permits:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	s.save()
+	return &UnlockResponse{}, nil
+}
+
+// revokeTimeout bounds the sending of a MethodRevoked notification.
+const revokeTimeout = 5 * time.Second
+
+// Revoke releases the permits held by the clients over connections (e.g.
+// TCP), including those gone without unlocking, and notifies the clients
+// still there by MethodRevoked. The permits held over HTTP are not: their
+// clients could not be told. It returns how many permits are released.
+func (s *LockServer) Revoke() int {
+	s.mu.Lock()
+	holders := s.holders
+	s.holders = make(map[*jsonrpc2.Peer]int)
+	s.mu.Unlock()
+
+	released := 0
+	for peer, n := range holders {
+		for i := 0; i < n; i++ {
+			select {
+			case <-s.permits:
+				released++
+			default: // released by an Unlock of another client
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), revokeTimeout)
+		_ = peer.Notify(ctx, MethodRevoked, &RevokedNotice{Permits: n}) // the gone ones fail
+		cancel()
+	}
+	if released > 0 {
+		s.save()
+	}
+	return released
+}
+
+// save the permits held into the state file, if any. The file is replaced
+// at once (by a rename), so it's never seen half written.
+func (s *LockServer) save() {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if s.stateFile == "" {
+		return
+	}
+
+	// counted after taking saveMu: the last save sees the last change
+	data, _ := json.Marshal(lockState{Held: len(s.permits)})
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.stateFile), filepath.Base(s.stateFile)+".*")
+	if err == nil {
+		_, err = tmp.Write(data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), s.stateFile)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		fmt.Println("Failed to save lock state: ", err)
+	}
+}
@@ -0,0 +1,94 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"simpleRpc/jsonrpc2/config"
+)
+
+// ServerOptions configures RunServer. The zero ServerOptions serves a
+// mutex over HTTP only, not persisted.
+type ServerOptions struct {
+	TcpAddr string // also serve over TCP on it, if not ""
+
+	Permits   int    // how many may hold the lock at once, 0 means 1: a mutex
+	StateFile string // persists the permits held across restarts, "" means not
+
+	// Server configures the jsonrpc2 server, e.g. its logging.
+	Server config.Server
+
+	// ReusePort listens with SO_REUSEPORT, so that an upgraded server may
+	// start listening before this one stops. See also jsonrpc2.Handoff.
+	ReusePort bool
+
+	// Revoke revokes the permits held over TCP (see LockServer.Revoke)
+	// every time it receives, e.g. on a signal. nil means never.
+	Revoke <-chan struct{}
+
+	// ShutdownTimeout bounds how long the calls in flight are waited for
+	// once ctx is done. 0 means config.DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+}
+
+// RunServer serves the lock service over HTTP on addr (and TCP on
+// opts.TcpAddr) until ctx is done, then shuts down gracefully: the calls
+// in flight are answered (or cancelled at the ShutdownTimeout), and it
+// returns nil. It returns early on errors, e.g. addr taken.
+//
+// It's not ready (see jsonrpc2 Server.WithReadinessGate) until the state
+// file is loaded, so the load balancers don't route calls to it meanwhile.
+func RunServer(ctx context.Context, addr string, opts *ServerOptions) error {
+	if opts == nil {
+		opts = &ServerOptions{}
+	}
+	permits := opts.Permits
+	if permits <= 0 {
+		permits = 1
+	}
+
+	cfg := &config.Config{
+		Server:          opts.Server,
+		Transports:      []config.Transport{{Kind: "http", Addr: addr, ReusePort: opts.ReusePort}},
+		ShutdownTimeout: config.Duration(opts.ShutdownTimeout),
+	}
+	if opts.TcpAddr != "" {
+		cfg.Transports = append(cfg.Transports, config.Transport{Kind: "tcp", Addr: opts.TcpAddr, ReusePort: opts.ReusePort})
+	}
+
+	s := cfg.NewServer().WithReadinessGate()
+	ls := NewLockServer(permits)
+	if err := registerService(s, ls); err != nil { // MethodLock, MethodUnlock, and their old names
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- cfg.ServeContext(ctx, s) }()
+
+	if opts.StateFile != "" {
+		if err := ls.restore(opts.StateFile); err != nil {
+			cancel()
+			<-served
+			return err
+		}
+	}
+	s.SetReady()
+
+	if opts.Revoke != nil {
+		go func() {
+			for {
+				select {
+				case <-opts.Revoke:
+					fmt.Println("Revoked", ls.Revoke(), "permits")
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	return <-served
+}
@@ -0,0 +1,196 @@
+package lock
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"simpleRpc/jsonrpc2"
+)
+
+func TestLoadLockServer(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "lock.json")
+	ctx := context.Background()
+
+	s, err := LoadLockServer(2, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Lock(ctx, &LockRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Unlock(ctx, &UnlockRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// restarted: the permit still held is taken back
+	s, err = LoadLockServer(2, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Lock(ctx, &LockRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Lock(timeout, &LockRequest{}); err != context.DeadlineExceeded {
+		t.Errorf("Lock with all the permits held: want DeadlineExceeded, got %v", err)
+	}
+
+	if _, err := LoadLockServer(1, stateFile); err == nil {
+		t.Error("LoadLockServer of fewer permits than held: want error")
+	}
+}
+
+func TestLockServer_Revoke(t *testing.T) {
+	ls := NewLockServer(2)
+	s := jsonrpc2.NewServer()
+	if err := jsonrpc2.RegisterDesc[Service](s, ServiceDesc, ls); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go (&jsonrpc2.StreamServerTransport{Network: "tcp"}).ServeListener(l, s)
+
+	revoked := make(chan int, 1)
+	ct := jsonrpc2.NewTcpClientTransport(l.Addr().String())
+	ct.Callbacks = jsonrpc2.NewServer()
+	ct.Callbacks.MustRegister(MethodRevoked, func(notice *RevokedNotice) (*struct{}, error) {
+		revoked <- notice.Permits
+		return nil, nil
+	})
+	c := jsonrpc2.NewClient(ct)
+	defer c.Close()
+	mutex := jsonrpc2.NewDescClient(c, ServiceDesc)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := mutex.Lock(ctx, &LockRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := ls.Revoke(); n != 2 {
+		t.Errorf("Revoke released %d permits, want 2", n)
+	}
+	select {
+	case n := <-revoked:
+		if n != 2 {
+			t.Errorf("notified of %d permits revoked, want 2", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the holder is not notified")
+	}
+
+	// the permits are free again, and not revoked twice
+	if _, err := ls.Lock(ctx, &LockRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := ls.Revoke(); n != 0 {
+		t.Errorf("Revoke released %d permits not held over TCP", n)
+	}
+}
+
+func TestRunServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- RunServer(ctx, addr, &ServerOptions{StateFile: filepath.Join(t.TempDir(), "lock.json")})
+	}()
+
+	c := jsonrpc2.NewClient(jsonrpc2.NewHttpClientTransport("http://" + addr))
+	defer c.Close()
+	mutex := jsonrpc2.NewDescClient(c, ServiceDesc)
+
+	var lockErr error
+	for i := 0; i < 50; i++ { // until it's up and ready
+		if _, lockErr = mutex.Lock(ctx, &LockRequest{}); lockErr == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if lockErr != nil {
+		t.Fatal(lockErr)
+	}
+	if _, err := mutex.Unlock(ctx, &UnlockRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunServer: want nil once shut down, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunServer not returning once ctx is done")
+	}
+}
+
+func TestRunServer_MaxConcurrency(t *testing.T) {
+	s := jsonrpc2.NewServer().WithMaxConcurrency(1)
+	if err := jsonrpc2.RegisterDesc[Service](s, ServiceDesc, NewLockServer(1)); err != nil {
+		t.Fatal(err)
+	}
+	mutex := jsonrpc2.NewDescClient(jsonrpc2.NewClient(&serverTransport{s}), ServiceDesc)
+	ctx := context.Background()
+
+	if _, err := mutex.Lock(ctx, &LockRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan error, 1)
+	go func() { // takes the only slot, waiting for the lock
+		_, err := mutex.Lock(ctx, &LockRequest{})
+		locked <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if _, err := mutex.Unlock(timeout, &UnlockRequest{}); err != nil {
+		t.Fatalf("Unlock with the slot taken by a Lock waiting: %v", err)
+	}
+	if err := <-locked; err != nil {
+		t.Errorf("Lock after the Unlock: %v", err)
+	}
+}
+
+func TestRegisterService_legacyNames(t *testing.T) {
+	s := jsonrpc2.NewServer()
+	if err := registerService(s, NewLockServer(1)); err != nil {
+		t.Fatal(err)
+	}
+	c := jsonrpc2.NewClient(&serverTransport{s})
+
+	for _, method := range []string{legacyMethodLock, legacyMethodUnlock, MethodLock, MethodUnlock} {
+		var result struct{}
+		if err := c.CallContext(context.Background(), method, struct{}{}, &result); err != nil {
+			t.Errorf("%s: %v", method, err)
+		}
+	}
+	if got := s.Stats()["deprecated.calls"]; got != 2 {
+		t.Errorf("deprecated.calls = %d, want 2 of the legacy names", got)
+	}
+}
+
+// serverTransport calls the server directly.
+type serverTransport struct {
+	server jsonrpc2.Server
+}
+
+func (t *serverTransport) SendAndReceive(ctx context.Context, req *jsonrpc2.Request) (*jsonrpc2.Response, error) {
+	return t.server.ServeRPC(ctx, req), nil
+}
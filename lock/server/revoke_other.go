@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// notifyRevoke does nothing: there is no SIGUSR1 on this system.
+func notifyRevoke(revoke chan<- struct{}) {}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyRevoke sends to revoke on each SIGUSR1.
+func notifyRevoke(revoke chan<- struct{}) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	go func() {
+		for range usr1 {
+			revoke <- struct{}{}
+		}
+	}()
+}
@@ -1,46 +1,108 @@
-// 这个程序实现了一个 RPC 锁服务 LockServer。
+// 这个程序运行 RPC 锁服务 lock.LockServer。
 // 该服务提供两个远程过程：Lock 和 Unlock，分别用于获取和释放锁。
 //
-// 在 main 函数中，我们创建了一个 delta 值为 1 的 LockServer 实例，然后将其注册到 JSON-RPC 服务端。
-// 初始化参数 delta=1 表示该锁服务最多允许一个客户端获取锁，即这是一个互斥锁服务。
+// 锁服务最多允许 -permits 个客户端同时获取锁，默认为 1，即这是一个互斥锁服务。
+//
+// 服务同时通过 HTTP (lock.ServerAddr) 与 TCP (lock.TcpAddr) 提供，由 lock.RunServer 装配。
+// 用 -state 指定状态文件时，已被持有的锁在重启后仍被持有：
+//
+//	go run ./lock/server -state lock.json
+//
+// 用 -max-concurrency 限制同时执行 (包括等待锁) 的 Lock 调用数，超出的请求排队等待；
+// Unlock 不受此限制 (见 jsonrpc2.Unlimited)，不会被等待锁的 Lock 调用饿死。
+//
+// 收到 SIGUSR1 时 (仅 Unix)，撤销通过 TCP 连接持有的锁 (见 lock.LockServer.Revoke)，如释放已断开的客户端没来得及释放的锁，
+// 仍连接着的持有者会收到服务端回调的 lock.Revoked 通知。
+//
+// 收到 Ctrl-C (SIGINT) 或 SIGTERM 时，服务不再接受新的请求，等待进行中的请求完成后退出。
+//
+// 收到 SIGHUP 时，服务升级为 (可能已被替换的) 可执行文件的新进程：
+// 当前进程像上面一样优雅退出后，把监听套接字交给新进程 (见 jsonrpc2.Handoff)，
+// 其间到达的连接在 backlog 中等待新进程接受，不会被拒绝。新进程从状态文件恢复已被持有的锁。
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
 	"simpleRpc/jsonrpc2"
+	"simpleRpc/jsonrpc2/config"
 	"simpleRpc/lock"
 )
 
-type LockServer struct {
-	mu chan struct{}
-}
+var (
+	permits = flag.Int("permits", 1, "how many may hold the lock at once")
+	state   = flag.String("state", "", "file persisting the locks held across restarts; none by default")
+	verbose = flag.Bool("verbose", true, "log every request and response")
+	reuse   = flag.Bool("reuse-port", false, "listen with SO_REUSEPORT, for an upgraded server to listen alongside")
+	maxConc = flag.Int("max-concurrency", 0, "how many Lock calls may run (or wait for the lock) at once; 0 means no limit")
+)
 
-func NewLockServer(delta int) *LockServer {
-	return &LockServer{
-		mu: make(chan struct{}, delta),
-	}
-}
+func main() {
+	flag.Parse()
 
-func (s *LockServer) Lock(req *lock.LockRequest) (*lock.LockResponse, error) {
-	s.mu <- struct{}{}
-	return &lock.LockResponse{}, nil
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func (s *LockServer) Unlock(req *lock.UnlockRequest) (*lock.UnlockResponse, error) {
-	<-s.mu
-	return &lock.UnlockResponse{}, nil
-}
+	// SIGHUP: hand the listeners off to the upgraded process, once this one is done
+	ctx, upgrade := context.WithCancel(ctx)
+	defer upgrade()
+	handoffs := make(chan *jsonrpc2.Handoff, 1)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			h, err := jsonrpc2.NewHandoff()
+			if err != nil {
+				fmt.Println("Failed to upgrade: ", err)
+				continue
+			}
+			handoffs <- h
+			upgrade()
+			return
+		}
+	}()
 
-func main() {
-	mutex := NewLockServer(1)
+	// SIGUSR1: revoke the locks held over TCP
+	revoke := make(chan struct{})
+	notifyRevoke(revoke)
 
-	s := jsonrpc2.NewServer()
-	jsonrpc2.Verbose = true
+	must(lock.RunServer(ctx, lock.ServerAddr, &lock.ServerOptions{
+		TcpAddr:   lock.TcpAddr,
+		Permits:   *permits,
+		StateFile: *state,
+		ReusePort: *reuse,
+		Revoke:    revoke,
+		Server:    config.Server{Logging: config.Logging{Verbose: *verbose}, MaxConcurrency: *maxConc},
+	}))
 
-	must(s.Register(lock.MethodLock, mutex.Lock))
-	must(s.Register(lock.MethodUnlock, mutex.Unlock))
+	select {
+	case h := <-handoffs:
+		must(startSuccessor(h))
+	default:
+	}
+}
 
-	st := jsonrpc2.NewHttpServerTransport(lock.ServerAddr)
-	must(st.Serve(s))
+// startSuccessor starts the executable of this process anew, with the same
+// arguments, taking over the listeners of h.
+func startSuccessor(h *jsonrpc2.Handoff) error {
+	path, err := os.Executable()
+	if err != nil {
+		h.Close()
+		return err
+	}
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := h.Start(cmd); err != nil {
+		return err
+	}
+	fmt.Println("Upgraded to the process", cmd.Process.Pid)
+	return nil
 }
 
 func must(err error) {
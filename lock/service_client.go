@@ -0,0 +1,34 @@
+// Code generated by naiverpc-gen -type Service -service lock; DO NOT EDIT.
+
+package lock
+
+import (
+	"context"
+	"simpleRpc/jsonrpc2"
+)
+
+// ServiceClient is a Service calling the methods of a JSON-RPC server.
+type ServiceClient struct {
+	c jsonrpc2.Client
+}
+
+var _ Service = (*ServiceClient)(nil)
+
+// NewServiceClient returns a Service calling the server of c.
+func NewServiceClient(c jsonrpc2.Client) *ServiceClient {
+	return &ServiceClient{c: c}
+}
+
+// Lock calls "lock.Lock".
+func (s *ServiceClient) Lock(ctx context.Context, arg *LockRequest) (*LockResponse, error) {
+	var ret *LockResponse
+	err := s.c.CallContext(ctx, "lock.Lock", arg, &ret)
+	return ret, err
+}
+
+// Unlock calls "lock.Unlock".
+func (s *ServiceClient) Unlock(ctx context.Context, arg *UnlockRequest) (*UnlockResponse, error) {
+	var ret *UnlockResponse
+	err := s.c.CallContext(ctx, "lock.Unlock", arg, &ret)
+	return ret, err
+}
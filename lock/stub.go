@@ -1,15 +1,39 @@
 package lock
 
+import "context"
+
 const ServerAddr = ":5680"
 
-const MethodLock = "lock"
+// TcpAddr serves the same service over TCP, where the calls of a client
+// are pipelined over one connection.
+const TcpAddr = ":5679"
+
+// Service is the lock service, as described by ServiceDesc.
+// ServiceClient, generated from it, calls it on a server too, see NewServiceClient.
+type Service interface {
+	Lock(ctx context.Context, req *LockRequest) (*LockResponse, error)
+	Unlock(ctx context.Context, req *UnlockRequest) (*UnlockResponse, error)
+}
+
+//go:generate go run simpleRpc/cmd/naiverpc-gen -type Service -service lock
+
+const MethodLock = "lock.Lock"
 
 type LockRequest struct{}
 
 type LockResponse struct{}
 
-const MethodUnlock = "unlock"
+const MethodUnlock = "lock.Unlock"
 
 type UnlockRequest struct{}
 
 type UnlockResponse struct{}
+
+// MethodRevoked is called back on the clients holding permits over a
+// connection (see jsonrpc2.Peer), as a notification, once their permits
+// are revoked by LockServer.Revoke. They must not Unlock them then.
+const MethodRevoked = "lock.Revoked"
+
+type RevokedNotice struct {
+	Permits int `json:"permits"` // of the client, revoked
+}